@@ -0,0 +1,137 @@
+// gencert 生成本地开发使用的 CA、服务端证书和客户端证书，用于 danmu-http 与 danmu-core 之间的 mTLS
+//
+//	go run ./cmd/gencert -out certs -hosts localhost,127.0.0.1
+//
+// 生成的文件:
+//
+//	ca.pem / ca-key.pem          自签名 CA
+//	server.pem / server-key.pem  danmu-core 使用
+//	client.pem / client-key.pem  danmu-http 使用
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func main() {
+	out := flag.String("out", "certs", "output directory")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "comma separated hostnames and IPs for the server certificate")
+	days := flag.Int("days", 365, "certificate validity in days")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0700); err != nil {
+		log.Fatalf("create output directory failed: %v", err)
+	}
+	validity := time.Duration(*days) * 24 * time.Hour
+
+	caKey, caCert, err := newCA(validity)
+	if err != nil {
+		log.Fatalf("generate ca failed: %v", err)
+	}
+	writePair(*out, "ca", caCert.Raw, caKey)
+
+	serverTemplate := leafTemplate("danmu-core", validity, x509.ExtKeyUsageServerAuth)
+	for _, h := range strings.Split(*hosts, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if ip := net.ParseIP(h); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, h)
+		}
+	}
+	serverDER, serverKey, err := signLeaf(serverTemplate, caCert, caKey)
+	if err != nil {
+		log.Fatalf("generate server cert failed: %v", err)
+	}
+	writePair(*out, "server", serverDER, serverKey)
+
+	clientDER, clientKey, err := signLeaf(leafTemplate("danmu-http", validity, x509.ExtKeyUsageClientAuth), caCert, caKey)
+	if err != nil {
+		log.Fatalf("generate client cert failed: %v", err)
+	}
+	writePair(*out, "client", clientDER, clientKey)
+
+	log.Printf("certificates written to %s", *out)
+}
+
+func newCA(validity time.Duration) (*ecdsa.PrivateKey, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: "danmu dev CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return key, cert, err
+}
+
+func leafTemplate(cn string, validity time.Duration, usage x509.ExtKeyUsage) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+}
+
+func signLeaf(template, ca *x509.Certificate, caKey *ecdsa.PrivateKey) ([]byte, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	return der, key, err
+}
+
+func serialNumber() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		log.Fatalf("generate serial number failed: %v", err)
+	}
+	return n
+}
+
+func writePair(dir, name string, der []byte, key *ecdsa.PrivateKey) {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		log.Fatalf("marshal %s key failed: %v", name, err)
+	}
+	writePEM(filepath.Join(dir, name+".pem"), "CERTIFICATE", der, 0644)
+	writePEM(filepath.Join(dir, name+"-key.pem"), "EC PRIVATE KEY", keyDER, 0600)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, perm); err != nil {
+		log.Fatalf("write %s failed: %v", path, err)
+	}
+}
@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
 	"danmu-core/core"
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/core/platform/douyin/session"
+	"danmu-core/internal/analytics"
+	"danmu-core/internal/deadletter"
+	"danmu-core/internal/dedup"
 	"danmu-core/internal/model"
+	"danmu-core/internal/proxy"
+	"danmu-core/internal/publish"
+	"danmu-core/internal/realtime"
 	"danmu-core/internal/server"
 	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"danmu-core/tracing"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func init() {
@@ -15,9 +28,68 @@ func init() {
 }
 
 func main() {
+	setting.Setup()
+	logger.Init()
+	model.Init()
+	if err := tracing.Init(); err != nil {
+		logger.Error().Err(err).Msg("tracing init fail")
+	}
+
+	var metricsServer *metrics.Server
+	if setting.MetricsSetting.Enable {
+		metricsServer = metrics.NewServer()
+		go func() {
+			if err := metricsServer.Start(); err != nil {
+				logger.Error().Err(err).Msg("metrics-server start fail")
+			}
+		}()
+	}
+
+	if setting.AnalyticsSetting.Enable {
+		analytics.Init()
+	}
+	if setting.RedisSetting.Enable {
+		realtime.Init()
+	}
+	if setting.PublishSetting.Enable {
+		if err := publish.Init(); err != nil {
+			logger.Fatal().Err(err).Msg("publish init failed")
+		}
+	}
+	if err := dedup.Init(); err != nil {
+		logger.Fatal().Err(err).Msg("dedup init failed")
+	}
+	if err := deadletter.Init(); err != nil {
+		logger.Fatal().Err(err).Msg("dead letter init failed")
+	}
+	if setting.ProxySetting.Enable {
+		if err := proxy.Init(); err != nil {
+			logger.Fatal().Err(err).Msg("proxy init failed")
+		}
+	}
+	if err := session.Init(); err != nil {
+		logger.Fatal().Err(err).Msg("douyin session init failed")
+	}
+	if err := platform.InitCursors(); err != nil {
+		logger.Fatal().Err(err).Msg("douyin cursors init failed")
+	}
+	if err := core.EnsurePartitions(); err != nil {
+		logger.Error().Err(err).Msg("create message partitions fail")
+	}
 	core.InitTaskManager()
-	rpcserver := server.NewRPCServer()
-	err := rpcserver.Start()
+	reconcileCtx, stopReconcile := context.WithCancel(context.Background())
+	if setting.ReconcileSetting.Enable {
+		go core.StartReconciler(reconcileCtx, time.Duration(setting.ReconcileSetting.Interval)*time.Second)
+	}
+	// 分区创建始终执行，RetentionSetting.Enable 只控制归档
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	go core.StartRetention(retentionCtx, time.Duration(setting.RetentionSetting.Interval)*time.Second)
+	rpcserver, err := server.NewRPCServer()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("rpc-server init fail")
+		return
+	}
+	err = rpcserver.Start()
 	if err != nil {
 		logger.Fatal().Err(err).Msg("rpc-server start fail")
 		return
@@ -29,7 +101,21 @@ func main() {
 	<-quit
 	logger.Info().Msg("Shutting down server...")
 
+	stopReconcile()
+	stopRetention()
 	rpcserver.Stop()
+	dedup.Close()
+	deadletter.Close()
+	session.Close()
+	platform.CloseCursors()
+	proxy.Close()
+	analytics.Close()
+	publish.Close()
+	realtime.Close()
+	if metricsServer != nil {
+		metricsServer.Stop()
+	}
+	tracing.Shutdown(context.Background())
 	model.Close()
 
 	logger.Info().Msg("Server exited")
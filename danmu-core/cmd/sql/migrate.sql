@@ -1,9 +1,9 @@
 SET search_path TO live;
 
+-- common_messages 和 gift_messages 按 timestamp (毫秒) 每月一个分区，分区由 danmu-core 启动时及定期创建
 create table common_messages
 (
-    id               bigint not null
-        primary key,
+    id               bigint not null,
     message_type     text   not null,
     room_id          bigint not null,
     room_display_id  text   not null,
@@ -13,8 +13,10 @@ create table common_messages
     user_display_id  text   not null,
     content          text   not null,
     timestamp        bigint not null,
-    favorite_user_id bigint default 0
-);
+    favorite_user_id bigint default 0,
+    streamer_id      bigint default 0,
+    primary key (id, timestamp)
+) partition by range (timestamp);
 
 alter table common_messages
     owner to postgres;
@@ -22,8 +24,7 @@ alter table common_messages
 
 create table gift_messages
 (
-    id                 bigint not null
-        primary key,
+    id                 bigint not null,
     user_id            bigint not null,
     user_name          text   not null,
     user_display_id    text   not null,
@@ -40,22 +41,172 @@ create table gift_messages
     diamond_count      bigint not null,
     image_url          text,
     repeat_end         integer,
-    combo_count        text
-);
+    combo_count        text,
+    streamer_id        bigint default 0,
+    primary key (id, timestamp)
+) partition by range (timestamp);
 
 alter table gift_messages
     owner to postgres;
 
 create table users
+(
+    id              bigserial
+        primary key,
+    user_id         bigint not null
+        constraint unique_user_id
+            unique,
+    display_id      text   not null,
+    user_name       text   not null,
+    sec_uid         text,
+    avatar          text,
+    gender          integer,
+    pay_grade       bigint,
+    follower_count  bigint,
+    following_count bigint,
+    first_seen      bigint,
+    last_seen       bigint
+);
+
+alter table users
+    owner to postgres;
+
+create table user_name_history
 (
     id         bigserial
         primary key,
     user_id    bigint not null,
+    user_name  text   not null,
     display_id text   not null,
-    user_name  text   not null
+    timestamp  bigint not null
 );
 
-alter table users
+alter table user_name_history
+    owner to postgres;
+
+create table user_room_stats
+(
+    user_id         bigint not null,
+    room_display_id text   not null,
+    room_name       text,
+    streamer_id     bigint default 0,
+    chat_count      bigint not null default 0,
+    gift_count      bigint not null default 0,
+    diamond_total   bigint not null default 0,
+    first_seen      bigint not null,
+    last_seen       bigint not null,
+    primary key (user_id, room_display_id)
+);
+
+alter table user_room_stats
+    owner to postgres;
+
+create table user_gift_stats
+(
+    user_id         bigint not null,
+    room_display_id text   not null,
+    to_user_id      bigint not null,
+    gift_id         bigint not null,
+    to_user_name    text,
+    gift_name       text,
+    image_url       text,
+    gift_count      bigint not null default 0,
+    diamond_total   bigint not null default 0,
+    last_sent       bigint not null,
+    primary key (user_id, room_display_id, to_user_id, gift_id)
+);
+
+alter table user_gift_stats
+    owner to postgres;
+
+create table user_daily_activity
+(
+    user_id         bigint not null,
+    room_display_id text   not null,
+    day             bigint not null,
+    chat_count      bigint not null default 0,
+    gift_count      bigint not null default 0,
+    diamond_total   bigint not null default 0,
+    first_seen      bigint not null,
+    last_seen       bigint not null,
+    primary key (user_id, room_display_id, day)
+);
+
+alter table user_daily_activity
+    owner to postgres;
+
+create table user_fans_clubs
+(
+    user_id         bigint not null,
+    anchor_id       bigint not null,
+    club_name       text   not null,
+    level           integer,
+    room_display_id text,
+    streamer_id     bigint default 0,
+    first_seen      bigint not null,
+    last_seen       bigint not null,
+    primary key (user_id, anchor_id)
+);
+
+alter table user_fans_clubs
+    owner to postgres;
+
+create table retention_policies
+(
+    room_display_id text    not null
+        primary key,
+    retain_days     integer not null,
+    modified_on     bigint,
+    modified_by     text
+);
+
+alter table retention_policies
+    owner to postgres;
+
+create table message_archives
+(
+    id              bigserial
+        primary key,
+    message_table   text   not null,
+    room_display_id text   not null,
+    month           bigint not null,
+    path            text   not null,
+    rows            bigint not null,
+    size            bigint not null,
+    begin           bigint not null,
+    "end"           bigint not null,
+    created_on      bigint
+);
+
+alter table message_archives
+    owner to postgres;
+
+create table overlays
+(
+    id              bigserial
+        primary key,
+    name            text,
+    token           text   not null,
+    room_display_id text   not null,
+    theme           text,
+    custom_css      text,
+    min_diamond     bigint  default 0,
+    alert_duration  integer default 0,
+    alert_queue     integer default 0,
+    chat_limit      integer default 0,
+    show_member     boolean default false,
+    top_count       integer default 0,
+    goal_title      text,
+    goal_type       text,
+    goal_gift_id    bigint  default 0,
+    goal_target     bigint  default 0,
+    reset_at        bigint  default 0,
+    created_on      bigint,
+    modified_on     bigint,
+    modified_by     text
+);
+
+alter table overlays
     owner to postgres;
 
 
@@ -77,19 +228,154 @@ create table live_confs
     modified_by     text,
     crated_by       text,
     cron            text,
+    handlers        text,
+    streamer_id     bigint default 0,
     enable          boolean default true
 );
 
 alter table live_confs
     owner to postgres;
 
+create table streamers
+(
+    id          bigserial
+        primary key,
+    sec_uid     text not null
+        constraint unique_sec_uid
+            unique,
+    uid         text,
+    nickname    text,
+    avatar      text,
+    display_id  text,
+    web_rid     text,
+    created_on  bigint,
+    modified_on bigint
+);
+
+alter table streamers
+    owner to postgres;
+
+create table room_snapshots
+(
+    id              bigserial
+        primary key,
+    room_id         bigint not null,
+    room_display_id text   not null,
+    streamer_id     bigint default 0,
+    status          bigint,
+    title           text,
+    cover           text,
+    owner_nickname  text,
+    owner_avatar    text,
+    category        text,
+    user_count      bigint,
+    total_user      bigint,
+    like_count      bigint,
+    source          text,
+    timestamp       bigint not null
+);
+
+alter table room_snapshots
+    owner to postgres;
+
+create table room_titles
+(
+    id              bigserial
+        primary key,
+    room_id         bigint not null,
+    room_display_id text   not null,
+    streamer_id     bigint default 0,
+    title           text   not null,
+    timestamp       bigint not null
+);
+
+alter table room_titles
+    owner to postgres;
+
+create table chat_flags
+(
+    user_id         bigint not null,
+    room_display_id text   not null,
+    user_name       text,
+    user_display_id text,
+    streamer_id     bigint default 0,
+    max_score       integer not null default 0,
+    last_score      integer not null default 0,
+    reasons         text,
+    flagged_count   bigint not null default 0,
+    excluded_count  bigint not null default 0,
+    evidence        text,
+    first_flagged   bigint not null,
+    last_flagged    bigint not null,
+    primary key (user_id, room_display_id)
+);
+
+alter table chat_flags
+    owner to postgres;
+
+create table dead_letters
+(
+    id              bigserial
+        primary key,
+    conf_id         bigint  not null,
+    room_display_id text    not null,
+    handler         text    not null,
+    method          text,
+    msg_id          bigint,
+    payload         bytea   not null,
+    error           text,
+    attempts        integer not null default 0,
+    replays         integer not null default 0,
+    first_failed    bigint  not null,
+    last_failed     bigint  not null
+);
+
+alter table dead_letters
+    owner to postgres;
+
+create table coverage_holes
+(
+    id              bigserial
+        primary key,
+    conf_id         bigint not null,
+    room_display_id text   not null,
+    room_id         text   not null,
+    start_time      bigint not null,
+    end_time        bigint not null,
+    reason          text   not null,
+    created_at      bigint not null
+);
+
+alter table coverage_holes
+    owner to postgres;
+
 -- 创建新索引
 -- common_messages 表索引
 CREATE INDEX idx_common_messages_user_id_timestamp ON common_messages (user_id, timestamp DESC);
 CREATE INDEX idx_common_messages_timestamp ON common_messages (timestamp DESC);
+CREATE INDEX idx_common_messages_room_display_id_timestamp ON common_messages (room_display_id, timestamp DESC);
 
 -- gift_messages 表索引
 CREATE INDEX idx_gift_messages_user_id_timestamp ON gift_messages (user_id, timestamp DESC);
 CREATE INDEX idx_gift_messages_timestamp ON gift_messages (timestamp DESC);
+CREATE INDEX idx_gift_messages_room_display_id_timestamp ON gift_messages (room_display_id, timestamp DESC);
+
+CREATE INDEX idx_users_last_seen ON users (last_seen DESC);
+CREATE INDEX idx_user_name_history_user_id_timestamp ON user_name_history (user_id, timestamp DESC);
+CREATE INDEX idx_user_room_stats_room_display_id ON user_room_stats (room_display_id);
+CREATE INDEX idx_user_gift_stats_to_user_id ON user_gift_stats (to_user_id);
+CREATE INDEX idx_message_archives_room_display_id_month ON message_archives (room_display_id, month);
+CREATE UNIQUE INDEX idx_overlays_token ON overlays (token);
+CREATE INDEX idx_overlays_room_display_id ON overlays (room_display_id);
+
+CREATE INDEX idx_common_messages_streamer_id_timestamp ON common_messages (streamer_id, timestamp DESC);
+CREATE INDEX idx_gift_messages_streamer_id_timestamp ON gift_messages (streamer_id, timestamp DESC);
 
-CREATE INDEX idx_users_user_id ON users (user_id);
\ No newline at end of file
+CREATE INDEX idx_room_snapshots_room_display_id_timestamp ON room_snapshots (room_display_id, timestamp DESC);
+CREATE INDEX idx_room_snapshots_room_id ON room_snapshots (room_id);
+CREATE INDEX idx_room_titles_room_display_id_timestamp ON room_titles (room_display_id, timestamp DESC);
+CREATE INDEX idx_room_titles_streamer_id_timestamp ON room_titles (streamer_id, timestamp DESC);
+CREATE INDEX idx_chat_flags_room_display_id_last_flagged ON chat_flags (room_display_id, last_flagged DESC);
+CREATE INDEX idx_dead_letters_room_display_id_last_failed ON dead_letters (room_display_id, last_failed DESC);
+CREATE INDEX idx_dead_letters_last_failed ON dead_letters (last_failed DESC);
+CREATE INDEX idx_coverage_holes_room_display_id_start_time ON coverage_holes (room_display_id, start_time DESC);
@@ -0,0 +1,25 @@
+-- 已有数据库升级: 添加弹幕刷屏和机器人检测的标记记录
+SET search_path TO live;
+
+create table if not exists chat_flags
+(
+    user_id         bigint not null,
+    room_display_id text   not null,
+    user_name       text,
+    user_display_id text,
+    streamer_id     bigint default 0,
+    max_score       integer not null default 0,
+    last_score      integer not null default 0,
+    reasons         text,
+    flagged_count   bigint not null default 0,
+    excluded_count  bigint not null default 0,
+    evidence        text,
+    first_flagged   bigint not null,
+    last_flagged    bigint not null,
+    primary key (user_id, room_display_id)
+);
+
+alter table chat_flags
+    owner to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_chat_flags_room_display_id_last_flagged ON chat_flags (room_display_id, last_flagged DESC);
@@ -0,0 +1,20 @@
+-- 已有数据库升级: 添加 websocket 重连后未能补发的消息缺口记录
+SET search_path TO live;
+
+create table if not exists coverage_holes
+(
+    id              bigserial
+        primary key,
+    conf_id         bigint not null,
+    room_display_id text   not null,
+    room_id         text   not null,
+    start_time      bigint not null,
+    end_time        bigint not null,
+    reason          text   not null,
+    created_at      bigint not null
+);
+
+alter table coverage_holes
+    owner to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_coverage_holes_room_display_id_start_time ON coverage_holes (room_display_id, start_time DESC);
@@ -0,0 +1,25 @@
+-- 已有数据库升级: 添加 handler 重试耗尽后的死信记录
+SET search_path TO live;
+
+create table if not exists dead_letters
+(
+    id              bigserial
+        primary key,
+    conf_id         bigint  not null,
+    room_display_id text    not null,
+    handler         text    not null,
+    method          text,
+    msg_id          bigint,
+    payload         bytea   not null,
+    error           text,
+    attempts        integer not null default 0,
+    replays         integer not null default 0,
+    first_failed    bigint  not null,
+    last_failed     bigint  not null
+);
+
+alter table dead_letters
+    owner to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_room_display_id_last_failed ON dead_letters (room_display_id, last_failed DESC);
+CREATE INDEX IF NOT EXISTS idx_dead_letters_last_failed ON dead_letters (last_failed DESC);
@@ -0,0 +1,35 @@
+-- 已有数据库升级: 粉丝团按 (user_id, anchor_id) 保存，用户可以同时加入多个主播的粉丝团
+-- db handler 更新用户资料时写入 user_fans_clubs，没有执行过 upgrade_user_stats.sql 的数据库同样需要执行
+SET search_path TO live;
+
+-- users 只保存最近一次出现时的粉丝团，改为由 user_fans_clubs 按主播保存
+alter table users
+    drop column if exists fans_club_name,
+    drop column if exists fans_club_level;
+
+create table if not exists user_fans_clubs
+(
+    user_id         bigint not null,
+    anchor_id       bigint not null,
+    club_name       text   not null,
+    level           integer,
+    room_display_id text,
+    streamer_id     bigint default 0,
+    first_seen      bigint not null,
+    last_seen       bigint not null,
+    primary key (user_id, anchor_id)
+);
+
+alter table user_fans_clubs
+    owner to postgres;
+
+-- 旧数据按粉丝团名称保存，没有主播 id，无法对应到主播，删除后由新的消息重新写入
+alter table user_fans_clubs
+    add column if not exists anchor_id bigint not null default 0;
+
+delete from user_fans_clubs where anchor_id = 0;
+
+alter table user_fans_clubs
+    alter column anchor_id drop default,
+    drop constraint if exists user_fans_clubs_pkey,
+    add primary key (user_id, anchor_id);
@@ -0,0 +1,33 @@
+-- 已有数据库升级: 添加 OBS 叠加层配置表，由 danmu-http 读写
+SET search_path TO live;
+
+create table if not exists overlays
+(
+    id              bigserial
+        primary key,
+    name            text,
+    token           text   not null,
+    room_display_id text   not null,
+    theme           text,
+    custom_css      text,
+    min_diamond     bigint  default 0,
+    alert_duration  integer default 0,
+    alert_queue     integer default 0,
+    chat_limit      integer default 0,
+    show_member     boolean default false,
+    top_count       integer default 0,
+    goal_title      text,
+    goal_type       text,
+    goal_gift_id    bigint  default 0,
+    goal_target     bigint  default 0,
+    reset_at        bigint  default 0,
+    created_on      bigint,
+    modified_on     bigint,
+    modified_by     text
+);
+
+alter table overlays
+    owner to postgres;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_overlays_token ON overlays (token);
+CREATE INDEX IF NOT EXISTS idx_overlays_room_display_id ON overlays (room_display_id);
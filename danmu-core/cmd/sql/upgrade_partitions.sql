@@ -0,0 +1,181 @@
+-- 已有数据库升级: common_messages、gift_messages 改为按月分区，添加保留策略和归档记录表
+-- 需先停止 danmu-core，数据量大时耗时较长，建议先备份
+-- 分区按 Asia/Shanghai 切分月份，与 danmu-core 的 model.PartitionLocation 一致，不受服务器时区影响
+SET search_path TO live;
+SET TIME ZONE 'Asia/Shanghai';
+
+begin;
+
+alter table common_messages rename to common_messages_old;
+alter table common_messages_old rename constraint common_messages_pkey to common_messages_old_pkey;
+alter table gift_messages rename to gift_messages_old;
+alter table gift_messages_old rename constraint gift_messages_pkey to gift_messages_old_pkey;
+
+DROP INDEX IF EXISTS idx_common_messages_user_id_timestamp;
+DROP INDEX IF EXISTS idx_common_messages_timestamp;
+DROP INDEX IF EXISTS idx_common_messages_streamer_id_timestamp;
+DROP INDEX IF EXISTS idx_gift_messages_user_id_timestamp;
+DROP INDEX IF EXISTS idx_gift_messages_timestamp;
+DROP INDEX IF EXISTS idx_gift_messages_streamer_id_timestamp;
+
+-- 旧版本的礼物消息可能是秒级时间戳，与 danmu-core 的 NormalizeTimestamp 一致转为毫秒
+update gift_messages_old
+set timestamp = timestamp * 1000
+where timestamp between 1000000000 and 9999999999;
+
+create table common_messages
+(
+    id               bigint not null,
+    message_type     text   not null,
+    room_id          bigint not null,
+    room_display_id  text   not null,
+    room_name        text   not null,
+    user_name        text   not null,
+    user_id          bigint not null,
+    user_display_id  text   not null,
+    content          text   not null,
+    timestamp        bigint not null,
+    favorite_user_id bigint default 0,
+    streamer_id      bigint default 0,
+    primary key (id, timestamp)
+) partition by range (timestamp);
+
+alter table common_messages
+    owner to postgres;
+
+create table gift_messages
+(
+    id                 bigint not null,
+    user_id            bigint not null,
+    user_name          text   not null,
+    user_display_id    text   not null,
+    to_user_id         bigint not null,
+    to_user_name       text   not null,
+    to_user_display_id text   not null,
+    gift_name          text   not null,
+    gift_id            bigint not null,
+    room_id            bigint not null,
+    room_display_id    text   not null,
+    room_name          text   not null,
+    message            text   not null,
+    timestamp          bigint not null,
+    diamond_count      bigint not null,
+    image_url          text,
+    repeat_end         integer,
+    combo_count        text,
+    streamer_id        bigint default 0,
+    primary key (id, timestamp)
+) partition by range (timestamp);
+
+alter table gift_messages
+    owner to postgres;
+
+-- 为已有数据所在的月份及未来 3 个月创建分区，命名与 danmu-core 一致: <table>_pYYYYMM
+create function pg_temp.create_month_partitions(tbl text, from_ms bigint) returns void as
+$$
+declare
+    m timestamptz := date_trunc('month', to_timestamp(from_ms / 1000.0));
+begin
+    while m < date_trunc('month', now()) + interval '4 month'
+        loop
+            execute format('create table if not exists %I partition of %I for values from (%s) to (%s)',
+                           tbl || '_p' || to_char(m, 'YYYYMM'), tbl,
+                           (extract(epoch from m) * 1000)::bigint,
+                           (extract(epoch from m + interval '1 month') * 1000)::bigint);
+            m := m + interval '1 month';
+        end loop;
+end
+$$ language plpgsql;
+
+select pg_temp.create_month_partitions('common_messages',
+                                       coalesce((select min(timestamp) from common_messages_old),
+                                                (extract(epoch from now()) * 1000)::bigint));
+select pg_temp.create_month_partitions('gift_messages',
+                                       coalesce((select min(timestamp) from gift_messages_old),
+                                                (extract(epoch from now()) * 1000)::bigint));
+
+insert into common_messages (id, message_type, room_id, room_display_id, room_name, user_name, user_id,
+                             user_display_id, content, timestamp, favorite_user_id, streamer_id)
+select id,
+       message_type,
+       room_id,
+       room_display_id,
+       room_name,
+       user_name,
+       user_id,
+       user_display_id,
+       content,
+       timestamp,
+       favorite_user_id,
+       streamer_id
+from common_messages_old;
+
+insert into gift_messages (id, user_id, user_name, user_display_id, to_user_id, to_user_name, to_user_display_id,
+                           gift_name, gift_id, room_id, room_display_id, room_name, message, timestamp, diamond_count,
+                           image_url, repeat_end, combo_count, streamer_id)
+select id,
+       user_id,
+       user_name,
+       user_display_id,
+       to_user_id,
+       to_user_name,
+       to_user_display_id,
+       gift_name,
+       gift_id,
+       room_id,
+       room_display_id,
+       room_name,
+       message,
+       timestamp,
+       diamond_count,
+       image_url,
+       repeat_end,
+       combo_count,
+       streamer_id
+from gift_messages_old;
+
+drop table common_messages_old;
+drop table gift_messages_old;
+
+CREATE INDEX idx_common_messages_user_id_timestamp ON common_messages (user_id, timestamp DESC);
+CREATE INDEX idx_common_messages_timestamp ON common_messages (timestamp DESC);
+CREATE INDEX idx_common_messages_room_display_id_timestamp ON common_messages (room_display_id, timestamp DESC);
+CREATE INDEX idx_common_messages_streamer_id_timestamp ON common_messages (streamer_id, timestamp DESC);
+CREATE INDEX idx_gift_messages_user_id_timestamp ON gift_messages (user_id, timestamp DESC);
+CREATE INDEX idx_gift_messages_timestamp ON gift_messages (timestamp DESC);
+CREATE INDEX idx_gift_messages_room_display_id_timestamp ON gift_messages (room_display_id, timestamp DESC);
+CREATE INDEX idx_gift_messages_streamer_id_timestamp ON gift_messages (streamer_id, timestamp DESC);
+
+create table if not exists retention_policies
+(
+    room_display_id text    not null
+        primary key,
+    retain_days     integer not null,
+    modified_on     bigint,
+    modified_by     text
+);
+
+alter table retention_policies
+    owner to postgres;
+
+create table if not exists message_archives
+(
+    id              bigserial
+        primary key,
+    message_table   text   not null,
+    room_display_id text   not null,
+    month           bigint not null,
+    path            text   not null,
+    rows            bigint not null,
+    size            bigint not null,
+    begin           bigint not null,
+    "end"           bigint not null,
+    created_on      bigint
+);
+
+alter table message_archives
+    owner to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_message_archives_room_display_id_month ON message_archives (room_display_id, month);
+
+commit;
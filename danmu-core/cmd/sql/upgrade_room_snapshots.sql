@@ -0,0 +1,44 @@
+-- 已有数据库升级: 添加直播间元数据快照和标题变更记录
+SET search_path TO live;
+
+create table if not exists room_snapshots
+(
+    id              bigserial
+        primary key,
+    room_id         bigint not null,
+    room_display_id text   not null,
+    streamer_id     bigint default 0,
+    status          bigint,
+    title           text,
+    cover           text,
+    owner_nickname  text,
+    owner_avatar    text,
+    category        text,
+    user_count      bigint,
+    total_user      bigint,
+    like_count      bigint,
+    source          text,
+    timestamp       bigint not null
+);
+
+alter table room_snapshots
+    owner to postgres;
+
+create table if not exists room_titles
+(
+    id              bigserial
+        primary key,
+    room_id         bigint not null,
+    room_display_id text   not null,
+    streamer_id     bigint default 0,
+    title           text   not null,
+    timestamp       bigint not null
+);
+
+alter table room_titles
+    owner to postgres;
+
+CREATE INDEX IF NOT EXISTS idx_room_snapshots_room_display_id_timestamp ON room_snapshots (room_display_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_room_snapshots_room_id ON room_snapshots (room_id);
+CREATE INDEX IF NOT EXISTS idx_room_titles_room_display_id_timestamp ON room_titles (room_display_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_room_titles_streamer_id_timestamp ON room_titles (streamer_id, timestamp DESC);
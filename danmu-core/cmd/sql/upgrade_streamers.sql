@@ -0,0 +1,42 @@
+-- 已有数据库升级: 添加 streamers 表并关联 live_confs 和消息
+-- 执行后 core 在下一次 CheckStream 时写入主播信息，再执行末尾的回填语句关联历史消息
+SET search_path TO live;
+
+create table if not exists streamers
+(
+    id          bigserial
+        primary key,
+    sec_uid     text not null
+        constraint unique_sec_uid
+            unique,
+    uid         text,
+    nickname    text,
+    avatar      text,
+    display_id  text,
+    web_rid     text,
+    created_on  bigint,
+    modified_on bigint
+);
+
+alter table live_confs add column if not exists handlers text;
+alter table live_confs add column if not exists streamer_id bigint default 0;
+alter table common_messages add column if not exists streamer_id bigint default 0;
+alter table gift_messages add column if not exists streamer_id bigint default 0;
+
+CREATE INDEX IF NOT EXISTS idx_common_messages_streamer_id_timestamp ON common_messages (streamer_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_gift_messages_streamer_id_timestamp ON gift_messages (streamer_id, timestamp DESC);
+
+-- 回填历史消息
+UPDATE common_messages m
+SET streamer_id = c.streamer_id
+FROM live_confs c
+WHERE m.room_display_id = c.room_display_id
+  AND m.streamer_id = 0
+  AND c.streamer_id <> 0;
+
+UPDATE gift_messages m
+SET streamer_id = c.streamer_id
+FROM live_confs c
+WHERE m.room_display_id = c.room_display_id
+  AND m.streamer_id = 0
+  AND c.streamer_id <> 0;
@@ -0,0 +1,149 @@
+-- 已有数据库升级: 添加用户跨直播间统计表，并从已保存的消息回填
+-- 回填前应先停止 danmu-core，避免回填期间写入的增量被重复统计
+SET search_path TO live;
+
+create table if not exists user_room_stats
+(
+    user_id         bigint not null,
+    room_display_id text   not null,
+    room_name       text,
+    streamer_id     bigint default 0,
+    chat_count      bigint not null default 0,
+    gift_count      bigint not null default 0,
+    diamond_total   bigint not null default 0,
+    first_seen      bigint not null,
+    last_seen       bigint not null,
+    primary key (user_id, room_display_id)
+);
+
+alter table user_room_stats
+    owner to postgres;
+
+create table if not exists user_gift_stats
+(
+    user_id         bigint not null,
+    room_display_id text   not null,
+    to_user_id      bigint not null,
+    gift_id         bigint not null,
+    to_user_name    text,
+    gift_name       text,
+    image_url       text,
+    gift_count      bigint not null default 0,
+    diamond_total   bigint not null default 0,
+    last_sent       bigint not null,
+    primary key (user_id, room_display_id, to_user_id, gift_id)
+);
+
+alter table user_gift_stats
+    owner to postgres;
+
+create table if not exists user_daily_activity
+(
+    user_id         bigint not null,
+    room_display_id text   not null,
+    day             bigint not null,
+    chat_count      bigint not null default 0,
+    gift_count      bigint not null default 0,
+    diamond_total   bigint not null default 0,
+    first_seen      bigint not null,
+    last_seen       bigint not null,
+    primary key (user_id, room_display_id, day)
+);
+
+alter table user_daily_activity
+    owner to postgres;
+
+create table if not exists user_fans_clubs
+(
+    user_id         bigint not null,
+    anchor_id       bigint not null,
+    club_name       text   not null,
+    level           integer,
+    room_display_id text,
+    streamer_id     bigint default 0,
+    first_seen      bigint not null,
+    last_seen       bigint not null,
+    primary key (user_id, anchor_id)
+);
+
+alter table user_fans_clubs
+    owner to postgres;
+
+truncate user_room_stats, user_gift_stats, user_daily_activity;
+
+-- 连击消息的数量是累计值，与 danmu-core 相同: 比上一条多出的部分计入，数量不增加时视为新的一次连击
+create temp table gift_deltas as
+select user_id,
+       room_display_id,
+       room_name,
+       streamer_id,
+       to_user_id,
+       to_user_name,
+       gift_id,
+       gift_name,
+       image_url,
+       timestamp,
+       diamond_count,
+       case when combo > prev_combo then combo - prev_combo else combo end as delta
+from (select g.*,
+             lag(combo, 1, 0::bigint) over (partition by user_id, room_display_id, to_user_id, gift_id
+                                            order by timestamp, id) as prev_combo
+      from (select *,
+                   case when combo_count ~ '^[0-9]+$' and combo_count::bigint > 0 then combo_count::bigint else 1 end as combo
+            from gift_messages
+            where user_id <> 0) g) t;
+
+-- 消息时间为毫秒时间戳，按数据库时区切分日期，与 danmu-core 所在时区一致
+create temp table activity as
+select user_id, room_display_id, room_name, streamer_id, timestamp::bigint as ts, 1::bigint as chats, 0::bigint as gifts, 0::bigint as diamonds
+from common_messages
+where message_type = 'WebcastChatMessage'
+  and user_id <> 0
+union all
+select user_id, room_display_id, room_name, streamer_id, timestamp::bigint, 0, delta, delta * diamond_count
+from gift_deltas;
+
+insert into user_room_stats (user_id, room_display_id, room_name, streamer_id, chat_count, gift_count, diamond_total, first_seen, last_seen)
+select user_id,
+       room_display_id,
+       (array_agg(room_name order by ts desc))[1],
+       (array_agg(streamer_id order by ts desc))[1],
+       sum(chats),
+       sum(gifts),
+       sum(diamonds),
+       min(ts),
+       max(ts)
+from activity
+group by user_id, room_display_id;
+
+insert into user_gift_stats (user_id, room_display_id, to_user_id, gift_id, to_user_name, gift_name, image_url, gift_count, diamond_total, last_sent)
+select user_id,
+       room_display_id,
+       to_user_id,
+       gift_id,
+       (array_agg(to_user_name order by timestamp desc))[1],
+       (array_agg(gift_name order by timestamp desc))[1],
+       (array_agg(image_url order by timestamp desc))[1],
+       sum(delta),
+       sum(delta * diamond_count),
+       max(timestamp)
+from gift_deltas
+group by user_id, room_display_id, to_user_id, gift_id;
+
+insert into user_daily_activity (user_id, room_display_id, day, chat_count, gift_count, diamond_total, first_seen, last_seen)
+select user_id,
+       room_display_id,
+       (extract(epoch from date_trunc('day', to_timestamp(ts / 1000.0))) * 1000)::bigint as day,
+       sum(chats),
+       sum(gifts),
+       sum(diamonds),
+       min(ts),
+       max(ts)
+from activity
+group by user_id, room_display_id, day;
+
+drop table gift_deltas;
+drop table activity;
+
+CREATE INDEX IF NOT EXISTS idx_user_room_stats_room_display_id ON user_room_stats (room_display_id);
+CREATE INDEX IF NOT EXISTS idx_user_gift_stats_to_user_id ON user_gift_stats (to_user_id);
@@ -0,0 +1,43 @@
+-- 已有数据库升级: 丰富用户资料并记录昵称变更
+-- 旧版本每次昵称变化插入一条 users 记录，这里先把它们转为变更历史，再按 user_id 去重
+SET search_path TO live;
+
+create table if not exists user_name_history
+(
+    id         bigserial
+        primary key,
+    user_id    bigint not null,
+    user_name  text   not null,
+    display_id text   not null,
+    timestamp  bigint not null
+);
+
+alter table user_name_history
+    owner to postgres;
+
+-- 旧数据没有时间，按 id 顺序生成递增的时间戳，保证历史顺序正确
+insert into user_name_history (user_id, user_name, display_id, timestamp)
+select user_id, user_name, display_id, row_number() over (partition by user_id order by id)
+from users;
+
+delete from users u
+    using users newer
+where u.user_id = newer.user_id
+  and u.id < newer.id;
+
+alter table users
+    add column if not exists sec_uid         text,
+    add column if not exists avatar          text,
+    add column if not exists gender          integer,
+    add column if not exists pay_grade       bigint,
+    add column if not exists follower_count  bigint,
+    add column if not exists following_count bigint,
+    add column if not exists first_seen      bigint,
+    add column if not exists last_seen       bigint;
+
+DROP INDEX IF EXISTS idx_users_user_id;
+alter table users
+    add constraint unique_user_id unique (user_id);
+
+CREATE INDEX IF NOT EXISTS idx_users_last_seen ON users (last_seen DESC);
+CREATE INDEX IF NOT EXISTS idx_user_name_history_user_id_timestamp ON user_name_history (user_id, timestamp DESC);
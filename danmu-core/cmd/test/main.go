@@ -4,12 +4,17 @@ import (
 	"danmu-core/core"
 	"danmu-core/internal/handler"
 	"danmu-core/internal/model"
+	"danmu-core/logger"
+	"danmu-core/setting"
 	"os"
 	"os/signal"
 	"syscall"
 )
 
 func main() {
+	setting.Setup()
+	logger.Init()
+	model.Init()
 	conf := &model.LiveConf{
 		Name:   "test",
 		URL:    "https://live.douyin.com/758593847340",
@@ -17,7 +22,10 @@ func main() {
 	}
 	//handler, _ := handler.NewDymsg2dbHandler(conf)
 	handler := handler.NewDyPrint2ConsoleHandler("837174427973")
-	c := core.MakeClient(conf)
+	c, err := core.MakeClient(conf)
+	if err != nil {
+		panic(err)
+	}
 	c.Subscribe(handler)
 	c.Start()
 	//core.InitTaskManager()
@@ -3,11 +3,17 @@ package core
 import (
 	"context"
 	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/dedup"
 	"danmu-core/internal/model"
+	"danmu-core/internal/proxy"
 	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/tracing"
 	"danmu-core/utils"
 	"fmt"
 	"net/http"
+	"reflect"
 	"runtime"
 	"runtime/debug"
 	"strings"
@@ -17,6 +23,8 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Platform interface {
@@ -26,6 +34,11 @@ type Platform interface {
 	CheckStream() (bool, error)
 }
 
+// HandshakeObserver 需要知道 websocket 握手结果的平台实现，例如签名被拒绝时切换签名的实现
+type HandshakeObserver interface {
+	Handshake(resp *http.Response, err error)
+}
+
 type MsgHandler interface {
 	Handle(msg interface{}) error
 }
@@ -33,18 +46,27 @@ type MsgHandler interface {
 const DefaultCron = "0 0/15 * * * ?"
 
 type Client struct {
-	liveurl    string
-	p          Platform
-	conn       *websocket.Conn
-	connMu     sync.RWMutex
-	ctx        context.Context
-	cancelFunc context.CancelFunc
-	enable     atomic.Bool
-	isLive     atomic.Bool
-	mu         sync.Mutex
-	cronTask   *cron.Cron
-	RecvMsg    chan interface{}
-	handlers   []MsgHandler
+	liveurl       string
+	room          string
+	confID        int64
+	roomDisplayID string
+	webRid        string
+	streamerID    atomic.Int64
+	p             Platform
+	conn          *websocket.Conn
+	dialer        *websocket.Dialer // 与 platform 的 http 请求使用同一个代理
+	connMu        sync.RWMutex
+	ctx           context.Context
+	cancelFunc    context.CancelFunc
+	enable        atomic.Bool
+	isLive        atomic.Bool
+	mu            sync.Mutex
+	cronTask      *cron.Cron
+	RecvMsg       chan interface{}
+	handlers      []MsgHandler
+	queues        []*handlerQueue
+	dedup         *dedup.Filter // 同一直播间的所有 handler 共用
+	transport     *transportSwitch
 }
 
 type zerologCronLogger struct{}
@@ -57,29 +79,41 @@ func (z zerologCronLogger) Error(err error, msg string, keysAndValues ...interfa
 	logger.Error().Err(err).Msgf(msg, keysAndValues...)
 }
 
-func MakeClient(conf *model.LiveConf) *Client {
+func MakeClient(conf *model.LiveConf) (*Client, error) {
 	if conf.Cron == "" {
 		conf.Cron = DefaultCron
 	}
 	client := &Client{
-		liveurl: conf.URL,
-		connMu:  sync.RWMutex{},
-		mu:      sync.Mutex{},
+		liveurl:       conf.URL,
+		room:          roomLabel(conf),
+		confID:        conf.ID,
+		roomDisplayID: conf.RoomDisplayID,
+		connMu:        sync.RWMutex{},
+		mu:            sync.Mutex{},
+	}
+	client.dialer = &websocket.Dialer{
+		Proxy:            proxy.Func(client.room),
+		HandshakeTimeout: 45 * time.Second,
 	}
+	client.dedup = dedup.Open(client.room)
 	client.enable.Store(conf.Enable)
 	client.isLive.Store(false)
 	var err error
 	switch {
 	case strings.Contains(conf.URL, "douyin.com"):
-		client.p, err = platform.NewDouyinPlatform(conf.URL)
+		client.p, err = platform.NewDouyinPlatform(conf.URL, client.room)
+		client.webRid = platform.ParseWebRid(conf.URL)
 	default:
 		logger.Warn().Str("liveurl", conf.URL).Msg("Unsupported platform")
-		return nil
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedPlatform, conf.URL)
 	}
 	if err != nil {
 		logger.Warn().Str("liveurl", conf.URL).Err(err).Msg("Init platform error")
-		return nil
+		return nil, fmt.Errorf("%w: %v", ErrPlatformInit, err)
 	}
+	client.initStreamer(conf)
+	client.initResume()
+	client.transport = newTransportSwitch(client)
 
 	// 初始化定时任务，用于定期检查直播状态
 	// 使用 cron 库创建定时器，支持秒级精度
@@ -105,7 +139,7 @@ func MakeClient(conf *model.LiveConf) *Client {
 			Str("liveurl", conf.URL).
 			Str("cron", conf.Cron).
 			Msg("添加定时任务失败")
-		return nil
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCron, err)
 	}
 
 	logger.Info().
@@ -114,7 +148,7 @@ func MakeClient(conf *model.LiveConf) *Client {
 		Bool("enable", conf.Enable).
 		Msg("客户端创建成功")
 
-	return client
+	return client, nil
 }
 
 func (c *Client) Start() {
@@ -136,6 +170,22 @@ func (c *Client) SetEnable(enable bool) {
 	}
 }
 
+// Restart 停止并等待当前连接退出后重新启动
+func (c *Client) Restart() {
+	c.Stop()
+	c.mu.Lock()
+	c.mu.Unlock()
+	c.Start()
+}
+
+func (c *Client) Enabled() bool {
+	return c.enable.Load()
+}
+
+func (c *Client) Live() bool {
+	return c.isLive.Load()
+}
+
 func (c *Client) Stop() {
 	c.enable.Store(false)
 	if c.cancelFunc != nil {
@@ -161,6 +211,11 @@ func (c *Client) close() {
 	}
 	c.connMu.Unlock()
 
+	c.stopQueues()
+	c.flushHandlers()
+	if err := c.dedup.Save(); err != nil {
+		logger.Warn().Str("liveurl", c.liveurl).Err(err).Msg("save dedup state error")
+	}
 	logger.Info().Str("liveurl", c.liveurl).Msg("客户端已关闭")
 }
 
@@ -168,6 +223,7 @@ func (c *Client) run() {
 	if !c.mu.TryLock() {
 		return
 	}
+	defer c.mu.Unlock()
 	if !c.enable.Load() {
 		return
 	}
@@ -175,56 +231,94 @@ func (c *Client) run() {
 		logger.Info().Str("liveurl", c.liveurl).Msg("live is not Living")
 		return
 	}
-	c.RecvMsg = make(chan interface{}, 100)
-	defer c.mu.Unlock()
 	defer c.close()
 
 	logger.Info().Str("liveurl", c.liveurl).Msg("Start DouyinLive")
 
 	for c.enable.Load() && c.isLive.Load() {
-
-		if !c.connectWebsocket(1) {
-			time.Sleep(time.Second * 5)
-			continue
+		if c.transport.Current() == TransportPolling {
+			c.runPolling()
+		} else {
+			if !c.connectWebsocket(1) {
+				time.Sleep(time.Second * 5)
+				continue
+			}
+			c.runWebsocket()
 		}
-
-		c.ctx, c.cancelFunc = context.WithCancel(context.Background())
-		var wg sync.WaitGroup
-		wg.Add(3)
-		go func() {
-			defer wg.Done()
-			logger.Info().Str("liveurl", c.liveurl).Msg("启动fetchMessage")
-			utils.SafeRun(c.fetchMessage)
-			logger.Info().Str("liveurl", c.liveurl).Msg("停止fetchMessage()")
-			c.cancelFunc()
-		}()
-		go func() {
-			defer wg.Done()
-			logger.Info().Str("liveurl", c.liveurl).Msg("启动heartbeat")
-			utils.SafeRun(c.heartbeat)
-			logger.Info().Str("liveurl", c.liveurl).Msg("停止heartbeat()")
-			c.cancelFunc()
-		}()
-		go func() {
-			defer wg.Done()
-			logger.Info().Str("liveurl", c.liveurl).Msg("启动processMsg")
-			utils.SafeRun(c.processMsg)
-			logger.Info().Str("liveurl", c.liveurl).Msg("停止processMsg()")
-			c.cancelFunc()
-		}()
-		wg.Wait()
 		if c.enable.Load() && c.isLive.Load() {
 			c.checkStreamTask()
 		}
 	}
 }
 
+// runWebsocket 通过 websocket 接收消息，连接断开且重连失败或切换到轮询时返回
+func (c *Client) runWebsocket() {
+	// 每次连接使用新的通道，fetchMessage 退出时关闭
+	c.RecvMsg = make(chan interface{}, 100)
+	c.ctx, c.cancelFunc = context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		logger.Info().Str("liveurl", c.liveurl).Msg("启动fetchMessage")
+		utils.SafeRun(c.fetchMessage)
+		logger.Info().Str("liveurl", c.liveurl).Msg("停止fetchMessage()")
+		c.cancelFunc()
+	}()
+	go func() {
+		defer wg.Done()
+		logger.Info().Str("liveurl", c.liveurl).Msg("启动heartbeat")
+		utils.SafeRun(c.heartbeat)
+		logger.Info().Str("liveurl", c.liveurl).Msg("停止heartbeat()")
+		c.cancelFunc()
+	}()
+	go func() {
+		defer wg.Done()
+		logger.Info().Str("liveurl", c.liveurl).Msg("启动processMsg")
+		utils.SafeRun(c.processMsg)
+		logger.Info().Str("liveurl", c.liveurl).Msg("停止processMsg()")
+		c.cancelFunc()
+	}()
+	wg.Wait()
+}
+
+// runPolling 通过 http 轮询接收消息，轮询失败过多或需要重新尝试 websocket 时返回
+func (c *Client) runPolling() {
+	// 切换到轮询后不再使用之前的 websocket 连接
+	c.connMu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.connMu.Unlock()
+	c.RecvMsg = make(chan interface{}, 100)
+	c.ctx, c.cancelFunc = context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		logger.Info().Str("liveurl", c.liveurl).Msg("启动pollMessages")
+		utils.SafeRun(c.pollMessages)
+		logger.Info().Str("liveurl", c.liveurl).Msg("停止pollMessages()")
+		c.cancelFunc()
+	}()
+	go func() {
+		defer wg.Done()
+		logger.Info().Str("liveurl", c.liveurl).Msg("启动processMsg")
+		utils.SafeRun(c.processMsg)
+		logger.Info().Str("liveurl", c.liveurl).Msg("停止processMsg()")
+		c.cancelFunc()
+	}()
+	wg.Wait()
+}
+
 func (c *Client) connectWebsocket(i int) bool {
 	c.connMu.Lock()
 	defer c.connMu.Unlock()
 	wssUrl, headers, wsErr := c.p.GetWsInfo()
 	if wsErr != nil {
 		logger.Warn().Str("liveurl", c.liveurl).Err(wsErr).Msg("获取ws url失败")
+		c.transport.wsConnectFailed()
 		return false
 	}
 	var err error
@@ -236,11 +330,23 @@ func (c *Client) connectWebsocket(i int) bool {
 			}
 		}
 		var resp *http.Response
-		c.conn, resp, err = websocket.DefaultDialer.Dial(wssUrl, headers)
+		c.conn, resp, err = c.dialer.Dial(wssUrl, headers)
+		if o, ok := c.p.(HandshakeObserver); ok {
+			o.Handshake(resp, err)
+		}
 		if err != nil {
+			metrics.Reconnects.WithLabelValues(c.room, "error").Inc()
+			if resp != nil && resp.StatusCode == http.StatusForbidden && proxy.Enabled() {
+				proxy.Rotate(c.room, proxy.ReasonBlocked)
+			}
 			logger.Warn().Str("liveurl", c.liveurl).Interface("resp", resp).Err(err).Msg("重连失败")
+			if c.transport.wsConnectFailed() {
+				return false
+			}
 			time.Sleep(5 * time.Second)
 		} else {
+			metrics.Reconnects.WithLabelValues(c.room, "success").Inc()
+			c.transport.wsConnected()
 			logger.Info().Str("liveurl", c.liveurl).Msg("连接成功")
 			return true
 		}
@@ -291,6 +397,9 @@ func (c *Client) fetchMessage() {
 					return
 				} else {
 					logger.Warn().Str("liveurl", c.liveurl).Str("resp", string(data)).Err(err).Msg("WebSocket 错误")
+					if c.transport.wsDropped() {
+						return
+					}
 					if c.connectWebsocket(3) {
 						continue
 					} else {
@@ -301,8 +410,10 @@ func (c *Client) fetchMessage() {
 			if msgType != websocket.BinaryMessage || len(data) == 0 {
 				continue
 			}
+			metrics.FramesReceived.WithLabelValues(c.room).Inc()
 			ack, err := c.p.DecodeMsg(data, c.RecvMsg, c.ctx, c.cancelFunc)
 			if err != nil {
+				metrics.DecodeErrors.WithLabelValues(c.room).Inc()
 				logger.Info().Str("liveurl", c.liveurl).Err(err).Msg("Parse data error")
 				continue
 			}
@@ -318,9 +429,12 @@ func (c *Client) fetchMessage() {
 
 func (c *Client) processMsg() {
 
+	queueDepth := metrics.RecvQueueDepth.WithLabelValues(c.room)
 	for msg := range c.RecvMsg {
+		queueDepth.Set(float64(len(c.RecvMsg)))
 		c.emit(msg)
 	}
+	queueDepth.Set(0)
 	logger.Info().Str("liveurl", c.liveurl).Msg("Channel closed, Stop ProcessingRecvMessage()")
 	c.RecvMsg = nil
 }
@@ -328,10 +442,17 @@ func (c *Client) processMsg() {
 func (c *Client) checkStreamTask() {
 	isLive, err := c.p.CheckStream()
 	c.isLive.Store(isLive)
+	if isLive {
+		metrics.RoomLive.WithLabelValues(c.room).Set(1)
+	} else {
+		metrics.RoomLive.WithLabelValues(c.room).Set(0)
+	}
 	logger.Info().
 		Err(err).
 		Str("liveurl", c.liveurl).
 		Msgf("CheckStream: %v", isLive)
+	c.syncStreamer()
+	c.syncRoomInfo()
 	if isLive {
 		go c.run()
 	} else {
@@ -363,8 +484,9 @@ func (c *Client) read() (int, []byte, error) {
 	return conn.ReadMessage()
 }
 
+// Subscribe 订阅 handler，使用类型名作为队列策略的配置名称
 func (c *Client) Subscribe(handler MsgHandler) {
-	c.handlers = append(c.handlers, handler)
+	c.subscribe(handlerName(handler), handler)
 }
 
 func (c *Client) emit(msg interface{}) {
@@ -386,11 +508,53 @@ func (c *Client) emit(msg interface{}) {
 				Msg("Panic recovered in SafeRun")
 		}
 	}()
-	for _, handler := range c.handlers {
-		err := handler.Handle(msg)
-		if err != nil {
-			logger.Warn().Str("liveurl", c.liveurl).Err(err).Msg("handle msg error")
-			continue
-		}
+	if c.duplicate(msg) {
+		return
+	}
+	// 按采样率为消息处理创建 span，未采样时不产生任何 trace 开销
+	var ctx context.Context
+	if tracing.SampleMessage() {
+		var span trace.Span
+		ctx, span = tracing.Start(context.Background(), "core.emit",
+			trace.WithAttributes(attribute.String("room", c.room)))
+		defer span.End()
+	}
+	// 分发到各 handler 的队列，由各自的 worker 处理
+	for _, q := range c.queues {
+		q.push(msg, ctx)
+	}
+}
+
+// duplicate 断线重连或重启后重复收到的消息在分发给 handler 前丢弃
+func (c *Client) duplicate(msg interface{}) bool {
+	message, ok := msg.(*dystruct.Webcast_Im_Message)
+	if !ok || message.MsgId == 0 {
+		return false
+	}
+	result := c.dedup.Check(message.MsgId, platform.MessageTime(message))
+	if result == dedup.ResultNew {
+		return false
+	}
+	metrics.DuplicateMessages.WithLabelValues(c.room, result).Inc()
+	return true
+}
+
+// roomLabel 指标中使用的直播间标识，优先使用 RoomDisplayID
+func roomLabel(conf *model.LiveConf) string {
+	if conf.RoomDisplayID != "" {
+		return conf.RoomDisplayID
+	}
+	return conf.URL
+}
+
+// handlerName 返回 handler 的类型名，作为指标 label
+func handlerName(h MsgHandler) string {
+	if r, ok := h.(*RetryHandler); ok {
+		h = r.MsgHandler
+	}
+	t := reflect.TypeOf(h)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
+	return t.Name()
 }
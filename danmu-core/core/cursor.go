@@ -0,0 +1,51 @@
+package core
+
+import (
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/internal/model"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/utils"
+	"time"
+)
+
+// ResumablePlatform 重连时可以从断点继续推送的平台，无法补发时报告缺口
+type ResumablePlatform interface {
+	Cursor() *platform.Cursor
+	OnGap(f func(platform.Gap))
+}
+
+func (c *Client) initResume() {
+	rp, ok := c.p.(ResumablePlatform)
+	if !ok {
+		return
+	}
+	rp.OnGap(c.recordGap)
+}
+
+// recordGap 记录重连后未能补发的时间范围，写入数据库不阻塞读取
+func (c *Client) recordGap(gap platform.Gap) {
+	duration := gap.End.Sub(gap.Start)
+	metrics.CoverageHoles.WithLabelValues(c.room, gap.Reason).Inc()
+	metrics.CoverageHoleSeconds.WithLabelValues(c.room).Add(duration.Seconds())
+	logger.Warn().
+		Str("liveurl", c.liveurl).
+		Str("room_id", gap.RoomID).
+		Str("reason", gap.Reason).
+		Dur("duration", duration).
+		Msg("消息缺口，断开期间的消息未补发")
+	hole := &model.CoverageHole{
+		ConfID:        c.confID,
+		RoomDisplayId: c.roomDisplayID,
+		RoomID:        gap.RoomID,
+		StartTime:     gap.Start.UnixMilli(),
+		EndTime:       gap.End.UnixMilli(),
+		Reason:        gap.Reason,
+		CreatedAt:     time.Now().UnixMilli(),
+	}
+	go utils.SafeRun(func() {
+		if err := model.SaveCoverageHole(hole); err != nil {
+			logger.Error().Err(err).Str("liveurl", c.liveurl).Msg("save coverage hole error")
+		}
+	})
+}
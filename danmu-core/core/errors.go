@@ -0,0 +1,13 @@
+package core
+
+import "errors"
+
+var (
+	ErrTaskNotFound        = errors.New("task not found")
+	ErrTaskExists          = errors.New("task already exists")
+	ErrTaskDisabled        = errors.New("task is disabled")
+	ErrUnsupportedPlatform = errors.New("unsupported platform")
+	ErrInvalidCron         = errors.New("invalid cron expression")
+	ErrUnknownHandler      = errors.New("unknown handler")
+	ErrPlatformInit        = errors.New("init platform failed")
+)
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"danmu-core/internal/analytics"
+	"danmu-core/internal/handler"
+	"danmu-core/internal/model"
+	"danmu-core/internal/realtime"
+	"danmu-core/logger"
+	"danmu-core/setting"
+	"fmt"
+)
+
+// DefaultHandlers 未配置 LiveConf.Handlers 和 [handlers] Default 时订阅的 handler
+// room、stats 需要新的表，已有 postgres 数据库执行升级脚本后通过 [handlers] Default 启用
+var DefaultHandlers = []string{"db"}
+
+// handlerFactories 可通过 LiveConf.Handlers 按名称订阅的 handler
+var handlerFactories = map[string]func(conf *model.LiveConf) (MsgHandler, error){
+	"db": func(conf *model.LiveConf) (MsgHandler, error) {
+		return handler.NewDymsg2dbHandler(conf)
+	},
+	"room": func(conf *model.LiveConf) (MsgHandler, error) {
+		return handler.NewRoom2dbHandler(conf), nil
+	},
+	"stats": func(conf *model.LiveConf) (MsgHandler, error) {
+		return handler.NewUserStats2dbHandler(conf)
+	},
+	"console": func(conf *model.LiveConf) (MsgHandler, error) {
+		return handler.NewDyPrint2ConsoleHandler(conf.RoomDisplayID), nil
+	},
+	"analytics": func(conf *model.LiveConf) (MsgHandler, error) {
+		return handler.NewDymsg2chHandler(conf)
+	},
+	"redis": func(conf *model.LiveConf) (MsgHandler, error) {
+		return handler.NewDymsg2redisHandler(conf)
+	},
+	"moderation": func(conf *model.LiveConf) (MsgHandler, error) {
+		return handler.NewModeration2dbHandler(conf)
+	},
+	// publish 需要在直播间的 handlers 中显式配置，不包含在默认 handler 中
+	"publish": func(conf *model.LiveConf) (MsgHandler, error) {
+		return handler.NewDymsg2busHandler(conf)
+	},
+}
+
+// FlushHandler 缓存消息后批量写入的 handler，连接关闭时写入剩余数据
+type FlushHandler interface {
+	Flush() error
+}
+
+// ModeratedHandler 需要跳过被 moderation 排除的弹幕的 handler
+type ModeratedHandler interface {
+	SetModerator(m handler.Moderator)
+}
+
+// newHandlers 根据配置创建 handler 并返回对应的名称，未配置时使用 DefaultHandlers，并始终附加分发实时订阅的 hub
+// moderation 总是排在最前面，其他 handler 处理弹幕时已经得到检测结果
+func newHandlers(conf *model.LiveConf) ([]MsgHandler, []string, error) {
+	names := conf.HandlerNames()
+	if len(names) == 0 {
+		names = defaultHandlers()
+	}
+	names = moderationFirst(names)
+	handlers := make([]MsgHandler, 0, len(names))
+	var moderator handler.Moderator
+	for _, name := range names {
+		factory, ok := handlerFactories[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnknownHandler, name)
+		}
+		h, err := factory(conf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create handler %s error: %w", name, err)
+		}
+		if m, ok := h.(handler.Moderator); ok {
+			moderator = m
+		} else if mh, ok := h.(ModeratedHandler); ok && moderator != nil {
+			mh.SetModerator(moderator)
+		}
+		handlers = append(handlers, h)
+	}
+	// hub 不需要配置，供 SubscribeEvents 实时订阅，没有订阅时不解析消息
+	hub, err := handler.NewDymsg2hubHandler(conf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create handler hub error: %w", err)
+	}
+	return append(handlers, hub), append(names, "hub"), nil
+}
+
+// moderationFirst 将 moderation 移到最前面
+func moderationFirst(names []string) []string {
+	for i, name := range names {
+		if name == "moderation" && i > 0 {
+			sorted := make([]string, 0, len(names))
+			sorted = append(sorted, name)
+			sorted = append(sorted, names[:i]...)
+			return append(sorted, names[i+1:]...)
+		}
+	}
+	return names
+}
+
+// defaultHandlers [handlers] Default 或 DefaultHandlers，
+// 启用 [analytics] 或 [redis] 时默认同时写入分析库和 redis，启用 [moderation] 时检测刷屏弹幕
+func defaultHandlers() []string {
+	names := DefaultHandlers[:len(DefaultHandlers):len(DefaultHandlers)]
+	// 与 LiveConf.Handlers 格式相同
+	if configured := (&model.LiveConf{Handlers: setting.HandlersSetting.Default}).HandlerNames(); len(configured) > 0 {
+		names = configured
+	}
+	if analytics.Enabled() {
+		names = append(names, "analytics")
+	}
+	if realtime.Enabled() {
+		names = append(names, "redis")
+	}
+	if setting.ModerationSetting.Enable {
+		names = append(names, "moderation")
+	}
+	return names
+}
+
+// ValidateHandlers 检查 handler 名称是否都已注册
+func ValidateHandlers(names []string) error {
+	for _, name := range names {
+		if _, ok := handlerFactories[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownHandler, name)
+		}
+	}
+	return nil
+}
+
+// flushHandlers 连接关闭时调用，避免停止任务或下播时丢失缓存的数据
+func (c *Client) flushHandlers() {
+	for _, h := range c.handlers {
+		if fh, ok := h.(FlushHandler); ok {
+			if err := fh.Flush(); err != nil {
+				logger.Warn().Str("liveurl", c.liveurl).Err(err).Str("handler", handlerName(h)).Msg("flush handler error")
+			}
+		}
+	}
+}
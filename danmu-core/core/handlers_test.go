@@ -0,0 +1,22 @@
+package core
+
+import (
+	"danmu-core/setting"
+	"slices"
+	"testing"
+)
+
+func TestDefaultHandlers(t *testing.T) {
+	if got := defaultHandlers(); !slices.Equal(got, []string{"db"}) {
+		t.Errorf("defaultHandlers = %v, want [db]", got)
+	}
+
+	setting.HandlersSetting.Default = " db, room ,stats,"
+	defer func() { setting.HandlersSetting.Default = "" }()
+	if got := defaultHandlers(); !slices.Equal(got, []string{"db", "room", "stats"}) {
+		t.Errorf("defaultHandlers = %v, want [db room stats]", got)
+	}
+	if err := ValidateHandlers(defaultHandlers()); err != nil {
+		t.Errorf("ValidateHandlers: %v", err)
+	}
+}
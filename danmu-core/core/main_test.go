@@ -0,0 +1,17 @@
+package core
+
+import (
+	"danmu-core/internal/model"
+	"danmu-core/internal/testenv"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	cleanup := testenv.Setup()
+	model.Init()
+	code := m.Run()
+	model.Close()
+	cleanup()
+	os.Exit(code)
+}
@@ -0,0 +1,124 @@
+package bogus_test
+
+import (
+	"crypto/md5"
+	"danmu-core/core/platform/douyin/bogus"
+	"danmu-core/core/platform/douyin/jsScript"
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+
+// goldenVectors 由 webmssdk.js 1.0.0.53 生成，Index 超过 63 时只保留低 6 位
+var goldenVectors = []struct {
+	params bogus.Params
+	sign   string
+}{
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 1, Bit: 1, Salt: 9, Key: 17, Stub: "89271fdab90dc9957375c493acf1711e"}, "6k407jV2/WbjDw73"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 2, Bit: 0, Salt: 129, Key: 40, Stub: "c4ca4238a0b923820dcc509a6f75849b"}, "fdNz8+upIMVoyxRH"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 3, Bit: 1, Salt: 10, Key: 107, Stub: "693a9fdd4c2fd0700968fba0d07ff3c0"}, "64zuVOhlEFdJV2h7"},
+	{bogus.Params{Kind: bogus.KindHttp, Index: 4, Bit: 0, Salt: 158, Key: 23, Stub: ""}, "DkWDY1gWs/20PeLY"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 5, Bit: 1, Salt: 33, Key: 182, Stub: "5ce386d1f2b55721d3ae10162ea6df13"}, "6B2YWMgrGlmuxBn1"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 6, Bit: 0, Salt: 217, Key: 92, Stub: "e4da3b7fbbce2345d7772b0674a318d5"}, "fhnoVBAr5LyEyTc7"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 63, Bit: 1, Salt: 181, Key: 217, Stub: "ece0a90e0ac82a7762bd413b3b8e081a"}, "60o+9/cJk8xfM/LQ"},
+	{bogus.Params{Kind: bogus.KindHttp, Index: 64, Bit: 1, Salt: 241, Key: 246, Stub: ""}, "gM5ce8YShx+hltlO"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 65, Bit: 1, Salt: 193, Key: 78, Stub: "ceae594646beadea5466fb368d860650"}, "6gVDx7dE9ULP4eVK"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 66, Bit: 1, Salt: 29, Key: 24, Stub: "fc490ca45c00b1249bbe3554a4fdf6fb"}, "6k7dMWh+wz1NwL8C"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 67, Bit: 1, Salt: 182, Key: 199, Stub: "2fdc411ceb06840c386ed1d6f200a135"}, "68WciIr+hAQSP1Ub"},
+	{bogus.Params{Kind: bogus.KindHttp, Index: 68, Bit: 0, Salt: 140, Key: 148, Stub: ""}, "Dsw+cYRWALRwnHHq"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 69, Bit: 1, Salt: 233, Key: 176, Stub: "20146b6546fd6827ab0ff1b99547c775"}, "6BDvc0uX0UKB2raS"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 70, Bit: 0, Salt: 33, Key: 171, Stub: "14bfa6bb14875e45bba028a21ed38046"}, "fQFtafhxyHn/7Iyd"},
+	{bogus.Params{Kind: bogus.KindWebsocket, Index: 71, Bit: 1, Salt: 243, Key: 164, Stub: "28fc5ccbacecd67043bf64aa94618460"}, "6QvJ87msZ9R8kGiD"},
+	{bogus.Params{Kind: bogus.KindHttp, Index: 72, Bit: 0, Salt: 10, Key: 162, Stub: ""}, "DQKIh4+oM8JvQRdB"},
+}
+
+func TestSignGoldenVectors(t *testing.T) {
+	for _, v := range goldenVectors {
+		if got := bogus.Sign(v.params); got != v.sign {
+			t.Errorf("Sign(%+v) = %s, want %s", v.params, got, v.sign)
+		}
+	}
+}
+
+func TestDecodeGoldenVectors(t *testing.T) {
+	for _, v := range goldenVectors {
+		p, err := bogus.Decode(v.sign)
+		if err != nil {
+			t.Errorf("Decode(%s): %v", v.sign, err)
+			continue
+		}
+		want := v.params
+		want.Index &= 63
+		want.Stub = ""
+		if p != want {
+			t.Errorf("Decode(%s) = %+v, want %+v", v.sign, p, want)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, sign := range []string{
+		"not base64 !!",
+		"6k407jV2",         // 长度不足
+		"6k407jV2/WbjDw74", // 校验和不一致
+	} {
+		if _, err := bogus.Decode(sign); err == nil {
+			t.Errorf("Decode(%q) = nil, want error", sign)
+		}
+	}
+}
+
+func TestSignerIndex(t *testing.T) {
+	s := bogus.New()
+	for i := 1; i <= 70; i++ {
+		var sign string
+		kind := bogus.KindWebsocket
+		if i%2 == 0 {
+			sign, kind = s.GetXBogus(), bogus.KindHttp
+		} else {
+			sign = s.GetSign("c4ca4238a0b923820dcc509a6f75849b")
+		}
+		p, err := bogus.Decode(sign)
+		if err != nil {
+			t.Fatalf("Decode(%s): %v", sign, err)
+		}
+		if p.Kind != kind || p.Index != i&63 {
+			t.Fatalf("sign %d: kind %d index %d, want kind %d index %d", i, p.Kind, p.Index, kind, i&63)
+		}
+	}
+}
+
+// TestSignMatchesGoja webmssdk.js 的计数和随机数无法从外部指定，
+// 解析 goja 生成的签名得到这些值后由 bogus 重新生成，两者必须完全一致。
+// 替换 webmssdk.js 后失败说明签名算法有变化，需要同步修改 bogus 或使用 [douyin] Signer = "goja"
+func TestSignMatchesGoja(t *testing.T) {
+	gd, err := jsScript.LoadGoja(userAgent)
+	if err != nil {
+		t.Fatalf("LoadGoja: %v", err)
+	}
+	for i := 1; i <= 130; i++ {
+		var sign, stub string
+		kind := bogus.KindWebsocket
+		if i%4 == 0 {
+			kind = bogus.KindHttp
+			sign = gd.GetXBogus()
+		} else {
+			h := md5.Sum([]byte(strconv.Itoa(i)))
+			stub = hex.EncodeToString(h[:])
+			sign = gd.GetSign(stub)
+		}
+		p, err := bogus.Decode(sign)
+		if err != nil {
+			t.Fatalf("Decode goja sign %s: %v", sign, err)
+		}
+		if p.Kind != kind || p.Index != i&63 {
+			t.Errorf("goja sign %d: kind %d index %d, want kind %d index %d", i, p.Kind, p.Index, kind, i&63)
+		}
+		p.Stub = stub
+		if got := bogus.Sign(p); got != sign {
+			t.Errorf("sign %d stub %q: goja %s, bogus %s", i, stub, sign, got)
+		}
+	}
+}
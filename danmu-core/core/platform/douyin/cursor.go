@@ -0,0 +1,271 @@
+package platform
+
+import (
+	"danmu-core/generated/dystruct"
+	"danmu-core/logger"
+	"danmu-core/setting"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultResumeWindow = 5 * time.Minute
+	cursorSaveInterval  = 30 * time.Second
+	// replayTolerance 携带 cursor 重连后第一个 Response 的 cursor 比保存的断点晚超过该时间 (且超过两个拉取间隔) 时，
+	// 认为服务端没有从断点补发
+	replayTolerance = 10 * time.Second
+)
+
+// 缺口的原因，作为 coverage_holes.reason 和指标 label
+const (
+	// GapExpired 断开时间超过 ResumeWindow，没有携带 cursor 重连，断开期间的消息没有补发
+	GapExpired = "expired"
+	// GapNotReplayed 携带 cursor 重连，但服务端从最新的位置开始推送，没有补发断开期间的消息
+	GapNotReplayed = "not_replayed"
+)
+
+// Cursor 最近一次推送的断点，重连时携带 cursor 和 internal_ext，服务端补发断开期间的消息
+type Cursor struct {
+	RoomID        string
+	Cursor        string
+	InternalExt   string
+	FetchInterval time.Duration // 服务端建议的拉取间隔
+	ServerTime    time.Time     // Response.now
+	ReceivedAt    time.Time
+}
+
+// Gap 断开期间没有被补发的时间范围，使用服务端时间
+type Gap struct {
+	RoomID string
+	Start  time.Time
+	End    time.Time
+	Reason string
+}
+
+// 每个直播间最近的断点，按直播间标识保存，更新任务后新的连接继续使用；
+// 配置 [douyin] CursorFile 时定时写入文件，重启后同一场直播可以继续补发或记录重启期间的缺口
+var (
+	cursorsMu    sync.Mutex
+	cursors      = make(map[string]*Cursor)
+	cursorsDirty bool
+	cursorFile   string
+	cursorStop   chan struct{}
+	cursorDone   chan struct{}
+)
+
+// InitCursors 读取 [douyin] CursorFile 中保存的断点，并定时写入文件；CursorFile 为空时只在内存中保存
+func InitCursors() error {
+	cursorsMu.Lock()
+	defer cursorsMu.Unlock()
+	cursorFile = setting.DouyinSetting.CursorFile
+	if cursorFile == "" {
+		logger.Warn().Msg("douyin cursor file is empty, coverage holes across restarts will not be recorded")
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cursorFile), 0755); err != nil {
+		return fmt.Errorf("create cursor dir error: %w", err)
+	}
+	data, err := os.ReadFile(cursorFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &cursors); err != nil {
+			logger.Warn().Err(err).Str("path", cursorFile).Msg("load douyin cursors failed")
+			cursors = make(map[string]*Cursor)
+		}
+	}
+	cursorStop = make(chan struct{})
+	cursorDone = make(chan struct{})
+	go saveCursorsLoop(cursorStop, cursorDone)
+	return nil
+}
+
+// CloseCursors 停止定时写入并保存所有断点，退出时调用
+func CloseCursors() {
+	if cursorStop == nil {
+		return
+	}
+	close(cursorStop)
+	<-cursorDone
+	cursorStop = nil
+	if err := saveCursors(); err != nil {
+		logger.Warn().Err(err).Str("path", cursorFile).Msg("save douyin cursors failed")
+	}
+}
+
+// RemoveCursor 删除任务时调用，之后重新添加该直播间不再认为与之前的连接连续
+func RemoveCursor(room string) {
+	cursorsMu.Lock()
+	defer cursorsMu.Unlock()
+	if _, ok := cursors[room]; ok {
+		delete(cursors, room)
+		cursorsDirty = true
+	}
+}
+
+func loadCursor(room string) *Cursor {
+	cursorsMu.Lock()
+	defer cursorsMu.Unlock()
+	return cursors[room]
+}
+
+func storeCursor(room string, cur *Cursor) {
+	cursorsMu.Lock()
+	defer cursorsMu.Unlock()
+	cursors[room] = cur
+	cursorsDirty = true
+}
+
+func saveCursorsLoop(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(cursorSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := saveCursors(); err != nil {
+				logger.Warn().Err(err).Str("path", cursorFile).Msg("save douyin cursors failed")
+			}
+		}
+	}
+}
+
+// saveCursors 先写临时文件再重命名，避免中途退出留下不完整的文件
+func saveCursors() error {
+	cursorsMu.Lock()
+	if cursorFile == "" || !cursorsDirty {
+		cursorsMu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(cursors)
+	cursorsDirty = false
+	path := cursorFile
+	cursorsMu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Cursor 返回最近一次推送的断点，尚未收到推送时为 nil
+func (dy *Douyin) Cursor() *Cursor {
+	return dy.cursor.Load()
+}
+
+// OnGap 设置重连后发现缺口时的回调，在读取 websocket 或轮询的协程中调用
+func (dy *Douyin) OnGap(f func(Gap)) {
+	dy.onGap = f
+}
+
+// updateCursor 保存每个 Response 的断点，连接后的第一个 Response 用于判断断开期间是否有缺口
+func (dy *Douyin) updateCursor(resp *dystruct.Webcast_Im_Response) {
+	now := time.UnixMilli(int64(resp.Now))
+	if resp.Now == 0 {
+		now = time.Now()
+	}
+	if resumed := dy.resumed.Swap(nil); resumed != nil && !replayed(resumed, resp.Cursor) {
+		dy.pending.Store(&Gap{RoomID: resumed.RoomID, Start: resumed.ServerTime, Reason: GapNotReplayed})
+	}
+	if pending := dy.pending.Swap(nil); pending != nil && dy.onGap != nil && now.After(pending.Start) {
+		pending.End = now
+		dy.onGap(*pending)
+	}
+	if resp.Cursor == "" {
+		return
+	}
+	cur := &Cursor{
+		RoomID:        dy.roomId,
+		Cursor:        resp.Cursor,
+		InternalExt:   resp.InternalExt,
+		FetchInterval: time.Duration(resp.FetchInterval) * time.Millisecond,
+		ServerTime:    now,
+		ReceivedAt:    time.Now(),
+	}
+	dy.cursor.Store(cur)
+	storeCursor(dy.room, cur)
+}
+
+// resumeParams 建立连接前调用，断点属于当前场次且未过期时返回 cursor 和 internal_ext，
+// 否则记录待确认的缺口，收到新连接的第一个 Response 时确定结束时间
+func (dy *Douyin) resumeParams() url.Values {
+	dy.pending.Store(nil)
+	dy.resumed.Store(nil)
+	cur := dy.cursor.Load()
+	if cur == nil || cur.RoomID != dy.roomId {
+		// 新的场次或第一次连接，之前没有需要补发的消息
+		return nil
+	}
+	window := time.Duration(setting.DouyinSetting.ResumeWindow) * time.Second
+	if window <= 0 {
+		window = defaultResumeWindow
+	}
+	if time.Since(cur.ReceivedAt) > window {
+		dy.pending.Store(&Gap{RoomID: cur.RoomID, Start: cur.ServerTime, Reason: GapExpired})
+		return nil
+	}
+	dy.resumed.Store(cur)
+	return cur.params()
+}
+
+// cursorParams 轮询翻页时携带当前场次的断点，不改变缺口的检查状态；
+// 断点已过期、还没有收到新的 Response 时与第一次请求一样不携带
+func (dy *Douyin) cursorParams() url.Values {
+	cur := dy.cursor.Load()
+	if cur == nil || cur.RoomID != dy.roomId || dy.pending.Load() != nil {
+		return nil
+	}
+	return cur.params()
+}
+
+func (cur *Cursor) params() url.Values {
+	params := url.Values{}
+	params.Set("cursor", cur.Cursor)
+	params.Set("internal_ext", cur.InternalExt)
+	return params
+}
+
+// replayed 判断携带 cursor 重连后服务端是否从断点补发: 补发时第一个 Response 从保存的断点继续，
+// cursor 中的时间 (t-<毫秒>) 接近保存的断点；没有补发时直接跳到最新的位置。cursor 无法解析时认为已补发
+func replayed(saved *Cursor, next string) bool {
+	from, ok := cursorTime(saved.Cursor)
+	if !ok {
+		return true
+	}
+	to, ok := cursorTime(next)
+	if !ok {
+		return true
+	}
+	return to.Sub(from) <= max(replayTolerance, 2*saved.FetchInterval)
+}
+
+// cursorTime 解析 cursor 中的 t-<毫秒> 字段，如 r-7301..._d-1_u-1_t-1700000000000
+func cursorTime(cursor string) (time.Time, bool) {
+	for _, field := range strings.Split(cursor, "_") {
+		v, ok := strings.CutPrefix(field, "t-")
+		if !ok {
+			continue
+		}
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms <= 0 {
+			return time.Time{}, false
+		}
+		return time.UnixMilli(ms), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,158 @@
+package platform
+
+import (
+	"danmu-core/generated/dystruct"
+	"danmu-core/setting"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func cursorAt(ms int64) string {
+	return fmt.Sprintf("r-7301_d-1_u-1_t-%d", ms)
+}
+
+func newCursorDouyin(room string) (*Douyin, *[]Gap) {
+	gaps := &[]Gap{}
+	dy := &Douyin{room: room, roomId: "100"}
+	if cur := loadCursor(room); cur != nil {
+		dy.cursor.Store(cur)
+	}
+	dy.OnGap(func(g Gap) { *gaps = append(*gaps, g) })
+	return dy, gaps
+}
+
+func response(ms int64) *dystruct.Webcast_Im_Response {
+	return &dystruct.Webcast_Im_Response{Cursor: cursorAt(ms), Now: uint64(ms), FetchInterval: 1000}
+}
+
+func TestResumeAfterUpdateKeepsCursor(t *testing.T) {
+	dy, _ := newCursorDouyin("resume-update")
+	dy.updateCursor(response(1_700_000_000_000))
+
+	// 更新任务后新的实例继续使用同一直播间的断点
+	next, gaps := newCursorDouyin("resume-update")
+	params := next.resumeParams()
+	if params.Get("cursor") != cursorAt(1_700_000_000_000) {
+		t.Fatalf("resume params = %v, want saved cursor", params)
+	}
+	next.updateCursor(response(1_700_000_002_000))
+	if len(*gaps) != 0 {
+		t.Errorf("gaps = %+v, want none after replay", *gaps)
+	}
+
+	RemoveCursor("resume-update")
+	if dy, _ := newCursorDouyin("resume-update"); dy.resumeParams() != nil {
+		t.Error("cursor should be cleared when the task is deleted")
+	}
+}
+
+func TestResumeNotReplayed(t *testing.T) {
+	dy, gaps := newCursorDouyin("not-replayed")
+	dy.updateCursor(response(1_700_000_000_000))
+	if dy.resumeParams() == nil {
+		t.Fatal("want resume params")
+	}
+	// 服务端没有补发，直接推送一分钟后的位置
+	dy.updateCursor(response(1_700_000_060_000))
+	if len(*gaps) != 1 {
+		t.Fatalf("gaps = %+v, want one", *gaps)
+	}
+	g := (*gaps)[0]
+	if g.Reason != GapNotReplayed || g.Start.UnixMilli() != 1_700_000_000_000 || g.End.UnixMilli() != 1_700_000_060_000 {
+		t.Errorf("gap = %+v, want not_replayed from the saved cursor", g)
+	}
+	// 只检查重连后的第一个 Response
+	dy.updateCursor(response(1_700_000_120_000))
+	if len(*gaps) != 1 {
+		t.Errorf("gaps = %+v, want only one", *gaps)
+	}
+}
+
+func TestResumeExpired(t *testing.T) {
+	dy, gaps := newCursorDouyin("expired")
+	dy.updateCursor(response(1_700_000_000_000))
+	cur := *dy.cursor.Load()
+	cur.ReceivedAt = time.Now().Add(-time.Hour)
+	dy.cursor.Store(&cur)
+	if dy.resumeParams() != nil {
+		t.Fatal("expired cursor should not be sent")
+	}
+	dy.updateCursor(response(1_700_003_600_000))
+	if len(*gaps) != 1 || (*gaps)[0].Reason != GapExpired {
+		t.Errorf("gaps = %+v, want one expired gap", *gaps)
+	}
+}
+
+func TestCursorTime(t *testing.T) {
+	for _, tc := range []struct {
+		cursor string
+		ms     int64
+		ok     bool
+	}{
+		{"t-1700000000000_r-1_d-1_u-1", 1700000000000, true},
+		{"r-7301_d-1_u-1_h-7301_t-1700000000001", 1700000000001, true},
+		{"r-7301_d-1_u-1", 0, false},
+		{"t-abc_r-1", 0, false},
+	} {
+		got, ok := cursorTime(tc.cursor)
+		if ok != tc.ok || (ok && got.UnixMilli() != tc.ms) {
+			t.Errorf("cursorTime(%q) = %v, %v, want %d, %v", tc.cursor, got.UnixMilli(), ok, tc.ms, tc.ok)
+		}
+	}
+}
+
+func TestCursorFileSurvivesRestart(t *testing.T) {
+	old := setting.DouyinSetting.CursorFile
+	setting.DouyinSetting.CursorFile = filepath.Join(t.TempDir(), "data", "cursors.json")
+	defer func() { setting.DouyinSetting.CursorFile = old }()
+
+	if err := InitCursors(); err != nil {
+		t.Fatal(err)
+	}
+	dy, _ := newCursorDouyin("restart")
+	dy.updateCursor(response(1_700_000_000_000))
+	CloseCursors()
+
+	cursorsMu.Lock()
+	cursors = make(map[string]*Cursor)
+	cursorsMu.Unlock()
+	if err := InitCursors(); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseCursors()
+	next, _ := newCursorDouyin("restart")
+	if got := next.resumeParams().Get("cursor"); got != cursorAt(1_700_000_000_000) {
+		t.Errorf("cursor after restart = %q, want the saved one", got)
+	}
+}
+
+func TestPollPagesDoNotRecordGaps(t *testing.T) {
+	dy, gaps := newCursorDouyin("poll-pages")
+	dy.updateCursor(response(1_700_000_000_000))
+	cur := *dy.cursor.Load()
+	cur.ReceivedAt = time.Now().Add(-time.Hour)
+	dy.cursor.Store(&cur)
+
+	// 切换到轮询后的第一次请求按重连处理，请求失败后的翻页不清除待确认的缺口
+	if dy.resumeParams() != nil {
+		t.Fatal("expired cursor should not be sent")
+	}
+	if dy.cursorParams() != nil {
+		t.Fatal("expired cursor should not be sent before a new response")
+	}
+	dy.updateCursor(response(1_700_003_600_000))
+	if len(*gaps) != 1 || (*gaps)[0].Reason != GapExpired {
+		t.Fatalf("gaps = %+v, want one expired gap", *gaps)
+	}
+
+	// 慢请求的下一页比上一页晚很多，不是缺口
+	if got := dy.cursorParams().Get("cursor"); got != cursorAt(1_700_003_600_000) {
+		t.Fatalf("page cursor = %q, want the latest one", got)
+	}
+	dy.updateCursor(response(1_700_003_660_000))
+	if len(*gaps) != 1 {
+		t.Errorf("gaps = %+v, want no gap for a slow page", *gaps)
+	}
+}
@@ -3,14 +3,17 @@ package platform
 import (
 	"bytes"
 	"context"
-	"danmu-core/core/platform/douyin/jsScript"
+	"danmu-core/core/platform/douyin/session"
 	"danmu-core/generated/douyin"
 	"danmu-core/generated/dystruct"
+	"danmu-core/internal/proxy"
 	"danmu-core/logger"
+	"danmu-core/metrics"
 	"danmu-core/utils"
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/imroc/req/v3"
@@ -20,44 +23,60 @@ import (
 
 const (
 	gzipBufferSize = 1024 * 4
+	// 触发风控时响应中带有验证码参数，body 为空
+	captchaHeader = "X-Vc-Bdturing-Parameters"
 )
 
 type Douyin struct {
 	ua         string
-	ttwid      string
+	cookie     *session.Cookie // 与其他直播间共用，定时刷新
 	roomId     string
 	webRid     string
 	secUid     string
 	liveurl    string
+	room       string
 	bufferPool *sync.Pool
 	client     *req.Client
-	header     map[string]string
-	gd         *jsScript.GojaDouyin
+	signer     Signer
+	streamer   atomic.Pointer[StreamerInfo]
+	roomInfo   atomic.Pointer[RoomInfo]
+	cursor     atomic.Pointer[Cursor]
+	pending    atomic.Pointer[Gap]    // 本次连接没有携带 cursor 时断开期间的缺口，等待第一个 Response 确定结束时间
+	resumed    atomic.Pointer[Cursor] // 本次连接携带的断点，第一个 Response 用于确认服务端是否补发
+	onGap      func(Gap)
 }
 
-func NewDouyinPlatform(liveurl string) (*Douyin, error) {
-	ua := utils.RandomUserAgent()
-	ttwid, err := getTTWID()
+func NewDouyinPlatform(liveurl, room string) (*Douyin, error) {
+	sess, err := session.Acquire()
 	if err != nil {
 		return nil, fmt.Errorf("fetch ttwid error: %w", err)
 	}
 	dy := &Douyin{
-		ua:         ua,
-		ttwid:      ttwid,
-		client:     req.C(),
+		ua:         sess.UserAgent,
+		cookie:     sess.Cookie,
+		client:     req.C().SetProxy(proxy.Func(room)),
 		bufferPool: &sync.Pool{New: func() interface{} { return bytes.NewBuffer(make([]byte, 0, gzipBufferSize)) }},
 		liveurl:    liveurl,
+		room:       room,
 	}
-	dy.gd, err = jsScript.LoadGoja(ua)
+	// 更新任务或重启后继续使用同一直播间之前的断点
+	if cur := loadCursor(room); cur != nil {
+		dy.cursor.Store(cur)
+	}
+	dy.signer, err = newSigner(room, dy.ua)
 	if err != nil {
-		return nil, fmt.Errorf("init goja js error: %w", err)
+		return nil, err
 	}
-	dy.header = map[string]string{
-		"User-Agent": ua,
+	return dy, nil
+}
+
+// header http 请求头，每次请求时读取 ttwid 以使用刷新后的值
+func (dy *Douyin) header() map[string]string {
+	return map[string]string{
+		"User-Agent": dy.ua,
 		"Referer":    "https://live.douyin.com/",
-		"Cookie":     fmt.Sprintf("ttwid=%s", dy.ttwid),
+		"Cookie":     fmt.Sprintf("ttwid=%s", dy.cookie.TTWID()),
 	}
-	return dy, nil
 }
 
 func (dy *Douyin) GetHeartbeatValue() (interval time.Duration, hb []byte) {
@@ -72,7 +91,7 @@ func (dy *Douyin) GetWsInfo() (url string, headers http.Header, err error) {
 	url, err = dy.getDouyinWsUrl()
 	headers = http.Header{}
 	headers.Set("User-Agent", dy.ua)
-	headers.Set("cookie", fmt.Sprintf("ttwid=%s", dy.ttwid))
+	headers.Set("cookie", fmt.Sprintf("ttwid=%s", dy.cookie.TTWID()))
 	return url, headers, err
 }
 
@@ -91,9 +110,35 @@ func (dy *Douyin) DecodeMsg(data []byte, RecvChan chan interface{}, ctx context.
 	if err := proto.Unmarshal(decompressed, &response); err != nil {
 		return nil, fmt.Errorf("unmarshal response error: %w", err)
 	}
+	dy.handleResponse(&response, RecvChan, cf)
+
+	if response.NeedAck {
+		ackFrame := &dystruct.Webcast_Im_PushFrame{
+			LogID:       pushFrame.LogID,
+			PayloadType: "ack",
+			Payload:     []byte(response.InternalExt),
+		}
+		ack, err = proto.Marshal(ackFrame)
+		if err != nil {
+			logger.Warn().Err(err).Msg("marshal ack frame error")
+			return nil, nil
+		}
+	}
+
+	return ack, nil
+}
+
+// handleResponse websocket 和轮询共用，保存断点并将消息写入 RecvChan，收到直播结束的控制消息时关闭连接
+func (dy *Douyin) handleResponse(response *dystruct.Webcast_Im_Response, RecvChan chan interface{}, cf context.CancelFunc) {
+	dy.updateCursor(response)
 
 	needClose := false
 	for _, msg := range response.Messages {
+		if IsKnownMethod(msg.Method) {
+			metrics.MessagesReceived.WithLabelValues(dy.room, msg.Method).Inc()
+		} else {
+			metrics.UnknownMethods.WithLabelValues(dy.room, msg.Method).Inc()
+		}
 		if msg.Method == WebcastControlMessage {
 			controlMsg := &douyin.ControlMessage{}
 			err := proto.Unmarshal(msg.Payload, controlMsg)
@@ -110,38 +155,43 @@ func (dy *Douyin) DecodeMsg(data []byte, RecvChan chan interface{}, ctx context.
 	if needClose {
 		cf()
 	}
-
-	var ackData []byte
-	if response.NeedAck {
-		ackFrame := &dystruct.Webcast_Im_PushFrame{
-			LogID:       pushFrame.LogID,
-			PayloadType: "ack",
-			Payload:     []byte(response.InternalExt),
-		}
-		ack, err = proto.Marshal(ackFrame)
-		if err != nil {
-			logger.Warn().Err(err).Msg("marshal ack frame error")
-		}
-	}
-
-	return ackData, nil
 }
 
 func (dy *Douyin) CheckStream() (bool, error) {
-	webRidMatches := webRidRg.FindStringSubmatch(dy.liveurl)
-	if len(webRidMatches) <= 1 {
-		return false, fmt.Errorf("未找到 web_rid")
+	// 检查失败时不保留上一次的直播间信息
+	dy.roomInfo.Store(nil)
+	if dy.webRid == "" {
+		webRidMatches := webRidRg.FindStringSubmatch(dy.liveurl)
+		if len(webRidMatches) <= 1 {
+			return false, fmt.Errorf("未找到 web_rid")
+		}
+		dy.webRid = webRidMatches[1]
 	}
-	dy.webRid = webRidMatches[1]
 	info, err := dy.getWebRoomInfo(dy.webRid)
 	if err != nil {
 		return false, err
 	}
 	user := info.Get("data.user")
+	if !user.Exists() && dy.secUid != "" {
+		// web_rid 失效时通过已知的 sec_uid 查找主播新的直播间
+		webRid, err := dy.webRidBySecUid(dy.secUid)
+		if err != nil {
+			return false, fmt.Errorf("未找到用户信息: %w", err)
+		}
+		if webRid != dy.webRid {
+			logger.Info().Str("liveurl", dy.liveurl).Str("old_web_rid", dy.webRid).Str("web_rid", webRid).Msg("主播 web_rid 已变更")
+			dy.webRid = webRid
+			if info, err = dy.getWebRoomInfo(dy.webRid); err != nil {
+				return false, err
+			}
+			user = info.Get("data.user")
+		}
+	}
 	if !user.Exists() {
 		return false, fmt.Errorf("未找到用户信息: %s", info.Raw)
 	}
 	dy.secUid = user.Get("sec_uid").String()
+	defer dy.storeStreamer(user)
 
 	var finalRoomInfo gjson.Result
 
@@ -153,6 +203,7 @@ func (dy *Douyin) CheckStream() (bool, error) {
 		return false, fmt.Errorf("room info is not exist: %s", info.Raw)
 	}
 	dy.roomId = finalRoomInfo.Get("id_str").String()
+	dy.storeRoomInfo(finalRoomInfo)
 	status := finalRoomInfo.Get("status").Int()
 	if status != 2 {
 		return false, fmt.Errorf("未开播")
@@ -162,28 +213,71 @@ func (dy *Douyin) CheckStream() (bool, error) {
 }
 
 func (dy *Douyin) getWebRoomInfo(webRid string) (gjson.Result, error) {
-	targetURL, err := dy.BuildRequestURL(fmt.Sprintf("https://live.douyin.com/webcast/room/web/enter/?web_rid=%s", webRid))
+	targetURL, err := dy.SignRequestURL(fmt.Sprintf("https://live.douyin.com/webcast/room/web/enter/?web_rid=%s", webRid))
 	if err != nil {
 		return gjson.Result{}, fmt.Errorf("build request url error: %w", err)
 	}
-	resp, err := dy.client.R().SetHeaders(dy.header).Get(targetURL)
+	if err := session.Wait(context.Background()); err != nil {
+		return gjson.Result{}, err
+	}
+	resp, err := dy.client.R().SetHeaders(dy.header()).Get(targetURL)
 	if err != nil {
 		return gjson.Result{}, fmt.Errorf("请求失败: %w", err)
 	}
+	dy.checkBlocked(resp.Response)
 
 	if resp.StatusCode != 200 {
 		return gjson.Result{}, fmt.Errorf("请求返回状态码: %d", resp.StatusCode)
 	}
+	body := resp.String()
+	// 未通过校验时返回 200 和空 body，要求验证码时是风控，与签名无关
+	if resp.Header.Get(captchaHeader) == "" {
+		dy.reportSign(body != "")
+	}
+
+	return gjson.Parse(body), nil
+}
+
+// Handshake websocket 握手的结果，服务端拒绝握手 (网络错误、403 风控、429 和 5xx 除外) 时按签名被拒绝处理
+func (dy *Douyin) Handshake(resp *http.Response, err error) {
+	switch {
+	case err == nil:
+		dy.reportSign(true)
+	case resp == nil, resp.StatusCode == http.StatusForbidden,
+		resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+	default:
+		dy.reportSign(false)
+	}
+}
 
-	return gjson.Parse(resp.String()), nil
+// checkBlocked 返回 403 或要求验证码时切换代理，本次请求仍按失败处理，下次请求使用新代理
+func (dy *Douyin) checkBlocked(resp *http.Response) {
+	if !proxy.Enabled() {
+		return
+	}
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		proxy.Rotate(dy.room, proxy.ReasonBlocked)
+	case resp.Header.Get(captchaHeader) != "":
+		proxy.Rotate(dy.room, proxy.ReasonCaptcha)
+	}
 }
 
 func (dy *Douyin) getDouyinWsUrl() (string, error) {
 	uniqueId := utils.GetUserUniqueID()
 	smap := NewSigMap(dy.roomId, uniqueId)
 	signaturemd5 := GetxMSStub(smap)
-	signature := dy.gd.GetSign(signaturemd5)
+	signature := dy.signer.GetSign(signaturemd5)
 	baseURl := "wss://webcast5-ws-web-lf.douyin.com/webcast/im/push/v2/"
-	initialWss := baseURl + "?" + NewWebCast5Param(dy.roomId, uniqueId, signature).Encode()
+	params := NewWebCast5Param(dy.roomId, uniqueId, signature)
+	// cursor 和 internal_ext 不参与签名
+	resume := dy.resumeParams()
+	if resume != nil {
+		metrics.WebsocketResumes.WithLabelValues(dy.room).Inc()
+	}
+	for k, v := range resume {
+		params[k] = v
+	}
+	initialWss := baseURl + "?" + params.Encode()
 	return dy.BuildRequestURL(initialWss)
 }
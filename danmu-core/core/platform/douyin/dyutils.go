@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"github.com/elliotchance/orderedmap"
 	"io"
-	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
@@ -19,20 +18,6 @@ var (
 	webRidRg = regexp.MustCompile(`douyin\.com/.*?(\d+)(?:\?.*)?$`)
 )
 
-func getTTWID() (string, error) {
-	res, err := http.Get("https://live.douyin.com/")
-	if err != nil {
-		return "", fmt.Errorf("获取直播 URL 失败: %w", err)
-	}
-
-	for _, cookie := range res.Cookies() {
-		if cookie.Name == "ttwid" {
-			return cookie.Value, nil
-		}
-	}
-	return "", fmt.Errorf("未找到 ttwid cookie")
-}
-
 func (dy *Douyin) BuildRequestURL(rawURL string) (string, error) {
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
@@ -67,6 +52,20 @@ func (dy *Douyin) BuildRequestURL(rawURL string) (string, error) {
 	return parsedURL.String(), nil
 }
 
+// SignRequestURL 在 BuildRequestURL 的参数后添加 X-Bogus，X-Bogus 与参数无关，只需追加在最后
+func (dy *Douyin) SignRequestURL(rawURL string) (string, error) {
+	target, err := dy.BuildRequestURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	parsedURL, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	parsedURL.RawQuery += "&X-Bogus=" + url.QueryEscape(dy.signer.GetXBogus())
+	return parsedURL.String(), nil
+}
+
 func (dy *Douyin) decompressGzip(data []byte) ([]byte, error) {
 	buf := dy.bufferPool.Get().(*bytes.Buffer)
 	defer func() {
@@ -131,6 +130,25 @@ func NewWebCast5Param(roomId, uniqueId, signature string) url.Values {
 	return webcast5Params
 }
 
+// NewFetchParam webcast/im/fetch 轮询接口的参数，返回 protobuf 格式的 Response
+func NewFetchParam(roomId, uniqueId string) url.Values {
+	fetchParams := url.Values{}
+	fetchParams.Set("resp_content_type", "protobuf")
+	fetchParams.Set("did_rule", "3")
+	fetchParams.Set("app_name", "douyin_web")
+	fetchParams.Set("endpoint", "live_pc")
+	fetchParams.Set("support_wrds", "1")
+	fetchParams.Set("user_unique_id", uniqueId)
+	fetchParams.Set("identity", "audience")
+	fetchParams.Set("need_persist_msg_count", "15")
+	fetchParams.Set("room_id", roomId)
+	fetchParams.Set("version_code", "180800")
+	fetchParams.Set("last_rtt", "0")
+	fetchParams.Set("live_id", "1")
+	fetchParams.Set("fetch_rule", "1")
+	return fetchParams
+}
+
 // GetxMSStub 拼接map并返回其MD5哈希值的十六进制字符串
 func GetxMSStub(params *orderedmap.OrderedMap) string {
 	var sigParams strings.Builder
@@ -0,0 +1,63 @@
+package platform
+
+import (
+	"context"
+	"danmu-core/generated/dystruct"
+	"danmu-core/utils"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	fetchURL = "https://live.douyin.com/webcast/im/fetch/"
+	// 服务端没有返回 fetch_interval 时的轮询间隔
+	defaultFetchInterval = time.Second
+)
+
+// Poll 通过 webcast/im/fetch 拉取一次消息，携带上一次的 cursor 和 internal_ext，
+// 解码后的消息与 websocket 一样写入 RecvChan，返回服务端建议的下次拉取间隔
+// 轮询请求频繁，不经过 session 的请求限速
+// resume 时与 websocket 重连一样检查断开期间的缺口；之后保存的 cursor 就是下一页，直接携带，不检查缺口
+func (dy *Douyin) Poll(ctx context.Context, RecvChan chan interface{}, cf context.CancelFunc, resume bool) (time.Duration, error) {
+	params := NewFetchParam(dy.roomId, utils.GetUserUniqueID())
+	next := dy.cursorParams()
+	if resume {
+		next = dy.resumeParams()
+	}
+	for k, v := range next {
+		params[k] = v
+	}
+	targetURL, err := dy.SignRequestURL(fetchURL + "?" + params.Encode())
+	if err != nil {
+		return 0, fmt.Errorf("build request url error: %w", err)
+	}
+	resp, err := dy.client.R().SetContext(ctx).SetHeaders(dy.header()).Get(targetURL)
+	if err != nil {
+		return 0, fmt.Errorf("请求失败: %w", err)
+	}
+	dy.checkBlocked(resp.Response)
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("请求返回状态码: %d", resp.StatusCode)
+	}
+	body := resp.Bytes()
+	if resp.Header.Get(captchaHeader) == "" {
+		dy.reportSign(len(body) > 0)
+	}
+	if len(body) == 0 {
+		// 未通过校验时返回 200 和空 body
+		return 0, fmt.Errorf("empty response")
+	}
+
+	var response dystruct.Webcast_Im_Response
+	if err := proto.Unmarshal(body, &response); err != nil {
+		return 0, fmt.Errorf("unmarshal response error: %w", err)
+	}
+	dy.handleResponse(&response, RecvChan, cf)
+
+	if response.FetchInterval == 0 {
+		return defaultFetchInterval, nil
+	}
+	return time.Duration(response.FetchInterval) * time.Millisecond, nil
+}
@@ -16,6 +16,7 @@ type GojaDouyin struct {
 	vm       *goja.Runtime
 	mu       sync.Mutex
 	fGetSign func(signature string) string
+	fXBogus  func() string
 }
 
 // LoadGoja 加载 JavaScript 到 Goja 运行时中，并设置必要的环境
@@ -44,6 +45,9 @@ func LoadGoja(ua string) (*GojaDouyin, error) {
 	if err := gd.vm.ExportTo(gd.vm.Get("get_sign"), &gd.fGetSign); err != nil {
 		return nil, err
 	}
+	if err := gd.vm.ExportTo(gd.vm.Get("get_x_bogus"), &gd.fXBogus); err != nil {
+		return nil, err
+	}
 	return gd, nil
 }
 
@@ -53,3 +57,10 @@ func (gd *GojaDouyin) GetSign(signature string) string {
 	defer gd.mu.Unlock()
 	return gd.fGetSign(signature)
 }
+
+// GetXBogus 执行 JavaScript 中的 get_x_bogus 函数，生成 http 请求的 X-Bogus
+func (gd *GojaDouyin) GetXBogus() string {
+	gd.mu.Lock()
+	defer gd.mu.Unlock()
+	return gd.fXBogus()
+}
@@ -4,6 +4,7 @@
 // Below is a modification to make it work without the original environment
 
 var crawler;
+var httpSign;
 
 
 /** 1.0.0.53 */
@@ -6988,6 +6989,7 @@ if (!window.byted_acrawler) {
             _0x59992f([_0x5612de(0x30c)]);
         var _0x1649bc = !(-0xc6b * -0x1 + -0x2315 + 0x16aa);
         crawler = _0x5c2014
+        httpSign = _0x11233a
         _0x1d18f2['frontierSign'] = _0x5c2014,
             _0x1d18f2[_0x5612de(0x2e8)] = _0x32e4a6,
             _0x1d18f2[_0x5612de(0x3b9)] = _0x498349,
@@ -7009,4 +7011,9 @@ function get_sign(md5) {
         "X-MS-STUB": md5
     }
     return crawler(data)["X-Bogus"];
+}
+
+// http 请求的 X-Bogus, webmssdk 中未导出, 计算时 query 会被置为空
+function get_x_bogus() {
+    return httpSign(0, "")["X-Bogus"];
 }
\ No newline at end of file
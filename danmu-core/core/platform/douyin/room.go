@@ -0,0 +1,45 @@
+package platform
+
+import (
+	"github.com/tidwall/gjson"
+)
+
+// RoomInfo CheckStream 时从 data.data[0] 中解析的直播间信息
+type RoomInfo struct {
+	RoomID        string
+	WebRid        string
+	Status        int64 // 2 为直播中
+	Title         string
+	Cover         string
+	OwnerNickname string
+	OwnerAvatar   string
+	Category      string
+	UserCount     int64 // 当前在线人数
+	TotalUser     int64 // 累计观看人数
+	LikeCount     int64
+}
+
+// RoomInfo 返回最近一次 CheckStream 解析到的直播间信息，尚未解析到时返回 nil
+func (dy *Douyin) RoomInfo() *RoomInfo {
+	return dy.roomInfo.Load()
+}
+
+func (dy *Douyin) storeRoomInfo(room gjson.Result) {
+	category := room.Get("partition_road_map.sub_partition.partition.title").String()
+	if category == "" {
+		category = room.Get("partition_road_map.partition.title").String()
+	}
+	dy.roomInfo.Store(&RoomInfo{
+		RoomID:        room.Get("id_str").String(),
+		WebRid:        dy.webRid,
+		Status:        room.Get("status").Int(),
+		Title:         room.Get("title").String(),
+		Cover:         room.Get("cover.url_list.0").String(),
+		OwnerNickname: room.Get("owner.nickname").String(),
+		OwnerAvatar:   room.Get("owner.avatar_thumb.url_list.0").String(),
+		Category:      category,
+		UserCount:     room.Get("room_view_stats.display_value").Int(),
+		TotalUser:     room.Get("stats.total_user").Int(),
+		LikeCount:     room.Get("like_count").Int(),
+	})
+}
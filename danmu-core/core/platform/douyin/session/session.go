@@ -0,0 +1,259 @@
+// Package session 所有抖音直播间共用的匿名会话：UA 池、定时刷新的 ttwid 池、goja 签名 VM 池和接口请求限流
+// 未调用 Init 时 (如 cmd/test) 与之前一样每个直播间单独获取 ttwid 和创建 VM，不限流
+package session
+
+import (
+	"context"
+	"danmu-core/internal/proxy"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"danmu-core/utils"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultUserAgents    = 20
+	defaultCookies       = 5
+	defaultCookieRefresh = 6 * time.Hour
+	defaultSignerVMs     = 4
+	defaultRequestRate   = 2
+	defaultRequestBurst  = 5
+
+	ttwidURL     = "https://live.douyin.com/"
+	fetchTimeout = 10 * time.Second
+	// 获取 ttwid 不属于某个直播间，所有 ttwid 共用一个代理
+	proxyKey = "ttwid"
+)
+
+var httpClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy.Func(proxyKey)
+	return &http.Client{Transport: transport}
+}()
+
+var m *manager
+
+type manager struct {
+	agents  []string
+	cookies []*Cookie
+	next    atomic.Uint64
+	limiter *rate.Limiter
+	vms     *vmPool
+	refresh time.Duration
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// Session 直播间使用的 UA 和 ttwid，UA 总是获取该 ttwid 时使用的 UA
+type Session struct {
+	UserAgent string
+	Cookie    *Cookie
+}
+
+// Cookie 共用的 ttwid，刷新后所有使用它的直播间在下次请求时使用新的值
+type Cookie struct {
+	ua    string // 获取 ttwid 时使用的 UA
+	mu    sync.Mutex
+	ttwid atomic.Value
+}
+
+func (c *Cookie) TTWID() string {
+	v, _ := c.ttwid.Load().(string)
+	return v
+}
+
+// ensure 第一次分配时获取 ttwid，同时启动的直播间只请求一次
+func (c *Cookie) ensure(ctx context.Context, wait func(context.Context) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.TTWID() != "" {
+		return nil
+	}
+	if err := wait(ctx); err != nil {
+		return err
+	}
+	return c.refresh(ctx)
+}
+
+func (c *Cookie) refresh(ctx context.Context) error {
+	ttwid, err := fetchTTWID(ctx, c.ua)
+	if err != nil {
+		metrics.TTWIDRefreshes.WithLabelValues("error").Inc()
+		return err
+	}
+	metrics.TTWIDRefreshes.WithLabelValues("ok").Inc()
+	c.ttwid.Store(ttwid)
+	return nil
+}
+
+// Init 按 [douyin] 配置创建 UA 池和 ttwid 池，ttwid 在第一次分配给直播间时获取
+func Init() error {
+	s := setting.DouyinSetting
+	agents := s.UserAgents
+	if agents <= 0 {
+		agents = defaultUserAgents
+	}
+	cookies := s.Cookies
+	if cookies <= 0 {
+		cookies = defaultCookies
+	}
+	refresh := time.Duration(s.CookieRefresh) * time.Second
+	if refresh <= 0 {
+		refresh = defaultCookieRefresh
+	}
+	vms := s.SignerVMs
+	if vms <= 0 {
+		vms = defaultSignerVMs
+	}
+	limit, burst := rate.Limit(s.RequestRate), s.RequestBurst
+	if limit <= 0 {
+		limit = defaultRequestRate
+	}
+	if burst <= 0 {
+		burst = defaultRequestBurst
+	}
+
+	mgr := &manager{
+		agents:  userAgents(agents),
+		limiter: rate.NewLimiter(limit, burst),
+		refresh: refresh,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	mgr.vms = newVMPool(vms, mgr.agents[0])
+	for i := 0; i < cookies; i++ {
+		mgr.cookies = append(mgr.cookies, &Cookie{ua: mgr.agents[i%len(mgr.agents)]})
+	}
+	m = mgr
+	go mgr.run()
+	logger.Info().Int("user_agents", len(mgr.agents)).Int("cookies", cookies).Int("signer_vms", vms).
+		Float64("request_rate", float64(limit)).Msg("douyin session initialized")
+	return nil
+}
+
+func Enabled() bool {
+	return m != nil
+}
+
+// Close 停止刷新 ttwid
+func Close() {
+	if m == nil {
+		return
+	}
+	close(m.done)
+	<-m.stopped
+}
+
+// Acquire 为直播间依次轮流分配池中的 ttwid，UA 使用获取该 ttwid 时的 UA，
+// 抖音会校验 ttwid 与 UA 是否一致，不能单独分配 UA
+func Acquire() (*Session, error) {
+	if m == nil {
+		ua := utils.RandomUserAgent()
+		c := &Cookie{ua: ua}
+		if err := c.refresh(context.Background()); err != nil {
+			return nil, err
+		}
+		return &Session{UserAgent: ua, Cookie: c}, nil
+	}
+	i := m.next.Add(1) - 1
+	c := m.cookies[i%uint64(len(m.cookies))]
+	if err := c.ensure(context.Background(), m.wait); err != nil {
+		return nil, err
+	}
+	return &Session{UserAgent: c.ua, Cookie: c}, nil
+}
+
+// Wait 请求 room/web/enter 等接口前等待限流
+func Wait(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	return m.wait(ctx)
+}
+
+func (mgr *manager) wait(ctx context.Context) error {
+	start := time.Now()
+	err := mgr.limiter.Wait(ctx)
+	metrics.DouyinRequestWait.Add(time.Since(start).Seconds())
+	return err
+}
+
+// run 定时依次刷新 ttwid，刷新失败时继续使用旧的值，尚未分配的 ttwid 不刷新
+func (mgr *manager) run() {
+	defer close(mgr.stopped)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-mgr.done
+		cancel()
+	}()
+	ticker := time.NewTicker(mgr.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, c := range mgr.cookies {
+			if c.TTWID() == "" {
+				continue
+			}
+			if err := mgr.wait(ctx); err != nil {
+				return
+			}
+			if err := c.refresh(ctx); err != nil {
+				logger.Warn().Err(err).Msg("refresh ttwid failed")
+			}
+		}
+	}
+}
+
+func fetchTTWID(ctx context.Context, ua string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ttwidURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", ua)
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("获取直播 URL 失败: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusForbidden && proxy.Enabled() {
+		proxy.Rotate(proxyKey, proxy.ReasonBlocked)
+	}
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == "ttwid" {
+			return cookie.Value, nil
+		}
+	}
+	return "", fmt.Errorf("未找到 ttwid cookie")
+}
+
+// userAgents 生成 n 个 UA，尽量不重复
+func userAgents(n int) []string {
+	agents := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for tries := 0; len(agents) < n && tries < n*10; tries++ {
+		ua := utils.RandomUserAgent()
+		if seen[ua] {
+			continue
+		}
+		seen[ua] = true
+		agents = append(agents, ua)
+	}
+	for len(agents) < n {
+		agents = append(agents, utils.RandomUserAgent())
+	}
+	return agents
+}
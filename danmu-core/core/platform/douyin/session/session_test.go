@@ -0,0 +1,31 @@
+package session
+
+import (
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestAcquireUsesCookieUserAgent(t *testing.T) {
+	mgr := &manager{
+		agents:  []string{"ua-0", "ua-1", "ua-2"},
+		limiter: rate.NewLimiter(rate.Inf, 1),
+	}
+	for i := 0; i < 2; i++ {
+		c := &Cookie{ua: mgr.agents[i%len(mgr.agents)]}
+		c.ttwid.Store("ttwid-" + c.ua)
+		mgr.cookies = append(mgr.cookies, c)
+	}
+	m = mgr
+	defer func() { m = nil }()
+
+	for i := 0; i < 5; i++ {
+		s, err := Acquire()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "ttwid-" + s.UserAgent; s.Cookie.TTWID() != want {
+			t.Errorf("session %d: ua %s with ttwid %s, want %s", i, s.UserAgent, s.Cookie.TTWID(), want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package session
+
+import (
+	"danmu-core/core/platform/douyin/jsScript"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"sync"
+)
+
+// vmPool goja 签名 (包括 auto 切换后) 所有直播间共用的 VM，按需创建，最多 size 个，全部占用时等待
+type vmPool struct {
+	ua      string // webmssdk.js 的签名与 UA 无关，所有 VM 使用同一个
+	size    int
+	mu      sync.Mutex
+	created int
+	idle    chan *jsScript.GojaDouyin
+}
+
+func newVMPool(size int, ua string) *vmPool {
+	return &vmPool{ua: ua, size: size, idle: make(chan *jsScript.GojaDouyin, size)}
+}
+
+func (p *vmPool) get() (*jsScript.GojaDouyin, error) {
+	select {
+	case gd := <-p.idle:
+		return gd, nil
+	default:
+	}
+	p.mu.Lock()
+	if p.created < p.size {
+		p.created++
+		p.mu.Unlock()
+		gd, err := jsScript.LoadGoja(p.ua)
+		if err != nil {
+			p.mu.Lock()
+			p.created--
+			p.mu.Unlock()
+			return nil, err
+		}
+		metrics.SignerVMs.Inc()
+		return gd, nil
+	}
+	p.mu.Unlock()
+	return <-p.idle, nil
+}
+
+func (p *vmPool) put(gd *jsScript.GojaDouyin) {
+	p.idle <- gd
+}
+
+// GojaSigner 每次签名时从池中借用一个 VM
+type GojaSigner struct {
+	pool *vmPool
+}
+
+func (s *GojaSigner) GetSign(stub string) string {
+	gd, err := s.pool.get()
+	if err != nil {
+		logger.Error().Err(err).Msg("init goja js error")
+		return ""
+	}
+	defer s.pool.put(gd)
+	return gd.GetSign(stub)
+}
+
+func (s *GojaSigner) GetXBogus() string {
+	gd, err := s.pool.get()
+	if err != nil {
+		logger.Error().Err(err).Msg("init goja js error")
+		return ""
+	}
+	defer s.pool.put(gd)
+	return gd.GetXBogus()
+}
+
+// NewGojaSigner 返回共用 VM 池的 goja 签名，确认至少能创建一个 VM，需要先调用 Init
+func NewGojaSigner() (*GojaSigner, error) {
+	gd, err := m.vms.get()
+	if err != nil {
+		return nil, err
+	}
+	m.vms.put(gd)
+	return &GojaSigner{pool: m.vms}, nil
+}
@@ -0,0 +1,129 @@
+package platform
+
+import (
+	"danmu-core/core/platform/douyin/bogus"
+	"danmu-core/core/platform/douyin/jsScript"
+	"danmu-core/core/platform/douyin/session"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"fmt"
+	"sync"
+)
+
+// 签名的实现，对应 [douyin] Signer
+const (
+	SignerAuto   = "auto" // 使用 native，签名连续被拒绝后切换到 goja
+	SignerNative = "native"
+	SignerGoja   = "goja"
+)
+
+// signRejections auto 模式下连续多少次签名被拒绝后切换到 goja
+const signRejections = 3
+
+// Signer 生成 websocket 地址的 signature 和 room/web/enter 等 http 请求的 X-Bogus
+// native 为纯 Go 实现 (bogus)，每个直播间一个实例；goja 运行 webmssdk.js，所有直播间共用 session 的 VM 池
+type Signer interface {
+	GetSign(stub string) string
+	GetXBogus() string // 与请求参数无关，见 bogus 包的说明
+}
+
+func newSigner(room, ua string) (Signer, error) {
+	switch setting.DouyinSetting.Signer {
+	case "", SignerAuto:
+		return &fallbackSigner{
+			room:    room,
+			current: bogus.New(),
+			newGoja: func() (Signer, error) { return newGojaSigner(ua) },
+		}, nil
+	case SignerNative:
+		return bogus.New(), nil
+	case SignerGoja:
+		return newGojaSigner(ua)
+	}
+	return nil, fmt.Errorf("unknown signer: %s", setting.DouyinSetting.Signer)
+}
+
+func newGojaSigner(ua string) (Signer, error) {
+	if session.Enabled() {
+		gs, err := session.NewGojaSigner()
+		if err != nil {
+			return nil, fmt.Errorf("init goja js error: %w", err)
+		}
+		return gs, nil
+	}
+	gd, err := jsScript.LoadGoja(ua)
+	if err != nil {
+		return nil, fmt.Errorf("init goja js error: %w", err)
+	}
+	return gd, nil
+}
+
+// fallbackSigner 先使用 native，websocket 握手或 http 请求连续 signRejections 次被拒绝时
+// 认为抖音更新了签名算法，切换到 goja 并保持到直播间重新创建。goja 创建失败时继续使用 native
+type fallbackSigner struct {
+	room    string
+	newGoja func() (Signer, error)
+
+	mu       sync.Mutex
+	current  Signer
+	rejected int
+	fallback bool
+}
+
+func (s *fallbackSigner) signer() Signer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+func (s *fallbackSigner) GetSign(stub string) string {
+	return s.signer().GetSign(stub)
+}
+
+func (s *fallbackSigner) GetXBogus() string {
+	return s.signer().GetXBogus()
+}
+
+// accepted 签名通过校验，清除连续被拒绝次数
+func (s *fallbackSigner) accepted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected = 0
+}
+
+// reject 签名被拒绝，达到 signRejections 次时切换到 goja
+func (s *fallbackSigner) reject() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fallback {
+		return
+	}
+	s.rejected++
+	if s.rejected < signRejections {
+		return
+	}
+	s.rejected = 0
+	gs, err := s.newGoja()
+	if err != nil {
+		logger.Error().Str("room", s.room).Err(err).Msg("native 签名被拒绝，切换到 goja 失败")
+		return
+	}
+	logger.Warn().Str("room", s.room).Int("rejections", signRejections).Msg("native 签名连续被拒绝，切换到 goja")
+	metrics.SignerFallbacks.WithLabelValues(s.room).Inc()
+	s.current = gs
+	s.fallback = true
+}
+
+// reportSign 记录签名的校验结果，只有 [douyin] Signer 为 auto 时使用
+func (dy *Douyin) reportSign(ok bool) {
+	fs, isFallback := dy.signer.(*fallbackSigner)
+	if !isFallback {
+		return
+	}
+	if ok {
+		fs.accepted()
+	} else {
+		fs.reject()
+	}
+}
@@ -0,0 +1,96 @@
+package platform
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type stubSigner string
+
+func (s stubSigner) GetSign(string) string { return string(s) }
+func (s stubSigner) GetXBogus() string     { return string(s) }
+
+func newTestFallback(gojaErr error) (*Douyin, *int) {
+	created := 0
+	fs := &fallbackSigner{
+		room:    "r1",
+		current: stubSigner("native"),
+		newGoja: func() (Signer, error) {
+			created++
+			if gojaErr != nil {
+				return nil, gojaErr
+			}
+			return stubSigner("goja"), nil
+		},
+	}
+	return &Douyin{room: "r1", signer: fs}, &created
+}
+
+func TestFallbackSignerSwitchesAfterRejections(t *testing.T) {
+	dy, created := newTestFallback(nil)
+	// 中间有一次通过校验时重新计数
+	dy.reportSign(false)
+	dy.reportSign(false)
+	dy.Handshake(&http.Response{StatusCode: http.StatusOK}, nil)
+	dy.reportSign(false)
+	dy.reportSign(false)
+	if got := dy.signer.GetSign("stub"); got != "native" || *created != 0 {
+		t.Fatalf("signer = %s (goja created %d), want native before %d consecutive rejections", got, *created, signRejections)
+	}
+	dy.reportSign(false)
+	if got := dy.signer.GetXBogus(); got != "goja" || *created != 1 {
+		t.Fatalf("signer = %s (goja created %d), want goja", got, *created)
+	}
+	// 切换后不再切换回 native
+	dy.reportSign(true)
+	for i := 0; i < signRejections; i++ {
+		dy.reportSign(false)
+	}
+	if got := dy.signer.GetSign("stub"); got != "goja" || *created != 1 {
+		t.Errorf("signer = %s (goja created %d), want goja created once", got, *created)
+	}
+}
+
+func TestFallbackSignerKeepsNativeWhenGojaFails(t *testing.T) {
+	dy, created := newTestFallback(errors.New("load failed"))
+	for i := 0; i < 2*signRejections; i++ {
+		dy.reportSign(false)
+	}
+	if got := dy.signer.GetSign("stub"); got != "native" || *created != 2 {
+		t.Errorf("signer = %s (goja attempts %d), want native with 2 attempts", got, *created)
+	}
+}
+
+func TestHandshakeRejections(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		resp   *http.Response
+		reject bool
+	}{
+		{"network error", nil, false},
+		{"blocked", &http.Response{StatusCode: http.StatusForbidden}, false},
+		{"rate limited", &http.Response{StatusCode: http.StatusTooManyRequests}, false},
+		{"server error", &http.Response{StatusCode: http.StatusBadGateway}, false},
+		{"not upgraded", &http.Response{StatusCode: http.StatusOK}, true},
+		{"bad request", &http.Response{StatusCode: http.StatusBadRequest}, true},
+	} {
+		dy, _ := newTestFallback(nil)
+		for i := 0; i < signRejections; i++ {
+			dy.Handshake(tc.resp, errors.New("websocket: bad handshake"))
+		}
+		if switched := dy.signer.GetSign("stub") == "goja"; switched != tc.reject {
+			t.Errorf("%s: switched to goja = %v, want %v", tc.name, switched, tc.reject)
+		}
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	s, err := newSigner("r1", "ua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*fallbackSigner); !ok {
+		t.Errorf("default signer = %T, want *fallbackSigner", s)
+	}
+}
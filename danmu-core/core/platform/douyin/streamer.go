@@ -0,0 +1,76 @@
+package platform
+
+import (
+	"context"
+	"danmu-core/core/platform/douyin/session"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+)
+
+// StreamerInfo CheckStream 时从 data.user 中解析的主播账号信息
+type StreamerInfo struct {
+	SecUid    string
+	Uid       string
+	Nickname  string
+	Avatar    string
+	DisplayID string // 抖音号
+	WebRid    string
+	RoomID    string
+}
+
+// Streamer 返回最近一次 CheckStream 解析到的主播信息，尚未解析到时返回 nil
+func (dy *Douyin) Streamer() *StreamerInfo {
+	return dy.streamer.Load()
+}
+
+// SetSecUid 设置已知的主播 sec_uid，web_rid 失效时用于重新查找直播间
+func (dy *Douyin) SetSecUid(secUid string) {
+	dy.secUid = secUid
+}
+
+// ParseWebRid 从直播间链接中解析 web_rid
+func ParseWebRid(liveurl string) string {
+	m := webRidRg.FindStringSubmatch(liveurl)
+	if len(m) <= 1 {
+		return ""
+	}
+	return m[1]
+}
+
+func (dy *Douyin) storeStreamer(user gjson.Result) {
+	displayID := user.Get("unique_id").String()
+	if displayID == "" {
+		displayID = user.Get("short_id").String()
+	}
+	dy.streamer.Store(&StreamerInfo{
+		SecUid:    user.Get("sec_uid").String(),
+		Uid:       user.Get("id_str").String(),
+		Nickname:  user.Get("nickname").String(),
+		Avatar:    user.Get("avatar_thumb.url_list.0").String(),
+		DisplayID: displayID,
+		WebRid:    dy.webRid,
+		RoomID:    dy.roomId,
+	})
+}
+
+// webRidBySecUid 通过 sec_uid 查询主播当前的 web_rid
+func (dy *Douyin) webRidBySecUid(secUid string) (string, error) {
+	targetURL := fmt.Sprintf("https://webcast.amemv.com/webcast/room/reflow/info/?type_id=0&live_id=1&room_id=2&sec_user_id=%s&app_id=1128", secUid)
+	if err := session.Wait(context.Background()); err != nil {
+		return "", err
+	}
+	resp, err := dy.client.R().SetHeaders(dy.header()).Get(targetURL)
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	dy.checkBlocked(resp.Response)
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("请求返回状态码: %d", resp.StatusCode)
+	}
+	webRid := gjson.Get(resp.String(), "data.room.owner.web_rid").String()
+	if webRid == "" {
+		return "", fmt.Errorf("未找到 web_rid: sec_uid=%s", secUid)
+	}
+	return webRid, nil
+}
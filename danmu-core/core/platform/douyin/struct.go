@@ -3,7 +3,10 @@ package platform
 import (
 	"danmu-core/generated/douyin"
 	"danmu-core/generated/dystruct"
+	"danmu-core/utils"
 	"errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"unicode/utf8"
 )
@@ -615,6 +618,12 @@ var newMessage = map[string]func() protoreflect.ProtoMessage{
 	},
 }
 
+// IsKnownMethod 判断方法名是否有对应的ProtoMessage定义
+func IsKnownMethod(method string) bool {
+	_, ok := newMessage[method]
+	return ok
+}
+
 // MatchMethod 根据方法名匹配并返回对应的ProtoMessage
 func MatchMethod(method string) (protoreflect.ProtoMessage, error) {
 	if !utf8.ValidString(method) {
@@ -625,3 +634,32 @@ func MatchMethod(method string) (protoreflect.ProtoMessage, error) {
 	}
 	return nil, errors.New("未知消息: " + method)
 }
+
+// MessageTime 消息 common.create_time 的毫秒时间戳，只解析 payload 的第 1 个字段 (common)，没有时返回 0
+func MessageTime(msg *dystruct.Webcast_Im_Message) int64 {
+	b := msg.Payload
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return 0
+		}
+		b = b[n:]
+		if num == 1 && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return 0
+			}
+			var common dystruct.Webcast_Im_Common
+			if err := proto.Unmarshal(v, &common); err != nil {
+				return 0
+			}
+			return utils.NormalizeTimestamp(int64(common.CreateTime))
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return 0
+		}
+		b = b[n:]
+	}
+	return 0
+}
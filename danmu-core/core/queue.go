@@ -0,0 +1,394 @@
+package core
+
+import (
+	"context"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/handler"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"danmu-core/tracing"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 队列满时的处理策略
+const (
+	PolicyBlock      = "block"       // 等待 handler 处理出空位，会阻塞 websocket 读取
+	PolicyDropOldest = "drop_oldest" // 丢弃队列中最早的消息
+	PolicyPriority   = "priority"    // 丢弃队列中优先级最低的消息，新消息优先级更低时丢弃新消息
+	PolicySpill      = "spill"       // 写入磁盘，handler 追上后按顺序读回
+)
+
+// defaultQueueSize 未配置 [queue] Size 时每个 handler 队列的容量
+const defaultQueueSize = 1000
+
+// queuedMsg 队列中的消息，ctx 为采样时的 trace 上下文
+// target 不为空时只交给该 handler 处理并通过 result 返回结果，用于重放死信
+type queuedMsg struct {
+	msg    interface{}
+	ctx    context.Context
+	rank   int
+	target MsgHandler
+	result chan error
+}
+
+// handlerQueue 每个订阅的 handler 独立的有界队列和 worker，慢的 handler 只会积压自己的队列
+// 启用 moderation 时，需要检测结果的 handler 与 moderation 共用队列按顺序处理
+type handlerQueue struct {
+	room     string
+	liveurl  string
+	name     string // 配置中的 handler 名称
+	label    string // 指标中的 handler 名称
+	policy   string
+	size     int
+	ranks    map[string]int
+	handlers []MsgHandler
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []queuedMsg
+	spill   *spillFile
+	running bool
+	closing bool
+	done    chan struct{}
+
+	depth      prometheus.Gauge
+	spilled    prometheus.Counter
+	spillBytes prometheus.Gauge
+	blocked    prometheus.Counter
+}
+
+func newHandlerQueue(c *Client, name string, h MsgHandler) *handlerQueue {
+	q := &handlerQueue{
+		room:    c.room,
+		liveurl: c.liveurl,
+		name:    name,
+		label:   handlerName(h),
+		policy:  queuePolicy(name),
+		size:    setting.QueueSetting.Size,
+	}
+	q.add(c, name, h)
+	if q.size <= 0 {
+		q.size = defaultQueueSize
+	}
+	q.cond = sync.NewCond(&q.mu)
+	q.depth = metrics.HandlerQueueDepth.WithLabelValues(q.room, q.label)
+	q.spilled = metrics.HandlerQueueSpilled.WithLabelValues(q.room, q.label)
+	q.spillBytes = metrics.HandlerQueueSpillBytes.WithLabelValues(q.room, q.label)
+	q.blocked = metrics.HandlerQueueBlocked.WithLabelValues(q.room, q.label)
+	if q.policy == PolicyPriority {
+		q.ranks = priorityRanks()
+	}
+	return q
+}
+
+// queuePolicy 返回 handler 的溢出策略，HandlerPolicies 中的配置优先
+func queuePolicy(name string) string {
+	policy := setting.QueueSetting.Policy
+	for _, item := range strings.Split(setting.QueueSetting.HandlerPolicies, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if ok && strings.TrimSpace(key) == name {
+			policy = strings.TrimSpace(value)
+		}
+	}
+	switch policy {
+	case PolicyBlock, PolicyDropOldest, PolicyPriority:
+		return policy
+	case PolicySpill:
+		if setting.QueueSetting.SpillDir != "" {
+			return policy
+		}
+		logger.Warn().Str("handler", name).Msg("queue SpillDir is empty, spill fall back to drop_oldest")
+		return PolicyDropOldest
+	case "":
+		return PolicyBlock
+	default:
+		logger.Warn().Str("handler", name).Str("policy", policy).Msg("unknown queue policy, use block")
+		return PolicyBlock
+	}
+}
+
+// priorityRanks 消息方法的优先级，数值越小优先级越高
+func priorityRanks() map[string]int {
+	ranks := make(map[string]int)
+	for _, method := range strings.Split(setting.QueueSetting.PriorityMethods, ",") {
+		method = strings.TrimSpace(method)
+		if _, ok := ranks[method]; method != "" && !ok {
+			ranks[method] = len(ranks)
+		}
+	}
+	return ranks
+}
+
+func (q *handlerQueue) rank(msg interface{}) int {
+	if message, ok := msg.(*dystruct.Webcast_Im_Message); ok {
+		if rank, ok := q.ranks[message.Method]; ok {
+			return rank
+		}
+	}
+	return len(q.ranks)
+}
+
+// push 由 processMsg 调用，除 block 策略外不会等待 handler
+func (q *handlerQueue) push(msg interface{}, ctx context.Context) {
+	item := queuedMsg{msg: msg, ctx: ctx}
+	if q.ranks != nil {
+		item.rank = q.rank(msg)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.running {
+		q.start()
+	}
+	switch {
+	case q.spill != nil && q.spill.pending():
+		// 已有消息溢出到磁盘时新消息也写入磁盘，保证处理顺序
+		q.spillMsg(item)
+	case len(q.items) < q.size:
+		q.items = append(q.items, item)
+	case q.policy == PolicyBlock:
+		start := time.Now()
+		for len(q.items) >= q.size {
+			q.cond.Wait()
+		}
+		q.blocked.Add(time.Since(start).Seconds())
+		q.items = append(q.items, item)
+	case q.policy == PolicySpill && q.spill != nil:
+		q.spillMsg(item)
+	case q.policy == PolicyPriority:
+		q.dropLowest(item)
+	default:
+		q.items[0] = queuedMsg{}
+		q.items = append(q.items[1:], item)
+		q.dropped("overflow")
+	}
+	q.depth.Set(float64(len(q.items)))
+	q.cond.Broadcast()
+}
+
+// dropLowest 丢弃优先级最低的消息中最早的一条，新消息优先级更低时丢弃新消息
+func (q *handlerQueue) dropLowest(item queuedMsg) {
+	lowest := -1
+	for i := range q.items {
+		if lowest < 0 || q.items[i].rank > q.items[lowest].rank {
+			lowest = i
+		}
+	}
+	q.dropped("priority")
+	if item.rank > q.items[lowest].rank {
+		return
+	}
+	copy(q.items[lowest:], q.items[lowest+1:])
+	q.items[len(q.items)-1] = item
+}
+
+func (q *handlerQueue) spillMsg(item queuedMsg) {
+	message, ok := item.msg.(*dystruct.Webcast_Im_Message)
+	if !ok {
+		q.dropped("spill_error")
+		return
+	}
+	if err := q.spill.write(message); err != nil {
+		if err == errSpillFull {
+			q.dropped("spill_full")
+			return
+		}
+		logger.Warn().Str("liveurl", q.liveurl).Str("handler", q.name).Err(err).Msg("write spill file error")
+		q.dropped("spill_error")
+		return
+	}
+	q.spilled.Inc()
+	q.spillBytes.Set(float64(q.spill.size()))
+}
+
+func (q *handlerQueue) dropped(reason string) {
+	metrics.HandlerQueueDropped.WithLabelValues(q.room, q.label, reason).Inc()
+}
+
+// start 启动 worker，spill 策略同时打开溢出文件，继续处理上次未处理完的消息，调用时需持有 q.mu
+func (q *handlerQueue) start() {
+	q.running = true
+	q.closing = false
+	if q.policy == PolicySpill && q.spill == nil {
+		spill, err := openSpillFile(setting.QueueSetting.SpillDir, q.room, q.name, setting.QueueSetting.SpillMaxBytes)
+		if err != nil {
+			logger.Warn().Str("liveurl", q.liveurl).Str("handler", q.name).Err(err).Msg("open spill file failed, drop oldest message when queue is full")
+		} else {
+			q.spill = spill
+			q.spillBytes.Set(float64(spill.size()))
+		}
+	}
+	q.done = make(chan struct{})
+	go q.run(q.done)
+}
+
+// stop 等待 worker 处理完队列和溢出文件中的消息后退出，连接关闭时在 flushHandlers 之前调用
+func (q *handlerQueue) stop() {
+	q.mu.Lock()
+	if !q.running {
+		q.mu.Unlock()
+		return
+	}
+	q.closing = true
+	done := q.done
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	<-done
+}
+
+func (q *handlerQueue) run(done chan struct{}) {
+	defer close(done)
+	for {
+		item, ok := q.pop()
+		if !ok {
+			return
+		}
+		if item.target != nil {
+			item.result <- q.handle(item.target, item)
+			continue
+		}
+		for _, h := range q.handlers {
+			q.handle(h, item)
+		}
+	}
+}
+
+// pop 内存队列中的消息早于溢出文件中的消息，先处理内存队列
+func (q *handlerQueue) pop() (queuedMsg, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if len(q.items) > 0 {
+			item := q.items[0]
+			q.items[0] = queuedMsg{}
+			q.items = q.items[1:]
+			q.depth.Set(float64(len(q.items)))
+			q.cond.Broadcast()
+			return item, true
+		}
+		if q.spill != nil && q.spill.pending() {
+			message, err := q.spill.read()
+			q.spillBytes.Set(float64(q.spill.size()))
+			if err != nil {
+				logger.Warn().Str("liveurl", q.liveurl).Str("handler", q.name).Err(err).Msg("read spill file error, discard spilled messages")
+				q.dropped("spill_error")
+				q.spill.reset()
+				q.spillBytes.Set(0)
+				continue
+			}
+			return queuedMsg{msg: message}, true
+		}
+		if q.closing {
+			q.running = false
+			if q.spill != nil {
+				if err := q.spill.close(); err != nil {
+					logger.Warn().Str("liveurl", q.liveurl).Str("handler", q.name).Err(err).Msg("close spill file error")
+				}
+				q.spill = nil
+			}
+			return queuedMsg{}, false
+		}
+		q.cond.Wait()
+	}
+}
+
+func (q *handlerQueue) handle(h MsgHandler, item queuedMsg) (err error) {
+	name := handlerName(h)
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panic: %v", p)
+			metrics.HandlerErrors.WithLabelValues(q.room, name).Inc()
+			logger.Error().
+				Str("liveurl", q.liveurl).
+				Str("handler", name).
+				Interface("panic", p).
+				Str("stack", string(debug.Stack())).
+				Msg("Panic recovered in handler")
+		}
+	}()
+	var span trace.Span
+	if item.ctx != nil {
+		_, span = tracing.Start(item.ctx, "handler."+name)
+	}
+	start := time.Now()
+	err = h.Handle(item.msg)
+	metrics.HandlerDuration.WithLabelValues(q.room, name).Observe(time.Since(start).Seconds())
+	if span != nil {
+		tracing.End(span, err)
+	}
+	if err != nil {
+		metrics.HandlerErrors.WithLabelValues(q.room, name).Inc()
+		logger.Warn().Str("liveurl", q.liveurl).Err(err).Msg("handle msg error")
+	}
+	return err
+}
+
+// add 加入队列的 handler 处理失败时重试，并在连接关闭时停止等待
+func (q *handlerQueue) add(c *Client, name string, h MsgHandler) {
+	r := NewRetryHandler(h, name, c.confID, c.room)
+	r.stopping = q.isClosing
+	q.handlers = append(q.handlers, r)
+}
+
+func (q *handlerQueue) isClosing() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closing
+}
+
+// call 执行单个 handler 并等待结果，不受队列容量和溢出策略限制。
+// 连接运行时由 worker 执行，与队列中的消息按顺序处理；连接已关闭 (任务未在直播) 时在调用方直接执行，
+// 不启动已经停止的 worker，否则没有人再停止它
+func (q *handlerQueue) call(ctx context.Context, h MsgHandler, msg interface{}) error {
+	result := make(chan error, 1)
+	item := queuedMsg{msg: msg, ctx: ctx, target: h, result: result}
+	q.mu.Lock()
+	if !q.running || q.closing {
+		q.mu.Unlock()
+		return q.handle(h, item)
+	}
+	q.items = append(q.items, item)
+	q.depth.Set(float64(len(q.items)))
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// subscribe 为 handler 创建独立队列，需要 moderation 检测结果的 handler 加入 moderation 的队列
+func (c *Client) subscribe(name string, h MsgHandler) {
+	c.handlers = append(c.handlers, h)
+	if _, ok := h.(ModeratedHandler); ok {
+		for _, q := range c.queues {
+			if _, ok := q.handlers[0].(*RetryHandler).MsgHandler.(handler.Moderator); ok {
+				q.add(c, name, h)
+				return
+			}
+		}
+	}
+	c.queues = append(c.queues, newHandlerQueue(c, name, h))
+}
+
+// stopQueues 等待所有 handler 处理完积压的消息
+func (c *Client) stopQueues() {
+	var wg sync.WaitGroup
+	for _, q := range c.queues {
+		wg.Add(1)
+		go func(q *handlerQueue) {
+			defer wg.Done()
+			q.stop()
+		}(q)
+	}
+	wg.Wait()
+}
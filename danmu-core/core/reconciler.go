@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"danmu-core/internal/model"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/tracing"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultReconcileInterval = 60 * time.Second
+	// 同一配置连续对齐失败时的等待时间，每次失败翻倍
+	reconcileBackoff    = time.Minute
+	reconcileMaxBackoff = 30 * time.Minute
+)
+
+// driftFailures 记录每个配置连续对齐失败的次数，等待时间内跳过该配置，
+// 避免无法创建的任务 (如平台初始化失败) 每次对齐都重试并刷屏日志
+var driftFailures = newDriftBackoff()
+
+type driftFailure struct {
+	count int
+	next  time.Time
+}
+
+type driftBackoff struct {
+	mu       sync.Mutex
+	failures map[int64]*driftFailure
+	now      func() time.Time
+}
+
+func newDriftBackoff() *driftBackoff {
+	return &driftBackoff{failures: make(map[int64]*driftFailure), now: time.Now}
+}
+
+// ready 配置不在等待时间内
+func (b *driftBackoff) ready(id int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, ok := b.failures[id]
+	return !ok || !b.now().Before(f.next)
+}
+
+// record 成功时清除失败记录，失败时返回连续失败次数和下次重试的时间
+func (b *driftBackoff) record(id int64, err error) (int, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.failures, id)
+		return 0, time.Time{}
+	}
+	f, ok := b.failures[id]
+	if !ok {
+		f = &driftFailure{}
+		b.failures[id] = f
+	}
+	f.count++
+	wait := reconcileMaxBackoff
+	if f.count <= 16 {
+		wait = min(reconcileBackoff<<(f.count-1), reconcileMaxBackoff)
+	}
+	f.next = b.now().Add(wait)
+	return f.count, f.next
+}
+
+// retain 删除已不存在的配置的失败记录
+func (b *driftBackoff) retain(keep func(id int64) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id := range b.failures {
+		if !keep(id) {
+			delete(b.failures, id)
+		}
+	}
+}
+
+// StartReconciler 定期将 live_confs 表与 TaskMap 对齐，弥补 danmu-http 的 rpc 调用失败或 core 宕机期间的配置变更
+// 阻塞直到 ctx 取消
+func StartReconciler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReconcileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.Info().Dur("interval", interval).Msg("reconciler started")
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("reconciler stopped")
+			return
+		case <-ticker.C:
+			if err := Reconcile(ctx); err != nil {
+				logger.Warn().Err(err).Msg("reconcile failed")
+			}
+		}
+	}
+}
+
+// Reconcile 对比数据库配置和运行中的任务，按需执行 Add/Update/Delete
+// 三个操作本身都是幂等的，重复执行不会产生副作用
+func Reconcile(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "core.Reconcile")
+	defer func() { tracing.End(span, err) }()
+
+	confs, err := model.GetAllLiveConf()
+	if err != nil {
+		metrics.ReconcileRuns.WithLabelValues("error").Inc()
+		return fmt.Errorf("get all live conf error: %w", err)
+	}
+
+	desired := make(map[int64]*model.LiveConf, len(confs))
+	for _, conf := range confs {
+		desired[conf.ID] = conf
+	}
+
+	// 复制一份当前任务配置，避免执行 Add/Update/Delete 时持有 mapMutex
+	mapMutex.RLock()
+	running := make(map[int64]model.LiveConf, len(TaskMap))
+	for id, task := range TaskMap {
+		running[id] = task.conf
+	}
+	mapMutex.RUnlock()
+
+	driftFailures.retain(func(id int64) bool {
+		_, inDesired := desired[id]
+		_, inRunning := running[id]
+		return inDesired || inRunning
+	})
+
+	var adds, updates, deletes int
+	for id, conf := range desired {
+		current, ok := running[id]
+		switch {
+		case !ok:
+			if driftFailures.ready(id) {
+				adds++
+				applyDrift("add", conf.ID, conf.URL, Add(ctx, conf))
+			}
+		case needRebuild(&current, conf) || current.Enable != conf.Enable:
+			if driftFailures.ready(id) {
+				updates++
+				applyDrift("update", conf.ID, conf.URL, Update(ctx, conf))
+			}
+		}
+	}
+	for id, current := range running {
+		if _, ok := desired[id]; !ok && driftFailures.ready(id) {
+			deletes++
+			applyDrift("delete", id, current.URL, Delete(ctx, id))
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("reconcile.add", adds),
+		attribute.Int("reconcile.update", updates),
+		attribute.Int("reconcile.delete", deletes),
+	)
+	metrics.ReconcileRuns.WithLabelValues("success").Inc()
+	metrics.ReconcileLastSuccess.SetToCurrentTime()
+	if adds+updates+deletes > 0 {
+		logger.Info().
+			Int("add", adds).
+			Int("update", updates).
+			Int("delete", deletes).
+			Msg("reconcile finished with drift")
+	}
+	return nil
+}
+
+func applyDrift(action string, id int64, liveurl string, err error) {
+	failures, next := driftFailures.record(id, err)
+	if err == nil {
+		metrics.ReconcileDrift.WithLabelValues(action, "success").Inc()
+		logger.Info().
+			Str("action", action).
+			Int64("id", id).
+			Str("liveurl", liveurl).
+			Msg("reconcile drift fixed")
+		return
+	}
+	metrics.ReconcileDrift.WithLabelValues(action, "error").Inc()
+	logger.Warn().
+		Err(err).
+		Str("action", action).
+		Int64("id", id).
+		Str("liveurl", liveurl).
+		Int("failures", failures).
+		Time("next_retry", next).
+		Msg("reconcile drift failed")
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDriftBackoff(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := newDriftBackoff()
+	b.now = func() time.Time { return now }
+
+	if !b.ready(1) {
+		t.Fatal("conf without failures should be ready")
+	}
+	fail := errors.New("init failed")
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		count, next := b.record(1, fail)
+		if count != i+1 || next.Sub(now) != want {
+			t.Fatalf("failure %d: count %d wait %v, want %d %v", i+1, count, next.Sub(now), i+1, want)
+		}
+	}
+	if b.ready(1) {
+		t.Fatal("conf should be skipped during backoff")
+	}
+	now = now.Add(4 * time.Minute)
+	if !b.ready(1) {
+		t.Fatal("conf should be retried after backoff expires")
+	}
+
+	for i := 0; i < 40; i++ {
+		b.record(1, fail)
+	}
+	if _, next := b.record(1, fail); next.Sub(now) != reconcileMaxBackoff {
+		t.Errorf("wait = %v, want capped at %v", next.Sub(now), reconcileMaxBackoff)
+	}
+
+	b.record(1, nil)
+	if !b.ready(1) {
+		t.Error("success should clear the backoff")
+	}
+
+	b.record(2, fail)
+	b.record(3, fail)
+	b.retain(func(id int64) bool { return id == 3 })
+	if !b.ready(2) || b.ready(3) {
+		t.Error("retain should drop only confs that no longer exist")
+	}
+}
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"context"
+	"danmu-core/internal/archive"
+	"danmu-core/internal/model"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"danmu-core/tracing"
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/parquet-go/parquet-go/compress"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultRetentionInterval = time.Hour
+	defaultPartitionsAhead   = 3
+	archiveBatchSize         = 5000
+	// emptyPartitionGrace 已清空的分区在结束后保留一段时间再删除，避免迟到的消息因没有分区写入失败
+	emptyPartitionGrace = 7 * 24 * time.Hour
+)
+
+// StartRetention 启动时和之后每隔 interval 维护消息表分区，阻塞直到 ctx 取消
+func StartRetention(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.Info().Dur("interval", interval).Msg("retention started")
+	for {
+		if err := MaintainPartitions(ctx); err != nil {
+			logger.Warn().Err(err).Msg("maintain partitions failed")
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("retention stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EnsurePartitions 创建当前及未来 PartitionsAhead 个月的分区，消息写入前必须存在对应分区
+func EnsurePartitions() error {
+	now := time.Now()
+	ahead := setting.RetentionSetting.PartitionsAhead
+	if ahead <= 0 {
+		ahead = defaultPartitionsAhead
+	}
+	for _, table := range model.PartitionedTables {
+		for i := 0; i <= ahead; i++ {
+			if err := model.EnsurePartition(model.NewPartition(table, model.MonthStart(now).AddDate(0, i, 0))); err != nil {
+				return fmt.Errorf("create partition of %s error: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MaintainPartitions 创建未来的分区；启用归档时按直播间保留策略归档过期的月份，删除已清空的旧分区
+func MaintainPartitions(ctx context.Context) (err error) {
+	_, span := tracing.Start(ctx, "core.MaintainPartitions")
+	defer func() {
+		tracing.End(span, err)
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		metrics.RetentionRuns.WithLabelValues(result).Inc()
+	}()
+
+	if err := EnsurePartitions(); err != nil {
+		return err
+	}
+	if !setting.RetentionSetting.Enable {
+		return nil
+	}
+
+	now := time.Now()
+	policies, err := model.GetRetentionDays()
+	if err != nil {
+		return fmt.Errorf("get retention policies error: %w", err)
+	}
+	codec, err := archive.Codec(setting.RetentionSetting.Compression)
+	if err != nil {
+		return err
+	}
+
+	var archived, dropped int
+	for _, table := range model.PartitionedTables {
+		partitions, err := model.ListPartitions(table)
+		if err != nil {
+			return fmt.Errorf("list partitions of %s error: %w", table, err)
+		}
+		for _, p := range partitions {
+			if p.End.After(now) {
+				break
+			}
+			n, err := archiveExpired(p, policies, now, codec)
+			archived += n
+			if err != nil {
+				return err
+			}
+			// sqlite 没有分区，归档时已删除消息，无需再删除空分区
+			if !model.HasPartitions() || p.End.Add(emptyPartitionGrace).After(now) {
+				continue
+			}
+			count, err := model.CountPartitionRows(p)
+			if err != nil {
+				return fmt.Errorf("count %s error: %w", p.Name, err)
+			}
+			if count == 0 {
+				if err := model.DropPartition(p); err != nil {
+					return fmt.Errorf("drop %s error: %w", p.Name, err)
+				}
+				dropped++
+				logger.Info().Str("partition", p.Name).Msg("drop empty partition")
+			}
+		}
+	}
+	span.SetAttributes(attribute.Int("retention.archived", archived), attribute.Int("retention.dropped", dropped))
+	return nil
+}
+
+// retainDays 直播间的保留天数，0 表示永久保留
+func retainDays(policies map[string]int, room string) int {
+	if days, ok := policies[room]; ok {
+		return days
+	}
+	return setting.RetentionSetting.DefaultRetainDays
+}
+
+// archiveExpired 归档分区中已过期的直播间，返回归档的文件数量
+func archiveExpired(p model.Partition, policies map[string]int, now time.Time, codec compress.Codec) (int, error) {
+	rooms, err := model.PartitionRooms(p)
+	if err != nil {
+		return 0, fmt.Errorf("list rooms of %s error: %w", p.Name, err)
+	}
+	var archived int
+	for _, room := range rooms {
+		days := retainDays(policies, room)
+		if days <= 0 || p.End.After(now.AddDate(0, 0, -days)) {
+			continue
+		}
+		if err := archiveRoom(p, room, codec); err != nil {
+			return archived, fmt.Errorf("archive %s room %s error: %w", p.Name, room, err)
+		}
+		archived++
+	}
+	return archived, nil
+}
+
+// archiveRoom 将分区中直播间的消息写入 parquet 文件，记录归档后从数据库删除
+func archiveRoom(p model.Partition, room string, codec compress.Codec) error {
+	path := archive.Path(setting.RetentionSetting.ArchiveDir, p.Table, room, p.Begin)
+	var (
+		file               *archive.File
+		begin, end, lastID int64
+		err                error
+	)
+	switch p.Table {
+	case model.TableNameCommonMessage:
+		file, begin, end, lastID, err = archiveRows(p, room, path, codec, func(r *archive.CommonMessageRow) (uint64, int64) {
+			return r.Timestamp, int64(r.ID)
+		})
+	case model.TableNameGiftMessage:
+		file, begin, end, lastID, err = archiveRows(p, room, path, codec, func(r *archive.GiftMessageRow) (uint64, int64) {
+			return r.Timestamp, r.ID
+		})
+	default:
+		return fmt.Errorf("unsupported table %s", p.Table)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 记录相对 ArchiveDir 的路径，danmu-http 按自己配置的目录读取
+	rel, err := filepath.Rel(setting.RetentionSetting.ArchiveDir, file.Path)
+	if err != nil {
+		rel = file.Path
+	}
+	record := &model.MessageArchive{
+		MessageTable:  p.Table,
+		RoomDisplayId: room,
+		Month:         p.Begin.UnixMilli(),
+		Path:          filepath.ToSlash(rel),
+		Rows:          file.Rows,
+		Size:          file.Size,
+		Begin:         begin,
+		End:           end,
+	}
+	if err := model.SaveArchive(p, record, lastID); err != nil {
+		return err
+	}
+	metrics.ArchivedRows.WithLabelValues(p.Table).Add(float64(file.Rows))
+	logger.Info().Str("partition", p.Name).Str("liveid", room).
+		Int64("rows", file.Rows).Int64("size", file.Size).Str("path", file.Path).
+		Msg("archive messages")
+	return nil
+}
+
+// archiveRows 按 (timestamp, id) 分批读取并写入文件，返回文件、消息的时间范围及最后一条消息的 id
+func archiveRows[T any](p model.Partition, room, path string, codec compress.Codec, key func(*T) (uint64, int64)) (*archive.File, int64, int64, int64, error) {
+	var (
+		afterTs    uint64
+		afterID    int64 = math.MinInt64
+		begin, end int64
+	)
+	file, err := archive.Write(path, codec, func() ([]T, error) {
+		rows, err := model.GetArchiveBatch[T](p, room, afterTs, afterID, archiveBatchSize)
+		if err != nil || len(rows) == 0 {
+			return nil, err
+		}
+		if begin == 0 {
+			ts, _ := key(&rows[0])
+			begin = int64(ts)
+		}
+		afterTs, afterID = key(&rows[len(rows)-1])
+		end = int64(afterTs)
+		return rows, nil
+	})
+	return file, begin, end, afterID, err
+}
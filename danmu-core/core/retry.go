@@ -0,0 +1,164 @@
+package core
+
+import (
+	"context"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/deadletter"
+	"danmu-core/internal/handler"
+	"danmu-core/internal/model"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultRetryAttempts   = 5
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
+// ErrDeadLetterNotFound 重放的死信不存在
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// RetryHandler handler 返回错误时按指数退避重试，重试耗尽或消息无法解析时将原始消息写入死信，未知的 method 直接跳过
+// 只包装 Handle，FlushHandler 等其他接口仍通过 Client.handlers 中原始的 handler 调用
+type RetryHandler struct {
+	MsgHandler
+	name       string // LiveConf.Handlers 中的名称，重放时据此找到 handler
+	confID     int64
+	room       string
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	stopping   func() bool // 连接关闭时不再等待重试，直接写入死信
+}
+
+func NewRetryHandler(h MsgHandler, name string, confID int64, room string) *RetryHandler {
+	r := &RetryHandler{
+		MsgHandler: h,
+		name:       name,
+		confID:     confID,
+		room:       room,
+		attempts:   setting.RetrySetting.Attempts,
+		backoff:    time.Duration(setting.RetrySetting.Backoff) * time.Millisecond,
+		maxBackoff: time.Duration(setting.RetrySetting.MaxBackoff) * time.Millisecond,
+	}
+	if r.attempts <= 0 {
+		r.attempts = defaultRetryAttempts
+	}
+	if r.backoff <= 0 {
+		r.backoff = defaultRetryBackoff
+	}
+	if r.maxBackoff <= 0 {
+		r.maxBackoff = defaultRetryMaxBackoff
+	}
+	return r
+}
+
+func (r *RetryHandler) Handle(msg interface{}) error {
+	backoff := r.backoff
+	for attempt := 1; ; attempt++ {
+		err := r.MsgHandler.Handle(msg)
+		if err == nil || handler.Skipped(err) {
+			// 抖音推送的大量 method 没有对应的 proto，不作为失败重试或写入死信
+			return nil
+		}
+		if attempt >= r.attempts || handler.Permanent(err) || (r.stopping != nil && r.stopping()) {
+			r.deadLetter(msg, err, attempt)
+			return err
+		}
+		metrics.HandlerRetries.WithLabelValues(r.room, handlerName(r.MsgHandler)).Inc()
+		time.Sleep(backoff)
+		backoff = min(backoff*2, r.maxBackoff)
+	}
+}
+
+func (r *RetryHandler) deadLetter(msg interface{}, err error, attempts int) {
+	message, ok := msg.(*dystruct.Webcast_Im_Message)
+	if !ok {
+		return
+	}
+	payload, merr := proto.Marshal(message)
+	if merr != nil {
+		logger.Warn().Err(merr).Str("handler", r.name).Msg("marshal dead letter error")
+		return
+	}
+	now := time.Now().UnixMilli()
+	result := deadletter.Add(&model.DeadLetter{
+		ConfID:        r.confID,
+		RoomDisplayId: r.room,
+		Handler:       r.name,
+		Method:        message.Method,
+		MsgID:         message.MsgId,
+		Payload:       payload,
+		Error:         err.Error(),
+		Attempts:      attempts,
+		FirstFailed:   now,
+		LastFailed:    now,
+	})
+	metrics.DeadLetters.WithLabelValues(r.room, handlerName(r.MsgHandler), result).Inc()
+}
+
+// ReplayResult 单条死信的重放结果，Err 为 nil 时死信已删除
+type ReplayResult struct {
+	ID  int64
+	Err error
+}
+
+// ReplayDeadLetters 将死信交给原任务中同名的 handler 重新处理，不重试，成功后删除死信
+// 重放在 handler 的队列中执行，与正常消息不会并发
+func ReplayDeadLetters(ctx context.Context, ids []int64) []ReplayResult {
+	results := make([]ReplayResult, 0, len(ids))
+	for _, id := range ids {
+		err := replayDeadLetter(ctx, id)
+		if err != nil {
+			logger.Warn().Err(err).Int64("id", id).Msg("replay dead letter failed")
+		}
+		results = append(results, ReplayResult{ID: id, Err: err})
+	}
+	return results
+}
+
+func replayDeadLetter(ctx context.Context, id int64) error {
+	letter, err := model.GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+	if letter == nil {
+		return fmt.Errorf("%w: %d", ErrDeadLetterNotFound, id)
+	}
+	err = replay(ctx, letter)
+	if err == nil {
+		return model.DeleteDeadLetter(id)
+	}
+	if uerr := model.UpdateDeadLetterReplay(id, err.Error(), time.Now().UnixMilli()); uerr != nil {
+		logger.Warn().Err(uerr).Int64("id", id).Msg("update dead letter error")
+	}
+	return err
+}
+
+func replay(ctx context.Context, letter *model.DeadLetter) error {
+	mapMutex.RLock()
+	task, ok := TaskMap[letter.ConfID]
+	mapMutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrTaskNotFound, letter.ConfID)
+	}
+	message := &dystruct.Webcast_Im_Message{}
+	if err := proto.Unmarshal(letter.Payload, message); err != nil {
+		return fmt.Errorf("unmarshal dead letter error: %w", err)
+	}
+	for _, q := range task.client.queues {
+		for _, h := range q.handlers {
+			if r, ok := h.(*RetryHandler); ok && r.name == letter.Handler {
+				return q.call(ctx, r.MsgHandler, message)
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownHandler, letter.Handler)
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/handler"
+	"danmu-core/internal/model"
+	"testing"
+)
+
+func countDeadLetters(t *testing.T, room string) int64 {
+	t.Helper()
+	var n int64
+	if err := model.DB.Model(&model.DeadLetter{}).Where("room_display_id = ?", room).Count(&n).Error; err != nil {
+		t.Fatalf("count dead letters: %v", err)
+	}
+	return n
+}
+
+func TestRetryHandlerSkipsUnknownMethod(t *testing.T) {
+	room := "retry-unknown"
+	r := NewRetryHandler(handler.NewDyPrint2ConsoleHandler(room), "console", 1, room)
+	msg := &dystruct.Webcast_Im_Message{Method: "WebcastNotDefinedMessage", MsgId: 1}
+	if err := r.Handle(msg); err != nil {
+		t.Fatalf("Handle unknown method = %v, want nil", err)
+	}
+	if n := countDeadLetters(t, room); n != 0 {
+		t.Fatalf("dead letters = %d, want 0", n)
+	}
+}
+
+func TestRetryHandlerDeadLettersUnmarshalError(t *testing.T) {
+	room := "retry-unmarshal"
+	r := NewRetryHandler(handler.NewDyPrint2ConsoleHandler(room), "console", 1, room)
+	msg := &dystruct.Webcast_Im_Message{Method: platform.WebcastChatMessage, MsgId: 2, Payload: []byte{0xff}}
+	if err := r.Handle(msg); err == nil {
+		t.Fatal("Handle invalid payload = nil, want error")
+	}
+	if n := countDeadLetters(t, room); n != 1 {
+		t.Fatalf("dead letters = %d, want 1", n)
+	}
+}
+
+type countHandler struct{ n int }
+
+func (h *countHandler) Handle(msg interface{}) error {
+	h.n++
+	return nil
+}
+
+func TestQueueCallDoesNotRestartStoppedQueue(t *testing.T) {
+	h := &countHandler{}
+	q := newHandlerQueue(&Client{room: "queue-call"}, "console", h)
+	if err := q.call(context.Background(), h, "msg"); err != nil {
+		t.Fatalf("call = %v, want nil", err)
+	}
+	if h.n != 1 {
+		t.Fatalf("handled = %d, want 1", h.n)
+	}
+	if q.running {
+		t.Fatal("call restarted a stopped queue")
+	}
+}
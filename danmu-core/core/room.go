@@ -0,0 +1,32 @@
+package core
+
+import (
+	platform "danmu-core/core/platform/douyin"
+)
+
+// RoomInfoPlatform 可提供直播间元数据的平台
+type RoomInfoPlatform interface {
+	RoomInfo() *platform.RoomInfo
+}
+
+// RoomInfoHandler 需要在每次 CheckStream 后获取直播间元数据的 handler
+type RoomInfoHandler interface {
+	SetRoomInfo(info *platform.RoomInfo)
+}
+
+// syncRoomInfo 将 CheckStream 解析到的直播间元数据交给 handler
+func (c *Client) syncRoomInfo() {
+	rp, ok := c.p.(RoomInfoPlatform)
+	if !ok {
+		return
+	}
+	info := rp.RoomInfo()
+	if info == nil {
+		return
+	}
+	for _, h := range c.handlers {
+		if rh, ok := h.(RoomInfoHandler); ok {
+			rh.SetRoomInfo(info)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"danmu-core/generated/dystruct"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// errSpillFull 溢出文件达到 SpillMaxBytes
+var errSpillFull = errors.New("spill file is full")
+
+// spillFile spill 策略的溢出文件，每条记录为 4 字节长度加 protobuf 编码的消息
+// 读完所有记录后截断文件，进程异常退出时残留的记录在下次启动后继续处理
+type spillFile struct {
+	f      *os.File
+	path   string
+	max    int64
+	offset int64 // 下一条待读取记录的位置
+	end    int64 // 文件末尾
+}
+
+func openSpillFile(dir, room, name string, max int64) (*spillFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create spill dir error: %w", err)
+	}
+	path := filepath.Join(dir, spillFileName(room, name))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open spill file error: %w", err)
+	}
+	s := &spillFile{f: f, path: path, max: max}
+	if err := s.recover(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// recover 找到最后一条完整记录的位置，丢弃写入一半的记录
+func (s *spillFile) recover() error {
+	info, err := s.f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat spill file error: %w", err)
+	}
+	var header [4]byte
+	for s.end+4 <= info.Size() {
+		if _, err := s.f.ReadAt(header[:], s.end); err != nil {
+			return fmt.Errorf("read spill file error: %w", err)
+		}
+		next := s.end + 4 + int64(binary.LittleEndian.Uint32(header[:]))
+		if next > info.Size() {
+			break
+		}
+		s.end = next
+	}
+	if s.end < info.Size() {
+		if err := s.f.Truncate(s.end); err != nil {
+			return fmt.Errorf("truncate spill file error: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *spillFile) pending() bool {
+	return s.offset < s.end
+}
+
+// size 尚未读取的字节数
+func (s *spillFile) size() int64 {
+	return s.end - s.offset
+}
+
+func (s *spillFile) write(msg *dystruct.Webcast_Im_Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal spill message error: %w", err)
+	}
+	if s.max > 0 && s.end+4+int64(len(data)) > s.max {
+		return errSpillFull
+	}
+	buf := make([]byte, 4+len(data))
+	binary.LittleEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], data)
+	if _, err := s.f.WriteAt(buf, s.end); err != nil {
+		return err
+	}
+	s.end += int64(len(buf))
+	return nil
+}
+
+// read 读取下一条记录，全部读完后截断文件
+func (s *spillFile) read() (*dystruct.Webcast_Im_Message, error) {
+	var header [4]byte
+	if _, err := s.f.ReadAt(header[:], s.offset); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(header[:]))
+	if _, err := s.f.ReadAt(data, s.offset+4); err != nil && !(errors.Is(err, io.EOF) && len(data) == 0) {
+		return nil, err
+	}
+	s.offset += 4 + int64(len(data))
+	if s.offset >= s.end {
+		s.reset()
+	}
+	msg := &dystruct.Webcast_Im_Message{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("unmarshal spill message error: %w", err)
+	}
+	return msg, nil
+}
+
+// reset 丢弃所有记录
+func (s *spillFile) reset() {
+	s.offset, s.end = 0, 0
+	s.f.Truncate(0)
+}
+
+// close 所有记录都已处理时删除文件
+func (s *spillFile) close() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if s.pending() {
+		return nil
+	}
+	return os.Remove(s.path)
+}
+
+func spillFileName(room, name string) string {
+	var b strings.Builder
+	for _, r := range room + "_" + name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String() + ".spill"
+}
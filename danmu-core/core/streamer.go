@@ -0,0 +1,86 @@
+package core
+
+import (
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/internal/model"
+	"danmu-core/logger"
+)
+
+// StreamerPlatform 可提供主播账号信息的平台
+type StreamerPlatform interface {
+	Streamer() *platform.StreamerInfo
+	SetSecUid(secUid string)
+}
+
+// StreamerHandler 需要在消息中关联主播的 handler
+type StreamerHandler interface {
+	SetStreamer(id int64)
+}
+
+// initStreamer 使用配置中已关联的主播初始化平台，web_rid 失效时平台可通过 sec_uid 找回直播间
+func (c *Client) initStreamer(conf *model.LiveConf) {
+	if conf.StreamerID == 0 {
+		return
+	}
+	c.streamerID.Store(conf.StreamerID)
+	sp, ok := c.p.(StreamerPlatform)
+	if !ok {
+		return
+	}
+	streamer, err := model.GetStreamerByID(conf.StreamerID)
+	if err != nil {
+		logger.Warn().Err(err).Str("liveurl", c.liveurl).Int64("streamer_id", conf.StreamerID).Msg("get streamer failed")
+		return
+	}
+	sp.SetSecUid(streamer.SecUid)
+}
+
+// syncStreamer 将 CheckStream 解析到的主播信息写入 streamers，并更新 live_confs 的关联和 web_rid
+func (c *Client) syncStreamer() {
+	sp, ok := c.p.(StreamerPlatform)
+	if !ok {
+		return
+	}
+	info := sp.Streamer()
+	if info == nil || info.SecUid == "" {
+		return
+	}
+
+	streamer := &model.Streamer{
+		SecUid:    info.SecUid,
+		Uid:       info.Uid,
+		Nickname:  info.Nickname,
+		Avatar:    info.Avatar,
+		DisplayID: info.DisplayID,
+		WebRid:    info.WebRid,
+	}
+	if err := streamer.Upsert(); err != nil {
+		logger.Warn().Err(err).Str("liveurl", c.liveurl).Str("sec_uid", info.SecUid).Msg("upsert streamer failed")
+		return
+	}
+
+	old := c.streamerID.Swap(streamer.ID)
+	webRidChanged := info.WebRid != "" && info.WebRid != c.webRid
+	if c.confID != 0 && (old != streamer.ID || webRidChanged) {
+		conf := &model.LiveConf{ID: c.confID, RoomDisplayID: c.roomDisplayID}
+		// web_rid 变化后 reconciler 会按新的 url 重建任务
+		if err := conf.UpdateStreamer(streamer.ID, c.webRid, info.WebRid, "https://live.douyin.com/"+info.WebRid); err != nil {
+			logger.Warn().Err(err).Str("liveurl", c.liveurl).Int64("streamer_id", streamer.ID).Msg("update live conf streamer failed")
+		} else if webRidChanged {
+			logger.Info().
+				Str("liveurl", c.liveurl).
+				Str("old_web_rid", c.webRid).
+				Str("web_rid", info.WebRid).
+				Msg("主播直播间已变更")
+			c.webRid = info.WebRid
+		}
+	}
+
+	if old != streamer.ID {
+		for _, h := range c.handlers {
+			if sh, ok := h.(StreamerHandler); ok {
+				sh.SetStreamer(streamer.ID)
+			}
+		}
+	}
+}
@@ -1,28 +1,42 @@
 package core
 
 import (
-	"danmu-core/internal/handler"
+	"context"
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/internal/dedup"
 	"danmu-core/internal/model"
+	"danmu-core/internal/proxy"
 	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/tracing"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
-var TaskMap map[int64]*Task
-var muMap map[int64]*sync.Mutex
+var TaskMap = make(map[int64]*Task)
+var muMap = make(map[int64]*sync.Mutex)
 var mapMutex sync.RWMutex
 
 type Task struct {
 	url      string
 	taskId   int64
+	conf     model.LiveConf // 创建任务时使用的配置快照，用于 reconcile 比对
 	client   *Client
 	handlers []MsgHandler
 	RecvChan chan interface{}
 }
 
-// todo 提供修改cron表达式功能
-// todo 提供unsubscribe handler功能，用于变动直播间名字时重新订阅handler
-// todo handler改为可配置，添加rpc接口
+// TaskState 任务配置及运行状态
+type TaskState struct {
+	Conf    model.LiveConf
+	Running bool
+	Live    bool
+}
+
 // todo 添加cookie自定义
 // todo 修改初始化，支持distributed多节点部署，动态负载均衡
 // todo 修改platform和handler获取方式，使用plugin特性动态加载，可不重新启动项目就能动态加载新的Platform和handler
@@ -32,25 +46,52 @@ func InitTaskManager() {
 		logger.Error().Err(err).Msg("获取所有直播配置失败")
 		return
 	}
-	TaskMap = make(map[int64]*Task, len(confs))
-	muMap = make(map[int64]*sync.Mutex, len(confs))
 	for _, conf := range confs {
 		go func(c *model.LiveConf) {
-			if err := Add(c); err != nil {
+			if err := Add(context.Background(), c); err != nil && !errors.Is(err, ErrTaskExists) {
 				logger.Warn().Err(err).Str("liveurl", c.URL).Msg("Add task failed")
 			}
 		}(conf)
 	}
 }
 
-func Add(conf *model.LiveConf) error {
+// newTask 创建 client 并订阅配置中的 handler
+func newTask(conf *model.LiveConf) (*Task, error) {
+	client, err := MakeClient(conf)
+	if err != nil {
+		logger.Warn().Str("liveurl", conf.URL).Err(err).Msg("MakeClient failed")
+		return nil, err
+	}
+	handlers, names, err := newHandlers(conf)
+	if err != nil {
+		logger.Warn().Err(err).Str("liveurl", conf.URL).Msg("create handlers failed")
+		return nil, err
+	}
+	for i, h := range handlers {
+		client.subscribe(names[i], h)
+	}
+	return &Task{
+		url:      conf.URL,
+		taskId:   conf.ID,
+		conf:     *conf,
+		client:   client,
+		handlers: handlers,
+		RecvChan: client.RecvMsg,
+	}, nil
+}
+
+func Add(ctx context.Context, conf *model.LiveConf) (err error) {
+	_, span := tracing.Start(ctx, "core.Add")
+	span.SetAttributes(attribute.Int64("task.id", conf.ID), attribute.String("task.url", conf.URL))
+	defer func() { tracing.End(span, err) }()
+
 	mapMutex.RLock()
 	_, ok := muMap[conf.ID]
 	mapMutex.RUnlock()
 
 	if ok {
 		logger.Info().Str("liveurl", conf.URL).Msg("task already exists")
-		return nil
+		return ErrTaskExists
 	}
 
 	mapMutex.Lock()
@@ -58,7 +99,7 @@ func Add(conf *model.LiveConf) error {
 	if _, exists := muMap[conf.ID]; exists {
 		mapMutex.Unlock()
 		logger.Info().Str("liveurl", conf.URL).Msg("task already exists")
-		return nil
+		return ErrTaskExists
 	}
 	muMap[conf.ID] = &sync.Mutex{}
 	mu := muMap[conf.ID]
@@ -66,27 +107,13 @@ func Add(conf *model.LiveConf) error {
 
 	mu.Lock()
 	defer mu.Unlock()
-	client := MakeClient(conf)
-	if client == nil {
-		logger.Warn().Str("liveurl", conf.URL).Msg("MakeClient failed")
+	task, err := newTask(conf)
+	if err != nil {
 		mapMutex.Lock()
 		delete(muMap, conf.ID)
 		mapMutex.Unlock()
-		return fmt.Errorf("MakeClient failed,conf: %v", conf)
-	}
-	task := &Task{
-		url:      conf.URL,
-		taskId:   conf.ID,
-		client:   client,
-		handlers: []MsgHandler{},
-		RecvChan: client.RecvMsg,
-	}
-	h, err := handler.NewDymsg2dbHandler(conf)
-	if err != nil {
-		logger.Warn().Err(err).Str("liveurl", conf.URL).Msg("NewDymsg2dbHandler failed")
-		return fmt.Errorf("NewDymsg2dbHandler failed,conf: %v", conf)
+		return err
 	}
-	task.client.Subscribe(h)
 	if conf.Enable {
 		task.client.Start()
 	}
@@ -96,71 +123,96 @@ func Add(conf *model.LiveConf) error {
 	return nil
 }
 
-func Update(conf *model.LiveConf) error {
+// lockTask 获取任务及其操作锁，调用方负责 Unlock
+func lockTask(id int64) (*Task, *sync.Mutex, error) {
 	mapMutex.RLock()
-	mu, ok := muMap[conf.ID]
+	mu, ok := muMap[id]
+	mapMutex.RUnlock()
 	if !ok {
-		mapMutex.RUnlock()
-		logger.Info().Int64("id", conf.ID).Msg("task not found")
-		return nil
+		return nil, nil, ErrTaskNotFound
 	}
-	task, taskExists := TaskMap[conf.ID]
+
+	mu.Lock()
+	mapMutex.RLock()
+	task, ok := TaskMap[id]
 	mapMutex.RUnlock()
+	if !ok {
+		mu.Unlock()
+		return nil, nil, ErrTaskNotFound
+	}
+	return task, mu, nil
+}
+
+func Update(ctx context.Context, conf *model.LiveConf) (err error) {
+	_, span := tracing.Start(ctx, "core.Update")
+	span.SetAttributes(attribute.Int64("task.id", conf.ID), attribute.String("task.url", conf.URL))
+	defer func() { tracing.End(span, err) }()
 
-	if !taskExists {
+	task, mu, err := lockTask(conf.ID)
+	if err != nil {
 		logger.Warn().Int64("id", conf.ID).Msg("task not found")
-		return fmt.Errorf("task not found")
+		return err
 	}
-
-	mu.Lock()
 	defer mu.Unlock()
-	if conf.URL != task.url {
-		task.client.Stop()
-		mapMutex.Lock()
-		delete(TaskMap, conf.ID)
-		mapMutex.Unlock()
 
-		client := MakeClient(conf)
-		if client == nil {
-			logger.Warn().Str("liveurl", conf.URL).Msg("MakeClient failed")
-			mapMutex.Lock()
-			delete(muMap, conf.ID)
-			mapMutex.Unlock()
-			return fmt.Errorf("MakeClient failed,conf: %v", conf)
-		}
-		task := &Task{
-			url:      conf.URL,
-			taskId:   conf.ID,
-			client:   client,
-			handlers: []MsgHandler{},
-			RecvChan: client.RecvMsg,
-		}
-		h, err := handler.NewDymsg2dbHandler(conf)
+	if needRebuild(&task.conf, conf) {
+		// 先创建新任务，失败时保留旧任务继续运行
+		newT, err := newTask(conf)
 		if err != nil {
-			logger.Warn().Err(err).Str("liveurl", conf.URL).Msg("NewDymsg2dbHandler failed")
-			return fmt.Errorf("NewDymsg2dbHandler failed,conf: %v", conf)
+			return err
+		}
+		task.client.Stop()
+		metrics.DeleteRoom(task.client.room)
+		if newT.client.room != task.client.room {
+			// 直播间变化后旧直播间的代理、去重状态和断点不再使用，与 Delete 一样释放
+			proxy.Release(task.client.room)
+			dedup.Remove(task.client.room)
+			platform.RemoveCursor(task.client.room)
 		}
-		task.client.Subscribe(h)
 		if conf.Enable {
-			task.client.Start()
+			newT.client.Start()
 		}
 		mapMutex.Lock()
-		TaskMap[conf.ID] = task
+		TaskMap[conf.ID] = newT
 		mapMutex.Unlock()
 		return nil
 	}
 	if conf.Enable != task.client.enable.Load() {
 		task.client.SetEnable(conf.Enable)
 	}
+	mapMutex.Lock()
+	task.conf.Enable = conf.Enable
+	mapMutex.Unlock()
 	return nil
 }
-func Delete(id int64) error {
+
+// needRebuild URL、名称、cron、handler 变化时 client 和 handler 都需要重新创建，enable 变化只需启停
+func needRebuild(old, conf *model.LiveConf) bool {
+	return old.URL != conf.URL ||
+		old.Name != conf.Name ||
+		old.RoomDisplayID != conf.RoomDisplayID ||
+		cronOrDefault(old.Cron) != cronOrDefault(conf.Cron) ||
+		old.Handlers != conf.Handlers
+}
+
+func cronOrDefault(cron string) string {
+	if cron == "" {
+		return DefaultCron
+	}
+	return cron
+}
+
+func Delete(ctx context.Context, id int64) (err error) {
+	_, span := tracing.Start(ctx, "core.Delete")
+	span.SetAttributes(attribute.Int64("task.id", id))
+	defer func() { tracing.End(span, err) }()
+
 	mapMutex.RLock()
 	mu, ok := muMap[id]
 	if !ok {
 		mapMutex.RUnlock()
 		logger.Info().Int64("id", id).Msg("task not found")
-		return nil
+		return ErrTaskNotFound
 	}
 	task, taskExists := TaskMap[id]
 	mapMutex.RUnlock()
@@ -170,6 +222,10 @@ func Delete(id int64) error {
 
 	if taskExists {
 		task.client.Stop()
+		metrics.DeleteRoom(task.client.room)
+		proxy.Release(task.client.room)
+		dedup.Remove(task.client.room)
+		platform.RemoveCursor(task.client.room)
 	}
 
 	mapMutex.Lock()
@@ -178,3 +234,98 @@ func Delete(id int64) error {
 	mapMutex.Unlock()
 	return nil
 }
+
+// SetEnable 启动或停止任务，不修改数据库
+func SetEnable(ctx context.Context, id int64, enable bool) (state *TaskState, err error) {
+	_, span := tracing.Start(ctx, "core.SetEnable")
+	span.SetAttributes(attribute.Int64("task.id", id), attribute.Bool("task.enable", enable))
+	defer func() { tracing.End(span, err) }()
+
+	task, mu, err := lockTask(id)
+	if err != nil {
+		return nil, err
+	}
+	defer mu.Unlock()
+
+	if task.client.Enabled() != enable {
+		task.client.SetEnable(enable)
+	}
+	mapMutex.Lock()
+	task.conf.Enable = enable
+	mapMutex.Unlock()
+	return task.state(), nil
+}
+
+// Restart 重启已启用的任务
+func Restart(ctx context.Context, id int64) (state *TaskState, err error) {
+	_, span := tracing.Start(ctx, "core.Restart")
+	span.SetAttributes(attribute.Int64("task.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	task, mu, err := lockTask(id)
+	if err != nil {
+		return nil, err
+	}
+	defer mu.Unlock()
+
+	if !task.client.Enabled() {
+		return nil, fmt.Errorf("%w: %d", ErrTaskDisabled, id)
+	}
+	task.client.Restart()
+	return task.state(), nil
+}
+
+// RotateProxy 将任务当前的代理放入冷却并切换到其他代理，运行中的任务重新连接以立即使用新代理
+func RotateProxy(ctx context.Context, id int64) (proxyURL string, err error) {
+	_, span := tracing.Start(ctx, "core.RotateProxy")
+	span.SetAttributes(attribute.Int64("task.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	task, mu, err := lockTask(id)
+	if err != nil {
+		return "", err
+	}
+	defer mu.Unlock()
+
+	proxyURL, err = proxy.Rotate(task.client.room, proxy.ReasonManual)
+	if err != nil {
+		return "", err
+	}
+	if task.client.Enabled() {
+		task.client.Restart()
+	}
+	return proxyURL, nil
+}
+
+func Get(id int64) (*TaskState, error) {
+	mapMutex.RLock()
+	defer mapMutex.RUnlock()
+	task, ok := TaskMap[id]
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	return task.state(), nil
+}
+
+func List() []*TaskState {
+	mapMutex.RLock()
+	states := make([]*TaskState, 0, len(TaskMap))
+	for _, task := range TaskMap {
+		states = append(states, task.state())
+	}
+	mapMutex.RUnlock()
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Conf.ID < states[j].Conf.ID
+	})
+	return states
+}
+
+// state 调用方需持有 mapMutex 或任务锁
+func (t *Task) state() *TaskState {
+	return &TaskState{
+		Conf:    t.conf,
+		Running: t.client.Enabled(),
+		Live:    t.client.Live(),
+	}
+}
@@ -0,0 +1,233 @@
+package core
+
+import (
+	"context"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"sync"
+	"time"
+)
+
+// 消息的传输方式，对应 [transport] Mode
+const (
+	TransportAuto      = "auto"
+	TransportWebsocket = "websocket"
+	TransportPolling   = "polling"
+)
+
+// 切换传输方式的原因，作为指标 label
+const (
+	switchConnectFailures = "connect_failures"
+	switchDrops           = "drops"
+	switchPollFailures    = "poll_failures"
+	switchRetryWebsocket  = "retry_websocket"
+)
+
+const (
+	defaultConnectFailures = 3
+	defaultDrops           = 5
+	defaultDropWindow      = 5 * time.Minute
+	defaultPollingDuration = 10 * time.Minute
+	defaultPollFailures    = 5
+
+	pollRetryInterval = 5 * time.Second
+	minPollInterval   = 500 * time.Millisecond
+	defaultPollDelay  = time.Second
+)
+
+// PollingPlatform 支持通过 http 轮询获取消息的平台，websocket 不可用时使用
+// Poll 请求一次，解码后的消息与 websocket 一样写入 recvMsg，返回服务端建议的下次请求间隔。
+// resume 为切换到轮询 (或重新开始轮询) 后的第一次请求，平台按重连处理断点和缺口，之后的请求直接翻页
+type PollingPlatform interface {
+	Poll(ctx context.Context, recvMsg chan interface{}, cf context.CancelFunc, resume bool) (next time.Duration, err error)
+}
+
+// transportSwitch 按 websocket 的连接失败、断开次数和轮询的失败次数选择传输方式
+type transportSwitch struct {
+	room            string
+	liveurl         string
+	mode            string
+	connectFailures int
+	drops           int
+	dropWindow      time.Duration
+	pollingDuration time.Duration
+	pollFailures    int
+
+	mu            sync.Mutex
+	current       string
+	failures      int         // websocket 连续连接失败次数
+	dropTimes     []time.Time // DropWindow 内 websocket 断开的时间
+	pollErrors    int         // 轮询连续失败次数
+	pollingSince  time.Time
+	pollSupported bool
+}
+
+func newTransportSwitch(c *Client) *transportSwitch {
+	s := setting.TransportSetting
+	t := &transportSwitch{
+		room:            c.room,
+		liveurl:         c.liveurl,
+		mode:            s.Mode,
+		connectFailures: s.ConnectFailures,
+		drops:           s.Drops,
+		dropWindow:      time.Duration(s.DropWindow) * time.Second,
+		pollingDuration: time.Duration(s.PollingDuration) * time.Second,
+		pollFailures:    s.PollFailures,
+	}
+	if t.mode == "" {
+		t.mode = TransportAuto
+	}
+	if t.connectFailures <= 0 {
+		t.connectFailures = defaultConnectFailures
+	}
+	if t.drops <= 0 {
+		t.drops = defaultDrops
+	}
+	if t.dropWindow <= 0 {
+		t.dropWindow = defaultDropWindow
+	}
+	if t.pollingDuration <= 0 {
+		t.pollingDuration = defaultPollingDuration
+	}
+	if t.pollFailures <= 0 {
+		t.pollFailures = defaultPollFailures
+	}
+	_, t.pollSupported = c.p.(PollingPlatform)
+	t.current = TransportWebsocket
+	if t.mode == TransportPolling && t.pollSupported {
+		t.current = TransportPolling
+		t.pollingSince = time.Now()
+	}
+	t.setGauge()
+	return t
+}
+
+func (t *transportSwitch) Current() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current
+}
+
+// wsConnected websocket 连接成功，清除连续失败次数
+func (t *transportSwitch) wsConnected() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures = 0
+}
+
+// wsConnectFailed websocket 连接失败，返回是否已切换到轮询
+func (t *transportSwitch) wsConnectFailed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures++
+	if t.failures < t.connectFailures {
+		return false
+	}
+	return t.switchTo(TransportPolling, switchConnectFailures)
+}
+
+// wsDropped websocket 异常断开，返回是否已切换到轮询
+func (t *transportSwitch) wsDropped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	kept := t.dropTimes[:0]
+	for _, at := range t.dropTimes {
+		if now.Sub(at) < t.dropWindow {
+			kept = append(kept, at)
+		}
+	}
+	t.dropTimes = append(kept, now)
+	if len(t.dropTimes) < t.drops {
+		return false
+	}
+	return t.switchTo(TransportPolling, switchDrops)
+}
+
+// pollSucceeded 轮询成功，在轮询 PollingDuration 后返回 true 并切换回 websocket 重新尝试
+func (t *transportSwitch) pollSucceeded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pollErrors = 0
+	if time.Since(t.pollingSince) < t.pollingDuration {
+		return false
+	}
+	return t.switchTo(TransportWebsocket, switchRetryWebsocket)
+}
+
+// pollFailed 轮询失败，返回是否已切换回 websocket
+func (t *transportSwitch) pollFailed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pollErrors++
+	if t.pollErrors < t.pollFailures {
+		return false
+	}
+	return t.switchTo(TransportWebsocket, switchPollFailures)
+}
+
+// switchTo 调用方需持有 mu，Mode 不为 auto 或平台不支持轮询时不切换
+func (t *transportSwitch) switchTo(to, reason string) bool {
+	if t.mode != TransportAuto || !t.pollSupported || t.current == to {
+		return false
+	}
+	logger.Warn().Str("liveurl", t.liveurl).Str("from", t.current).Str("to", to).Str("reason", reason).Msg("切换消息传输方式")
+	metrics.TransportSwitches.WithLabelValues(t.room, to, reason).Inc()
+	t.current = to
+	t.failures = 0
+	t.dropTimes = nil
+	t.pollErrors = 0
+	if to == TransportPolling {
+		t.pollingSince = time.Now()
+	}
+	t.setGauge()
+	return true
+}
+
+// setGauge 调用方需持有 mu
+func (t *transportSwitch) setGauge() {
+	for _, tr := range []string{TransportWebsocket, TransportPolling} {
+		v := 0.0
+		if tr == t.current {
+			v = 1
+		}
+		metrics.Transport.WithLabelValues(t.room, tr).Set(v)
+	}
+}
+
+// pollMessages 通过 http 轮询获取消息，直到连接被关闭或切换回 websocket
+func (c *Client) pollMessages() {
+	defer close(c.RecvMsg)
+	pp := c.p.(PollingPlatform)
+	for resume := true; ; resume = false {
+		next, err := pp.Poll(c.ctx, c.RecvMsg, c.cancelFunc, resume)
+		if c.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			metrics.PollRequests.WithLabelValues(c.room, "error").Inc()
+			logger.Warn().Str("liveurl", c.liveurl).Err(err).Msg("轮询消息失败")
+			if c.transport.pollFailed() {
+				return
+			}
+			next = pollRetryInterval
+		} else {
+			metrics.PollRequests.WithLabelValues(c.room, "ok").Inc()
+			if c.transport.pollSucceeded() {
+				return
+			}
+			if next <= 0 {
+				next = defaultPollDelay
+			}
+			next = max(next, minPollInterval)
+		}
+		timer := time.NewTimer(next)
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const requestTimeout = 30 * time.Second
+
+// Client 通过 HTTP 接口访问 ClickHouse 兼容的数据库
+type Client struct {
+	endpoint string
+	database string
+	user     string
+	password string
+	http     *http.Client
+}
+
+func NewClient(endpoint, database, user, password string) *Client {
+	return &Client{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		database: database,
+		user:     user,
+		password: password,
+		http:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Exec 执行不返回数据的语句，database 为空时不指定默认库 (用于创建库)
+func (c *Client) Exec(ctx context.Context, database, query string) error {
+	return c.do(ctx, database, query, nil)
+}
+
+// Insert 以 JSONEachRow 格式批量写入
+func (c *Client) Insert(ctx context.Context, table string, rows []any) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return fmt.Errorf("encode %s row error: %w", table, err)
+		}
+	}
+	return c.do(ctx, c.database, "INSERT INTO "+table+" FORMAT JSONEachRow", &body)
+}
+
+func (c *Client) do(ctx context.Context, database, query string, body io.Reader) error {
+	params := url.Values{}
+	if database != "" {
+		params.Set("database", database)
+	}
+	// 语句放在 query 参数中，请求体只包含写入的数据
+	if body == nil {
+		body = strings.NewReader(query)
+	} else {
+		params.Set("query", query)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/?"+params.Encode(), body)
+	if err != nil {
+		return err
+	}
+	if c.user != "" {
+		req.Header.Set("X-ClickHouse-User", c.user)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("clickhouse status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
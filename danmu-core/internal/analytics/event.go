@@ -0,0 +1,46 @@
+package analytics
+
+// Event 事件表共有的列
+type Event struct {
+	MsgID         uint64 `json:"msg_id"`
+	RoomID        uint64 `json:"room_id"`
+	RoomDisplayId string `json:"room_display_id"`
+	RoomName      string `json:"room_name"`
+	StreamerID    int64  `json:"streamer_id"`
+	UserID        uint64 `json:"user_id"`
+	UserName      string `json:"user_name"`
+	UserDisplayId string `json:"user_display_id"`
+	Timestamp     int64  `json:"timestamp"` // 毫秒
+}
+
+// ChatEvent content 为弹幕原文，不带昵称前缀
+type ChatEvent struct {
+	Event
+	Content string `json:"content"`
+}
+
+// GiftEvent combo_count 为连击的累计数量，解析失败时为 1
+type GiftEvent struct {
+	Event
+	ToUserID        uint64 `json:"to_user_id"`
+	ToUserName      string `json:"to_user_name"`
+	ToUserDisplayId string `json:"to_user_display_id"`
+	GiftID          int64  `json:"gift_id"`
+	GiftName        string `json:"gift_name"`
+	DiamondCount    uint32 `json:"diamond_count"`
+	ComboCount      uint64 `json:"combo_count"`
+	Image           string `json:"image_url"`
+	Message         string `json:"message"`
+}
+
+type MemberEvent struct {
+	Event
+	MemberCount uint64 `json:"member_count"`
+	Action      uint64 `json:"action"`
+}
+
+type LikeEvent struct {
+	Event
+	Count uint64 `json:"count"`
+	Total uint64 `json:"total"`
+}
@@ -0,0 +1,69 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	TableChatEvents   = "chat_events"
+	TableGiftEvents   = "gift_events"
+	TableMemberEvents = "member_events"
+	TableLikeEvents   = "like_events"
+)
+
+// 所有事件表共有的列，timestamp 为毫秒时间戳
+const eventColumns = `
+    msg_id          UInt64,
+    room_id         UInt64,
+    room_display_id LowCardinality(String),
+    room_name       String,
+    streamer_id     Int64,
+    user_id         UInt64,
+    user_name       String,
+    user_display_id String,
+    timestamp       Int64,`
+
+// ReplacingMergeTree 合并时去掉重复推送的同一条消息
+const eventEngine = `
+) ENGINE = ReplacingMergeTree
+PARTITION BY toYYYYMM(fromUnixTimestamp64Milli(timestamp))
+ORDER BY (room_display_id, timestamp, msg_id)`
+
+var schemas = []struct {
+	table   string
+	columns string
+}{
+	{TableChatEvents, `
+    content         String`},
+	{TableGiftEvents, `
+    to_user_id         UInt64,
+    to_user_name       String,
+    to_user_display_id String,
+    gift_id            Int64,
+    gift_name          String,
+    diamond_count      UInt32,
+    combo_count        UInt64,
+    image_url          String,
+    message            String`},
+	{TableMemberEvents, `
+    member_count    UInt64,
+    action          UInt64`},
+	{TableLikeEvents, `
+    count           UInt64,
+    total           UInt64`},
+}
+
+// EnsureSchema 创建库和事件表，已存在时不做任何操作
+func (c *Client) EnsureSchema(ctx context.Context) error {
+	if err := c.Exec(ctx, "", "CREATE DATABASE IF NOT EXISTS "+c.database); err != nil {
+		return fmt.Errorf("create database %s error: %w", c.database, err)
+	}
+	for _, s := range schemas {
+		query := "CREATE TABLE IF NOT EXISTS " + s.table + " (" + eventColumns + s.columns + eventEngine
+		if err := c.Exec(ctx, c.database, query); err != nil {
+			return fmt.Errorf("create table %s error: %w", s.table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package analytics
+
+import (
+	"context"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize     = 1000
+	defaultFlushInterval = 5 * time.Second
+	defaultMaxPending    = 100000
+)
+
+// Writer 按表缓存事件，累计 batchSize 行或每隔 interval 批量写入
+// 写入失败的行放回缓存等待下次重试，超过 maxPending 时丢弃最早的行
+type Writer struct {
+	client     *Client
+	batchSize  int
+	maxPending int
+	interval   time.Duration
+
+	mu      sync.Mutex
+	pending map[string][]any
+
+	flushMu sync.Mutex
+	ready   bool // 表结构已创建
+
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewWriter(client *Client, batchSize int, interval time.Duration, maxPending int) *Writer {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	if maxPending <= 0 {
+		maxPending = defaultMaxPending
+	}
+	maxPending = max(maxPending, batchSize)
+	return &Writer{
+		client:     client,
+		batchSize:  batchSize,
+		maxPending: maxPending,
+		interval:   interval,
+		pending:    make(map[string][]any),
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+func (w *Writer) Add(table string, row any) {
+	w.mu.Lock()
+	rows := append(w.pending[table], row)
+	if len(rows) > w.maxPending {
+		rows = rows[1:]
+		metrics.AnalyticsRows.WithLabelValues(table, "dropped").Inc()
+	}
+	w.pending[table] = rows
+	full := len(rows) >= w.batchSize
+	w.mu.Unlock()
+	if full {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run 定期写入，直到 Close
+func (w *Writer) Run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			if err := w.Flush(); err != nil {
+				logger.Warn().Err(err).Msg("flush analytics events failed")
+			}
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+		if err := w.Flush(); err != nil {
+			logger.Warn().Err(err).Msg("flush analytics events failed")
+		}
+	}
+}
+
+// Flush 写入所有缓存的事件，第一次写入前创建表结构
+func (w *Writer) Flush() error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	ctx := context.Background()
+	if !w.ready {
+		if err := w.client.EnsureSchema(ctx); err != nil {
+			return err
+		}
+		w.ready = true
+	}
+
+	w.mu.Lock()
+	batches := w.pending
+	w.pending = make(map[string][]any, len(batches))
+	w.mu.Unlock()
+
+	var errs []error
+	for table, rows := range batches {
+		if len(rows) == 0 {
+			continue
+		}
+		start := time.Now()
+		err := w.client.Insert(ctx, table, rows)
+		metrics.ObserveDBInsert(table, start, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("insert %s error: %w", table, err))
+			w.requeue(table, rows)
+			continue
+		}
+		metrics.AnalyticsRows.WithLabelValues(table, "success").Add(float64(len(rows)))
+	}
+	return errors.Join(errs...)
+}
+
+// requeue 失败的行放回队首，保证重试时的顺序
+func (w *Writer) requeue(table string, rows []any) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	merged := append(rows, w.pending[table]...)
+	if drop := len(merged) - w.maxPending; drop > 0 {
+		merged = merged[drop:]
+		metrics.AnalyticsRows.WithLabelValues(table, "dropped").Add(float64(drop))
+	}
+	w.pending[table] = merged
+}
+
+// Close 停止后台写入并写入剩余的事件
+func (w *Writer) Close() {
+	close(w.done)
+	<-w.stopped
+}
+
+var defaultWriter *Writer
+
+// Init 按 [analytics] 配置创建默认 Writer 并在后台写入
+func Init() {
+	s := setting.AnalyticsSetting
+	client := NewClient(s.Endpoint, s.Database, s.User, s.Password)
+	defaultWriter = NewWriter(client, s.BatchSize, time.Duration(s.FlushInterval)*time.Second, s.MaxPending)
+	// 分析库暂时不可用时不影响启动，第一次写入前会再次尝试
+	if err := client.EnsureSchema(context.Background()); err != nil {
+		logger.Warn().Err(err).Str("endpoint", s.Endpoint).Msg("create analytics schema failed")
+	} else {
+		defaultWriter.ready = true
+	}
+	go defaultWriter.Run()
+	logger.Info().Str("endpoint", s.Endpoint).Str("database", s.Database).Msg("analytics sink started")
+}
+
+func Enabled() bool {
+	return defaultWriter != nil
+}
+
+// Add 写入默认 Writer，未启用时忽略
+func Add(table string, row any) {
+	if defaultWriter != nil {
+		defaultWriter.Add(table, row)
+	}
+}
+
+func Flush() error {
+	if defaultWriter == nil {
+		return nil
+	}
+	return defaultWriter.Flush()
+}
+
+func Close() {
+	if defaultWriter != nil {
+		defaultWriter.Close()
+	}
+}
@@ -0,0 +1,282 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClickHouse 记录收到的语句和写入的行，fail 为 true 时所有请求返回 500
+type fakeClickHouse struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	fail    bool
+	ddl     []request
+	inserts []insert
+}
+
+type request struct {
+	database string
+	query    string
+}
+
+type insert struct {
+	table string
+	rows  []Event
+}
+
+func newFakeClickHouse(t *testing.T) *fakeClickHouse {
+	f := &fakeClickHouse{}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeClickHouse) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("X-ClickHouse-User") != "default" || r.Header.Get("X-ClickHouse-Key") != "secret" {
+		http.Error(w, "authentication failed", http.StatusUnauthorized)
+		return
+	}
+	if f.fail {
+		http.Error(w, "Code: 242. DB::Exception: Table is in readonly mode", http.StatusInternalServerError)
+		return
+	}
+	database := r.URL.Query().Get("database")
+	query := r.URL.Query().Get("query")
+	if query == "" {
+		body, _ := io.ReadAll(r.Body)
+		f.ddl = append(f.ddl, request{database: database, query: string(body)})
+		return
+	}
+	table, ok := strings.CutSuffix(strings.TrimPrefix(query, "INSERT INTO "), " FORMAT JSONEachRow")
+	if !ok {
+		http.Error(w, "unexpected query: "+query, http.StatusBadRequest)
+		return
+	}
+	ins := insert{table: table}
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ins.rows = append(ins.rows, e)
+	}
+	f.inserts = append(f.inserts, ins)
+}
+
+func (f *fakeClickHouse) setFail(fail bool) {
+	f.mu.Lock()
+	f.fail = fail
+	f.mu.Unlock()
+}
+
+func (f *fakeClickHouse) snapshot() ([]request, []insert) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]request(nil), f.ddl...), append([]insert(nil), f.inserts...)
+}
+
+func (f *fakeClickHouse) client() *Client {
+	return NewClient(f.URL+"/", "danmu", "default", "secret")
+}
+
+func msgIDs(rows []Event) []uint64 {
+	ids := make([]uint64, len(rows))
+	for i, r := range rows {
+		ids[i] = r.MsgID
+	}
+	return ids
+}
+
+func pendingIDs(w *Writer, table string) []uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ids := make([]uint64, len(w.pending[table]))
+	for i, r := range w.pending[table] {
+		ids[i] = r.(*ChatEvent).MsgID
+	}
+	return ids
+}
+
+func chat(id uint64) *ChatEvent {
+	return &ChatEvent{Event: Event{MsgID: id, RoomDisplayId: "room", Timestamp: int64(id)}, Content: "hi"}
+}
+
+func TestEnsureSchema(t *testing.T) {
+	f := newFakeClickHouse(t)
+	if err := f.client().EnsureSchema(context.Background()); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+	ddl, _ := f.snapshot()
+	if len(ddl) != 1+len(schemas) {
+		t.Fatalf("got %d statements, want %d", len(ddl), 1+len(schemas))
+	}
+	if ddl[0].database != "" || ddl[0].query != "CREATE DATABASE IF NOT EXISTS danmu" {
+		t.Errorf("first statement = %+v, want CREATE DATABASE without database", ddl[0])
+	}
+	for i, s := range schemas {
+		got := ddl[i+1]
+		if got.database != "danmu" {
+			t.Errorf("%s: database = %q, want danmu", s.table, got.database)
+		}
+		if !strings.HasPrefix(got.query, "CREATE TABLE IF NOT EXISTS "+s.table+" (") {
+			t.Errorf("%s: unexpected statement %q", s.table, got.query)
+		}
+		for _, want := range []string{"msg_id          UInt64", "ENGINE = ReplacingMergeTree", "ORDER BY (room_display_id, timestamp, msg_id)"} {
+			if !strings.Contains(got.query, want) {
+				t.Errorf("%s: statement missing %q", s.table, want)
+			}
+		}
+	}
+}
+
+func TestEnsureSchemaError(t *testing.T) {
+	f := newFakeClickHouse(t)
+	f.setFail(true)
+	err := f.client().EnsureSchema(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Fatalf("EnsureSchema error = %v, want status 500", err)
+	}
+}
+
+func TestWriterFlushBatchesPerTable(t *testing.T) {
+	f := newFakeClickHouse(t)
+	w := NewWriter(f.client(), 10, time.Hour, 100)
+	for id := uint64(1); id <= 3; id++ {
+		w.Add(TableChatEvents, chat(id))
+	}
+	w.Add(TableGiftEvents, &GiftEvent{Event: Event{MsgID: 9}, GiftName: "rose", ComboCount: 1})
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	// 第二次 Flush 没有新的行，不再建表也不写入
+	if err := w.Flush(); err != nil {
+		t.Fatalf("second Flush: %v", err)
+	}
+
+	ddl, inserts := f.snapshot()
+	if len(ddl) != 1+len(schemas) {
+		t.Errorf("schema statements = %d, want %d (created once)", len(ddl), 1+len(schemas))
+	}
+	got := map[string][]uint64{}
+	for _, ins := range inserts {
+		if _, dup := got[ins.table]; dup {
+			t.Errorf("table %s written in more than one batch", ins.table)
+		}
+		got[ins.table] = msgIDs(ins.rows)
+	}
+	if !slices.Equal(got[TableChatEvents], []uint64{1, 2, 3}) {
+		t.Errorf("chat_events rows = %v, want [1 2 3]", got[TableChatEvents])
+	}
+	if !slices.Equal(got[TableGiftEvents], []uint64{9}) {
+		t.Errorf("gift_events rows = %v, want [9]", got[TableGiftEvents])
+	}
+}
+
+func TestWriterRunFlushesFullBatch(t *testing.T) {
+	f := newFakeClickHouse(t)
+	w := NewWriter(f.client(), 2, time.Hour, 100)
+	go w.Run()
+	w.Add(TableChatEvents, chat(1))
+	w.Add(TableChatEvents, chat(2))
+
+	// interval 为一小时，只有累计到 batchSize 才会触发写入
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, inserts := f.snapshot(); len(inserts) > 0 {
+			if !slices.Equal(msgIDs(inserts[0].rows), []uint64{1, 2}) {
+				t.Errorf("batch rows = %v, want [1 2]", msgIDs(inserts[0].rows))
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("full batch was not flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Close 写入剩余不足一批的行
+	w.Add(TableChatEvents, chat(3))
+	w.Close()
+	_, inserts := f.snapshot()
+	if last := inserts[len(inserts)-1]; !slices.Equal(msgIDs(last.rows), []uint64{3}) {
+		t.Errorf("rows flushed on Close = %v, want [3]", msgIDs(last.rows))
+	}
+}
+
+func TestWriterRetriesFailedBatch(t *testing.T) {
+	f := newFakeClickHouse(t)
+	w := NewWriter(f.client(), 10, time.Hour, 100)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	f.setFail(true)
+	w.Add(TableChatEvents, chat(1))
+	w.Add(TableChatEvents, chat(2))
+	if err := w.Flush(); err == nil {
+		t.Fatal("Flush with failing server = nil, want error")
+	}
+	if ids := pendingIDs(w, TableChatEvents); !slices.Equal(ids, []uint64{1, 2}) {
+		t.Fatalf("pending after failure = %v, want [1 2]", ids)
+	}
+
+	// 失败的行在新加入的行之前重试
+	w.Add(TableChatEvents, chat(3))
+	f.setFail(false)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("retry Flush: %v", err)
+	}
+	_, inserts := f.snapshot()
+	if len(inserts) != 1 || !slices.Equal(msgIDs(inserts[0].rows), []uint64{1, 2, 3}) {
+		t.Fatalf("inserts = %+v, want one batch [1 2 3]", inserts)
+	}
+	if ids := pendingIDs(w, TableChatEvents); len(ids) != 0 {
+		t.Errorf("pending after retry = %v, want empty", ids)
+	}
+}
+
+func TestWriterMaxPendingDropsOldest(t *testing.T) {
+	f := newFakeClickHouse(t)
+	w := NewWriter(f.client(), 2, time.Hour, 3)
+	for id := uint64(1); id <= 5; id++ {
+		w.Add(TableChatEvents, chat(id))
+	}
+	if ids := pendingIDs(w, TableChatEvents); !slices.Equal(ids, []uint64{3, 4, 5}) {
+		t.Fatalf("pending after Add = %v, want [3 4 5]", ids)
+	}
+
+	// 写入失败后放回的行仍受 maxPending 限制
+	if err := w.Flush(); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	f.setFail(true)
+	w.Add(TableChatEvents, chat(6))
+	w.Add(TableChatEvents, chat(7))
+	if err := w.Flush(); err == nil {
+		t.Fatal("Flush with failing server = nil, want error")
+	}
+	w.Add(TableChatEvents, chat(8))
+	if ids := pendingIDs(w, TableChatEvents); !slices.Equal(ids, []uint64{6, 7, 8}) {
+		t.Fatalf("pending after failed Flush = %v, want [6 7 8]", ids)
+	}
+
+	// Flush 期间新加入的行与放回的行合计超过 maxPending 时丢弃最早放回的行
+	w.requeue(TableChatEvents, []any{chat(4), chat(5)})
+	if ids := pendingIDs(w, TableChatEvents); !slices.Equal(ids, []uint64{6, 7, 8}) {
+		t.Fatalf("pending after requeue = %v, want [6 7 8]", ids)
+	}
+}
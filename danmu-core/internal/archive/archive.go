@@ -0,0 +1,140 @@
+// Package archive 将过期的消息分区写入本地 parquet 文件，文件格式需与 danmu-http 的 archive 包保持一致
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+)
+
+var unsafePathRg = regexp.MustCompile(`[^0-9A-Za-z_.-]`)
+
+// CommonMessageRow common_messages 的归档行，也直接用于从数据库读取
+type CommonMessageRow struct {
+	ID             uint64 `parquet:"id"`
+	MessageType    string `parquet:"message_type,dict"`
+	RoomID         uint64 `parquet:"room_id"`
+	RoomDisplayId  string `parquet:"room_display_id,dict"`
+	RoomName       string `parquet:"room_name,dict"`
+	UserName       string `parquet:"user_name"`
+	UserID         uint64 `parquet:"user_id"`
+	UserDisplayId  string `parquet:"user_display_id"`
+	Content        string `parquet:"content"`
+	Timestamp      uint64 `parquet:"timestamp"`
+	FavoriteUserId uint64 `parquet:"favorite_user_id"`
+	StreamerID     int64  `parquet:"streamer_id"`
+}
+
+// GiftMessageRow gift_messages 的归档行
+type GiftMessageRow struct {
+	ID              int64  `parquet:"id"`
+	UserID          uint64 `parquet:"user_id"`
+	UserName        string `parquet:"user_name"`
+	UserDisplayId   string `parquet:"user_display_id"`
+	ToUserID        uint64 `parquet:"to_user_id"`
+	ToUserName      string `parquet:"to_user_name,dict"`
+	ToUserDisplayId string `parquet:"to_user_display_id,dict"`
+	GiftName        string `parquet:"gift_name,dict"`
+	GiftID          int64  `parquet:"gift_id"`
+	RoomID          uint64 `parquet:"room_id"`
+	RoomDisplayId   string `parquet:"room_display_id,dict"`
+	RoomName        string `parquet:"room_name,dict"`
+	Message         string `parquet:"message"`
+	Timestamp       uint64 `parquet:"timestamp"`
+	DiamondCount    int32  `parquet:"diamond_count"`
+	Image           string `parquet:"image_url,dict" gorm:"column:image_url"`
+	RepeatEnd       int32  `parquet:"repeat_end"`
+	ComboCount      string `parquet:"combo_count"`
+	StreamerID      int64  `parquet:"streamer_id"`
+}
+
+// File 写入完成的归档文件
+type File struct {
+	Path string
+	Rows int64
+	Size int64
+}
+
+// Codec 根据配置返回压缩方式，默认 zstd
+func Codec(name string) (compress.Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "zstd":
+		return &parquet.Zstd, nil
+	case "snappy":
+		return &parquet.Snappy, nil
+	case "gzip":
+		return &parquet.Gzip, nil
+	case "none":
+		return &parquet.Uncompressed, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", name)
+	}
+}
+
+// Path 返回 <dir>/<table>/<room>/<yyyymm>-<unix>.parquet，同一个月可能因迟到的消息产生多个文件
+func Path(dir, table, room string, month time.Time) string {
+	name := fmt.Sprintf("%s-%d.parquet", month.Format("200601"), time.Now().Unix())
+	return filepath.Join(dir, table, unsafePathRg.ReplaceAllString(room, "_"), name)
+}
+
+// Write 依次调用 next 获取数据写入 path，next 返回空切片时结束
+// 先写入临时文件，完成后重命名，中途失败不会留下不完整的归档
+func Write[T any](path string, codec compress.Codec, next func() ([]T, error)) (file *File, err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	w := parquet.NewGenericWriter[T](f, parquet.Compression(codec))
+	var rows int64
+	for {
+		batch, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if _, err := w.Write(batch); err != nil {
+			return nil, fmt.Errorf("write %s error: %w", path, err)
+		}
+		rows += int64(len(batch))
+	}
+	if rows == 0 {
+		return nil, errors.New("no rows to archive")
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("close parquet writer error: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return &File{Path: path, Rows: rows, Size: info.Size()}, nil
+}
@@ -0,0 +1,150 @@
+// Package deadletter 保存 handler 重试耗尽或无法解析的原始消息
+// 数据库不可用时先追加到 DeadLetterFile，恢复后定时导入 dead_letters
+package deadletter
+
+import (
+	"bufio"
+	"danmu-core/internal/model"
+	"danmu-core/logger"
+	"danmu-core/setting"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 死信的写入位置，作为指标 label
+const (
+	ResultDB   = "db"
+	ResultFile = "file"
+	ResultLost = "lost"
+)
+
+const importInterval = 30 * time.Second
+
+var (
+	mu      sync.Mutex
+	file    string
+	done    chan struct{}
+	stopped chan struct{}
+)
+
+// Init 读取 [retry] 配置，定时将文件中的死信导入数据库
+func Init() error {
+	file = setting.RetrySetting.DeadLetterFile
+	if file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("create dead letter dir error: %w", err)
+	}
+	done = make(chan struct{})
+	stopped = make(chan struct{})
+	go run()
+	return nil
+}
+
+// Add 写入 dead_letters，失败时追加到文件，返回写入位置
+func Add(letter *model.DeadLetter) string {
+	err := model.SaveDeadLetters([]*model.DeadLetter{letter})
+	if err == nil {
+		return ResultDB
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if file == "" {
+		logger.Error().Err(err).Str("room_display_id", letter.RoomDisplayId).Str("handler", letter.Handler).
+			Uint64("msg_id", letter.MsgID).Msg("save dead letter failed, message lost")
+		return ResultLost
+	}
+	if ferr := appendFile(letter); ferr != nil {
+		logger.Error().Err(ferr).Str("room_display_id", letter.RoomDisplayId).Str("handler", letter.Handler).
+			Uint64("msg_id", letter.MsgID).Msg("save dead letter to file failed, message lost")
+		return ResultLost
+	}
+	return ResultFile
+}
+
+func appendFile(letter *model.DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func run() {
+	defer close(stopped)
+	ticker := time.NewTicker(importInterval)
+	defer ticker.Stop()
+	for {
+		if err := importFile(); err != nil {
+			logger.Warn().Err(err).Str("file", file).Msg("import dead letters failed")
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// importFile 将文件中的死信一次性导入数据库，成功后删除文件，失败时保留等待下次导入
+func importFile() error {
+	mu.Lock()
+	defer mu.Unlock()
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var letters []*model.DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var letter model.DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			// 写入一半的行 (进程异常退出) 丢弃
+			logger.Warn().Err(err).Str("file", file).Msg("skip invalid dead letter line")
+			continue
+		}
+		letters = append(letters, &letter)
+	}
+	err = scanner.Err()
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err := model.ImportDeadLetters(letters); err != nil {
+		return err
+	}
+	if len(letters) > 0 {
+		logger.Info().Int("count", len(letters)).Str("file", file).Msg("imported dead letters")
+	}
+	return os.Remove(file)
+}
+
+// Close 退出前再导入一次
+func Close() {
+	if done == nil {
+		return
+	}
+	close(done)
+	<-stopped
+	if err := importFile(); err != nil {
+		logger.Warn().Err(err).Str("file", file).Msg("import dead letters failed, will retry on next start")
+	}
+}
@@ -0,0 +1,187 @@
+// Package dedup 按直播间对消息 id 去重，所有 handler 共用，断线重连、重启和更新任务后仍然有效
+//
+// 每个直播间的状态由水位线和若干个按时间切分的布隆过滤器 (桶) 组成：
+// 水位线为已处理消息的最大时间戳，早于 水位线-Window 的消息视为重放直接丢弃，
+// 其余消息在所有桶中查找，新消息写入最新的桶。每个桶的时间跨度为 Window/Buckets，
+// 消息数达到 Capacity 时提前切换，桶内最新的消息早于 水位线-Window 后整桶丢弃。
+// 布隆过滤器存在误判，误判的新消息会被当作重复丢弃，概率由 FalsePositive 控制
+package dedup
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	ResultNew       = "new"
+	ResultDuplicate = "duplicate"
+	ResultExpired   = "expired" // 早于去重时间范围的重放消息
+)
+
+// maxClockSkew 消息时间戳超过当前时间的部分按当前时间处理，避免异常时间戳推高水位线
+const maxClockSkew = time.Minute
+
+type Config struct {
+	Window        time.Duration
+	Buckets       int
+	Capacity      int
+	FalsePositive float64
+}
+
+func (c *Config) applyDefaults() {
+	if c.Window <= 0 {
+		c.Window = time.Hour
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = 6
+	}
+	if c.Capacity <= 0 {
+		c.Capacity = 50000
+	}
+	if c.FalsePositive <= 0 || c.FalsePositive >= 1 {
+		c.FalsePositive = 0.0001
+	}
+}
+
+type bucket struct {
+	start int64 // 桶内消息的最早和最晚时间戳
+	end   int64
+	count int
+	bits  []uint64
+}
+
+func (b *bucket) contains(m uint64, k int, h1, h2 uint64) bool {
+	for i := 0; i < k; i++ {
+		pos := (h1 + uint64(i)*h2) % m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bucket) add(m uint64, k int, h1, h2 uint64) {
+	for i := 0; i < k; i++ {
+		pos := (h1 + uint64(i)*h2) % m
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+	b.count++
+}
+
+// Filter 一个直播间的去重状态
+type Filter struct {
+	mu        sync.Mutex
+	conf      Config
+	m         uint64 // 每个桶的位数
+	k         int    // 哈希函数个数
+	span      int64  // 每个桶的时间跨度，毫秒
+	buckets   []*bucket
+	watermark int64
+	dirty     bool
+
+	store *fileStore // 为空时不保存
+}
+
+func NewFilter(conf Config) *Filter {
+	conf.applyDefaults()
+	m, k := bloomSize(conf.Capacity, conf.FalsePositive)
+	span := conf.Window.Milliseconds() / int64(conf.Buckets)
+	if span <= 0 {
+		span = 1
+	}
+	return &Filter{conf: conf, m: m, k: k, span: span}
+}
+
+// bloomSize 按容量和误判率计算位数 (按 64 对齐) 和哈希函数个数
+func bloomSize(n int, p float64) (uint64, int) {
+	bits := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	m := (uint64(bits) + 63) / 64 * 64
+	k := int(math.Round(float64(m) / float64(n) * math.Ln2))
+	return m, min(max(k, 1), 16)
+}
+
+// Check 返回消息的去重结果，新消息同时写入过滤器；ts 为消息的毫秒时间戳，0 表示未知
+func (f *Filter) Check(msgID uint64, ts int64) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now().UnixMilli()
+	if ts <= 0 || ts > now+maxClockSkew.Milliseconds() {
+		ts = now
+	}
+	if f.watermark > 0 && ts < f.watermark-f.conf.Window.Milliseconds() {
+		return ResultExpired
+	}
+	h1, h2 := hashes(msgID)
+	for _, b := range f.buckets {
+		if b.contains(f.m, f.k, h1, h2) {
+			return ResultDuplicate
+		}
+	}
+	f.current(ts).add(f.m, f.k, h1, h2)
+	if ts > f.watermark {
+		f.watermark = ts
+		f.expire()
+	}
+	f.dirty = true
+	if f.store != nil {
+		f.store.maybeSave(f)
+	}
+	return ResultNew
+}
+
+// current 返回写入 ts 的桶，最新的桶已满或超过时间跨度时新建
+func (f *Filter) current(ts int64) *bucket {
+	if n := len(f.buckets); n > 0 {
+		b := f.buckets[n-1]
+		if b.count < f.conf.Capacity && ts-b.start < f.span {
+			b.start = min(b.start, ts)
+			b.end = max(b.end, ts)
+			return b
+		}
+	}
+	b := &bucket{start: ts, end: ts, bits: make([]uint64, f.m/64)}
+	f.buckets = append(f.buckets, b)
+	// 消息数远超容量时限制内存，丢弃最早的桶
+	if limit := 4 * f.conf.Buckets; len(f.buckets) > limit {
+		f.buckets = append(f.buckets[:0], f.buckets[len(f.buckets)-limit:]...)
+	}
+	return b
+}
+
+// expire 丢弃整桶过期的数据
+func (f *Filter) expire() {
+	since := f.watermark - f.conf.Window.Milliseconds()
+	kept := f.buckets[:0]
+	for _, b := range f.buckets {
+		if b.end >= since {
+			kept = append(kept, b)
+		}
+	}
+	for i := len(kept); i < len(f.buckets); i++ {
+		f.buckets[i] = nil
+	}
+	f.buckets = kept
+}
+
+// Save 将状态写入文件，没有变化或不保存时直接返回
+func (f *Filter) Save() error {
+	if f.store == nil {
+		return nil
+	}
+	return f.store.save(f)
+}
+
+// hashes 双重哈希的两个基础值
+func hashes(id uint64) (uint64, uint64) {
+	h1 := splitmix64(id)
+	h2 := splitmix64(id^0x9e3779b97f4a7c15) | 1
+	return h1, h2
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,235 @@
+package dedup
+
+import (
+	"bufio"
+	"bytes"
+	"danmu-core/logger"
+	"danmu-core/setting"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	fileMagic   = "DDUP"
+	fileVersion = 1
+
+	defaultSaveInterval = 30 * time.Second
+)
+
+var (
+	registryMu   sync.Mutex
+	filters      = make(map[string]*Filter)
+	defaultConf  Config
+	dir          string
+	saveInterval = defaultSaveInterval
+)
+
+// Init 读取 [dedup] 配置，Dir 为空时只在内存中去重
+func Init() error {
+	c := setting.DedupSetting
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	defaultConf = Config{
+		Window:        time.Duration(c.Window) * time.Second,
+		Buckets:       c.Buckets,
+		Capacity:      c.Capacity,
+		FalsePositive: c.FalsePositive,
+	}
+	if c.SaveInterval > 0 {
+		saveInterval = time.Duration(c.SaveInterval) * time.Second
+	}
+	dir = c.Dir
+	if dir == "" {
+		logger.Warn().Msg("dedup dir is empty, message dedup state will be lost on restart")
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create dedup dir error: %w", err)
+	}
+	return nil
+}
+
+// Open 返回直播间共用的 Filter，第一次打开时从文件恢复；同一直播间更新任务后继续使用同一个 Filter
+func Open(room string) *Filter {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if f, ok := filters[room]; ok {
+		return f
+	}
+	f := NewFilter(defaultConf)
+	if dir != "" {
+		f.store = &fileStore{path: filepath.Join(dir, fileName(room)), interval: saveInterval, lastSave: time.Now()}
+		if err := f.store.load(f); err != nil {
+			logger.Warn().Err(err).Str("room", room).Str("path", f.store.path).Msg("load dedup state failed")
+		}
+	}
+	filters[room] = f
+	return f
+}
+
+// Remove 删除任务时调用，保存直播间的状态后从内存中移除，文件保留，之后重新添加该直播间时从文件恢复
+func Remove(room string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	f, ok := filters[room]
+	if !ok {
+		return
+	}
+	if err := f.Save(); err != nil {
+		logger.Warn().Err(err).Str("room", room).Msg("save dedup state failed")
+	}
+	delete(filters, room)
+}
+
+// Close 保存所有直播间的状态，退出时调用
+func Close() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for room, f := range filters {
+		if err := f.Save(); err != nil {
+			logger.Warn().Err(err).Str("room", room).Msg("save dedup state failed")
+		}
+	}
+}
+
+// fileName 直播间标识可能是 url，只保留文件名安全的字符
+func fileName(room string) string {
+	var b strings.Builder
+	for _, r := range room {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String() + ".dedup"
+}
+
+type fileStore struct {
+	path     string
+	interval time.Duration
+	lastSave time.Time
+	saving   atomic.Bool
+	writeMu  sync.Mutex
+}
+
+// maybeSave 由 Check 在持有 Filter 锁时调用，到达保存间隔后在后台写入文件
+func (s *fileStore) maybeSave(f *Filter) {
+	if time.Since(s.lastSave) < s.interval || !s.saving.CompareAndSwap(false, true) {
+		return
+	}
+	data := f.encode()
+	f.dirty = false
+	s.lastSave = time.Now()
+	go func() {
+		defer s.saving.Store(false)
+		if err := s.write(data); err != nil {
+			logger.Warn().Err(err).Str("path", s.path).Msg("save dedup state failed")
+		}
+	}()
+}
+
+func (s *fileStore) save(f *Filter) error {
+	f.mu.Lock()
+	if !f.dirty {
+		f.mu.Unlock()
+		return nil
+	}
+	data := f.encode()
+	f.dirty = false
+	s.lastSave = time.Now()
+	f.mu.Unlock()
+	return s.write(data)
+}
+
+// write 先写临时文件再重命名，避免中途退出留下不完整的文件
+func (s *fileStore) write(data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// load 文件不存在时为空状态；配置变化导致过滤器大小不同时只恢复水位线
+func (s *fileStore) load(f *Filter) error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return f.decode(bufio.NewReader(file))
+}
+
+type fileHeader struct {
+	Magic     [4]byte
+	Version   uint32
+	M         uint64
+	K         uint32
+	Watermark int64
+	Buckets   uint32
+}
+
+type bucketHeader struct {
+	Start int64
+	End   int64
+	Count uint32
+}
+
+func (f *Filter) encode() []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(f.buckets)*int(f.m/8) + 64)
+	h := fileHeader{Version: fileVersion, M: f.m, K: uint32(f.k), Watermark: f.watermark, Buckets: uint32(len(f.buckets))}
+	copy(h.Magic[:], fileMagic)
+	binary.Write(&buf, binary.LittleEndian, &h)
+	for _, b := range f.buckets {
+		binary.Write(&buf, binary.LittleEndian, &bucketHeader{Start: b.start, End: b.end, Count: uint32(b.count)})
+		binary.Write(&buf, binary.LittleEndian, b.bits)
+	}
+	return buf.Bytes()
+}
+
+func (f *Filter) decode(r io.Reader) error {
+	var h fileHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return fmt.Errorf("read dedup header error: %w", err)
+	}
+	if string(h.Magic[:]) != fileMagic || h.Version != fileVersion {
+		return fmt.Errorf("unknown dedup file format")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watermark = h.Watermark
+	if h.M != f.m || int(h.K) != f.k {
+		return nil
+	}
+	buckets := make([]*bucket, 0, h.Buckets)
+	for i := uint32(0); i < h.Buckets; i++ {
+		var bh bucketHeader
+		if err := binary.Read(r, binary.LittleEndian, &bh); err != nil {
+			return fmt.Errorf("read dedup bucket error: %w", err)
+		}
+		b := &bucket{start: bh.Start, end: bh.End, count: int(bh.Count), bits: make([]uint64, f.m/64)}
+		if err := binary.Read(r, binary.LittleEndian, b.bits); err != nil {
+			return fmt.Errorf("read dedup bucket error: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+	f.buckets = buckets
+	f.expire()
+	return nil
+}
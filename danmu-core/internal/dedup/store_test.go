@@ -0,0 +1,42 @@
+package dedup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoveSavesAndReleasesFilter(t *testing.T) {
+	dir = t.TempDir()
+	defer func() { dir = "" }()
+
+	room := "remove-room"
+	f := Open(room)
+	ts := time.Now().UnixMilli()
+	if got := f.Check(42, ts); got != ResultNew {
+		t.Fatalf("Check = %s, want new", got)
+	}
+
+	Remove(room)
+	registryMu.Lock()
+	_, kept := filters[room]
+	registryMu.Unlock()
+	if kept {
+		t.Error("filter still registered after Remove")
+	}
+	if _, err := os.Stat(filepath.Join(dir, fileName(room))); err != nil {
+		t.Fatalf("dedup state not saved: %v", err)
+	}
+
+	// 重新添加该直播间时从文件恢复
+	reopened := Open(room)
+	if reopened == f {
+		t.Fatal("Open returned the removed filter")
+	}
+	if got := reopened.Check(42, ts); got != ResultDuplicate {
+		t.Errorf("Check after reopen = %s, want duplicate", got)
+	}
+	Remove(room)
+	Remove(room) // 不存在时不做任何操作
+}
@@ -0,0 +1,83 @@
+// Package eventhub 在进程内按直播间分发标准化事件，供 SubscribeEvents 等实时订阅使用
+package eventhub
+
+import (
+	"danmu-core/generated/event"
+	"danmu-core/metrics"
+	"sync"
+)
+
+// defaultBuffer 每个订阅的缓冲大小，订阅方消费过慢时丢弃新事件，不阻塞消息处理
+const defaultBuffer = 256
+
+var (
+	mu   sync.RWMutex
+	subs = make(map[string]map[*Subscription]struct{})
+)
+
+// Subscription 一个直播间的订阅，使用完成后需要调用 Close
+type Subscription struct {
+	room string
+	ch   chan *event.Event
+	once sync.Once
+}
+
+// C 接收事件的通道，Close 后关闭
+func (s *Subscription) C() <-chan *event.Event {
+	return s.ch
+}
+
+// Close 取消订阅，可重复调用
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if set, ok := subs[s.room]; ok {
+			delete(set, s)
+			if len(set) == 0 {
+				delete(subs, s.room)
+			}
+		}
+		close(s.ch)
+		metrics.HubSubscribers.Dec()
+	})
+}
+
+// Subscribe 订阅直播间的事件
+func Subscribe(roomDisplayId string) *Subscription {
+	s := &Subscription{
+		room: roomDisplayId,
+		ch:   make(chan *event.Event, defaultBuffer),
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	set, ok := subs[roomDisplayId]
+	if !ok {
+		set = make(map[*Subscription]struct{})
+		subs[roomDisplayId] = set
+	}
+	set[s] = struct{}{}
+	metrics.HubSubscribers.Inc()
+	return s
+}
+
+// HasSubscribers 直播间是否有订阅，没有订阅时 handler 可以跳过解析消息
+func HasSubscribers(roomDisplayId string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(subs[roomDisplayId]) > 0
+}
+
+// Publish 将事件分发给直播间的所有订阅，不会阻塞
+func Publish(ev *event.Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for s := range subs[ev.RoomDisplayId] {
+		select {
+		case s.ch <- ev:
+			metrics.HubEvents.WithLabelValues("delivered").Inc()
+		default:
+			metrics.HubEvents.WithLabelValues("dropped").Inc()
+		}
+	}
+}
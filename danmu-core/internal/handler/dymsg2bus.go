@@ -0,0 +1,62 @@
+package handler
+
+import (
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/model"
+	"danmu-core/internal/publish"
+	"fmt"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Dymsg2busHandler 将弹幕、礼物、进场和点赞消息转换为标准化事件，发布到消息总线
+type Dymsg2busHandler struct {
+	roomDisplayId string
+	roomName      string
+	streamerID    atomic.Int64
+}
+
+func NewDymsg2busHandler(conf *model.LiveConf) (*Dymsg2busHandler, error) {
+	if !publish.Enabled() {
+		return nil, fmt.Errorf("publish is not enabled")
+	}
+	h := &Dymsg2busHandler{
+		roomDisplayId: conf.RoomDisplayID,
+		roomName:      conf.Name,
+	}
+	h.streamerID.Store(conf.StreamerID)
+	return h, nil
+}
+
+func (h *Dymsg2busHandler) SetStreamer(id int64) {
+	h.streamerID.Store(id)
+}
+
+func (h *Dymsg2busHandler) Handle(msg interface{}) error {
+	message := msg.(*dystruct.Webcast_Im_Message)
+	switch message.Method {
+	case platform.WebcastChatMessage, platform.WebcastGiftMessage, platform.WebcastMemberMessage, platform.WebcastLikeMessage:
+	default:
+		return nil
+	}
+	unMarshallMsg, err := platform.MatchMethod(message.Method)
+	if err != nil || unMarshallMsg == nil {
+		return ErrProtoUndefined
+	}
+	if err := proto.Unmarshal(message.Payload, unMarshallMsg); err != nil {
+		return ErrUnmarshal
+	}
+
+	ev := newEvent(eventRoom{h.roomDisplayId, h.roomName, h.streamerID.Load()}, message.MsgId, unMarshallMsg)
+	if ev == nil {
+		return nil
+	}
+	return publish.Publish(ev)
+}
+
+// Flush 连接关闭时立即发送缓存的事件
+func (h *Dymsg2busHandler) Flush() error {
+	return publish.Flush()
+}
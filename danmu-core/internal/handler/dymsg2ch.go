@@ -0,0 +1,118 @@
+package handler
+
+import (
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/analytics"
+	"danmu-core/internal/model"
+	"danmu-core/utils"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Dymsg2chHandler 将弹幕、礼物、进场和点赞消息转换为事件，由 analytics 批量写入分析库
+type Dymsg2chHandler struct {
+	roomDisplayId string
+	roomName      string
+	streamerID    atomic.Int64
+}
+
+func NewDymsg2chHandler(conf *model.LiveConf) (*Dymsg2chHandler, error) {
+	if !analytics.Enabled() {
+		return nil, fmt.Errorf("analytics is not enabled")
+	}
+	h := &Dymsg2chHandler{
+		roomDisplayId: conf.RoomDisplayID,
+		roomName:      conf.Name,
+	}
+	h.streamerID.Store(conf.StreamerID)
+	return h, nil
+}
+
+func (h *Dymsg2chHandler) SetStreamer(id int64) {
+	h.streamerID.Store(id)
+}
+
+func (h *Dymsg2chHandler) Handle(msg interface{}) error {
+	message := msg.(*dystruct.Webcast_Im_Message)
+	switch message.Method {
+	case platform.WebcastChatMessage, platform.WebcastGiftMessage, platform.WebcastMemberMessage, platform.WebcastLikeMessage:
+	default:
+		return nil
+	}
+	unMarshallMsg, err := platform.MatchMethod(message.Method)
+	if err != nil || unMarshallMsg == nil {
+		return ErrProtoUndefined
+	}
+	if err := proto.Unmarshal(message.Payload, unMarshallMsg); err != nil {
+		return ErrUnmarshal
+	}
+
+	switch m := unMarshallMsg.(type) {
+	case *dystruct.Webcast_Im_ChatMessage:
+		analytics.Add(analytics.TableChatEvents, &analytics.ChatEvent{
+			Event:   h.event(message.MsgId, m.Common, m.User, int64(m.EventTime)),
+			Content: m.Content,
+		})
+	case *dystruct.Webcast_Im_GiftMessage:
+		// 与 Dymsg2dbHandler 一致，忽略连击结束消息
+		if m.Gift == nil || m.User == nil || m.Common == nil || m.RepeatEnd == 1 {
+			return nil
+		}
+		gift := model.NewGiftMessage(m)
+		combo, _ := strconv.ParseUint(gift.ComboCount, 10, 64)
+		if combo == 0 {
+			combo = 1
+		}
+		analytics.Add(analytics.TableGiftEvents, &analytics.GiftEvent{
+			Event:           h.event(message.MsgId, m.Common, m.User, int64(gift.Timestamp)),
+			ToUserID:        gift.ToUserID,
+			ToUserName:      gift.ToUserName,
+			ToUserDisplayId: gift.ToUserDisplayId,
+			GiftID:          gift.GiftID,
+			GiftName:        gift.GiftName,
+			DiamondCount:    uint32(gift.DiamondCount),
+			ComboCount:      combo,
+			Image:           gift.Image,
+			Message:         gift.Message,
+		})
+	case *dystruct.Webcast_Im_MemberMessage:
+		analytics.Add(analytics.TableMemberEvents, &analytics.MemberEvent{
+			Event:       h.event(message.MsgId, m.Common, m.User, int64(m.GetCommon().GetCreateTime())),
+			MemberCount: m.MemberCount,
+			Action:      m.Action,
+		})
+	case *dystruct.Webcast_Im_LikeMessage:
+		analytics.Add(analytics.TableLikeEvents, &analytics.LikeEvent{
+			Event: h.event(message.MsgId, m.Common, m.User, int64(m.GetCommon().GetCreateTime())),
+			Count: m.Count,
+			Total: m.Total,
+		})
+	}
+	return nil
+}
+
+// Flush 连接关闭时立即写入，不等待下一次定时写入
+func (h *Dymsg2chHandler) Flush() error {
+	return analytics.Flush()
+}
+
+func (h *Dymsg2chHandler) event(msgID uint64, common *dystruct.Webcast_Im_Common, user *dystruct.Webcast_Data_User, ts int64) analytics.Event {
+	e := analytics.Event{
+		MsgID:         msgID,
+		RoomID:        common.GetRoomId(),
+		RoomDisplayId: h.roomDisplayId,
+		RoomName:      h.roomName,
+		StreamerID:    h.streamerID.Load(),
+		Timestamp:     utils.NormalizeTimestamp(ts),
+	}
+	if user != nil {
+		e.UserID = user.Id
+		e.UserName = user.Nickname
+		e.UserDisplayId = user.DisplayId
+	}
+	return e
+}
@@ -7,48 +7,68 @@ import (
 	"danmu-core/logger"
 	"danmu-core/utils"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
+// userUpsertInterval 同一用户资料未变化时两次写入 users 的最小间隔
+const userUpsertInterval = 5 * time.Minute
+
+type userSeen struct {
+	key string
+	at  time.Time
+}
+
 type Dymsg2dbHandler struct {
-	cache         *lru.Cache
+	users         *lru.Cache
 	roomDisplayId string
 	roomName      string
 	liveUrl       string
+	streamerID    atomic.Int64
+	moderator     Moderator // 为空时不排除弹幕
 }
 
 func NewDymsg2dbHandler(conf *model.LiveConf) (*Dymsg2dbHandler, error) {
-	cache, err := lru.New(1000)
+	users, err := lru.New(5000)
 	if err != nil {
 		return nil, fmt.Errorf("Dymsg2dbHandler Init Cache failure, err:%v", err)
 	}
-	return &Dymsg2dbHandler{
-		cache:         cache,
+	h := &Dymsg2dbHandler{
+		users:         users,
 		roomDisplayId: conf.RoomDisplayID,
 		roomName:      conf.Name,
 		liveUrl:       conf.URL,
-	}, nil
+	}
+	h.streamerID.Store(conf.StreamerID)
+	return h, nil
+}
+
+// SetStreamer 之后保存的消息关联到该主播
+func (h *Dymsg2dbHandler) SetStreamer(id int64) {
+	h.streamerID.Store(id)
+}
+
+// SetModerator 之后跳过被排除的弹幕，在处理消息前调用
+func (h *Dymsg2dbHandler) SetModerator(m Moderator) {
+	h.moderator = m
 }
 
 func (h *Dymsg2dbHandler) Handle(msg interface{}) error {
 	message := msg.(*dystruct.Webcast_Im_Message)
 	unMarshallMsg, err := platform.MatchMethod(message.Method)
 	if err != nil || unMarshallMsg == nil {
-		return fmt.Errorf("proto type undefied")
-	}
-	if _, exists := h.cache.Get(message.MsgId); exists {
-		return nil
+		return ErrProtoUndefined
 	}
 	if err := proto.Unmarshal(message.Payload, unMarshallMsg); err != nil {
-		return fmt.Errorf("unmarshal failed")
+		return ErrUnmarshal
 	}
 	if err := h.saveToDB(unMarshallMsg, message.Method, message.MsgId); err != nil {
 		return err
 	}
-	h.cache.Add(message.MsgId, true)
 	return nil
 }
 
@@ -57,12 +77,8 @@ func (h *Dymsg2dbHandler) saveToDB(msg protoreflect.ProtoMessage, method string,
 	switch method {
 	case platform.WebcastGiftMessage:
 		m := msg.(*dystruct.Webcast_Im_GiftMessage)
-		// 先处理用户信息
-		user := model.NewUser(m.User)
-		if err := user.CheckAndInsert(); err != nil {
-			logger.Warn().Str("liveid", h.roomDisplayId).Err(err).Msg("Failed to process user")
-			// 不返回错误，继续处理礼物消息
-		}
+		// 先处理用户信息，失败不影响礼物消息
+		h.saveUser(m.User)
 
 		if m.RepeatEnd == 1 {
 			return nil
@@ -82,6 +98,8 @@ func (h *Dymsg2dbHandler) saveToDB(msg protoreflect.ProtoMessage, method string,
 		giftMessage.ID = int64(id)
 		giftMessage.RoomDisplayId = h.roomDisplayId
 		giftMessage.RoomName = h.roomName
+		giftMessage.StreamerID = h.streamerID.Load()
+		giftMessage.Timestamp = uint64(utils.NormalizeTimestamp(int64(giftMessage.Timestamp)))
 		if err := giftMessage.Insert(); err != nil {
 			logger.Warn().Str("liveid", h.roomDisplayId).Err(err).
 				Msgf("Failed to insert gift message: %v", m)
@@ -91,6 +109,10 @@ func (h *Dymsg2dbHandler) saveToDB(msg protoreflect.ProtoMessage, method string,
 		}
 	case platform.WebcastChatMessage:
 		m := msg.(*dystruct.Webcast_Im_ChatMessage)
+		h.saveUser(m.User)
+		if h.moderator != nil && h.moderator.Excluded(id) {
+			return nil
+		}
 		common = &model.CommonMessage{
 			MessageType:   method,
 			UserName:      m.User.Nickname,
@@ -108,6 +130,7 @@ func (h *Dymsg2dbHandler) saveToDB(msg protoreflect.ProtoMessage, method string,
 	}
 	if common != nil {
 		common.ID = id
+		common.StreamerID = h.streamerID.Load()
 		common.Timestamp = uint64(utils.NormalizeTimestamp(int64(common.Timestamp)))
 		if err := common.Insert(); err != nil {
 			logger.Warn().Str("liveid", h.roomDisplayId).Err(err).
@@ -120,3 +143,27 @@ func (h *Dymsg2dbHandler) saveToDB(msg protoreflect.ProtoMessage, method string,
 	}
 	return nil
 }
+
+// saveUser 更新用户资料，资料未变化时按 userUpsertInterval 节流
+func (h *Dymsg2dbHandler) saveUser(u *dystruct.Webcast_Data_User) {
+	if u == nil || u.Id == 0 {
+		return
+	}
+	user := model.NewUser(u)
+	if user.FansClub != nil {
+		user.FansClub.RoomDisplayId = h.roomDisplayId
+		user.FansClub.StreamerID = h.streamerID.Load()
+	}
+	key := user.UserName + "\x00" + user.DisplayID
+	if v, ok := h.users.Get(user.UserID); ok {
+		seen := v.(userSeen)
+		if seen.key == key && time.Since(seen.at) < userUpsertInterval {
+			return
+		}
+	}
+	if err := user.Upsert(); err != nil {
+		logger.Warn().Str("liveid", h.roomDisplayId).Err(err).Msg("Failed to process user")
+		return
+	}
+	h.users.Add(user.UserID, userSeen{key: key, at: time.Now()})
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/eventhub"
+	"danmu-core/internal/model"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Dymsg2hubHandler 将标准化事件分发给进程内的实时订阅 (SubscribeEvents)，没有订阅时不解析消息
+type Dymsg2hubHandler struct {
+	roomDisplayId string
+	roomName      string
+	streamerID    atomic.Int64
+}
+
+func NewDymsg2hubHandler(conf *model.LiveConf) (*Dymsg2hubHandler, error) {
+	h := &Dymsg2hubHandler{
+		roomDisplayId: conf.RoomDisplayID,
+		roomName:      conf.Name,
+	}
+	h.streamerID.Store(conf.StreamerID)
+	return h, nil
+}
+
+func (h *Dymsg2hubHandler) SetStreamer(id int64) {
+	h.streamerID.Store(id)
+}
+
+func (h *Dymsg2hubHandler) Handle(msg interface{}) error {
+	message := msg.(*dystruct.Webcast_Im_Message)
+	switch message.Method {
+	case platform.WebcastChatMessage, platform.WebcastGiftMessage, platform.WebcastMemberMessage, platform.WebcastLikeMessage:
+	default:
+		return nil
+	}
+	if !eventhub.HasSubscribers(h.roomDisplayId) {
+		return nil
+	}
+	unMarshallMsg, err := platform.MatchMethod(message.Method)
+	if err != nil || unMarshallMsg == nil {
+		return ErrProtoUndefined
+	}
+	if err := proto.Unmarshal(message.Payload, unMarshallMsg); err != nil {
+		return ErrUnmarshal
+	}
+
+	ev := newEvent(eventRoom{h.roomDisplayId, h.roomName, h.streamerID.Load()}, message.MsgId, unMarshallMsg)
+	if ev == nil {
+		return nil
+	}
+	eventhub.Publish(ev)
+	return nil
+}
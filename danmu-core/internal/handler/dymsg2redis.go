@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/model"
+	"danmu-core/internal/publish"
+	"danmu-core/internal/realtime"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru"
+	"google.golang.org/protobuf/proto"
+)
+
+// Dymsg2redisHandler 将事件发布到直播间的 redis 频道，并更新本场直播的在线人数、点赞、钻石和礼物榜
+type Dymsg2redisHandler struct {
+	store         *realtime.Store
+	combos        *lru.Cache // userGiftKey -> 当前连击数
+	roomDisplayId string
+	roomName      string
+	streamerID    atomic.Int64
+}
+
+func NewDymsg2redisHandler(conf *model.LiveConf) (*Dymsg2redisHandler, error) {
+	if !realtime.Enabled() {
+		return nil, fmt.Errorf("redis is not enabled")
+	}
+	combos, err := lru.New(5000)
+	if err != nil {
+		return nil, fmt.Errorf("Dymsg2redisHandler Init Cache failure, err:%v", err)
+	}
+	h := &Dymsg2redisHandler{
+		store:         realtime.Default(),
+		combos:        combos,
+		roomDisplayId: conf.RoomDisplayID,
+		roomName:      conf.Name,
+	}
+	h.streamerID.Store(conf.StreamerID)
+	return h, nil
+}
+
+func (h *Dymsg2redisHandler) SetStreamer(id int64) {
+	h.streamerID.Store(id)
+}
+
+func (h *Dymsg2redisHandler) Handle(msg interface{}) error {
+	message := msg.(*dystruct.Webcast_Im_Message)
+	switch message.Method {
+	case platform.WebcastChatMessage, platform.WebcastGiftMessage, platform.WebcastMemberMessage, platform.WebcastLikeMessage,
+		platform.WebcastRoomUserSeqMessage, platform.WebcastRoomStatsMessage:
+	default:
+		return nil
+	}
+	unMarshallMsg, err := platform.MatchMethod(message.Method)
+	if err != nil || unMarshallMsg == nil {
+		return ErrProtoUndefined
+	}
+	if err := proto.Unmarshal(message.Payload, unMarshallMsg); err != nil {
+		return ErrUnmarshal
+	}
+
+	u := &realtime.Update{RoomDisplayId: h.roomDisplayId}
+	switch m := unMarshallMsg.(type) {
+	case *dystruct.Webcast_Im_RoomUserSeqMessage:
+		u.SessionID = m.GetCommon().GetRoomId()
+		u.Viewers = int64(m.Total)
+		u.TotalViewers = int64(m.TotalUser)
+	case *dystruct.Webcast_Im_RoomStatsMessage:
+		u.SessionID = m.GetCommon().GetRoomId()
+		u.Viewers = int64(m.DisplayValue)
+		u.TotalViewers = int64(m.Total)
+	default:
+		ev := newEvent(eventRoom{h.roomDisplayId, h.roomName, h.streamerID.Load()}, message.MsgId, unMarshallMsg)
+		if ev == nil {
+			return nil
+		}
+		if u.Event, err = publish.MarshalJSON(ev); err != nil {
+			return err
+		}
+		u.SessionID = ev.RoomId
+		switch {
+		case ev.GetChat() != nil:
+			u.Chats = 1
+		case ev.GetGift() != nil:
+			gift := ev.GetGift()
+			key := userGiftKey{userID: ev.GetUser().GetId(), toUserID: gift.GetToUser().GetId(), giftID: gift.GiftId}
+			delta := comboDelta(h.combos, key, int64(gift.ComboCount))
+			u.Gifts = delta
+			u.Diamonds = int64(gift.DiamondCount) * delta
+			if ev.GetUser().GetId() != 0 {
+				u.Gifter = &realtime.Gifter{UserID: ev.User.Id, Name: ev.User.Name, Diamonds: u.Diamonds}
+			}
+		case ev.GetMember() != nil:
+			u.Viewers = int64(ev.GetMember().MemberCount)
+		case ev.GetLike() != nil:
+			u.Likes = int64(ev.GetLike().Total)
+		}
+	}
+	// redis 不可用期间丢弃更新，不影响其他 handler
+	if err := h.store.Apply(context.Background(), u); err != nil && !errors.Is(err, realtime.ErrUnavailable) {
+		return err
+	}
+	return nil
+}
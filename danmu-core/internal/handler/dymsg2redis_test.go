@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/realtime"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestRedisHandler(t *testing.T) (*Dymsg2redisHandler, *miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	combos, err := lru.New(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Dymsg2redisHandler{
+		store:         realtime.NewStore(rdb, "danmu", time.Hour, 10*time.Minute, 10),
+		combos:        combos,
+		roomDisplayId: "r1",
+		roomName:      "test room",
+	}
+	return h, mr, rdb
+}
+
+func imMessage(t *testing.T, method string, id uint64, payload proto.Message) *dystruct.Webcast_Im_Message {
+	t.Helper()
+	data, err := proto.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dystruct.Webcast_Im_Message{Method: method, MsgId: id, Payload: data}
+}
+
+// giftMessage combo 通过展示文本 "x<combo>" 解析
+func giftMessage(t *testing.T, id uint64, combo string) *dystruct.Webcast_Im_Message {
+	return imMessage(t, platform.WebcastGiftMessage, id, &dystruct.Webcast_Im_GiftMessage{
+		Common: &dystruct.Webcast_Im_Common{
+			RoomId:     100,
+			CreateTime: 1714564800000,
+			DisplayText: &dystruct.Webcast_Data_Text{
+				DefaultPattern: "{0:user}{1:gift}{2:string}",
+				Pieces:         []*dystruct.Webcast_Data_TextPiece{{}, {}, {StringValue: "x" + combo}},
+			},
+		},
+		GiftId: 9,
+		User:   &dystruct.Webcast_Data_User{Id: 7, Nickname: "alice"},
+		Gift:   &dystruct.Webcast_Data_GiftStruct{Name: "rose", DiamondCount: 10, Image: &dystruct.Webcast_Data_Image{}},
+	})
+}
+
+func TestDymsg2redisGiftCombo(t *testing.T) {
+	h, mr, _ := newTestRedisHandler(t)
+	// 同一次连击的推送只累加增量，连击数变小时为新的一次连击
+	for i, combo := range []string{"1", "3", "5", "2"} {
+		if err := h.Handle(giftMessage(t, uint64(i+1), combo)); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+	}
+	stats := "danmu:session:100:stats"
+	if got := mr.HGet(stats, "gifts"); got != "7" {
+		t.Errorf("gifts = %q, want 7", got)
+	}
+	if got := mr.HGet(stats, "diamonds"); got != "70" {
+		t.Errorf("diamonds = %q, want 70", got)
+	}
+	if score, _ := mr.ZScore("danmu:session:100:gifters", "7"); score != 70 {
+		t.Errorf("session gifter score = %v, want 70", score)
+	}
+	if score, _ := mr.ZScore("danmu:room:r1:top_gifters", "7"); score != 70 {
+		t.Errorf("top gifter score = %v, want 70", score)
+	}
+	if got := mr.HGet("danmu:session:100:users", "7"); got != "alice" {
+		t.Errorf("user name = %q, want alice", got)
+	}
+}
+
+func TestDymsg2redisPublishesChat(t *testing.T) {
+	h, mr, rdb := newTestRedisHandler(t)
+	sub := rdb.Subscribe(context.Background(), "danmu:room:r1:events")
+	defer sub.Close()
+	if _, err := sub.Receive(context.Background()); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	msg := imMessage(t, platform.WebcastChatMessage, 42, &dystruct.Webcast_Im_ChatMessage{
+		Common:  &dystruct.Webcast_Im_Common{RoomId: 100},
+		User:    &dystruct.Webcast_Data_User{Id: 7, Nickname: "alice"},
+		Content: "hello",
+	})
+	if err := h.Handle(msg); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	select {
+	case m := <-sub.Channel():
+		var ev map[string]any
+		if err := json.Unmarshal([]byte(m.Payload), &ev); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		chat, _ := ev["chat"].(map[string]any)
+		if ev["type"] != "chat" || ev["msg_id"] != "42" || ev["room_display_id"] != "r1" || chat["content"] != "hello" {
+			t.Errorf("event = %s", m.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("chat event not published")
+	}
+	if got := mr.HGet("danmu:session:100:stats", "chats"); got != "1" {
+		t.Errorf("chats = %q, want 1", got)
+	}
+}
+
+func TestDymsg2redisIgnoresOtherMethods(t *testing.T) {
+	h, mr, _ := newTestRedisHandler(t)
+	msg := &dystruct.Webcast_Im_Message{Method: platform.WebcastControlMessage, Payload: []byte{0xff}}
+	if err := h.Handle(msg); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("keys = %v, want none", keys)
+	}
+}
@@ -22,11 +22,11 @@ func (h *DyPrint2Console) Handle(msg interface{}) error {
 	message := msg.(*dystruct.Webcast_Im_Message)
 	unMarshallMsg, err := platform.MatchMethod(message.Method)
 	if err != nil || unMarshallMsg == nil {
-		return fmt.Errorf("proto type undefied")
+		return ErrProtoUndefined
 	}
 
 	if err := proto.Unmarshal(message.Payload, unMarshallMsg); err != nil {
-		return fmt.Errorf("unmarshal failed")
+		return ErrUnmarshal
 	}
 	if err := h.print(unMarshallMsg, message.Method, message.MsgId); err != nil {
 		return err
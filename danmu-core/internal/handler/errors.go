@@ -0,0 +1,20 @@
+package handler
+
+import "errors"
+
+var (
+	// ErrProtoUndefined 未知的 method 没有对应的 proto，所有 handler 都无法处理，跳过不写入死信
+	ErrProtoUndefined = errors.New("proto type undefied")
+	// ErrUnmarshal 消息本身无法解析，重试没有意义，直接写入死信
+	ErrUnmarshal = errors.New("unmarshal failed")
+)
+
+// Permanent 判断 handler 返回的错误是否不需要重试
+func Permanent(err error) bool {
+	return errors.Is(err, ErrUnmarshal)
+}
+
+// Skipped 判断消息是否被 handler 跳过，不属于处理失败
+func Skipped(err error) bool {
+	return errors.Is(err, ErrProtoUndefined)
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"danmu-core/generated/dystruct"
+	"danmu-core/generated/event"
+	"danmu-core/internal/model"
+	"danmu-core/internal/publish"
+	"danmu-core/utils"
+	"strconv"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// eventRoom 事件中的直播间信息
+type eventRoom struct {
+	roomDisplayId string
+	roomName      string
+	streamerID    int64
+}
+
+// newEvent 将弹幕、礼物、进场和点赞消息转换为标准化事件，其他消息和连击结束消息返回 nil
+func newEvent(room eventRoom, msgID uint64, msg protoreflect.ProtoMessage) *event.Event {
+	var ev *event.Event
+	switch m := msg.(type) {
+	case *dystruct.Webcast_Im_ChatMessage:
+		ev = room.event(publish.TypeChat, msgID, m.Common, m.User, int64(m.EventTime))
+		ev.Payload = &event.Event_Chat{Chat: &event.Chat{Content: m.Content}}
+	case *dystruct.Webcast_Im_GiftMessage:
+		// 与 Dymsg2dbHandler 一致，忽略连击结束消息
+		if m.Gift == nil || m.User == nil || m.Common == nil || m.RepeatEnd == 1 {
+			return nil
+		}
+		gift := model.NewGiftMessage(m)
+		combo, _ := strconv.ParseUint(gift.ComboCount, 10, 64)
+		if combo == 0 {
+			combo = 1
+		}
+		payload := &event.Gift{
+			GiftId:       gift.GiftID,
+			GiftName:     gift.GiftName,
+			DiamondCount: uint32(gift.DiamondCount),
+			ComboCount:   combo,
+			ImageUrl:     gift.Image,
+			Message:      gift.Message,
+		}
+		if gift.ToUserID != 0 {
+			payload.ToUser = &event.User{Id: gift.ToUserID, Name: gift.ToUserName, DisplayId: gift.ToUserDisplayId}
+		}
+		ev = room.event(publish.TypeGift, msgID, m.Common, m.User, int64(gift.Timestamp))
+		ev.Payload = &event.Event_Gift{Gift: payload}
+	case *dystruct.Webcast_Im_MemberMessage:
+		ev = room.event(publish.TypeMember, msgID, m.Common, m.User, int64(m.GetCommon().GetCreateTime()))
+		ev.Payload = &event.Event_Member{Member: &event.Member{MemberCount: m.MemberCount, Action: m.Action}}
+	case *dystruct.Webcast_Im_LikeMessage:
+		ev = room.event(publish.TypeLike, msgID, m.Common, m.User, int64(m.GetCommon().GetCreateTime()))
+		ev.Payload = &event.Event_Like{Like: &event.Like{Count: m.Count, Total: m.Total}}
+	}
+	return ev
+}
+
+func (room eventRoom) event(typ string, msgID uint64, common *dystruct.Webcast_Im_Common, user *dystruct.Webcast_Data_User, ts int64) *event.Event {
+	ev := &event.Event{
+		MsgId:         msgID,
+		Type:          typ,
+		RoomId:        common.GetRoomId(),
+		RoomDisplayId: room.roomDisplayId,
+		RoomName:      room.roomName,
+		StreamerId:    room.streamerID,
+		Timestamp:     utils.NormalizeTimestamp(ts),
+	}
+	if user != nil {
+		ev.User = &event.User{Id: user.Id, Name: user.Nickname, DisplayId: user.DisplayId}
+	}
+	return ev
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/model"
+	"danmu-core/internal/moderation"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"danmu-core/utils"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultModerationFlushInterval 标记记录写入数据库的默认间隔
+const defaultModerationFlushInterval = 10 * time.Second
+
+// Moderator 判断弹幕是否因刷屏或疑似机器人被排除
+type Moderator interface {
+	Excluded(msgID uint64) bool
+}
+
+// Moderation2dbHandler 检测刷屏和疑似机器人的弹幕，定时将标记的用户写入 chat_flags
+// 需要在 db handler 之前处理消息，排除的弹幕由 Dymsg2dbHandler 通过 Excluded 跳过
+type Moderation2dbHandler struct {
+	detector      *moderation.Detector
+	excluded      *lru.Cache // 排除的消息 id
+	roomDisplayId string
+	streamerID    atomic.Int64
+	flushInterval time.Duration
+
+	mu        sync.Mutex
+	flags     map[uint64]*model.ChatFlag // user_id
+	lastFlush time.Time
+}
+
+func NewModeration2dbHandler(conf *model.LiveConf) (*Moderation2dbHandler, error) {
+	detector, err := moderation.NewDetector(moderation.SettingConfig())
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := lru.New(1000)
+	if err != nil {
+		return nil, fmt.Errorf("Moderation2dbHandler Init Cache failure, err:%v", err)
+	}
+	flushInterval := time.Duration(setting.ModerationSetting.FlushInterval) * time.Second
+	if flushInterval <= 0 {
+		flushInterval = defaultModerationFlushInterval
+	}
+	h := &Moderation2dbHandler{
+		detector:      detector,
+		excluded:      excluded,
+		roomDisplayId: conf.RoomDisplayID,
+		flushInterval: flushInterval,
+		flags:         make(map[uint64]*model.ChatFlag),
+		lastFlush:     time.Now(),
+	}
+	h.streamerID.Store(conf.StreamerID)
+	return h, nil
+}
+
+func (h *Moderation2dbHandler) SetStreamer(id int64) {
+	h.streamerID.Store(id)
+}
+
+// Excluded 实现 Moderator
+func (h *Moderation2dbHandler) Excluded(msgID uint64) bool {
+	return h.excluded.Contains(msgID)
+}
+
+func (h *Moderation2dbHandler) Handle(msg interface{}) error {
+	message := msg.(*dystruct.Webcast_Im_Message)
+	switch message.Method {
+	case platform.WebcastChatMessage, platform.WebcastMemberMessage:
+	default:
+		return nil
+	}
+	unMarshallMsg, err := platform.MatchMethod(message.Method)
+	if err != nil || unMarshallMsg == nil {
+		return ErrProtoUndefined
+	}
+	if err := proto.Unmarshal(message.Payload, unMarshallMsg); err != nil {
+		return ErrUnmarshal
+	}
+
+	switch m := unMarshallMsg.(type) {
+	case *dystruct.Webcast_Im_MemberMessage:
+		h.detector.Join(m.GetUser().GetId(), utils.NormalizeTimestamp(int64(m.GetCommon().GetCreateTime())))
+		return nil
+	case *dystruct.Webcast_Im_ChatMessage:
+		verdict := h.detector.Check(&moderation.Chat{
+			MsgID:     message.MsgId,
+			User:      m.User,
+			Content:   m.Content,
+			Timestamp: utils.NormalizeTimestamp(int64(m.EventTime)),
+		})
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if verdict.Flagged {
+			if verdict.Excluded {
+				h.excluded.Add(message.MsgId, true)
+				metrics.ModerationExcluded.WithLabelValues(h.roomDisplayId).Inc()
+			}
+			for _, reason := range verdict.Reasons() {
+				metrics.ModerationFlags.WithLabelValues(h.roomDisplayId, reason).Inc()
+			}
+			if err := h.addFlag(m.User, verdict, utils.NormalizeTimestamp(int64(m.EventTime))); err != nil {
+				return err
+			}
+		}
+		if len(h.flags) == 0 || time.Since(h.lastFlush) < h.flushInterval {
+			return nil
+		}
+		return h.flush()
+	}
+	return nil
+}
+
+// Flush 连接关闭时由 client 调用，写入剩余的标记记录
+func (h *Moderation2dbHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.flush()
+}
+
+// addFlag 合并同一用户在一个写入间隔内的多次标记
+func (h *Moderation2dbHandler) addFlag(user *dystruct.Webcast_Data_User, verdict *moderation.Verdict, ts int64) error {
+	evidence, err := json.Marshal(verdict.Evidence)
+	if err != nil {
+		return fmt.Errorf("marshal moderation evidence error: %w", err)
+	}
+	flag, ok := h.flags[user.Id]
+	if !ok {
+		flag = &model.ChatFlag{
+			UserID:        user.Id,
+			RoomDisplayId: h.roomDisplayId,
+			FirstFlagged:  ts,
+		}
+		h.flags[user.Id] = flag
+	}
+	flag.UserName = user.Nickname
+	flag.UserDisplayId = user.DisplayId
+	flag.StreamerID = h.streamerID.Load()
+	flag.MaxScore = max(flag.MaxScore, verdict.Score)
+	flag.LastScore = verdict.Score
+	flag.Reasons = strings.Join(verdict.Reasons(), ",")
+	flag.FlaggedCount++
+	if verdict.Excluded {
+		flag.ExcludedCount++
+	}
+	flag.Evidence = string(evidence)
+	flag.LastFlagged = ts
+	return nil
+}
+
+func (h *Moderation2dbHandler) flush() error {
+	if len(h.flags) == 0 {
+		return nil
+	}
+	flags := make([]*model.ChatFlag, 0, len(h.flags))
+	for _, flag := range h.flags {
+		flags = append(flags, flag)
+	}
+	h.flags = make(map[uint64]*model.ChatFlag)
+	h.lastFlush = time.Now()
+
+	if err := model.SaveChatFlags(flags); err != nil {
+		logger.Warn().Str("liveid", h.roomDisplayId).Err(err).
+			Int("users", len(flags)).Msg("Failed to save chat flags")
+		return err
+	}
+	return nil
+}
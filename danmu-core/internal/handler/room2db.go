@@ -0,0 +1,144 @@
+package handler
+
+import (
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/model"
+	"danmu-core/logger"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// roomSnapshotInterval 统计消息触发快照的最小间隔，统计消息每隔几秒推送一次
+const roomSnapshotInterval = time.Minute
+
+const roomSourceCheck = "check"
+
+// Room2dbHandler 保存直播间元数据快照和标题变更记录
+type Room2dbHandler struct {
+	roomDisplayId string
+	streamerID    atomic.Int64
+
+	mu           sync.Mutex
+	last         model.RoomSnapshot // 最近一次的直播间元数据，统计消息只更新其中的人数
+	lastTitle    string
+	lastSnapshot time.Time
+}
+
+func NewRoom2dbHandler(conf *model.LiveConf) *Room2dbHandler {
+	h := &Room2dbHandler{
+		roomDisplayId: conf.RoomDisplayID,
+	}
+	h.streamerID.Store(conf.StreamerID)
+	title, err := model.GetLastRoomTitle(conf.RoomDisplayID)
+	if err != nil {
+		logger.Warn().Str("liveid", conf.RoomDisplayID).Err(err).Msg("get last room title failed")
+	}
+	h.lastTitle = title
+	return h
+}
+
+func (h *Room2dbHandler) SetStreamer(id int64) {
+	h.streamerID.Store(id)
+}
+
+// SetRoomInfo CheckStream 后由 client 调用，每次检查都保存一条快照
+func (h *Room2dbHandler) SetRoomInfo(info *platform.RoomInfo) {
+	roomID, _ := strconv.ParseUint(info.RoomID, 10, 64)
+	if roomID == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.last = model.RoomSnapshot{
+		RoomID:        roomID,
+		RoomDisplayId: h.roomDisplayId,
+		Status:        info.Status,
+		Title:         info.Title,
+		Cover:         info.Cover,
+		OwnerNickname: info.OwnerNickname,
+		OwnerAvatar:   info.OwnerAvatar,
+		Category:      info.Category,
+		UserCount:     info.UserCount,
+		TotalUser:     info.TotalUser,
+		LikeCount:     info.LikeCount,
+	}
+	h.save(roomSourceCheck)
+}
+
+func (h *Room2dbHandler) Handle(msg interface{}) error {
+	message := msg.(*dystruct.Webcast_Im_Message)
+	switch message.Method {
+	case platform.WebcastRoomStatsMessage, platform.WebcastRoomUserSeqMessage, platform.WebcastRoomMessage:
+	default:
+		return nil
+	}
+	unMarshallMsg, err := platform.MatchMethod(message.Method)
+	if err != nil || unMarshallMsg == nil {
+		return ErrProtoUndefined
+	}
+	if err := proto.Unmarshal(message.Payload, unMarshallMsg); err != nil {
+		return ErrUnmarshal
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch m := unMarshallMsg.(type) {
+	case *dystruct.Webcast_Im_RoomStatsMessage:
+		if m.DisplayValue > 0 {
+			h.last.UserCount = int64(m.DisplayValue)
+		}
+		if m.Total > 0 {
+			h.last.TotalUser = int64(m.Total)
+		}
+	case *dystruct.Webcast_Im_RoomUserSeqMessage:
+		if m.Total > 0 {
+			h.last.UserCount = int64(m.Total)
+		}
+		if m.TotalUser > 0 {
+			h.last.TotalUser = int64(m.TotalUser)
+		}
+	}
+	// 还没有 CheckStream 的结果时不保存，避免快照缺少标题等信息
+	if h.last.RoomID == 0 || time.Since(h.lastSnapshot) < roomSnapshotInterval {
+		return nil
+	}
+	return h.save(message.Method)
+}
+
+// save 调用方需持有 h.mu
+func (h *Room2dbHandler) save(source string) error {
+	now := time.Now()
+	streamerID := h.streamerID.Load()
+	if h.last.Title != "" && h.last.Title != h.lastTitle {
+		title := &model.RoomTitle{
+			RoomID:        h.last.RoomID,
+			RoomDisplayId: h.roomDisplayId,
+			StreamerID:    streamerID,
+			Title:         h.last.Title,
+			Timestamp:     now.UnixMilli(),
+		}
+		if err := title.Insert(); err != nil {
+			logger.Warn().Str("liveid", h.roomDisplayId).Err(err).Msg("Failed to insert room title")
+		} else {
+			logger.Info().Str("liveid", h.roomDisplayId).Str("old", h.lastTitle).Str("title", h.last.Title).Msg("直播间标题变更")
+			h.lastTitle = h.last.Title
+		}
+	}
+
+	snapshot := h.last
+	snapshot.StreamerID = streamerID
+	snapshot.Source = source
+	snapshot.Timestamp = now.UnixMilli()
+	if err := snapshot.Insert(); err != nil {
+		logger.Warn().Str("liveid", h.roomDisplayId).Err(err).Msg("Failed to insert room snapshot")
+		return err
+	}
+	h.lastSnapshot = now
+	return nil
+}
@@ -0,0 +1,271 @@
+package handler
+
+import (
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/model"
+	"danmu-core/logger"
+	"danmu-core/utils"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// userStatsFlushInterval 累计的统计增量写入数据库的间隔
+	userStatsFlushInterval = 30 * time.Second
+	// userStatsMaxPending 写入失败时保留的增量行数上限，超过后丢弃，避免数据库长时间不可用时内存无限增长
+	userStatsMaxPending = 100000
+)
+
+type userGiftKey struct {
+	userID   uint64
+	toUserID uint64
+	giftID   int64
+}
+
+type userDayKey struct {
+	userID uint64
+	day    int64
+}
+
+type userClubKey struct {
+	userID   uint64
+	anchorID uint64
+}
+
+// UserStats2dbHandler 累计用户在直播间的弹幕、送礼和粉丝团信息，定时增量写入统计表
+type UserStats2dbHandler struct {
+	combos        *lru.Cache // userGiftKey -> 当前连击数
+	roomDisplayId string
+	roomName      string
+	streamerID    atomic.Int64
+
+	mu        sync.Mutex
+	rooms     map[uint64]*model.UserRoomStat // user_id
+	gifts     map[userGiftKey]*model.UserGiftStat
+	daily     map[userDayKey]*model.UserDailyActivity
+	clubs     map[userClubKey]*model.UserFansClub
+	lastFlush time.Time
+}
+
+func NewUserStats2dbHandler(conf *model.LiveConf) (*UserStats2dbHandler, error) {
+	combos, err := lru.New(5000)
+	if err != nil {
+		return nil, fmt.Errorf("UserStats2dbHandler Init Cache failure, err:%v", err)
+	}
+	h := &UserStats2dbHandler{
+		combos:        combos,
+		roomDisplayId: conf.RoomDisplayID,
+		roomName:      conf.Name,
+		lastFlush:     time.Now(),
+	}
+	h.streamerID.Store(conf.StreamerID)
+	h.reset()
+	return h, nil
+}
+
+func (h *UserStats2dbHandler) SetStreamer(id int64) {
+	h.streamerID.Store(id)
+}
+
+func (h *UserStats2dbHandler) Handle(msg interface{}) error {
+	message := msg.(*dystruct.Webcast_Im_Message)
+	switch message.Method {
+	case platform.WebcastChatMessage, platform.WebcastGiftMessage:
+	default:
+		return nil
+	}
+	unMarshallMsg, err := platform.MatchMethod(message.Method)
+	if err != nil || unMarshallMsg == nil {
+		return ErrProtoUndefined
+	}
+	if err := proto.Unmarshal(message.Payload, unMarshallMsg); err != nil {
+		return ErrUnmarshal
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch m := unMarshallMsg.(type) {
+	case *dystruct.Webcast_Im_ChatMessage:
+		if m.User == nil || m.User.Id == 0 {
+			return nil
+		}
+		ts := utils.NormalizeTimestamp(int64(m.EventTime))
+		h.addActivity(m.User, ts, 1, 0, 0)
+	case *dystruct.Webcast_Im_GiftMessage:
+		// 与 Dymsg2dbHandler 一致，忽略连击结束消息
+		if m.User == nil || m.User.Id == 0 || m.Gift == nil || m.RepeatEnd == 1 {
+			return nil
+		}
+		h.addGift(m)
+	}
+	if time.Since(h.lastFlush) < userStatsFlushInterval {
+		return nil
+	}
+	// 本条消息已经计入增量，写入失败时增量保留到下次写入，不返回错误，避免重试时重复累加
+	h.flush()
+	return nil
+}
+
+// Flush 连接关闭时由 client 调用，写入剩余的增量
+func (h *UserStats2dbHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.flush()
+}
+
+// comboDelta 连击消息中的数量是累计值，返回比上一条多出的部分；数量不增加时视为新的一次连击
+func comboDelta(combos *lru.Cache, key userGiftKey, combo int64) int64 {
+	delta := combo
+	if v, ok := combos.Get(key); ok {
+		if last := v.(int64); combo > last {
+			delta = combo - last
+		}
+	}
+	combos.Add(key, combo)
+	return delta
+}
+
+// addGift 按连击增量累计送礼数量和钻石数
+func (h *UserStats2dbHandler) addGift(m *dystruct.Webcast_Im_GiftMessage) {
+	gift := model.NewGiftMessage(m)
+	ts := utils.NormalizeTimestamp(int64(gift.Timestamp))
+	combo, _ := strconv.ParseInt(gift.ComboCount, 10, 64)
+	if combo <= 0 {
+		combo = 1
+	}
+	key := userGiftKey{userID: gift.UserID, toUserID: gift.ToUserID, giftID: gift.GiftID}
+	delta := comboDelta(h.combos, key, combo)
+	diamonds := int64(gift.DiamondCount) * delta
+
+	stat, ok := h.gifts[key]
+	if !ok {
+		stat = &model.UserGiftStat{
+			UserID:        gift.UserID,
+			RoomDisplayId: h.roomDisplayId,
+			ToUserID:      gift.ToUserID,
+			GiftID:        gift.GiftID,
+		}
+		h.gifts[key] = stat
+	}
+	stat.ToUserName = gift.ToUserName
+	stat.GiftName = gift.GiftName
+	stat.Image = gift.Image
+	stat.GiftCount += delta
+	stat.DiamondTotal += diamonds
+	stat.LastSent = max(stat.LastSent, ts)
+
+	h.addActivity(m.User, ts, 0, delta, diamonds)
+}
+
+// addActivity 调用方需持有 h.mu
+func (h *UserStats2dbHandler) addActivity(u *dystruct.Webcast_Data_User, ts int64, chats, gifts, diamonds int64) {
+	streamerID := h.streamerID.Load()
+
+	room, ok := h.rooms[u.Id]
+	if !ok {
+		room = &model.UserRoomStat{
+			UserID:        u.Id,
+			RoomDisplayId: h.roomDisplayId,
+			FirstSeen:     ts,
+		}
+		h.rooms[u.Id] = room
+	}
+	room.RoomName = h.roomName
+	room.StreamerID = streamerID
+	room.ChatCount += chats
+	room.GiftCount += gifts
+	room.DiamondTotal += diamonds
+	room.FirstSeen = min(room.FirstSeen, ts)
+	room.LastSeen = max(room.LastSeen, ts)
+
+	t := time.UnixMilli(ts)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).UnixMilli()
+	daily, ok := h.daily[userDayKey{userID: u.Id, day: day}]
+	if !ok {
+		daily = &model.UserDailyActivity{
+			UserID:        u.Id,
+			RoomDisplayId: h.roomDisplayId,
+			Day:           day,
+			FirstSeen:     ts,
+		}
+		h.daily[userDayKey{userID: u.Id, day: day}] = daily
+	}
+	daily.ChatCount += chats
+	daily.GiftCount += gifts
+	daily.DiamondTotal += diamonds
+	daily.FirstSeen = min(daily.FirstSeen, ts)
+	daily.LastSeen = max(daily.LastSeen, ts)
+
+	if badge := model.NewUserFansClub(u); badge != nil {
+		key := userClubKey{userID: u.Id, anchorID: badge.AnchorID}
+		club, ok := h.clubs[key]
+		if !ok {
+			club = badge
+			club.FirstSeen = ts
+			h.clubs[key] = club
+		}
+		club.ClubName = badge.ClubName
+		club.Level = badge.Level
+		club.RoomDisplayId = h.roomDisplayId
+		club.StreamerID = streamerID
+		club.FirstSeen = min(club.FirstSeen, ts)
+		club.LastSeen = max(club.LastSeen, ts)
+	}
+}
+
+// flush 调用方需持有 h.mu。写入在一个事务中完成，失败时数据库没有变化，
+// 增量保留在内存中，之后的消息继续累加到同一行，下次一起写入；超过 userStatsMaxPending 行时丢弃
+func (h *UserStats2dbHandler) flush() error {
+	batch := &model.UserStatsBatch{
+		Rooms:     make([]*model.UserRoomStat, 0, len(h.rooms)),
+		Gifts:     make([]*model.UserGiftStat, 0, len(h.gifts)),
+		Daily:     make([]*model.UserDailyActivity, 0, len(h.daily)),
+		FansClubs: make([]*model.UserFansClub, 0, len(h.clubs)),
+	}
+	for _, v := range h.rooms {
+		batch.Rooms = append(batch.Rooms, v)
+	}
+	for _, v := range h.gifts {
+		batch.Gifts = append(batch.Gifts, v)
+	}
+	for _, v := range h.daily {
+		batch.Daily = append(batch.Daily, v)
+	}
+	for _, v := range h.clubs {
+		batch.FansClubs = append(batch.FansClubs, v)
+	}
+	h.lastFlush = time.Now()
+
+	if err := batch.Save(); err != nil {
+		if pending := h.pending(); pending > userStatsMaxPending {
+			logger.Error().Str("liveid", h.roomDisplayId).Err(err).
+				Int("rows", pending).Msg("Failed to save user stats, pending rows exceed limit and are dropped")
+			h.reset()
+			return err
+		}
+		logger.Warn().Str("liveid", h.roomDisplayId).Err(err).
+			Int("users", len(batch.Rooms)).Msg("Failed to save user stats, will retry with the next batch")
+		return err
+	}
+	h.reset()
+	return nil
+}
+
+// pending 内存中等待写入的行数，调用方需持有 h.mu
+func (h *UserStats2dbHandler) pending() int {
+	return len(h.rooms) + len(h.gifts) + len(h.daily) + len(h.clubs)
+}
+
+func (h *UserStats2dbHandler) reset() {
+	h.rooms = make(map[uint64]*model.UserRoomStat)
+	h.gifts = make(map[userGiftKey]*model.UserGiftStat)
+	h.daily = make(map[userDayKey]*model.UserDailyActivity)
+	h.clubs = make(map[userClubKey]*model.UserFansClub)
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	platform "danmu-core/core/platform/douyin"
+	"danmu-core/generated/dystruct"
+	"danmu-core/internal/model"
+	"testing"
+)
+
+func chatMessage(t *testing.T, id uint64, userID uint64) *dystruct.Webcast_Im_Message {
+	return imMessage(t, platform.WebcastChatMessage, id, &dystruct.Webcast_Im_ChatMessage{
+		Common:    &dystruct.Webcast_Im_Common{RoomId: 100},
+		User:      &dystruct.Webcast_Data_User{Id: userID, Nickname: "alice"},
+		Content:   "hello",
+		EventTime: 1714564800,
+	})
+}
+
+func roomChats(t *testing.T, room string, userID uint64) int64 {
+	t.Helper()
+	var stat model.UserRoomStat
+	err := model.DB.Where("user_id = ? AND room_display_id = ?", userID, room).Limit(1).Find(&stat).Error
+	if err != nil {
+		t.Fatalf("query user_room_stats: %v", err)
+	}
+	return stat.ChatCount
+}
+
+func TestUserStatsKeepsFailedBatch(t *testing.T) {
+	room := "userstats-retry"
+	h, err := NewUserStats2dbHandler(&model.LiveConf{RoomDisplayID: room, Name: "test room"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(1); i <= 2; i++ {
+		if err := h.Handle(chatMessage(t, i, 7)); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+	}
+
+	// 事务中最后写入的表不可用，整批回滚，增量保留在内存中
+	tmp := model.TableNameUserDailyActivity + "_tmp"
+	if err := model.DB.Migrator().RenameTable(model.TableNameUserDailyActivity, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Flush(); err == nil {
+		t.Fatal("Flush with missing table = nil, want error")
+	}
+	if err := model.DB.Migrator().RenameTable(tmp, model.TableNameUserDailyActivity); err != nil {
+		t.Fatal(err)
+	}
+	if n := roomChats(t, room, 7); n != 0 {
+		t.Fatalf("chat_count after failed flush = %d, want 0", n)
+	}
+
+	// 下一批包含失败的增量，只累加一次
+	if err := h.Handle(chatMessage(t, 3, 7)); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if err := h.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if n := roomChats(t, room, 7); n != 3 {
+		t.Errorf("chat_count = %d, want 3", n)
+	}
+	if err := h.Flush(); err != nil {
+		t.Fatalf("empty Flush: %v", err)
+	}
+	if n := roomChats(t, room, 7); n != 3 {
+		t.Errorf("chat_count after empty flush = %d, want 3", n)
+	}
+}
+
+func TestUserStatsDropsPendingOverLimit(t *testing.T) {
+	room := "userstats-limit"
+	h, err := NewUserStats2dbHandler(&model.LiveConf{RoomDisplayID: room})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := uint64(1); id <= userStatsMaxPending/2+1; id++ {
+		h.rooms[id] = &model.UserRoomStat{UserID: id, RoomDisplayId: room, ChatCount: 1}
+		h.daily[userDayKey{userID: id}] = &model.UserDailyActivity{UserID: id, RoomDisplayId: room, ChatCount: 1}
+	}
+	tmp := model.TableNameUserRoomStat + "_tmp"
+	if err := model.DB.Migrator().RenameTable(model.TableNameUserRoomStat, tmp); err != nil {
+		t.Fatal(err)
+	}
+	defer model.DB.Migrator().RenameTable(tmp, model.TableNameUserRoomStat)
+	if err := h.Flush(); err == nil {
+		t.Fatal("Flush with missing table = nil, want error")
+	}
+	if n := h.pending(); n != 0 {
+		t.Errorf("pending = %d, want 0 after exceeding userStatsMaxPending", n)
+	}
+}
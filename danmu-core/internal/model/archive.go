@@ -0,0 +1,85 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TableNameMessageArchive  = "message_archives"
+	TableNameRetentionPolicy = "retention_policies"
+)
+
+// MessageArchive mapped from table <message_archives>
+// 已归档并从数据库删除的消息文件，每个表、直播间、月份至少一个文件
+type MessageArchive struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	MessageTable  string `gorm:"column:message_table;not null" json:"message_table"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	Month         int64  `gorm:"column:month;not null" json:"month"` // 分区开始的毫秒时间戳
+	Path          string `gorm:"column:path;not null" json:"path"`   // 相对归档目录的路径
+	Rows          int64  `gorm:"column:rows;not null" json:"rows"`
+	Size          int64  `gorm:"column:size;not null" json:"size"`
+	Begin         int64  `gorm:"column:begin;not null" json:"begin"` // 文件中消息的最早时间
+	End           int64  `gorm:"column:end;not null" json:"end"`
+	CreatedOn     int64  `gorm:"column:created_on" json:"created_on"`
+}
+
+// TableName MessageArchive's table name
+func (*MessageArchive) TableName() string {
+	return TableNameMessageArchive
+}
+
+// RetentionPolicy mapped from table <retention_policies>
+// 直播间消息的保留天数，0 表示永久保留，未配置的直播间使用 danmu-core 配置中的默认值
+type RetentionPolicy struct {
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	RetainDays    int    `gorm:"column:retain_days;not null" json:"retain_days"`
+	ModifiedOn    int64  `gorm:"column:modified_on" json:"modified_on"`
+	ModifiedBy    string `gorm:"column:modified_by" json:"modified_by"`
+}
+
+// TableName RetentionPolicy's table name
+func (*RetentionPolicy) TableName() string {
+	return TableNameRetentionPolicy
+}
+
+// GetRetentionDays 返回 room_display_id -> 保留天数
+func GetRetentionDays() (map[string]int, error) {
+	var policies []*RetentionPolicy
+	if err := DB.Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	days := make(map[string]int, len(policies))
+	for _, p := range policies {
+		days[p.RoomDisplayId] = p.RetainDays
+	}
+	return days, nil
+}
+
+// GetArchiveBatch 按 (timestamp, id) 顺序分批读取分区中直播间的消息
+func GetArchiveBatch[T any](p Partition, room string, afterTs uint64, afterID int64, limit int) ([]T, error) {
+	var rows []T
+	err := p.scope().
+		Where("room_display_id = ? AND (timestamp, id) > (?, ?)", room, afterTs, afterID).
+		Order("timestamp, id").
+		Limit(limit).
+		Find(&rows).Error
+	return rows, err
+}
+
+// SaveArchive 记录归档文件并删除分区范围内该直播间已写入文件的消息
+// 只删除 (timestamp, id) 不超过文件中最后一条消息 (archive.End, lastID) 的行，
+// 归档期间迟到写入的消息留在数据库中，下次归档时写入新的文件
+func SaveArchive(p Partition, archive *MessageArchive, lastID int64) error {
+	archive.CreatedOn = time.Now().Unix()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(archive).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE room_display_id = ? AND timestamp >= ? AND timestamp < ? AND (timestamp, id) <= (?, ?)", p.Table),
+			archive.RoomDisplayId, p.Begin.UnixMilli(), p.End.UnixMilli(), archive.End, lastID).Error
+	})
+}
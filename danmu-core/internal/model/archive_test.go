@@ -0,0 +1,51 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthStartUsesPartitionLocation(t *testing.T) {
+	// UTC 4 月 30 日 16:00 在 Asia/Shanghai 已经是 5 月 1 日
+	got := MonthStart(time.Date(2024, 4, 30, 16, 0, 0, 0, time.UTC))
+	want := time.Date(2024, 5, 1, 0, 0, 0, 0, PartitionLocation)
+	if !got.Equal(want) {
+		t.Errorf("MonthStart = %v, want %v", got, want)
+	}
+	p := NewPartition(TableNameCommonMessage, got)
+	if p.Name != "common_messages_p202405" || p.Begin.UnixMilli() != 1714492800000 {
+		t.Errorf("partition = %s begin %d, want common_messages_p202405 begin 1714492800000", p.Name, p.Begin.UnixMilli())
+	}
+}
+
+func TestSaveArchiveDeletesOnlyArchivedRows(t *testing.T) {
+	room := "archive-bound"
+	p := NewPartition(TableNameCommonMessage, time.Date(2024, 3, 1, 0, 0, 0, 0, PartitionLocation))
+	ts := uint64(p.Begin.UnixMilli())
+	rows := []*CommonMessage{
+		{ID: 1, RoomDisplayId: room, Timestamp: ts + 10},
+		{ID: 2, RoomDisplayId: room, Timestamp: ts + 20},
+		// 与最后归档的消息时间相同但 id 更大，写入文件后才到达
+		{ID: 3, RoomDisplayId: room, Timestamp: ts + 20},
+		{ID: 4, RoomDisplayId: room, Timestamp: ts + 30},
+		{ID: 5, RoomDisplayId: "other-room", Timestamp: ts + 10},
+	}
+	if err := DB.Create(rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	record := &MessageArchive{MessageTable: p.Table, RoomDisplayId: room, Month: p.Begin.UnixMilli(),
+		Path: "archive.parquet", Rows: 2, Begin: int64(ts + 10), End: int64(ts + 20)}
+	if err := SaveArchive(p, record, 2); err != nil {
+		t.Fatalf("SaveArchive: %v", err)
+	}
+
+	var left []uint64
+	if err := DB.Model(&CommonMessage{}).Where("timestamp >= ? AND timestamp < ?", p.Begin.UnixMilli(), p.End.UnixMilli()).
+		Order("id").Pluck("id", &left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 3 || left[0] != 3 || left[1] != 4 || left[2] != 5 {
+		t.Errorf("rows left = %v, want [3 4 5]", left)
+	}
+}
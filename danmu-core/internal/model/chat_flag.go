@@ -0,0 +1,60 @@
+package model
+
+import (
+	"danmu-core/metrics"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const TableNameChatFlag = "chat_flags"
+
+// ChatFlag mapped from table <chat_flags>
+// 被检测为刷屏或疑似机器人的用户，每个直播间每个用户一条，evidence 为最近一次标记的依据
+type ChatFlag struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	UserName      string `gorm:"column:user_name" json:"user_name"`
+	UserDisplayId string `gorm:"column:user_display_id" json:"user_display_id"`
+	StreamerID    int64  `gorm:"column:streamer_id;default:0" json:"streamer_id"`
+	MaxScore      int    `gorm:"column:max_score;not null" json:"max_score"`
+	LastScore     int    `gorm:"column:last_score;not null" json:"last_score"`
+	Reasons       string `gorm:"column:reasons" json:"reasons"` // 最近一次标记命中的信号，逗号分隔
+	FlaggedCount  int64  `gorm:"column:flagged_count;not null" json:"flagged_count"`
+	ExcludedCount int64  `gorm:"column:excluded_count;not null" json:"excluded_count"` // 未写入 common_messages 的弹幕数
+	Evidence      string `gorm:"column:evidence" json:"evidence"`                      // json，见 moderation.Evidence
+	FirstFlagged  int64  `gorm:"column:first_flagged;not null" json:"first_flagged"`
+	LastFlagged   int64  `gorm:"column:last_flagged;not null" json:"last_flagged"`
+}
+
+// TableName ChatFlag's table name
+func (*ChatFlag) TableName() string {
+	return TableNameChatFlag
+}
+
+// SaveChatFlags 计数字段与已有值相加，分数和证据取最新一次
+func SaveChatFlags(flags []*ChatFlag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	start := time.Now()
+	set := addColumns(TableNameChatFlag, "flagged_count", "excluded_count")
+	set = append(set, clause.Assignments(map[string]interface{}{
+		"user_name":       gorm.Expr("excluded.user_name"),
+		"user_display_id": gorm.Expr("excluded.user_display_id"),
+		"streamer_id":     gorm.Expr("excluded.streamer_id"),
+		"max_score":       gorm.Expr(greatest(TableNameChatFlag+".max_score", "excluded.max_score")),
+		"last_score":      gorm.Expr("excluded.last_score"),
+		"reasons":         gorm.Expr("excluded.reasons"),
+		"evidence":        gorm.Expr("excluded.evidence"),
+		"first_flagged":   gorm.Expr(least(TableNameChatFlag+".first_flagged", "excluded.first_flagged")),
+		"last_flagged":    gorm.Expr(greatest(TableNameChatFlag+".last_flagged", "excluded.last_flagged")),
+	})...)
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   columns("user_id", "room_display_id"),
+		DoUpdates: set,
+	}).Create(flags).Error
+	metrics.ObserveDBInsert(TableNameChatFlag, start, err)
+	return err
+}
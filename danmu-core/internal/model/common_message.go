@@ -1,14 +1,18 @@
 package model
 
 import (
+	"danmu-core/metrics"
+	"time"
+
 	"gorm.io/gorm/clause"
 )
 
 const TableNameCommonMessage = "common_messages"
 
 // CommonMessage mapped from table <common_messages>
+// 按 timestamp 每月一个分区，见 core.MaintainPartitions
 type CommonMessage struct {
-	ID             uint64 `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	ID             uint64 `gorm:"column:id;primaryKey;autoIncrement:false" json:"id"`
 	MessageType    string `gorm:"column:message_type;not null" json:"message_type"`
 	RoomID         uint64 `gorm:"column:room_id;not null" json:"room_id"`
 	RoomDisplayId  string `gorm:"column:room_display_id;not null" json:"room_display_id"`
@@ -17,8 +21,9 @@ type CommonMessage struct {
 	UserID         uint64 `gorm:"column:user_id;not null" json:"user_id"`
 	UserDisplayId  string `gorm:"column:user_display_id;not null" json:"user_display_id"`
 	Content        string `gorm:"column:content;not null" json:"content"`
-	Timestamp      uint64 `gorm:"column:timestamp;not null" json:"timestamp"`
+	Timestamp      uint64 `gorm:"column:timestamp;primaryKey;not null" json:"timestamp"` // 分区键，与 id 组成主键
 	FavoriteUserId uint64 `gorm:"column:favorite_user_id;default:0" json:"favorite"`
+	StreamerID     int64  `gorm:"column:streamer_id;default:0" json:"streamer_id"`
 }
 
 // TableName CommonMessage's table name
@@ -27,12 +32,12 @@ func (*CommonMessage) TableName() string {
 }
 
 func (model *CommonMessage) Insert() error {
-	if err := DB.Clauses(clause.OnConflict{
+	start := time.Now()
+	err := DB.Clauses(clause.OnConflict{
 		UpdateAll: true,
-	}).Create(model).Error; err != nil {
-		return err
-	}
-	return nil
+	}).Create(model).Error
+	metrics.ObserveDBInsert(TableNameCommonMessage, start, err)
+	return err
 }
 
 func (model *CommonMessage) BatchInsert(models []*CommonMessage) error {
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"danmu-core/metrics"
+	"time"
+)
+
+const TableNameCoverageHole = "coverage_holes"
+
+// CoverageHole mapped from table <coverage_holes>
+// websocket 断开后未能通过 cursor 补发的时间范围，范围内的消息可能缺失
+type CoverageHole struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	ConfID        int64  `gorm:"column:conf_id;not null" json:"conf_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	RoomID        string `gorm:"column:room_id;not null" json:"room_id"`       // 抖音 room_id，每场直播不同
+	StartTime     int64  `gorm:"column:start_time;not null" json:"start_time"` // 断开前最后一次推送的服务端时间，毫秒
+	EndTime       int64  `gorm:"column:end_time;not null" json:"end_time"`     // 重连后第一次推送的服务端时间，毫秒
+	Reason        string `gorm:"column:reason;not null" json:"reason"`
+	CreatedAt     int64  `gorm:"column:created_at;not null" json:"created_at"`
+}
+
+// TableName CoverageHole's table name
+func (*CoverageHole) TableName() string {
+	return TableNameCoverageHole
+}
+
+func SaveCoverageHole(hole *CoverageHole) error {
+	start := time.Now()
+	err := DB.Create(hole).Error
+	metrics.ObserveDBInsert(TableNameCoverageHole, start, err)
+	return err
+}
@@ -4,27 +4,43 @@ import (
 	"danmu-core/logger"
 	"danmu-core/setting"
 	"fmt"
+	"os"
+	"path/filepath"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// [database] Type 支持的数据库
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+const defaultSQLitePath = "../data/danmu.db"
+
 var DB *gorm.DB
 
-func init() {
-	var dsn = fmt.Sprintf("user=%s password=%s host=%s dbname=%s port=%s sslmode=disable search_path=%s",
-		setting.DatabaseSetting.User,
-		setting.DatabaseSetting.Password,
-		setting.DatabaseSetting.Host,
-		setting.DatabaseSetting.DBName,
-		setting.DatabaseSetting.Port,
-		setting.DatabaseSetting.SearchPath)
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Init 按 [database] 配置连接数据库，在 setting.Setup 之后调用
+func Init() {
+	dialector, err := openDialector()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("db.Setup failure")
+		return
+	}
+	DB, err = gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		logger.Fatal().Err(err).Msg("db.Setup failure")
 		return
 	}
+	// postgres 的表结构由 cmd/sql/migrate.sql 创建，sqlite 由程序自动创建
+	if IsSQLite() {
+		if err := migrate(); err != nil {
+			logger.Fatal().Err(err).Msg("db.Migrate failure")
+			return
+		}
+	}
 	/*	sqlDB, err := DB.DB()
 		if err != nil {
 			log.Fatalf("failed to get Sql DB: %v", err)
@@ -33,6 +49,101 @@ func init() {
 		sqlDB.SetMaxOpenConns(settings.DatabaseSetting.MaxOpenConns)*/
 }
 
+func openDialector() (gorm.Dialector, error) {
+	switch setting.DatabaseSetting.Type {
+	case "", DialectPostgres:
+		var dsn = fmt.Sprintf("user=%s password=%s host=%s dbname=%s port=%s sslmode=disable search_path=%s",
+			setting.DatabaseSetting.User,
+			setting.DatabaseSetting.Password,
+			setting.DatabaseSetting.Host,
+			setting.DatabaseSetting.DBName,
+			setting.DatabaseSetting.Port,
+			setting.DatabaseSetting.SearchPath)
+		return postgres.Open(dsn), nil
+	case DialectSQLite:
+		path := setting.DatabaseSetting.Path
+		if path == "" {
+			path = defaultSQLitePath
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("create sqlite dir error: %w", err)
+		}
+		return sqlite.Open(sqliteDSN(path)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type %q", setting.DatabaseSetting.Type)
+	}
+}
+
+// sqliteDSN danmu-core 和 danmu-http 同时读写同一个文件，使用 WAL 并在锁冲突时等待
+func sqliteDSN(path string) string {
+	return path + "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+}
+
+// IsSQLite 当前是否使用 sqlite，部分 postgres 专用的语句需要区分处理
+func IsSQLite() bool {
+	return DB.Dialector.Name() == DialectSQLite
+}
+
+// sqliteIndexes 与 cmd/sql/migrate.sql 中的索引和唯一约束对应
+var sqliteIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_common_messages_user_id_timestamp ON common_messages (user_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_common_messages_timestamp ON common_messages (timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_common_messages_room_display_id_timestamp ON common_messages (room_display_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_common_messages_streamer_id_timestamp ON common_messages (streamer_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_gift_messages_user_id_timestamp ON gift_messages (user_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_gift_messages_timestamp ON gift_messages (timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_gift_messages_room_display_id_timestamp ON gift_messages (room_display_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_gift_messages_streamer_id_timestamp ON gift_messages (streamer_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_users_last_seen ON users (last_seen DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_user_name_history_user_id_timestamp ON user_name_history (user_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_user_room_stats_room_display_id ON user_room_stats (room_display_id)",
+	"CREATE INDEX IF NOT EXISTS idx_user_gift_stats_to_user_id ON user_gift_stats (to_user_id)",
+	"CREATE INDEX IF NOT EXISTS idx_message_archives_room_display_id_month ON message_archives (room_display_id, month)",
+	"CREATE INDEX IF NOT EXISTS idx_room_snapshots_room_display_id_timestamp ON room_snapshots (room_display_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_room_snapshots_room_id ON room_snapshots (room_id)",
+	"CREATE INDEX IF NOT EXISTS idx_room_titles_room_display_id_timestamp ON room_titles (room_display_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_room_titles_streamer_id_timestamp ON room_titles (streamer_id, timestamp DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_chat_flags_room_display_id_last_flagged ON chat_flags (room_display_id, last_flagged DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_dead_letters_room_display_id_last_failed ON dead_letters (room_display_id, last_failed DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_dead_letters_last_failed ON dead_letters (last_failed DESC)",
+	"CREATE INDEX IF NOT EXISTS idx_coverage_holes_room_display_id_start_time ON coverage_holes (room_display_id, start_time DESC)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS unique_display_id ON live_confs (room_display_id)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS unique_url ON live_confs (url)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS unique_name ON live_confs (name)",
+}
+
+// migrate 创建或更新 sqlite 的表结构，auths、overlays 表由 danmu-http 创建
+func migrate() error {
+	err := DB.AutoMigrate(
+		&CommonMessage{},
+		&GiftMessage{},
+		&User{},
+		&UserNameHistory{},
+		&UserRoomStat{},
+		&UserGiftStat{},
+		&UserDailyActivity{},
+		&UserFansClub{},
+		&LiveConf{},
+		&Streamer{},
+		&RoomSnapshot{},
+		&RoomTitle{},
+		&RetentionPolicy{},
+		&MessageArchive{},
+		&ChatFlag{},
+		&DeadLetter{},
+		&CoverageHole{},
+	)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range sqliteIndexes {
+		if err := DB.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Close closes the database connection
 func Close() {
 	if DB != nil {
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"danmu-core/metrics"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const TableNameDeadLetter = "dead_letters"
+
+// DeadLetter mapped from table <dead_letters>
+// handler 重试耗尽或无法解析的原始消息，可通过 danmu-http 重放或清除
+type DeadLetter struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	ConfID        int64  `gorm:"column:conf_id;not null" json:"conf_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	Handler       string `gorm:"column:handler;not null" json:"handler"` // LiveConf.Handlers 中的名称
+	Method        string `gorm:"column:method" json:"method"`
+	MsgID         uint64 `gorm:"column:msg_id" json:"msg_id"`
+	Payload       []byte `gorm:"column:payload;not null" json:"payload"` // protobuf 编码的 Webcast_Im_Message
+	Error         string `gorm:"column:error" json:"error"`
+	Attempts      int    `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	Replays       int    `gorm:"column:replays;not null;default:0" json:"replays"`
+	FirstFailed   int64  `gorm:"column:first_failed;not null" json:"first_failed"`
+	LastFailed    int64  `gorm:"column:last_failed;not null" json:"last_failed"`
+}
+
+// TableName DeadLetter's table name
+func (*DeadLetter) TableName() string {
+	return TableNameDeadLetter
+}
+
+func SaveDeadLetters(letters []*DeadLetter) error {
+	if len(letters) == 0 {
+		return nil
+	}
+	start := time.Now()
+	err := DB.Create(letters).Error
+	metrics.ObserveDBInsert(TableNameDeadLetter, start, err)
+	return err
+}
+
+// GetDeadLetter 不存在时返回 nil
+func GetDeadLetter(id int64) (*DeadLetter, error) {
+	var letter DeadLetter
+	err := DB.Where("id = ?", id).Take(&letter).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &letter, nil
+}
+
+func DeleteDeadLetter(id int64) error {
+	return DB.Where("id = ?", id).Delete(&DeadLetter{}).Error
+}
+
+// UpdateDeadLetterReplay 记录重放失败的原因
+func UpdateDeadLetterReplay(id int64, reason string, ts int64) error {
+	return DB.Model(&DeadLetter{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"error":       reason,
+		"replays":     gorm.Expr("replays + 1"),
+		"last_failed": ts,
+	}).Error
+}
+
+// ImportDeadLetters 在一个事务中写入，失败时不会留下部分记录
+func ImportDeadLetters(letters []*DeadLetter) error {
+	if len(letters) == 0 {
+		return nil
+	}
+	start := time.Now()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(letters, 500).Error
+	})
+	metrics.ObserveDBInsert(TableNameDeadLetter, start, err)
+	return err
+}
@@ -2,7 +2,9 @@ package model
 
 import (
 	"danmu-core/generated/dystruct"
+	"danmu-core/metrics"
 	"strings"
+	"time"
 
 	"gorm.io/gorm/clause"
 )
@@ -10,8 +12,9 @@ import (
 const TableNameGiftMessage = "gift_messages"
 
 // GiftMessage mapped from table <gift_messages>
+// 按 timestamp 每月一个分区，见 core.MaintainPartitions
 type GiftMessage struct {
-	ID              int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	ID              int64  `gorm:"column:id;primaryKey;autoIncrement:false" json:"id"`
 	UserID          uint64 `gorm:"column:user_id;not null" json:"user_id"`                       // User ID who sent the gift
 	UserName        string `gorm:"column:user_name;not null" json:"user_name"`                   // User Name
 	UserDisplayId   string `gorm:"column:user_display_id;not null" json:"user_display_id"`       // User Display ID
@@ -24,11 +27,12 @@ type GiftMessage struct {
 	RoomDisplayId   string `gorm:"column:room_display_id;not null" json:"room_display_id"` // Room Display ID
 	RoomName        string `gorm:"column:room_name;not null" json:"room_name"`             // Room Name
 	Message         string `gorm:"column:message;not null" json:"message"`                 // The gift message
-	Timestamp       uint64 `gorm:"column:timestamp;not null" json:"timestamp"`
+	Timestamp       uint64 `gorm:"column:timestamp;primaryKey;not null" json:"timestamp"`  // 分区键，与 id 组成主键
 	DiamondCount    int32  `gorm:"column:diamond_count;not null" json:"diamond_count"`
 	Image           string `gorm:"column:image_url" json:"image_url"`
 	RepeatEnd       int32  `gorm:"column:repeat_end" json:"repeat_end"`
 	ComboCount      string `gorm:"column:combo_count" json:"combo_count"`
+	StreamerID      int64  `gorm:"column:streamer_id;default:0" json:"streamer_id"`
 }
 
 // TableName GiftMessage's table name
@@ -105,12 +109,12 @@ func NewGiftMessage(message *dystruct.Webcast_Im_GiftMessage) *GiftMessage {
 
 // Insert
 func (model *GiftMessage) Insert() error {
-	if err := DB.Clauses(clause.OnConflict{
+	start := time.Now()
+	err := DB.Clauses(clause.OnConflict{
 		UpdateAll: true,
-	}).Create(model).Error; err != nil {
-		return err
-	}
-	return nil
+	}).Create(model).Error
+	metrics.ObserveDBInsert(TableNameGiftMessage, start, err)
+	return err
 }
 
 func (model *GiftMessage) BatchInsert(models []*GiftMessage) error {
@@ -1,5 +1,7 @@
 package model
 
+import "strings"
+
 const TableNameLiveConf = "live_confs"
 
 // LiveConf mapped from table <live_confs>
@@ -14,6 +16,8 @@ type LiveConf struct {
 	CratedBy      string `gorm:"column:crated_by" json:"crated_by"`
 	Enable        bool   `gorm:"column:enable;not null;" json:"enable"`
 	Cron          string `gorm:"column:cron" json:"cron"`
+	Handlers      string `gorm:"column:handlers" json:"handlers"` // 逗号分隔的 handler 名称
+	StreamerID    int64  `gorm:"column:streamer_id;default:0" json:"streamer_id"`
 }
 
 // TableName LiveConf's table name
@@ -21,6 +25,17 @@ func (*LiveConf) TableName() string {
 	return TableNameLiveConf
 }
 
+// HandlerNames 解析 Handlers 字段
+func (conf *LiveConf) HandlerNames() []string {
+	var names []string
+	for _, name := range strings.Split(conf.Handlers, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // select enable=1
 func SelectEnableLiveConf() ([]*LiveConf, error) {
 	var liveConfs []*LiveConf
@@ -46,3 +61,18 @@ func GetLiveConfByID(id int64) (*LiveConf, error) {
 	}
 	return liveConf, nil
 }
+
+// UpdateStreamer 关联主播，web_rid 变化时更新 url
+// room_display_id 与旧 web_rid 相同（即由链接自动生成）时一并更新，否则保留用户填写的值
+func (conf *LiveConf) UpdateStreamer(streamerID int64, oldWebRid, webRid, url string) error {
+	updates := map[string]interface{}{
+		"streamer_id": streamerID,
+	}
+	if webRid != "" && webRid != oldWebRid {
+		updates["url"] = url
+		if conf.RoomDisplayID == oldWebRid {
+			updates["room_display_id"] = webRid
+		}
+	}
+	return DB.Model(&LiveConf{}).Where("id = ?", conf.ID).Updates(updates).Error
+}
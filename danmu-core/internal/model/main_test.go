@@ -0,0 +1,16 @@
+package model
+
+import (
+	"danmu-core/internal/testenv"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	cleanup := testenv.Setup()
+	Init()
+	code := m.Run()
+	Close()
+	cleanup()
+	os.Exit(code)
+}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PartitionedTables 按 timestamp (毫秒) 每月一个分区的消息表
+var PartitionedTables = []string{TableNameCommonMessage, TableNameGiftMessage}
+
+const partitionMonthLayout = "200601"
+
+// PartitionLocation 按该时区切分分区的月份，与 cmd/sql 中创建分区的 SET TIME ZONE 'Asia/Shanghai' 一致，
+// 不使用服务器的本地时区，避免 danmu-core 与已有分区的边界不一致。没有时区数据库时使用固定的 UTC+8 (该时区没有夏令时)
+var PartitionLocation = func() *time.Location {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return time.FixedZone("CST", 8*60*60)
+	}
+	return loc
+}()
+
+// Partition 消息表的一个月分区，范围为 [Begin, End)
+type Partition struct {
+	Table string
+	Name  string
+	Begin time.Time
+	End   time.Time
+}
+
+// MonthStart 返回 t 所在月份第一天 0 点 (PartitionLocation)
+func MonthStart(t time.Time) time.Time {
+	t = t.In(PartitionLocation)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, PartitionLocation)
+}
+
+func NewPartition(table string, month time.Time) Partition {
+	begin := MonthStart(month)
+	return Partition{
+		Table: table,
+		Name:  table + "_p" + begin.Format(partitionMonthLayout),
+		Begin: begin,
+		End:   begin.AddDate(0, 1, 0),
+	}
+}
+
+// HasPartitions sqlite 不支持表分区，按月份范围处理同一张表
+func HasPartitions() bool {
+	return !IsSQLite()
+}
+
+// scope 分区对应的消息范围，通过父表查询，postgres 会裁剪到对应分区
+func (p Partition) scope() *gorm.DB {
+	return DB.Table(p.Table).Where("timestamp >= ? AND timestamp < ?", p.Begin.UnixMilli(), p.End.UnixMilli())
+}
+
+// EnsurePartition 创建分区，已存在时不做任何操作
+func EnsurePartition(p Partition) error {
+	if !HasPartitions() {
+		return nil
+	}
+	return DB.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%d) TO (%d)",
+		p.Name, p.Table, p.Begin.UnixMilli(), p.End.UnixMilli())).Error
+}
+
+// ListPartitions 按月份顺序返回表在当前 schema 下的所有月分区
+// sqlite 返回从最早的消息到当前月份的每个月
+func ListPartitions(table string) ([]Partition, error) {
+	if !HasPartitions() {
+		return listMonths(table)
+	}
+	var names []string
+	err := DB.Raw(`SELECT c.relname
+FROM pg_inherits i
+         JOIN pg_class c ON c.oid = i.inhrelid
+         JOIN pg_class p ON p.oid = i.inhparent
+         JOIN pg_namespace n ON n.oid = p.relnamespace
+WHERE p.relname = ?
+  AND n.nspname = current_schema()
+ORDER BY c.relname`, table).Scan(&names).Error
+	if err != nil {
+		return nil, err
+	}
+	partitions := make([]Partition, 0, len(names))
+	for _, name := range names {
+		month, err := time.ParseInLocation(partitionMonthLayout, strings.TrimPrefix(name, table+"_p"), PartitionLocation)
+		if err != nil {
+			// 不是按本规则命名的分区 (如手动创建的 default 分区)，不参与维护
+			continue
+		}
+		partitions = append(partitions, NewPartition(table, month))
+	}
+	return partitions, nil
+}
+
+func listMonths(table string) ([]Partition, error) {
+	var first sql.NullInt64
+	if err := DB.Table(table).Select("MIN(timestamp)").Scan(&first).Error; err != nil {
+		return nil, err
+	}
+	if !first.Valid {
+		return nil, nil
+	}
+	var partitions []Partition
+	last := MonthStart(time.Now())
+	for month := MonthStart(time.UnixMilli(first.Int64)); !month.After(last); month = month.AddDate(0, 1, 0) {
+		partitions = append(partitions, NewPartition(table, month))
+	}
+	return partitions, nil
+}
+
+// PartitionRooms 返回分区中出现的直播间
+func PartitionRooms(p Partition) ([]string, error) {
+	var rooms []string
+	err := p.scope().Distinct("room_display_id").Pluck("room_display_id", &rooms).Error
+	return rooms, err
+}
+
+func CountPartitionRows(p Partition) (int64, error) {
+	var count int64
+	err := p.scope().Count(&count).Error
+	return count, err
+}
+
+func DropPartition(p Partition) error {
+	if !HasPartitions() {
+		return nil
+	}
+	return DB.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", p.Name)).Error
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"danmu-core/metrics"
+	"time"
+)
+
+const (
+	TableNameRoomSnapshot = "room_snapshots"
+	TableNameRoomTitle    = "room_titles"
+)
+
+// RoomSnapshot mapped from table <room_snapshots>
+// 直播间元数据快照，CheckStream 时和收到直播间统计消息时写入
+type RoomSnapshot struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	RoomID        uint64 `gorm:"column:room_id;not null" json:"room_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	StreamerID    int64  `gorm:"column:streamer_id;default:0" json:"streamer_id"`
+	Status        int64  `gorm:"column:status" json:"status"`
+	Title         string `gorm:"column:title" json:"title"`
+	Cover         string `gorm:"column:cover" json:"cover"`
+	OwnerNickname string `gorm:"column:owner_nickname" json:"owner_nickname"`
+	OwnerAvatar   string `gorm:"column:owner_avatar" json:"owner_avatar"`
+	Category      string `gorm:"column:category" json:"category"`
+	UserCount     int64  `gorm:"column:user_count" json:"user_count"`
+	TotalUser     int64  `gorm:"column:total_user" json:"total_user"`
+	LikeCount     int64  `gorm:"column:like_count" json:"like_count"`
+	Source        string `gorm:"column:source" json:"source"` // check 或触发快照的消息类型
+	Timestamp     int64  `gorm:"column:timestamp;not null" json:"timestamp"`
+}
+
+// TableName RoomSnapshot's table name
+func (*RoomSnapshot) TableName() string {
+	return TableNameRoomSnapshot
+}
+
+func (model *RoomSnapshot) Insert() error {
+	start := time.Now()
+	err := DB.Create(model).Error
+	metrics.ObserveDBInsert(TableNameRoomSnapshot, start, err)
+	return err
+}
+
+// RoomTitle mapped from table <room_titles>
+// 直播间标题变更记录
+type RoomTitle struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	RoomID        uint64 `gorm:"column:room_id;not null" json:"room_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	StreamerID    int64  `gorm:"column:streamer_id;default:0" json:"streamer_id"`
+	Title         string `gorm:"column:title;not null" json:"title"`
+	Timestamp     int64  `gorm:"column:timestamp;not null" json:"timestamp"`
+}
+
+// TableName RoomTitle's table name
+func (*RoomTitle) TableName() string {
+	return TableNameRoomTitle
+}
+
+func (model *RoomTitle) Insert() error {
+	start := time.Now()
+	err := DB.Create(model).Error
+	metrics.ObserveDBInsert(TableNameRoomTitle, start, err)
+	return err
+}
+
+// GetLastRoomTitle 获取直播间最近一次记录的标题，没有记录时返回空字符串
+func GetLastRoomTitle(roomDisplayId string) (string, error) {
+	var titles []string
+	err := DB.Model(&RoomTitle{}).
+		Where("room_display_id = ?", roomDisplayId).
+		Order("timestamp desc").
+		Limit(1).
+		Pluck("title", &titles).Error
+	if err != nil || len(titles) == 0 {
+		return "", err
+	}
+	return titles[0], nil
+}
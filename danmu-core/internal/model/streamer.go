@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const TableNameStreamer = "streamers"
+
+// Streamer mapped from table <streamers>
+// 以 sec_uid 标识主播账号，web_rid、抖音号、昵称变化时更新同一条记录
+type Streamer struct {
+	ID         int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	SecUid     string `gorm:"column:sec_uid;not null;uniqueIndex" json:"sec_uid"`
+	Uid        string `gorm:"column:uid" json:"uid"`
+	Nickname   string `gorm:"column:nickname" json:"nickname"`
+	Avatar     string `gorm:"column:avatar" json:"avatar"`
+	DisplayID  string `gorm:"column:display_id" json:"display_id"`
+	WebRid     string `gorm:"column:web_rid" json:"web_rid"`
+	CreatedOn  int64  `gorm:"column:created_on" json:"created_on"`
+	ModifiedOn int64  `gorm:"column:modified_on" json:"modified_on"`
+}
+
+// TableName Streamer's table name
+func (*Streamer) TableName() string {
+	return TableNameStreamer
+}
+
+// Upsert 按 sec_uid 插入或更新主播信息，完成后 ID 为数据库中的主键
+func (model *Streamer) Upsert() error {
+	now := time.Now().Unix()
+	model.CreatedOn = now
+	model.ModifiedOn = now
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "sec_uid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"uid", "nickname", "avatar", "display_id", "web_rid", "modified_on"}),
+	}, clause.Returning{Columns: []clause.Column{{Name: "id"}}}).Create(model).Error
+}
+
+func GetStreamerByID(id int64) (*Streamer, error) {
+	var streamer Streamer
+	if err := DB.Where("id = ?", id).First(&streamer).Error; err != nil {
+		return nil, err
+	}
+	return &streamer, nil
+}
@@ -2,15 +2,39 @@ package model
 
 import (
 	"danmu-core/generated/dystruct"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-const TableNameUser = "users"
+const (
+	TableNameUser            = "users"
+	TableNameUserNameHistory = "user_name_history"
+)
 
 // User mapped from table <users>
+// 每个 user_id 一条记录，收到用户消息时更新资料和 last_seen
 type User struct {
-	UserID    uint64 `gorm:"column:user_id;not null;index" json:"user_id"`
-	DisplayID string `gorm:"column:display_id;not null" json:"display_id"`
-	UserName  string `gorm:"column:user_name;not null" json:"user_name"`
+	ID             int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	UserID         uint64 `gorm:"column:user_id;not null;uniqueIndex" json:"user_id"`
+	DisplayID      string `gorm:"column:display_id;not null" json:"display_id"`
+	UserName       string `gorm:"column:user_name;not null" json:"user_name"`
+	SecUid         string `gorm:"column:sec_uid" json:"sec_uid"`
+	Avatar         string `gorm:"column:avatar" json:"avatar"`
+	Gender         int32  `gorm:"column:gender" json:"gender"` // 0 未知 1 男 2 女
+	PayGrade       uint64 `gorm:"column:pay_grade" json:"pay_grade"`
+	FollowerCount  uint64 `gorm:"column:follower_count" json:"follower_count"`
+	FollowingCount uint64 `gorm:"column:following_count" json:"following_count"`
+	FirstSeen      int64  `gorm:"column:first_seen" json:"first_seen"`
+	LastSeen       int64  `gorm:"column:last_seen" json:"last_seen"`
+
+	// FansClub 消息中用户佩戴的粉丝团徽章，用户可以加入多个主播的粉丝团，按主播写入 user_fans_clubs
+	FansClub *UserFansClub `gorm:"-" json:"-"`
 }
 
 // TableName User's table name
@@ -18,31 +42,102 @@ func (*User) TableName() string {
 	return TableNameUser
 }
 
+// UserNameHistory mapped from table <user_name_history>
+// 用户昵称和抖音号的变更记录，每次变化插入一条
+type UserNameHistory struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	UserID    uint64 `gorm:"column:user_id;not null;index" json:"user_id"`
+	UserName  string `gorm:"column:user_name;not null" json:"user_name"`
+	DisplayID string `gorm:"column:display_id;not null" json:"display_id"`
+	Timestamp int64  `gorm:"column:timestamp;not null" json:"timestamp"`
+}
+
+// TableName UserNameHistory's table name
+func (*UserNameHistory) TableName() string {
+	return TableNameUserNameHistory
+}
+
 func NewUser(user *dystruct.Webcast_Data_User) *User {
-	return &User{
+	u := &User{
 		UserID:    user.Id,
 		DisplayID: user.DisplayId,
 		UserName:  user.Nickname,
+		SecUid:    user.SecUid,
+		Gender:    user.Gender,
+	}
+	if len(user.GetAvatarThumb().GetUrlList()) > 0 {
+		u.Avatar = user.AvatarThumb.UrlList[0]
+	}
+	if user.PayGrade != nil {
+		u.PayGrade = user.PayGrade.Level
 	}
+	u.FansClub = NewUserFansClub(user)
+	if user.FollowInfo != nil {
+		u.FollowerCount = user.FollowInfo.FollowerCount
+		u.FollowingCount = user.FollowInfo.FollowingCount
+	}
+	return u
 }
 
-// Insert
-func (model *User) Insert() error {
-	if err := DB.Create(model).Error; err != nil {
-		return err
+// fansClubWarnInterval 写入 user_fans_clubs 失败 (如未执行 upgrade_fans_clubs.sql) 时日志的最小间隔
+const fansClubWarnInterval = time.Minute
+
+var lastFansClubWarn atomic.Int64
+
+// Upsert 按 user_id 插入或更新用户资料，昵称或抖音号变化时记录到 user_name_history，
+// 佩戴粉丝团徽章时再更新 user_fans_clubs 中该主播的粉丝团，粉丝团写入失败只记录日志，不影响用户资料
+func (model *User) Upsert() error {
+	start := time.Now()
+	now := start.UnixMilli()
+	model.FirstSeen = now
+	model.LastSeen = now
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var last UserNameHistory
+		err := tx.Where("user_id = ?", model.UserID).Order("timestamp desc").First(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err != nil || last.UserName != model.UserName || last.DisplayID != model.DisplayID {
+			history := &UserNameHistory{
+				UserID:    model.UserID,
+				UserName:  model.UserName,
+				DisplayID: model.DisplayID,
+				Timestamp: now,
+			}
+			if err := tx.Create(history).Error; err != nil {
+				return err
+			}
+		}
+		// first_seen 只在插入时写入
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"display_id", "user_name", "sec_uid", "avatar", "gender", "pay_grade",
+				"follower_count", "following_count", "last_seen",
+			}),
+		}).Create(model).Error
+	})
+	metrics.ObserveDBInsert(TableNameUser, start, err)
+	if err == nil && model.FansClub != nil {
+		model.FansClub.FirstSeen = now
+		model.FansClub.LastSeen = now
+		model.FansClub.save()
 	}
-	return nil
+	return err
 }
 
-// CheckAndInsert 检查用户是否存在且信息是否变化，如果变化则插入新记录
-func (model *User) CheckAndInsert() error {
-	var existingUser User
-	// 查找该用户最新的一条记录
-	if err := DB.Where("user_id = ? AND user_name = ? AND display_id = ?", model.UserID, model.UserName, model.DisplayID).
-		Order("id DESC").
-		First(&existingUser).Error; err == nil {
-		return nil
+// save 单独写入粉丝团，失败时按 fansClubWarnInterval 限制日志频率
+func (club *UserFansClub) save() {
+	start := time.Now()
+	err := upsertFansClubs(DB, []*UserFansClub{club})
+	metrics.ObserveDBInsert(TableNameUserFansClub, start, err)
+	if err == nil {
+		return
+	}
+	last := lastFansClubWarn.Load()
+	if start.UnixMilli()-last < fansClubWarnInterval.Milliseconds() || !lastFansClubWarn.CompareAndSwap(last, start.UnixMilli()) {
+		return
 	}
-	// 用户不存在或信息有变化，插入新记录
-	return model.Insert()
+	logger.Warn().Err(err).Uint64("user_id", club.UserID).
+		Msg("Failed to save user fans club, run cmd/sql/upgrade_fans_clubs.sql on existing postgres databases")
 }
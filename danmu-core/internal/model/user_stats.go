@@ -0,0 +1,233 @@
+package model
+
+import (
+	"danmu-core/generated/dystruct"
+	"danmu-core/metrics"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TableNameUserRoomStat      = "user_room_stats"
+	TableNameUserGiftStat      = "user_gift_stats"
+	TableNameUserDailyActivity = "user_daily_activity"
+	TableNameUserFansClub      = "user_fans_clubs"
+)
+
+// UserRoomStat mapped from table <user_room_stats>
+// 用户在每个直播间的累计弹幕和送礼统计
+type UserRoomStat struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	RoomName      string `gorm:"column:room_name" json:"room_name"`
+	StreamerID    int64  `gorm:"column:streamer_id;default:0" json:"streamer_id"`
+	ChatCount     int64  `gorm:"column:chat_count;not null" json:"chat_count"`
+	GiftCount     int64  `gorm:"column:gift_count;not null" json:"gift_count"`
+	DiamondTotal  int64  `gorm:"column:diamond_total;not null" json:"diamond_total"`
+	FirstSeen     int64  `gorm:"column:first_seen;not null" json:"first_seen"`
+	LastSeen      int64  `gorm:"column:last_seen;not null" json:"last_seen"`
+}
+
+// TableName UserRoomStat's table name
+func (*UserRoomStat) TableName() string {
+	return TableNameUserRoomStat
+}
+
+// UserGiftStat mapped from table <user_gift_stats>
+// 用户在直播间送给每个接收者的每种礼物的累计数量，连击只按最终数量计算
+type UserGiftStat struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	ToUserID      uint64 `gorm:"column:to_user_id;primaryKey" json:"to_user_id"`
+	GiftID        int64  `gorm:"column:gift_id;primaryKey" json:"gift_id"`
+	ToUserName    string `gorm:"column:to_user_name" json:"to_user_name"`
+	GiftName      string `gorm:"column:gift_name" json:"gift_name"`
+	Image         string `gorm:"column:image_url" json:"image_url"`
+	GiftCount     int64  `gorm:"column:gift_count;not null" json:"gift_count"`
+	DiamondTotal  int64  `gorm:"column:diamond_total;not null" json:"diamond_total"`
+	LastSent      int64  `gorm:"column:last_sent;not null" json:"last_sent"`
+}
+
+// TableName UserGiftStat's table name
+func (*UserGiftStat) TableName() string {
+	return TableNameUserGiftStat
+}
+
+// UserDailyActivity mapped from table <user_daily_activity>
+// 用户每天在每个直播间的活动，day 为当天 0 点的毫秒时间戳
+type UserDailyActivity struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	Day           int64  `gorm:"column:day;primaryKey" json:"day"`
+	ChatCount     int64  `gorm:"column:chat_count;not null" json:"chat_count"`
+	GiftCount     int64  `gorm:"column:gift_count;not null" json:"gift_count"`
+	DiamondTotal  int64  `gorm:"column:diamond_total;not null" json:"diamond_total"`
+	FirstSeen     int64  `gorm:"column:first_seen;not null" json:"first_seen"`
+	LastSeen      int64  `gorm:"column:last_seen;not null" json:"last_seen"`
+}
+
+// TableName UserDailyActivity's table name
+func (*UserDailyActivity) TableName() string {
+	return TableNameUserDailyActivity
+}
+
+// UserFansClub mapped from table <user_fans_clubs>
+// 用户加入的粉丝团，从消息中的用户粉丝团徽章获取，每个用户每个主播一条。
+// 粉丝团名称可以重名也可以修改，按主播的 user_id (anchor_id) 区分
+type UserFansClub struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	AnchorID      uint64 `gorm:"column:anchor_id;primaryKey" json:"anchor_id"`
+	ClubName      string `gorm:"column:club_name;not null" json:"club_name"`
+	Level         int32  `gorm:"column:level" json:"level"`
+	RoomDisplayId string `gorm:"column:room_display_id" json:"room_display_id"`
+	StreamerID    int64  `gorm:"column:streamer_id;default:0" json:"streamer_id"`
+	FirstSeen     int64  `gorm:"column:first_seen;not null" json:"first_seen"`
+	LastSeen      int64  `gorm:"column:last_seen;not null" json:"last_seen"`
+}
+
+// TableName UserFansClub's table name
+func (*UserFansClub) TableName() string {
+	return TableNameUserFansClub
+}
+
+// NewUserFansClub 返回用户佩戴的粉丝团徽章，没有徽章或缺少主播 id 时返回 nil
+func NewUserFansClub(user *dystruct.Webcast_Data_User) *UserFansClub {
+	data := user.GetFansClub().GetData()
+	if data == nil || data.ClubName == "" || data.AnchorId == 0 {
+		return nil
+	}
+	return &UserFansClub{
+		UserID:   user.Id,
+		AnchorID: data.AnchorId,
+		ClubName: data.ClubName,
+		Level:    data.Level,
+	}
+}
+
+// upsertFansClubs 按 (user_id, anchor_id) 写入粉丝团，名称、等级和所在直播间取最新值，
+// 所在直播间为空时保留原值
+func upsertFansClubs(tx *gorm.DB, clubs []*UserFansClub) error {
+	set := seenColumns(TableNameUserFansClub)
+	set = append(set, clause.Assignments(map[string]interface{}{
+		"club_name":       gorm.Expr("excluded.club_name"),
+		"level":           gorm.Expr("excluded.level"),
+		"room_display_id": gorm.Expr("COALESCE(NULLIF(excluded.room_display_id, ''), " + TableNameUserFansClub + ".room_display_id)"),
+		"streamer_id":     gorm.Expr("COALESCE(NULLIF(excluded.streamer_id, 0), " + TableNameUserFansClub + ".streamer_id)"),
+	})...)
+	return tx.Clauses(clause.OnConflict{
+		Columns:   columns("user_id", "anchor_id"),
+		DoUpdates: set,
+	}).Create(clubs).Error
+}
+
+// UserStatsBatch 一段时间内累计的增量，计数字段在写入时与已有值相加
+type UserStatsBatch struct {
+	Rooms     []*UserRoomStat
+	Gifts     []*UserGiftStat
+	Daily     []*UserDailyActivity
+	FansClubs []*UserFansClub
+}
+
+func (b *UserStatsBatch) Empty() bool {
+	return len(b.Rooms) == 0 && len(b.Gifts) == 0 && len(b.Daily) == 0 && len(b.FansClubs) == 0
+}
+
+// addColumns 返回 "table.col + excluded.col" 形式的累加赋值
+func addColumns(table string, cols ...string) []clause.Assignment {
+	assignments := make([]clause.Assignment, 0, len(cols))
+	for _, col := range cols {
+		assignments = append(assignments, clause.Assignment{
+			Column: clause.Column{Name: col},
+			Value:  gorm.Expr(table + "." + col + " + excluded." + col),
+		})
+	}
+	return assignments
+}
+
+// seenColumns first_seen 取较早值，last_seen 取较晚值
+func seenColumns(table string) []clause.Assignment {
+	return []clause.Assignment{
+		{Column: clause.Column{Name: "first_seen"}, Value: gorm.Expr(least(table+".first_seen", "excluded.first_seen"))},
+		{Column: clause.Column{Name: "last_seen"}, Value: gorm.Expr(greatest(table+".last_seen", "excluded.last_seen"))},
+	}
+}
+
+// greatest sqlite 没有 GREATEST/LEAST，多参数的 MAX/MIN 为标量函数
+func greatest(a, b string) string {
+	if IsSQLite() {
+		return "MAX(" + a + ", " + b + ")"
+	}
+	return "GREATEST(" + a + ", " + b + ")"
+}
+
+func least(a, b string) string {
+	if IsSQLite() {
+		return "MIN(" + a + ", " + b + ")"
+	}
+	return "LEAST(" + a + ", " + b + ")"
+}
+
+func columns(names ...string) []clause.Column {
+	cols := make([]clause.Column, 0, len(names))
+	for _, name := range names {
+		cols = append(cols, clause.Column{Name: name})
+	}
+	return cols
+}
+
+// Save 在一个事务中累加写入所有统计
+func (b *UserStatsBatch) Save() error {
+	if b.Empty() {
+		return nil
+	}
+	start := time.Now()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if len(b.Rooms) > 0 {
+			set := append(addColumns(TableNameUserRoomStat, "chat_count", "gift_count", "diamond_total"), seenColumns(TableNameUserRoomStat)...)
+			set = append(set, clause.Assignments(map[string]interface{}{
+				"room_name":   gorm.Expr("excluded.room_name"),
+				"streamer_id": gorm.Expr("excluded.streamer_id"),
+			})...)
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   columns("user_id", "room_display_id"),
+				DoUpdates: set,
+			}).Create(b.Rooms).Error; err != nil {
+				return err
+			}
+		}
+		if len(b.Gifts) > 0 {
+			set := addColumns(TableNameUserGiftStat, "gift_count", "diamond_total")
+			set = append(set, clause.Assignments(map[string]interface{}{
+				"to_user_name": gorm.Expr("excluded.to_user_name"),
+				"gift_name":    gorm.Expr("excluded.gift_name"),
+				"image_url":    gorm.Expr("excluded.image_url"),
+				"last_sent":    gorm.Expr(greatest(TableNameUserGiftStat+".last_sent", "excluded.last_sent")),
+			})...)
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   columns("user_id", "room_display_id", "to_user_id", "gift_id"),
+				DoUpdates: set,
+			}).Create(b.Gifts).Error; err != nil {
+				return err
+			}
+		}
+		if len(b.Daily) > 0 {
+			set := append(addColumns(TableNameUserDailyActivity, "chat_count", "gift_count", "diamond_total"), seenColumns(TableNameUserDailyActivity)...)
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   columns("user_id", "room_display_id", "day"),
+				DoUpdates: set,
+			}).Create(b.Daily).Error; err != nil {
+				return err
+			}
+		}
+		if len(b.FansClubs) > 0 {
+			if err := upsertFansClubs(tx, b.FansClubs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	metrics.ObserveDBInsert(TableNameUserRoomStat, start, err)
+	return err
+}
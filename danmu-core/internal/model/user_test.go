@@ -0,0 +1,93 @@
+package model
+
+import (
+	"danmu-core/generated/dystruct"
+	"testing"
+)
+
+func badgeUser(id, anchor uint64, club string, level int32) *dystruct.Webcast_Data_User {
+	return &dystruct.Webcast_Data_User{
+		Id:        id,
+		Nickname:  "viewer",
+		DisplayId: "viewer01",
+		FansClub: &dystruct.Webcast_Data_User_FansClub{
+			Data: &dystruct.Webcast_Data_User_FansClub_FansClubData{ClubName: club, Level: level, AnchorId: anchor},
+		},
+	}
+}
+
+func TestUserUpsertKeepsFansClubPerAnchor(t *testing.T) {
+	const userID = 9001
+	for _, u := range []*dystruct.Webcast_Data_User{
+		badgeUser(userID, 1, "同名团", 3),
+		badgeUser(userID, 2, "同名团", 7),
+		// 同一主播的粉丝团改名、升级
+		badgeUser(userID, 1, "新团名", 4),
+		// 没有主播 id 的徽章无法对应到主播，不写入
+		badgeUser(userID, 0, "未知团", 9),
+	} {
+		user := NewUser(u)
+		if user.FansClub != nil {
+			user.FansClub.RoomDisplayId = "room1"
+		}
+		if err := user.Upsert(); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+	}
+
+	var clubs []*UserFansClub
+	if err := DB.Where("user_id = ?", userID).Order("anchor_id").Find(&clubs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(clubs) != 2 {
+		t.Fatalf("got %d fans clubs, want 2", len(clubs))
+	}
+	if c := clubs[0]; c.AnchorID != 1 || c.ClubName != "新团名" || c.Level != 4 || c.RoomDisplayId != "room1" {
+		t.Errorf("anchor 1 club = %+v, want 新团名 level 4", c)
+	}
+	if c := clubs[1]; c.AnchorID != 2 || c.ClubName != "同名团" || c.Level != 7 {
+		t.Errorf("anchor 2 club = %+v, want 同名团 level 7", c)
+	}
+
+	var users int64
+	DB.Model(&User{}).Where("user_id = ?", userID).Count(&users)
+	if users != 1 {
+		t.Errorf("got %d users, want 1", users)
+	}
+}
+
+func TestUpsertFansClubsKeepsRoomWhenEmpty(t *testing.T) {
+	club := &UserFansClub{UserID: 9002, AnchorID: 1, ClubName: "团", Level: 1, RoomDisplayId: "room1", StreamerID: 5, FirstSeen: 10, LastSeen: 10}
+	if err := upsertFansClubs(DB, []*UserFansClub{club}); err != nil {
+		t.Fatal(err)
+	}
+	update := &UserFansClub{UserID: 9002, AnchorID: 1, ClubName: "团", Level: 2, FirstSeen: 20, LastSeen: 20}
+	if err := upsertFansClubs(DB, []*UserFansClub{update}); err != nil {
+		t.Fatal(err)
+	}
+	var got UserFansClub
+	if err := DB.Where("user_id = ? AND anchor_id = ?", 9002, 1).First(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Level != 2 || got.RoomDisplayId != "room1" || got.StreamerID != 5 || got.FirstSeen != 10 || got.LastSeen != 20 {
+		t.Errorf("club = %+v, want level 2 in room1 streamer 5 seen 10..20", got)
+	}
+}
+
+func TestUserUpsertWithoutFansClubTable(t *testing.T) {
+	// 未执行 upgrade_fans_clubs.sql 的数据库，粉丝团写入失败不影响用户资料
+	tmp := TableNameUserFansClub + "_missing"
+	if err := DB.Migrator().RenameTable(TableNameUserFansClub, tmp); err != nil {
+		t.Fatal(err)
+	}
+	defer DB.Migrator().RenameTable(tmp, TableNameUserFansClub)
+
+	if err := NewUser(badgeUser(9003, 1, "团", 1)).Upsert(); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	var users int64
+	DB.Model(&User{}).Where("user_id = ?", 9003).Count(&users)
+	if users != 1 {
+		t.Errorf("got %d users, want 1", users)
+	}
+}
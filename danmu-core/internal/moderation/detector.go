@@ -0,0 +1,395 @@
+// Package moderation 按发送频率、重复内容、账号特征和进场时间为弹幕打分，识别刷屏和疑似机器人
+//
+// 每条弹幕的得分为各项信号之和 (最高 100)：
+//
+//	rate       RateWindow 内的弹幕数超过 RateLimit 时 20 分，之后每多一条加 10 分，最多 50 分
+//	duplicate  与该用户 DuplicateWindow 内的弹幕相似，每条 20 分，最多 40 分
+//	flood      DuplicateWindow 内有 CrossUserLimit 个以上的用户发送相同内容，30-40 分
+//	join_chat  进场后 JoinChatWindow 内发送第一条弹幕，20 分
+//	account    默认昵称、没有粉丝、大量关注、没有消费和粉丝团，最多 20 分
+//
+// 账号特征和进场时间只作为辅助，单独不会达到默认的标记分数
+package moderation
+
+import (
+	"danmu-core/generated/dystruct"
+	"danmu-core/setting"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	ReasonRate      = "rate"
+	ReasonDuplicate = "duplicate"
+	ReasonFlood     = "flood"
+	ReasonJoinChat  = "join_chat"
+	ReasonAccount   = "account"
+)
+
+const (
+	maxScore = 100
+	// maxRecent 每个用户保留用于比较和作为证据的最近弹幕数
+	maxRecent     = 10
+	userCacheSize = 20000
+	textCacheSize = 5000
+)
+
+// defaultNamePattern 未修改过昵称的账号
+var defaultNamePattern = regexp.MustCompile(`^用户\d{6,}$`)
+
+type Config struct {
+	FlagScore       int
+	ExcludeScore    int // 0 表示不排除
+	RateWindow      time.Duration
+	RateLimit       int
+	DuplicateWindow time.Duration
+	Similarity      float64
+	MinContentLen   int
+	CrossUserLimit  int
+	JoinChatWindow  time.Duration
+}
+
+// SettingConfig 读取 [moderation] 配置，未配置的项由 NewDetector 使用默认值
+func SettingConfig() Config {
+	c := setting.ModerationSetting
+	return Config{
+		FlagScore:       c.FlagScore,
+		ExcludeScore:    c.ExcludeScore,
+		RateWindow:      time.Duration(c.RateWindow) * time.Second,
+		RateLimit:       c.RateLimit,
+		DuplicateWindow: time.Duration(c.DuplicateWindow) * time.Second,
+		Similarity:      c.Similarity,
+		MinContentLen:   c.MinContentLen,
+		CrossUserLimit:  c.CrossUserLimit,
+		JoinChatWindow:  time.Duration(c.JoinChatWindow) * time.Second,
+	}
+}
+
+func (c *Config) applyDefaults() {
+	if c.FlagScore <= 0 {
+		c.FlagScore = 60
+	}
+	if c.RateWindow <= 0 {
+		c.RateWindow = 10 * time.Second
+	}
+	if c.RateLimit <= 0 {
+		c.RateLimit = 5
+	}
+	if c.DuplicateWindow <= 0 {
+		c.DuplicateWindow = 2 * time.Minute
+	}
+	if c.Similarity <= 0 || c.Similarity > 1 {
+		c.Similarity = 0.8
+	}
+	if c.MinContentLen <= 0 {
+		c.MinContentLen = 4
+	}
+	if c.CrossUserLimit <= 1 {
+		c.CrossUserLimit = 5
+	}
+	if c.JoinChatWindow <= 0 {
+		c.JoinChatWindow = 3 * time.Second
+	}
+}
+
+// Chat 待检测的弹幕，Timestamp 为毫秒
+type Chat struct {
+	MsgID     uint64
+	User      *dystruct.Webcast_Data_User
+	Content   string
+	Timestamp int64
+}
+
+// Signal 一项命中的信号
+type Signal struct {
+	Reason string `json:"reason"`
+	Score  int    `json:"score"`
+	Detail string `json:"detail"`
+}
+
+// Message 作为证据保存的弹幕
+type Message struct {
+	ID        uint64 `json:"id,string"`
+	Content   string `json:"content"`
+	Timestamp int64  `json:"timestamp"`
+
+	normalized []rune
+}
+
+// Account 检测时的账号信息
+type Account struct {
+	FollowerCount  uint64 `json:"follower_count"`
+	FollowingCount uint64 `json:"following_count"`
+	PayGrade       uint64 `json:"pay_grade"`
+	FansClubLevel  int32  `json:"fans_club_level"`
+	DefaultName    bool   `json:"default_name"`
+}
+
+// Evidence 标记用户的依据，保存到 chat_flags.evidence
+type Evidence struct {
+	Signals  []Signal   `json:"signals"`
+	Messages []*Message `json:"messages"` // 该用户最近的弹幕，包含本条
+	Account  *Account   `json:"account,omitempty"`
+}
+
+type Verdict struct {
+	Score    int
+	Flagged  bool
+	Excluded bool      // 排除的弹幕同时也会被标记
+	Evidence *Evidence // 只在 Flagged 时填充
+}
+
+// Reasons 命中的信号名称
+func (v *Verdict) Reasons() []string {
+	if v.Evidence == nil {
+		return nil
+	}
+	reasons := make([]string, 0, len(v.Evidence.Signals))
+	for _, s := range v.Evidence.Signals {
+		reasons = append(reasons, s.Reason)
+	}
+	return reasons
+}
+
+type userState struct {
+	times    []int64 // RateWindow 内的弹幕时间
+	recent   []*Message
+	joinedAt int64 // 最近一次进场时间，发送弹幕后清零
+}
+
+type textState struct {
+	users map[uint64]int64 // user_id -> 最近一次发送时间
+}
+
+// Detector 一个直播间的检测状态，不是并发安全的，由直播间的 handler 串行调用
+type Detector struct {
+	conf  Config
+	users *lru.Cache // user_id -> *userState
+	texts *lru.Cache // 归一化内容 -> *textState
+}
+
+func NewDetector(conf Config) (*Detector, error) {
+	conf.applyDefaults()
+	users, err := lru.New(userCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("moderation init cache failure, err:%v", err)
+	}
+	texts, err := lru.New(textCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("moderation init cache failure, err:%v", err)
+	}
+	return &Detector{conf: conf, users: users, texts: texts}, nil
+}
+
+func (d *Detector) user(id uint64) *userState {
+	if v, ok := d.users.Get(id); ok {
+		return v.(*userState)
+	}
+	u := &userState{}
+	d.users.Add(id, u)
+	return u
+}
+
+// Join 记录用户进场时间
+func (d *Detector) Join(userID uint64, ts int64) {
+	if userID == 0 {
+		return
+	}
+	d.user(userID).joinedAt = ts
+}
+
+// Check 为弹幕打分并更新用户状态
+func (d *Detector) Check(c *Chat) *Verdict {
+	if c.User == nil || c.User.Id == 0 {
+		return &Verdict{}
+	}
+	u := d.user(c.User.Id)
+	msg := &Message{ID: c.MsgID, Content: c.Content, Timestamp: c.Timestamp, normalized: normalize(c.Content)}
+
+	var signals []Signal
+	if s, ok := d.rate(u, c.Timestamp); ok {
+		signals = append(signals, s)
+	}
+	if len(msg.normalized) >= d.conf.MinContentLen {
+		if s, ok := d.duplicate(u, msg); ok {
+			signals = append(signals, s)
+		}
+		if s, ok := d.flood(c.User.Id, msg); ok {
+			signals = append(signals, s)
+		}
+	}
+	if s, ok := d.joinChat(u, c.Timestamp); ok {
+		signals = append(signals, s)
+	}
+	account := newAccount(c.User)
+	if s, ok := account.signal(); ok {
+		signals = append(signals, s)
+	}
+	d.remember(u, msg)
+
+	v := &Verdict{}
+	for _, s := range signals {
+		v.Score += s.Score
+	}
+	if v.Score > maxScore {
+		v.Score = maxScore
+	}
+	v.Excluded = d.conf.ExcludeScore > 0 && v.Score >= d.conf.ExcludeScore
+	v.Flagged = v.Excluded || v.Score >= d.conf.FlagScore
+	if v.Flagged {
+		v.Evidence = &Evidence{
+			Signals:  signals,
+			Messages: append([]*Message(nil), u.recent...),
+			Account:  account,
+		}
+	}
+	return v
+}
+
+// rate 统计 RateWindow 内的弹幕数，包含本条
+func (d *Detector) rate(u *userState, ts int64) (Signal, bool) {
+	since := ts - d.conf.RateWindow.Milliseconds()
+	kept := u.times[:0]
+	for _, t := range u.times {
+		if t > since {
+			kept = append(kept, t)
+		}
+	}
+	u.times = append(kept, ts)
+	over := len(u.times) - d.conf.RateLimit
+	if over <= 0 {
+		return Signal{}, false
+	}
+	return Signal{
+		Reason: ReasonRate,
+		Score:  min(20+10*(over-1), 50),
+		Detail: fmt.Sprintf("%d 条/%ds", len(u.times), int(d.conf.RateWindow.Seconds())),
+	}, true
+}
+
+// duplicate 与该用户 DuplicateWindow 内的弹幕比较相似度
+func (d *Detector) duplicate(u *userState, msg *Message) (Signal, bool) {
+	since := msg.Timestamp - d.conf.DuplicateWindow.Milliseconds()
+	count := 0
+	for _, prev := range u.recent {
+		if prev.Timestamp <= since || len(prev.normalized) < d.conf.MinContentLen {
+			continue
+		}
+		if similarity(prev.normalized, msg.normalized) >= d.conf.Similarity {
+			count++
+		}
+	}
+	if count == 0 {
+		return Signal{}, false
+	}
+	return Signal{
+		Reason: ReasonDuplicate,
+		Score:  min(20*count, 40),
+		Detail: fmt.Sprintf("与最近 %d 条弹幕重复", count),
+	}, true
+}
+
+// flood 统计 DuplicateWindow 内发送相同内容的用户数，跨用户只比较归一化后完全相同的内容
+func (d *Detector) flood(userID uint64, msg *Message) (Signal, bool) {
+	key := string(msg.normalized)
+	var t *textState
+	if v, ok := d.texts.Get(key); ok {
+		t = v.(*textState)
+	} else {
+		t = &textState{users: make(map[uint64]int64)}
+		d.texts.Add(key, t)
+	}
+	since := msg.Timestamp - d.conf.DuplicateWindow.Milliseconds()
+	for id, ts := range t.users {
+		if ts <= since {
+			delete(t.users, id)
+		}
+	}
+	t.users[userID] = msg.Timestamp
+	n := len(t.users)
+	if n < d.conf.CrossUserLimit {
+		return Signal{}, false
+	}
+	return Signal{
+		Reason: ReasonFlood,
+		Score:  min(30+5*(n-d.conf.CrossUserLimit), 40),
+		Detail: fmt.Sprintf("%d 个用户发送相同内容", n),
+	}, true
+}
+
+// joinChat 进场后很快发送的第一条弹幕
+func (d *Detector) joinChat(u *userState, ts int64) (Signal, bool) {
+	joinedAt := u.joinedAt
+	u.joinedAt = 0
+	if joinedAt == 0 {
+		return Signal{}, false
+	}
+	delay := ts - joinedAt
+	if delay < 0 || delay > d.conf.JoinChatWindow.Milliseconds() {
+		return Signal{}, false
+	}
+	return Signal{
+		Reason: ReasonJoinChat,
+		Score:  20,
+		Detail: fmt.Sprintf("进场 %.1fs 后发送", float64(delay)/1000),
+	}, true
+}
+
+func (d *Detector) remember(u *userState, msg *Message) {
+	since := msg.Timestamp - d.conf.DuplicateWindow.Milliseconds()
+	kept := u.recent[:0]
+	for _, m := range u.recent {
+		if m.Timestamp > since {
+			kept = append(kept, m)
+		}
+	}
+	if len(kept) >= maxRecent {
+		kept = append(kept[:0], kept[len(kept)-maxRecent+1:]...)
+	}
+	u.recent = append(kept, msg)
+}
+
+func newAccount(user *dystruct.Webcast_Data_User) *Account {
+	a := &Account{DefaultName: defaultNamePattern.MatchString(user.Nickname)}
+	if user.FollowInfo != nil {
+		a.FollowerCount = user.FollowInfo.FollowerCount
+		a.FollowingCount = user.FollowInfo.FollowingCount
+	}
+	if user.PayGrade != nil {
+		a.PayGrade = user.PayGrade.Level
+	}
+	if data := user.GetFansClub().GetData(); data != nil {
+		a.FansClubLevel = data.Level
+	}
+	return a
+}
+
+// signal 消息中没有 FollowInfo 时不按粉丝数判断
+func (a *Account) signal() (Signal, bool) {
+	score := 0
+	var details []string
+	if a.DefaultName {
+		score += 10
+		details = append(details, "默认昵称")
+	}
+	if a.FollowerCount == 0 && a.FollowingCount > 0 {
+		score += 5
+		details = append(details, "没有粉丝")
+	}
+	if a.FollowingCount >= 1000 && a.FollowerCount < 10 {
+		score += 5
+		details = append(details, fmt.Sprintf("关注 %d 人", a.FollowingCount))
+	}
+	if a.PayGrade == 0 && a.FansClubLevel == 0 {
+		score += 5
+		details = append(details, "没有消费和粉丝团")
+	}
+	if score == 0 {
+		return Signal{}, false
+	}
+	return Signal{Reason: ReasonAccount, Score: min(score, 20), Detail: strings.Join(details, "，")}, true
+}
@@ -0,0 +1,53 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// emotePattern 抖音表情在弹幕中为 [表情名] 形式
+var emotePattern = regexp.MustCompile(`\[[^\[\]]{1,8}\]`)
+
+// normalize 去掉表情、空白、标点和符号，转为小写并合并连续重复的字符，
+// 使 "哈哈哈哈!!" 与 "哈 哈" 这类只在格式上不同的内容相同
+func normalize(content string) []rune {
+	content = emotePattern.ReplaceAllString(content, "")
+	out := make([]rune, 0, len(content))
+	for _, r := range strings.ToLower(content) {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsControl(r) {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1] == r {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// similarity 字符二元组的 Dice 系数，1 表示相同
+func similarity(a, b []rune) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if string(a) == string(b) {
+		return 1
+	}
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+	bigrams := make(map[[2]rune]int, len(a)-1)
+	for i := 0; i < len(a)-1; i++ {
+		bigrams[[2]rune{a[i], a[i+1]}]++
+	}
+	matched := 0
+	for i := 0; i < len(b)-1; i++ {
+		key := [2]rune{b[i], b[i+1]}
+		if bigrams[key] > 0 {
+			bigrams[key]--
+			matched++
+		}
+	}
+	return 2 * float64(matched) / float64(len(a)+len(b)-2)
+}
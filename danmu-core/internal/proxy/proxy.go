@@ -0,0 +1,461 @@
+// Package proxy 抖音出站请求使用的代理池
+//
+// 每个直播间第一次请求时分配连接数最少的可用代理，之后固定使用 (sticky)，
+// 遇到 403 或验证码时调用 Rotate 切换，原代理进入冷却。健康检查定时通过每个代理请求 CheckURL，
+// 连续失败 FailThreshold 次后不再分配，使用它的直播间在下次请求时切换到其他代理。
+// 未启用时 Func 返回 http.ProxyFromEnvironment，与之前的行为一致。
+// 通过管理接口添加和删除的代理只保存在内存中，重启后以配置为准
+package proxy
+
+import (
+	"context"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 切换代理的原因，作为指标 label
+const (
+	ReasonBlocked   = "blocked"
+	ReasonCaptcha   = "captcha"
+	ReasonUnhealthy = "unhealthy"
+	ReasonManual    = "manual"
+)
+
+const (
+	defaultCheckURL      = "https://live.douyin.com/"
+	defaultCheckInterval = time.Minute
+	defaultCheckTimeout  = 10 * time.Second
+	defaultFailThreshold = 3
+	defaultCooldown      = 10 * time.Minute
+)
+
+var (
+	ErrDisabled    = errors.New("proxy is disabled")
+	ErrInvalid     = errors.New("invalid proxy url")
+	ErrExists      = errors.New("proxy already exists")
+	ErrNotFound    = errors.New("proxy not found")
+	ErrUnavailable = errors.New("no proxy available")
+)
+
+var p *pool
+
+type pool struct {
+	mu            sync.Mutex
+	proxies       []*entry
+	rooms         map[string]*entry // 直播间 (或 session 等共用请求) 当前使用的代理
+	checkURL      string
+	interval      time.Duration
+	timeout       time.Duration
+	failThreshold int
+	cooldown      time.Duration
+	allowDirect   bool
+	done          chan struct{}
+	stopped       chan struct{}
+}
+
+type entry struct {
+	url           *url.URL
+	key           string // 去掉用户名密码的 scheme://host:port，用于日志、指标和管理接口
+	healthy       bool
+	failures      int
+	cooldownUntil time.Time
+	lastCheck     time.Time
+	lastError     string
+	latency       time.Duration
+	rooms         int
+	removed       bool
+}
+
+// Status 代理的当前状态
+type Status struct {
+	URL           string
+	Healthy       bool
+	CooldownUntil time.Time
+	Failures      int
+	Rooms         int
+	LastCheck     time.Time
+	LastError     string
+	Latency       time.Duration
+}
+
+// Init 按 [proxy] 配置创建代理池并启动健康检查
+func Init() error {
+	s := setting.ProxySetting
+	pl := &pool{
+		rooms:         make(map[string]*entry),
+		checkURL:      s.CheckURL,
+		interval:      time.Duration(s.CheckInterval) * time.Second,
+		timeout:       time.Duration(s.CheckTimeout) * time.Second,
+		failThreshold: s.FailThreshold,
+		cooldown:      time.Duration(s.Cooldown) * time.Second,
+		allowDirect:   s.AllowDirect,
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	if pl.checkURL == "" {
+		pl.checkURL = defaultCheckURL
+	}
+	if pl.interval <= 0 {
+		pl.interval = defaultCheckInterval
+	}
+	if pl.timeout <= 0 {
+		pl.timeout = defaultCheckTimeout
+	}
+	if pl.failThreshold <= 0 {
+		pl.failThreshold = defaultFailThreshold
+	}
+	if pl.cooldown <= 0 {
+		pl.cooldown = defaultCooldown
+	}
+	for _, raw := range strings.Split(s.Proxies, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if _, err := pl.add(raw); err != nil {
+			return fmt.Errorf("%w: %s", err, raw)
+		}
+	}
+	if len(pl.proxies) == 0 && !pl.allowDirect {
+		logger.Warn().Msg("proxy enabled without proxies, douyin requests fail until one is added")
+	}
+	p = pl
+	go pl.run()
+	logger.Info().Int("proxies", len(pl.proxies)).Bool("allow_direct", pl.allowDirect).Msg("proxy pool initialized")
+	return nil
+}
+
+func Enabled() bool {
+	return p != nil
+}
+
+// Close 停止健康检查
+func Close() {
+	if p == nil {
+		return
+	}
+	close(p.done)
+	<-p.stopped
+}
+
+// Func 返回 http.Transport 和 websocket.Dialer 使用的 Proxy 函数，每次建立连接时读取 key 当前分配的代理，
+// 切换后新的连接立即使用新代理
+func Func(key string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if p == nil {
+			return http.ProxyFromEnvironment(req)
+		}
+		return p.assign(key)
+	}
+}
+
+// Rotate 将 key 当前使用的代理放入冷却并分配新的代理，返回新代理，直接连接时为空
+func Rotate(key, reason string) (string, error) {
+	if p == nil {
+		return "", ErrDisabled
+	}
+	metrics.ProxyRotations.WithLabelValues(reason).Inc()
+	p.mu.Lock()
+	old := p.rooms[key]
+	if old != nil {
+		old.cooldownUntil = time.Now().Add(p.cooldown)
+		p.release(key)
+		p.updateGauges(old)
+	}
+	p.mu.Unlock()
+	u, err := p.assign(key)
+	logEvent := logger.Warn().Str("key", key).Str("reason", reason)
+	if old != nil {
+		logEvent = logEvent.Str("old", old.key)
+	}
+	if err != nil {
+		logEvent.Err(err).Msg("rotate proxy failed")
+		return "", err
+	}
+	next := ""
+	if u != nil {
+		next = redact(u)
+	}
+	logEvent.Str("new", next).Msg("proxy rotated")
+	return next, nil
+}
+
+// Release 直播间删除后释放分配的代理
+func Release(key string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.rooms[key]; e != nil {
+		p.release(key)
+		p.updateGauges(e)
+	}
+}
+
+// List 返回所有代理的状态，按添加顺序排列
+func List() ([]*Status, error) {
+	if p == nil {
+		return nil, ErrDisabled
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := make([]*Status, 0, len(p.proxies))
+	for _, e := range p.proxies {
+		list = append(list, e.status())
+	}
+	return list, nil
+}
+
+// Add 添加代理并立即检查一次，返回其状态
+func Add(raw string) (*Status, error) {
+	if p == nil {
+		return nil, ErrDisabled
+	}
+	e, err := p.add(raw)
+	if err != nil {
+		return nil, err
+	}
+	p.check(context.Background(), e)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return e.status(), nil
+}
+
+// Remove 删除代理，使用它的直播间在下次请求时分配其他代理，raw 可以不包含用户名密码
+func Remove(raw string) error {
+	if p == nil {
+		return ErrDisabled
+	}
+	u, err := parse(raw)
+	if err != nil {
+		return err
+	}
+	key := redact(u)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, e := range p.proxies {
+		if e.key != key {
+			continue
+		}
+		e.removed = true
+		p.proxies = append(p.proxies[:i], p.proxies[i+1:]...)
+		for room, re := range p.rooms {
+			if re == e {
+				delete(p.rooms, room)
+			}
+		}
+		metrics.ProxyHealthy.DeleteLabelValues(key)
+		metrics.ProxyRooms.DeleteLabelValues(key)
+		metrics.ProxyChecks.DeletePartialMatch(map[string]string{"proxy": key})
+		logger.Info().Str("proxy", key).Msg("proxy removed")
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+func (pl *pool) add(raw string) (*entry, error) {
+	u, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{url: u, key: redact(u), healthy: true}
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	for _, old := range pl.proxies {
+		if old.key == e.key {
+			return nil, fmt.Errorf("%w: %s", ErrExists, e.key)
+		}
+	}
+	pl.proxies = append(pl.proxies, e)
+	pl.updateGauges(e)
+	return e, nil
+}
+
+// assign 返回 key 使用的代理，当前代理不可用时重新分配
+func (pl *pool) assign(key string) (*url.URL, error) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	now := time.Now()
+	if e := pl.rooms[key]; e != nil {
+		if e.usable(now) {
+			return e.url, nil
+		}
+		pl.release(key)
+		pl.updateGauges(e)
+		metrics.ProxyRotations.WithLabelValues(ReasonUnhealthy).Inc()
+	}
+	var best *entry
+	for _, e := range pl.proxies {
+		if e.usable(now) && (best == nil || e.rooms < best.rooms) {
+			best = e
+		}
+	}
+	if best == nil {
+		if pl.allowDirect {
+			return nil, nil
+		}
+		return nil, ErrUnavailable
+	}
+	best.rooms++
+	pl.rooms[key] = best
+	pl.updateGauges(best)
+	return best.url, nil
+}
+
+// release 调用方需持有 mu
+func (pl *pool) release(key string) {
+	if e := pl.rooms[key]; e != nil {
+		e.rooms--
+		delete(pl.rooms, key)
+	}
+}
+
+// updateGauges 调用方需持有 mu
+func (pl *pool) updateGauges(e *entry) {
+	if e.removed {
+		return
+	}
+	healthy := 0.0
+	if e.usable(time.Now()) {
+		healthy = 1
+	}
+	metrics.ProxyHealthy.WithLabelValues(e.key).Set(healthy)
+	metrics.ProxyRooms.WithLabelValues(e.key).Set(float64(e.rooms))
+}
+
+func (pl *pool) run() {
+	defer close(pl.stopped)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-pl.done
+		cancel()
+	}()
+	pl.checkAll(ctx)
+	ticker := time.NewTicker(pl.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pl.checkAll(ctx)
+		}
+	}
+}
+
+func (pl *pool) checkAll(ctx context.Context) {
+	pl.mu.Lock()
+	proxies := append([]*entry(nil), pl.proxies...)
+	pl.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, e := range proxies {
+		wg.Add(1)
+		go func(e *entry) {
+			defer wg.Done()
+			pl.check(ctx, e)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// check 通过代理请求 CheckURL，连接失败、5xx 和 403 都计为失败
+func (pl *pool) check(ctx context.Context, e *entry) {
+	start := time.Now()
+	err := pl.probe(ctx, e.url)
+	latency := time.Since(start)
+	if ctx.Err() != nil {
+		return
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	e.lastCheck = time.Now()
+	e.latency = latency
+	if err != nil {
+		metrics.ProxyChecks.WithLabelValues(e.key, "error").Inc()
+		e.failures++
+		e.lastError = err.Error()
+		if e.healthy && e.failures >= pl.failThreshold {
+			e.healthy = false
+			logger.Warn().Str("proxy", e.key).Err(err).Int("failures", e.failures).Msg("proxy unhealthy")
+		}
+	} else {
+		metrics.ProxyChecks.WithLabelValues(e.key, "ok").Inc()
+		if !e.healthy {
+			logger.Info().Str("proxy", e.key).Msg("proxy healthy")
+		}
+		e.failures = 0
+		e.lastError = ""
+		e.healthy = true
+	}
+	pl.updateGauges(e)
+}
+
+func (pl *pool) probe(ctx context.Context, u *url.URL) error {
+	ctx, cancel := context.WithTimeout(ctx, pl.timeout)
+	defer cancel()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(u)
+	defer transport.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pl.checkURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusForbidden || res.StatusCode >= 500 {
+		return fmt.Errorf("check returned status %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (e *entry) usable(now time.Time) bool {
+	return !e.removed && e.healthy && now.After(e.cooldownUntil)
+}
+
+// status 调用方需持有 mu
+func (e *entry) status() *Status {
+	return &Status{
+		URL:           e.key,
+		Healthy:       e.healthy,
+		CooldownUntil: e.cooldownUntil,
+		Failures:      e.failures,
+		Rooms:         e.rooms,
+		LastCheck:     e.lastCheck,
+		LastError:     e.lastError,
+		Latency:       e.latency,
+	}
+}
+
+func parse(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	switch u.Scheme {
+	case "http", "socks5": // websocket.Dialer 只支持这两种
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalid, u.Scheme)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("%w: host and port are required", ErrInvalid)
+	}
+	return u, nil
+}
+
+func redact(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,50 @@
+// Package publish 将标准化的直播事件发布到 NATS JetStream 或 Kafka，供其他系统订阅
+package publish
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	BrokerNATS  = "nats"
+	BrokerKafka = "kafka"
+)
+
+// Message 一条待发送的事件，Key 为抖音消息ID，用于 JetStream 去重；Room 为 room_display_id，
+// 用于 Kafka 分区，同一直播间的事件写入同一分区以保证顺序
+type Message struct {
+	Topic       string `json:"topic"`
+	Key         string `json:"key"`
+	Room        string `json:"room"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// Broker 消息总线，Publish 返回 nil 时所有消息都已被确认
+type Broker interface {
+	Publish(ctx context.Context, msgs []*Message) error
+	Close() error
+}
+
+// NewBroker 按名称创建 Broker，url 为 nats 地址或逗号分隔的 kafka broker 地址
+func NewBroker(name, url, stream, topicPrefix string) (Broker, error) {
+	switch name {
+	case "", BrokerNATS:
+		return newNATSBroker(url, stream, topicPrefix)
+	case BrokerKafka:
+		var addrs []string
+		for _, addr := range strings.Split(url, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("kafka broker address is empty")
+		}
+		return newKafkaBroker(addrs), nil
+	default:
+		return nil, fmt.Errorf("unsupported broker %q", name)
+	}
+}
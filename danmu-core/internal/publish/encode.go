@@ -0,0 +1,91 @@
+package publish
+
+import (
+	"danmu-core/generated/event"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatProtobuf = "protobuf"
+)
+
+// 事件类型，同时作为 subject/topic 的最后一段
+const (
+	TypeChat   = "chat"
+	TypeGift   = "gift"
+	TypeMember = "member"
+	TypeLike   = "like"
+)
+
+// jsonOptions 字段名与 event.proto 一致，零值字段也输出，64 位整数按 proto3 json 规范输出为字符串
+var jsonOptions = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// Encoder 将事件编码为消息
+type Encoder struct {
+	format      string
+	contentType string
+	topicPrefix string
+}
+
+func NewEncoder(format, topicPrefix string) (*Encoder, error) {
+	e := &Encoder{format: format, topicPrefix: topicPrefix}
+	switch format {
+	case "", FormatJSON:
+		e.format, e.contentType = FormatJSON, "application/json"
+	case FormatProtobuf:
+		e.contentType = "application/x-protobuf"
+	default:
+		return nil, fmt.Errorf("unsupported publish format %q", format)
+	}
+	return e, nil
+}
+
+func (e *Encoder) Encode(ev *event.Event) (*Message, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if e.format == FormatProtobuf {
+		data, err = proto.Marshal(ev)
+	} else {
+		data, err = MarshalJSON(ev)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode %s event error: %w", ev.Type, err)
+	}
+	return &Message{
+		Topic:       Topic(e.topicPrefix, ev.RoomDisplayId, ev.Type),
+		Key:         strconv.FormatUint(ev.MsgId, 10),
+		Room:        ev.RoomDisplayId,
+		ContentType: e.contentType,
+		Data:        data,
+	}, nil
+}
+
+// MarshalJSON 以 json 格式编码事件，其他输出 json 事件的 handler 共用
+func MarshalJSON(ev *event.Event) ([]byte, error) {
+	return jsonOptions.Marshal(ev)
+}
+
+// Topic 返回 <prefix>.<room_display_id>.<type>，nats subject 和 kafka topic 通用
+func Topic(prefix, roomDisplayId, typ string) string {
+	return prefix + "." + topicToken(roomDisplayId) + "." + typ
+}
+
+// topicToken 只保留 kafka topic 和 nats subject 都允许的字符，其余替换为 _
+func topicToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
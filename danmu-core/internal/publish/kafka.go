@@ -0,0 +1,56 @@
+package publish
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// msgIDHeader 抖音消息ID，消费方据此去重
+const msgIDHeader = "Msg-Id"
+
+type kafkaBroker struct {
+	writer *kafka.Writer
+}
+
+func newKafkaBroker(addrs []string) *kafkaBroker {
+	return &kafkaBroker{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(addrs...),
+		Balancer:               &kafka.Hash{}, // 按直播间分区，同一直播间的事件保持顺序
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		BatchTimeout:           10 * time.Millisecond,
+		// 失败时由 Publisher 统一重试
+		MaxAttempts: 1,
+	}}
+}
+
+// Publish 同步写入，所有副本确认后返回
+func (b *kafkaBroker) Publish(ctx context.Context, msgs []*Message) error {
+	records := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		records[i] = kafka.Message{
+			Topic: m.Topic,
+			Key:   []byte(partitionKey(m)),
+			Value: m.Data,
+			Headers: []kafka.Header{
+				{Key: "Content-Type", Value: []byte(m.ContentType)},
+				{Key: msgIDHeader, Value: []byte(m.Key)},
+			},
+		}
+	}
+	return b.writer.WriteMessages(ctx, records...)
+}
+
+func (b *kafkaBroker) Close() error {
+	return b.writer.Close()
+}
+
+// partitionKey 升级前保存到 BufferFile 的消息没有 Room，仍按消息ID分区
+func partitionKey(m *Message) string {
+	if m.Room != "" {
+		return m.Room
+	}
+	return m.Key
+}
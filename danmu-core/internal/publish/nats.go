@@ -0,0 +1,98 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// duplicateWindow JetStream 按 Nats-Msg-Id 去重的时间窗口，重试发送的消息在窗口内不会重复写入
+const duplicateWindow = 10 * time.Minute
+
+type natsBroker struct {
+	nc     *nats.Conn
+	js     jetstream.JetStream
+	config jetstream.StreamConfig
+
+	mu    sync.Mutex
+	ready bool // stream 已创建
+}
+
+func newNATSBroker(url, stream, topicPrefix string) (*natsBroker, error) {
+	// 启动时 nats 不可用不影响启动，事件缓存在本地，连接成功后发送
+	nc, err := nats.Connect(url,
+		nats.Name("danmu-core"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connect nats %s error: %w", url, err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return &natsBroker{
+		nc: nc,
+		js: js,
+		config: jetstream.StreamConfig{
+			Name:       stream,
+			Subjects:   []string{topicPrefix + ".>"},
+			Storage:    jetstream.FileStorage,
+			Duplicates: duplicateWindow,
+		},
+	}, nil
+}
+
+func (b *natsBroker) ensureStream(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ready {
+		return nil
+	}
+	if _, err := b.js.CreateOrUpdateStream(ctx, b.config); err != nil {
+		return fmt.Errorf("create stream %s error: %w", b.config.Name, err)
+	}
+	b.ready = true
+	return nil
+}
+
+// Publish 异步发送后等待所有 ack，任意一条失败时整批重试，已写入的消息由 Nats-Msg-Id 去重
+func (b *natsBroker) Publish(ctx context.Context, msgs []*Message) error {
+	if err := b.ensureStream(ctx); err != nil {
+		return err
+	}
+	futures := make([]jetstream.PubAckFuture, 0, len(msgs))
+	for _, m := range msgs {
+		msg := nats.NewMsg(m.Topic)
+		msg.Data = m.Data
+		msg.Header.Set(jetstream.MsgIDHeader, m.Key)
+		msg.Header.Set("Content-Type", m.ContentType)
+		future, err := b.js.PublishMsgAsync(msg)
+		if err != nil {
+			return fmt.Errorf("publish %s error: %w", m.Topic, err)
+		}
+		futures = append(futures, future)
+	}
+	for _, future := range futures {
+		select {
+		case <-future.Ok():
+		case err := <-future.Err():
+			return fmt.Errorf("publish %s error: %w", future.Msg().Subject, err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close Publish 返回前已等待所有 ack，直接关闭连接
+func (b *natsBroker) Close() error {
+	b.nc.Close()
+	return nil
+}
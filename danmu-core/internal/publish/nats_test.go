@@ -0,0 +1,285 @@
+package publish
+
+import (
+	"bufio"
+	"context"
+	"danmu-core/generated/event"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	testStream = "DANMU_TEST"
+	testPrefix = "danmu"
+)
+
+// freePort 重启 nats 时需要使用同一个端口
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// runNATS 启动开启 JetStream 的嵌入式 nats，storeDir 相同时重启后保留 stream
+func runNATS(t *testing.T, port int, storeDir string) *server.Server {
+	t.Helper()
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      port,
+		JetStream: true,
+		StoreDir:  storeDir,
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func natsURL(port int) string {
+	return fmt.Sprintf("nats://127.0.0.1:%d", port)
+}
+
+func newTestPublisher(t *testing.T, port int, bufferFile string) *Publisher {
+	t.Helper()
+	broker, err := NewBroker(BrokerNATS, natsURL(port), testStream, testPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder, err := NewEncoder(FormatJSON, testPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPublisher(broker, encoder, 100, 100*time.Millisecond, 1000, bufferFile)
+}
+
+func chatEvent(id uint64) *event.Event {
+	return &event.Event{
+		Type:          TypeChat,
+		MsgId:         id,
+		RoomDisplayId: "room1",
+		Timestamp:     int64(id),
+		Payload:       &event.Event_Chat{Chat: &event.Chat{Content: fmt.Sprintf("msg %d", id)}},
+	}
+}
+
+// streamMessages 读取 stream 中的所有消息
+func streamMessages(t *testing.T, port int) []jetstream.RawStreamMsg {
+	t.Helper()
+	nc, err := nats.Connect(natsURL(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := js.Stream(ctx, testStream)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []jetstream.RawStreamMsg
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq && info.State.Msgs > 0; seq++ {
+		msg, err := stream.GetMsg(ctx, seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, *msg)
+	}
+	return msgs
+}
+
+// waitMessages 等待 stream 中的消息数量达到 n
+func waitMessages(t *testing.T, port, n int, timeout time.Duration) []jetstream.RawStreamMsg {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		msgs := streamMessages(t, port)
+		if len(msgs) >= n {
+			return msgs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream has %d messages, want %d", len(msgs), n)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func msgIDsOf(msgs []jetstream.RawStreamMsg) []string {
+	ids := make([]string, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.Header.Get(jetstream.MsgIDHeader)
+	}
+	return ids
+}
+
+func pendingCount(p *Publisher) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+func TestPublishToJetStream(t *testing.T) {
+	port := freePort(t)
+	runNATS(t, port, t.TempDir())
+	p := newTestPublisher(t, port, "")
+	go p.Run()
+	defer p.Close()
+
+	if err := p.Publish(chatEvent(1)); err != nil {
+		t.Fatal(err)
+	}
+	msgs := waitMessages(t, port, 1, 5*time.Second)
+	m := msgs[0]
+	if m.Subject != "danmu.room1.chat" {
+		t.Errorf("subject = %q, want danmu.room1.chat", m.Subject)
+	}
+	if got := m.Header.Get(jetstream.MsgIDHeader); got != "1" {
+		t.Errorf("Nats-Msg-Id = %q, want 1", got)
+	}
+	if got := m.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(m.Data, &body); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if body["msg_id"] != "1" || body["room_display_id"] != "room1" {
+		t.Errorf("event = %v", body)
+	}
+
+	// 重试发送的同一条消息由 Nats-Msg-Id 去重
+	if err := p.Publish(chatEvent(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Publish(chatEvent(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if ids := msgIDsOf(streamMessages(t, port)); len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+		t.Errorf("stream messages = %v, want [1 2]", ids)
+	}
+}
+
+func TestPublishBuffersWhileBrokerDown(t *testing.T) {
+	port := freePort(t)
+	store := t.TempDir()
+	srv := runNATS(t, port, store)
+	p := newTestPublisher(t, port, "")
+	if err := p.Publish(chatEvent(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Shutdown()
+	srv.WaitForShutdown()
+	for id := uint64(2); id <= 4; id++ {
+		if err := p.Publish(chatEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 缩短超时，避免等待默认的 publishTimeout
+	if err := p.flush(500 * time.Millisecond); err == nil {
+		t.Fatal("flush with broker down = nil, want error")
+	}
+	if n := pendingCount(p); n != 3 {
+		t.Fatalf("pending = %d, want 3", n)
+	}
+
+	// 恢复后按顺序发送缓存的事件
+	runNATS(t, port, store)
+	go p.Run()
+	defer p.Close()
+	msgs := waitMessages(t, port, 4, 20*time.Second)
+	if ids := msgIDsOf(msgs); len(ids) != 4 || ids[1] != "2" || ids[2] != "3" || ids[3] != "4" {
+		t.Errorf("stream messages = %v, want [1 2 3 4]", ids)
+	}
+}
+
+func TestBufferFileReplayOnRestart(t *testing.T) {
+	port := freePort(t)
+	bufferFile := filepath.Join(t.TempDir(), "publish", "buffer.jsonl")
+
+	// nats 不可用时退出，未发送的事件保存到 BufferFile
+	p := newTestPublisher(t, port, bufferFile)
+	for id := uint64(1); id <= 3; id++ {
+		if err := p.Publish(chatEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 只在退出时尝试发送一次 (closeTimeout)，不等待 Run 中的 publishTimeout
+	<-p.wake
+	go p.Run()
+	p.Close()
+
+	f, err := os.Open(bufferFile)
+	if err != nil {
+		t.Fatalf("buffer file not saved: %v", err)
+	}
+	var saved []Message
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m Message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			t.Fatalf("decode buffer line: %v", err)
+		}
+		saved = append(saved, m)
+	}
+	f.Close()
+	if len(saved) != 3 || saved[0].Key != "1" || saved[2].Key != "3" || saved[0].Room != "room1" {
+		t.Fatalf("saved messages = %+v, want msg 1..3 of room1", saved)
+	}
+
+	// 重启后先发送上次保存的事件，发送完成后删除 BufferFile
+	runNATS(t, port, t.TempDir())
+	p = newTestPublisher(t, port, bufferFile)
+	if err := p.load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Publish(chatEvent(4)); err != nil {
+		t.Fatal(err)
+	}
+	go p.Run()
+	msgs := waitMessages(t, port, 4, 10*time.Second)
+	if ids := msgIDsOf(msgs); ids[0] != "1" || ids[1] != "2" || ids[2] != "3" || ids[3] != "4" {
+		t.Errorf("stream messages = %v, want [1 2 3 4]", ids)
+	}
+	p.Close()
+	if _, err := os.Stat(bufferFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("buffer file still exists after replay: %v", err)
+	}
+}
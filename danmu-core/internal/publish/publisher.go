@@ -0,0 +1,290 @@
+package publish
+
+import (
+	"bufio"
+	"context"
+	"danmu-core/generated/event"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize     = 500
+	defaultRetryInterval = 5 * time.Second
+	defaultMaxPending    = 100000
+	publishTimeout       = 10 * time.Second
+	closeTimeout         = 5 * time.Second // 退出时发送剩余事件的超时，未发送的保存到 bufferFile
+)
+
+// Publisher 在本地缓存事件并按顺序发送，消息总线确认后才从缓存中移除 (at-least-once)
+// 消息总线不可用时按 retryInterval 重试，缓存超过 maxPending 时丢弃最早的事件
+type Publisher struct {
+	broker        Broker
+	encoder       *Encoder
+	batchSize     int
+	maxPending    int
+	retryInterval time.Duration
+	bufferFile    string
+
+	mu      sync.Mutex
+	pending []*Message
+
+	flushMu sync.Mutex
+
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func NewPublisher(broker Broker, encoder *Encoder, batchSize int, retryInterval time.Duration, maxPending int, bufferFile string) *Publisher {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if retryInterval <= 0 {
+		retryInterval = defaultRetryInterval
+	}
+	if maxPending <= 0 {
+		maxPending = defaultMaxPending
+	}
+	return &Publisher{
+		broker:        broker,
+		encoder:       encoder,
+		batchSize:     batchSize,
+		maxPending:    max(maxPending, batchSize),
+		retryInterval: retryInterval,
+		bufferFile:    bufferFile,
+		wake:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+}
+
+// Publish 编码后放入缓存，不等待发送结果
+func (p *Publisher) Publish(ev *event.Event) error {
+	msg, err := p.encoder.Encode(ev)
+	if err != nil {
+		return err
+	}
+	p.enqueue([]*Message{msg}, false)
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// enqueue front 为 true 时放回队首 (发送失败的消息)，超过 maxPending 时丢弃最早的消息
+func (p *Publisher) enqueue(msgs []*Message, front bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if front {
+		p.pending = append(msgs, p.pending...)
+	} else {
+		p.pending = append(p.pending, msgs...)
+	}
+	if drop := len(p.pending) - p.maxPending; drop > 0 {
+		p.pending = p.pending[drop:]
+		metrics.PublishEvents.WithLabelValues("dropped").Add(float64(drop))
+	}
+	metrics.PublishPending.Set(float64(len(p.pending)))
+}
+
+// Run 有新事件时立即发送，发送失败后等待 retryInterval 再重试，直到 Close
+func (p *Publisher) Run() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(p.retryInterval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		select {
+		case <-p.done:
+			if err := p.flush(closeTimeout); err != nil {
+				logger.Warn().Err(err).Msg("publish pending events failed")
+			}
+			if err := p.save(); err != nil {
+				logger.Error().Err(err).Str("file", p.bufferFile).Msg("save publish buffer failed")
+			}
+			return
+		case <-ticker.C:
+		case <-p.wake:
+			// 消息总线不可用期间不因新事件频繁重试
+			if failing {
+				continue
+			}
+		}
+		err := p.Flush()
+		switch {
+		case err != nil && !failing:
+			logger.Warn().Err(err).Msg("publish events failed, buffering until broker is available")
+		case err == nil && failing:
+			logger.Info().Msg("publish events recovered")
+		}
+		failing = err != nil
+	}
+}
+
+// Flush 按顺序发送所有缓存的事件，失败时保留未确认的事件
+func (p *Publisher) Flush() error {
+	return p.flush(publishTimeout)
+}
+
+func (p *Publisher) flush(timeout time.Duration) error {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	for {
+		p.mu.Lock()
+		n := min(len(p.pending), p.batchSize)
+		batch := p.pending[:n:n]
+		p.pending = p.pending[n:]
+		p.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := p.broker.Publish(ctx, batch)
+		cancel()
+		if err != nil {
+			p.enqueue(batch, true)
+			return err
+		}
+		metrics.PublishEvents.WithLabelValues("success").Add(float64(n))
+		p.mu.Lock()
+		metrics.PublishPending.Set(float64(len(p.pending)))
+		p.mu.Unlock()
+	}
+}
+
+// Close 停止后台发送，未发送的事件保存到 bufferFile
+func (p *Publisher) Close() {
+	close(p.done)
+	<-p.stopped
+	if err := p.broker.Close(); err != nil {
+		logger.Warn().Err(err).Msg("close publish broker failed")
+	}
+}
+
+// save 以 json lines 格式保存未发送的事件，没有未发送的事件时删除文件
+func (p *Publisher) save() error {
+	if p.bufferFile == "" {
+		return nil
+	}
+	p.mu.Lock()
+	pending := p.pending
+	p.mu.Unlock()
+	if len(pending) == 0 {
+		if err := os.Remove(p.bufferFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p.bufferFile), 0o755); err != nil {
+		return err
+	}
+	tmp := p.bufferFile + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, msg := range pending {
+		if err := enc.Encode(msg); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	logger.Info().Int("count", len(pending)).Str("file", p.bufferFile).Msg("saved unpublished events")
+	return os.Rename(tmp, p.bufferFile)
+}
+
+// load 读取上次退出时保存的事件，放在缓存最前面
+func (p *Publisher) load() error {
+	if p.bufferFile == "" {
+		return nil
+	}
+	f, err := os.Open(p.bufferFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var msgs []*Message
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var msg Message
+		if err := dec.Decode(&msg); err != nil {
+			return fmt.Errorf("decode %s error: %w", p.bufferFile, err)
+		}
+		msgs = append(msgs, &msg)
+	}
+	p.enqueue(msgs, true)
+	logger.Info().Int("count", len(msgs)).Str("file", p.bufferFile).Msg("loaded unpublished events")
+	return nil
+}
+
+var defaultPublisher *Publisher
+
+// Init 按 [publish] 配置创建默认 Publisher 并在后台发送
+func Init() error {
+	s := setting.PublishSetting
+	encoder, err := NewEncoder(s.Format, s.TopicPrefix)
+	if err != nil {
+		return err
+	}
+	broker, err := NewBroker(s.Broker, s.URL, s.Stream, s.TopicPrefix)
+	if err != nil {
+		return err
+	}
+	p := NewPublisher(broker, encoder, s.BatchSize, time.Duration(s.RetryInterval)*time.Second, s.MaxPending, s.BufferFile)
+	if err := p.load(); err != nil {
+		logger.Error().Err(err).Str("file", s.BufferFile).Msg("load publish buffer failed")
+	}
+	defaultPublisher = p
+	go p.Run()
+	logger.Info().Str("broker", s.Broker).Str("url", s.URL).Str("format", encoder.format).Msg("event publisher started")
+	return nil
+}
+
+func Enabled() bool {
+	return defaultPublisher != nil
+}
+
+// Publish 发送到默认 Publisher，未启用时忽略
+func Publish(ev *event.Event) error {
+	if defaultPublisher == nil {
+		return nil
+	}
+	return defaultPublisher.Publish(ev)
+}
+
+func Flush() error {
+	if defaultPublisher == nil {
+		return nil
+	}
+	return defaultPublisher.Flush()
+}
+
+func Close() {
+	if defaultPublisher != nil {
+		defaultPublisher.Close()
+	}
+}
@@ -0,0 +1,236 @@
+// Package realtime 将直播事件发布到 redis 频道，并在 redis 中维护直播场次的实时计数和滚动礼物榜
+//
+// key 结构 (prefix 为 [redis] KeyPrefix):
+//
+//	<prefix>:room:<room_display_id>:events           pub/sub 频道，json 格式的事件
+//	<prefix>:room:<room_display_id>:session          当前直播场次 (抖音 room_id)
+//	<prefix>:room:<room_display_id>:top_gifters      zset，最近 RollingWindow 内送礼钻石数前 TopGifters 名
+//	<prefix>:room:<room_display_id>:gifters:<minute> zset，每分钟的送礼钻石数，用于计算滚动礼物榜
+//	<prefix>:session:<room_id>:stats                 hash，viewers、total_viewers、likes、diamonds、chats、gifts
+//	<prefix>:session:<room_id>:gifters               zset，本场送礼钻石数
+//	<prefix>:session:<room_id>:users                 hash，user_id -> 昵称
+package realtime
+
+import (
+	"context"
+	"danmu-core/logger"
+	"danmu-core/metrics"
+	"danmu-core/setting"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultSessionTTL    = 24 * time.Hour
+	defaultRollingWindow = 10 * time.Minute
+	defaultTopGifters    = 10
+	// retryInterval redis 出错后暂停写入的时间，避免 redis 不可用时拖慢其他 handler
+	retryInterval  = 5 * time.Second
+	requestTimeout = time.Second
+)
+
+// ErrUnavailable redis 最近出错，暂停写入中
+var ErrUnavailable = errors.New("redis is unavailable")
+
+// Gifter 一次送礼的用户和新增的钻石数
+type Gifter struct {
+	UserID   uint64
+	Name     string
+	Diamonds int64
+}
+
+// Update 一条消息对直播间的更新，零值字段不更新
+type Update struct {
+	RoomDisplayId string
+	SessionID     uint64 // 抖音 room_id，每场直播不同
+	Event         []byte // 发布到频道的事件
+	Viewers       int64  // 当前在线人数
+	TotalViewers  int64  // 本场累计观看人数
+	Likes         int64  // 本场累计点赞数
+	Chats         int64  // 以下为增量
+	Gifts         int64
+	Diamonds      int64
+	Gifter        *Gifter
+}
+
+type Store struct {
+	rdb           *redis.Client
+	prefix        string
+	sessionTTL    time.Duration
+	rollingWindow time.Duration
+	topGifters    int
+	now           func() time.Time // 滚动礼物榜按分钟分桶使用的时钟
+
+	failUntil atomic.Int64 // unix 毫秒
+}
+
+func NewStore(rdb *redis.Client, prefix string, sessionTTL, rollingWindow time.Duration, topGifters int) *Store {
+	if sessionTTL <= 0 {
+		sessionTTL = defaultSessionTTL
+	}
+	if rollingWindow < time.Minute {
+		rollingWindow = defaultRollingWindow
+	}
+	if topGifters <= 0 {
+		topGifters = defaultTopGifters
+	}
+	return &Store{
+		rdb:           rdb,
+		prefix:        prefix,
+		sessionTTL:    sessionTTL,
+		rollingWindow: rollingWindow,
+		topGifters:    topGifters,
+		now:           time.Now,
+	}
+}
+
+func (s *Store) roomKey(roomDisplayId, name string) string {
+	return s.prefix + ":room:" + roomDisplayId + ":" + name
+}
+
+func (s *Store) sessionKey(sessionID uint64, name string) string {
+	return s.prefix + ":session:" + strconv.FormatUint(sessionID, 10) + ":" + name
+}
+
+// EventChannel 直播间事件的 pub/sub 频道
+func (s *Store) EventChannel(roomDisplayId string) string {
+	return s.roomKey(roomDisplayId, "events")
+}
+
+// Apply 在一个 pipeline 中发布事件并更新计数，redis 出错后 retryInterval 内直接返回 ErrUnavailable
+func (s *Store) Apply(ctx context.Context, u *Update) error {
+	now := s.now()
+	if now.UnixMilli() < s.failUntil.Load() {
+		metrics.RealtimeUpdates.WithLabelValues("skipped").Inc()
+		return ErrUnavailable
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	pipe := s.rdb.Pipeline()
+	if len(u.Event) > 0 {
+		pipe.Publish(ctx, s.EventChannel(u.RoomDisplayId), u.Event)
+	}
+	if u.SessionID != 0 {
+		s.updateSession(ctx, pipe, u, now)
+	}
+	if u.Gifter != nil && u.Gifter.Diamonds > 0 {
+		s.updateTopGifters(ctx, pipe, u, now)
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		if s.failUntil.Swap(now.Add(retryInterval).UnixMilli()) == 0 {
+			logger.Warn().Err(err).Str("liveid", u.RoomDisplayId).Msg("redis update failed, pausing realtime updates")
+		}
+		metrics.RealtimeUpdates.WithLabelValues("error").Inc()
+		return err
+	}
+	if s.failUntil.Swap(0) != 0 {
+		logger.Info().Msg("redis realtime updates recovered")
+	}
+	metrics.RealtimeUpdates.WithLabelValues("success").Inc()
+	return nil
+}
+
+func (s *Store) updateSession(ctx context.Context, pipe redis.Pipeliner, u *Update, now time.Time) {
+	pipe.Set(ctx, s.roomKey(u.RoomDisplayId, "session"), u.SessionID, s.sessionTTL)
+
+	stats := s.sessionKey(u.SessionID, "stats")
+	fields := []any{"room_display_id", u.RoomDisplayId, "updated_at", now.UnixMilli()}
+	if u.Viewers > 0 {
+		fields = append(fields, "viewers", u.Viewers)
+	}
+	if u.TotalViewers > 0 {
+		fields = append(fields, "total_viewers", u.TotalViewers)
+	}
+	if u.Likes > 0 {
+		fields = append(fields, "likes", u.Likes)
+	}
+	pipe.HSet(ctx, stats, fields...)
+	if u.Chats > 0 {
+		pipe.HIncrBy(ctx, stats, "chats", u.Chats)
+	}
+	if u.Gifts > 0 {
+		pipe.HIncrBy(ctx, stats, "gifts", u.Gifts)
+	}
+	if u.Diamonds > 0 {
+		pipe.HIncrBy(ctx, stats, "diamonds", u.Diamonds)
+	}
+	pipe.Expire(ctx, stats, s.sessionTTL)
+
+	if g := u.Gifter; g != nil && g.Diamonds > 0 {
+		member := strconv.FormatUint(g.UserID, 10)
+		gifters := s.sessionKey(u.SessionID, "gifters")
+		pipe.ZIncrBy(ctx, gifters, float64(g.Diamonds), member)
+		pipe.Expire(ctx, gifters, s.sessionTTL)
+		users := s.sessionKey(u.SessionID, "users")
+		pipe.HSet(ctx, users, member, g.Name)
+		pipe.Expire(ctx, users, s.sessionTTL)
+	}
+}
+
+// updateTopGifters 累加到当前分钟的 zset，再合并窗口内的分钟 zset 得到滚动礼物榜
+func (s *Store) updateTopGifters(ctx context.Context, pipe redis.Pipeliner, u *Update, now time.Time) {
+	minute := now.Unix() / 60
+	buckets := int64(s.rollingWindow / time.Minute)
+	bucket := s.roomKey(u.RoomDisplayId, "gifters:"+strconv.FormatInt(minute, 10))
+	pipe.ZIncrBy(ctx, bucket, float64(u.Gifter.Diamonds), strconv.FormatUint(u.Gifter.UserID, 10))
+	pipe.Expire(ctx, bucket, s.rollingWindow+time.Minute)
+
+	keys := make([]string, 0, buckets)
+	for i := int64(0); i < buckets; i++ {
+		keys = append(keys, s.roomKey(u.RoomDisplayId, "gifters:"+strconv.FormatInt(minute-i, 10)))
+	}
+	top := s.roomKey(u.RoomDisplayId, "top_gifters")
+	pipe.ZUnionStore(ctx, top, &redis.ZStore{Keys: keys})
+	pipe.ZRemRangeByRank(ctx, top, 0, int64(-s.topGifters-1))
+	pipe.Expire(ctx, top, s.rollingWindow)
+}
+
+var defaultStore *Store
+
+// Init 按 [redis] 配置创建默认 Store，redis 暂时不可用不影响启动
+func Init() {
+	c := setting.RedisSetting
+	rdb := redis.NewClient(&redis.Options{
+		Addr:         c.Addr,
+		Password:     c.Password,
+		DB:           c.DB,
+		DialTimeout:  requestTimeout,
+		ReadTimeout:  requestTimeout,
+		WriteTimeout: requestTimeout,
+		MaxRetries:   1,
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		logger.Warn().Err(err).Str("addr", c.Addr).Msg("ping redis failed")
+	}
+	defaultStore = NewStore(rdb, c.KeyPrefix,
+		time.Duration(c.SessionTTL)*time.Second,
+		time.Duration(c.RollingWindow)*time.Second,
+		c.TopGifters)
+	logger.Info().Str("addr", c.Addr).Str("prefix", c.KeyPrefix).Msg("redis realtime store started")
+}
+
+func Enabled() bool {
+	return defaultStore != nil
+}
+
+// Default 返回默认 Store，未启用时为 nil
+func Default() *Store {
+	return defaultStore
+}
+
+func Close() {
+	if defaultStore != nil {
+		if err := defaultStore.rdb.Close(); err != nil {
+			logger.Warn().Err(err).Msg("close redis failed")
+		}
+	}
+}
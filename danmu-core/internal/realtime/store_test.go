@@ -0,0 +1,191 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestStore 使用 miniredis，clock 控制滚动礼物榜的分钟
+func newTestStore(t *testing.T, sessionTTL, rollingWindow time.Duration, topGifters int) (*Store, *miniredis.Miniredis, *time.Time) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	s := NewStore(rdb, "danmu", sessionTTL, rollingWindow, topGifters)
+	clock := time.Date(2024, 5, 1, 20, 0, 30, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+	return s, mr, &clock
+}
+
+func apply(t *testing.T, s *Store, u *Update) {
+	t.Helper()
+	if err := s.Apply(context.Background(), u); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+}
+
+func TestApplyCounters(t *testing.T) {
+	s, mr, _ := newTestStore(t, time.Hour, 10*time.Minute, 10)
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 100, Viewers: 50, TotalViewers: 300})
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 100, Chats: 1})
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 100, Chats: 1, Likes: 20})
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 100, Gifts: 3, Diamonds: 30,
+		Gifter: &Gifter{UserID: 7, Name: "alice", Diamonds: 30}})
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 100, Gifts: 1, Diamonds: 5,
+		Gifter: &Gifter{UserID: 7, Name: "alice2", Diamonds: 5}})
+
+	if got, _ := mr.Get("danmu:room:r1:session"); got != "100" {
+		t.Errorf("session = %q, want 100", got)
+	}
+	stats := "danmu:session:100:stats"
+	for field, want := range map[string]string{
+		"room_display_id": "r1",
+		"viewers":         "50",
+		"total_viewers":   "300",
+		"likes":           "20",
+		"chats":           "2",
+		"gifts":           "4",
+		"diamonds":        "35",
+	} {
+		if got := mr.HGet(stats, field); got != want {
+			t.Errorf("stats %s = %q, want %q", field, got, want)
+		}
+	}
+	if score, _ := mr.ZScore("danmu:session:100:gifters", "7"); score != 35 {
+		t.Errorf("session gifter score = %v, want 35", score)
+	}
+	if got := mr.HGet("danmu:session:100:users", "7"); got != "alice2" {
+		t.Errorf("user name = %q, want latest name alice2", got)
+	}
+
+	// 新的场次使用新的 key，不累加到上一场
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 200, Chats: 1})
+	if got, _ := mr.Get("danmu:room:r1:session"); got != "200" {
+		t.Errorf("session = %q, want 200", got)
+	}
+	if got := mr.HGet("danmu:session:200:stats", "chats"); got != "1" {
+		t.Errorf("new session chats = %q, want 1", got)
+	}
+}
+
+func TestSessionTTLExpiry(t *testing.T) {
+	s, mr, _ := newTestStore(t, 30*time.Minute, 10*time.Minute, 10)
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 100, Chats: 1,
+		Gifter: &Gifter{UserID: 7, Name: "alice", Diamonds: 1}, Gifts: 1, Diamonds: 1})
+	keys := []string{
+		"danmu:room:r1:session",
+		"danmu:session:100:stats",
+		"danmu:session:100:gifters",
+		"danmu:session:100:users",
+	}
+	for _, key := range keys {
+		if ttl := mr.TTL(key); ttl != 30*time.Minute {
+			t.Errorf("%s ttl = %v, want 30m", key, ttl)
+		}
+	}
+
+	// 每次更新都会刷新 TTL
+	mr.FastForward(20 * time.Minute)
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 100, Chats: 1})
+	mr.FastForward(20 * time.Minute)
+	if !mr.Exists("danmu:session:100:stats") {
+		t.Fatal("stats expired although refreshed 20 minutes ago")
+	}
+	if got := mr.HGet("danmu:session:100:stats", "chats"); got != "2" {
+		t.Errorf("chats = %q, want 2", got)
+	}
+
+	// 直播结束不再更新后全部过期
+	mr.FastForward(11 * time.Minute)
+	for _, key := range keys {
+		if mr.Exists(key) {
+			t.Errorf("%s still exists after SessionTTL", key)
+		}
+	}
+}
+
+func TestRollingTopGifters(t *testing.T) {
+	s, mr, clock := newTestStore(t, time.Hour, 3*time.Minute, 2)
+	gift := func(user uint64, diamonds int64) {
+		apply(t, s, &Update{RoomDisplayId: "r1", Gifter: &Gifter{UserID: user, Diamonds: diamonds}})
+	}
+	top := func() []string {
+		members, err := mr.ZMembers("danmu:room:r1:top_gifters")
+		if err != nil {
+			return nil
+		}
+		return members
+	}
+	score := func(member string) float64 {
+		v, _ := mr.ZScore("danmu:room:r1:top_gifters", member)
+		return v
+	}
+
+	gift(1, 10)
+	gift(2, 5)
+	*clock = clock.Add(time.Minute)
+	gift(3, 7)
+	gift(1, 1)
+	// 窗口内累加，只保留前 TopGifters 名
+	if got := top(); len(got) != 2 || score("1") != 11 || score("3") != 7 {
+		t.Fatalf("top gifters = %v (1:%v 3:%v), want 1:11 3:7", got, score("1"), score("3"))
+	}
+	if ttl := mr.TTL("danmu:room:r1:top_gifters"); ttl != 3*time.Minute {
+		t.Errorf("top_gifters ttl = %v, want 3m", ttl)
+	}
+
+	// 第一分钟移出窗口，用户 2 之前的 5 钻石和用户 1 的 10 钻石不再计入
+	*clock = clock.Add(2 * time.Minute)
+	gift(2, 2)
+	if got := top(); len(got) != 2 || score("3") != 7 || score("2") != 2 {
+		t.Fatalf("top gifters = %v (2:%v 3:%v), want 3:7 2:2", got, score("2"), score("3"))
+	}
+
+	// 分钟 zset 在窗口结束后过期
+	bucket := "danmu:room:r1:gifters:" + strconv.FormatInt(clock.Unix()/60, 10)
+	if ttl := mr.TTL(bucket); ttl != 4*time.Minute {
+		t.Errorf("%s ttl = %v, want 4m", bucket, ttl)
+	}
+}
+
+func TestPublishEvent(t *testing.T) {
+	s, _, _ := newTestStore(t, time.Hour, 10*time.Minute, 10)
+	sub := s.rdb.Subscribe(context.Background(), s.EventChannel("r1"))
+	defer sub.Close()
+	if _, err := sub.Receive(context.Background()); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 100, Event: []byte(`{"type":"chat"}`), Chats: 1})
+	select {
+	case msg := <-sub.Channel():
+		if msg.Channel != "danmu:room:r1:events" || msg.Payload != `{"type":"chat"}` {
+			t.Errorf("message = %s %s", msg.Channel, msg.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not published")
+	}
+}
+
+func TestApplyPausesAfterError(t *testing.T) {
+	s, mr, clock := newTestStore(t, time.Hour, 10*time.Minute, 10)
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	if err := s.Apply(context.Background(), &Update{RoomDisplayId: "r1", SessionID: 100, Chats: 1}); err == nil {
+		t.Fatal("Apply = nil, want redis error")
+	}
+	mr.SetError("")
+	if err := s.Apply(context.Background(), &Update{RoomDisplayId: "r1", SessionID: 100, Chats: 1}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Apply during pause = %v, want ErrUnavailable", err)
+	}
+	*clock = clock.Add(retryInterval)
+	apply(t, s, &Update{RoomDisplayId: "r1", SessionID: 100, Chats: 1})
+	if got := mr.HGet("danmu:session:100:stats", "chats"); got != "1" {
+		t.Errorf("chats = %q, want 1 (updates during pause are dropped)", got)
+	}
+}
@@ -9,15 +9,19 @@ import (
 	"net"
 	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
 )
 
+// stopTimeout 关闭时等待进行中的调用结束的时间
+const stopTimeout = 5 * time.Second
+
 type RPCServer struct {
 	server *grpc.Server
 }
 
-func NewRPCServer() *RPCServer {
+func NewRPCServer() (*RPCServer, error) {
 	// 创建 gRPC 服务器选项
 	opts := []grpc.ServerOption{
 		grpc.KeepaliveParams(keepalive.ServerParameters{
@@ -27,14 +31,20 @@ func NewRPCServer() *RPCServer {
 			Timeout:           time.Duration(setting.RpcSetting.ConnectTimeout) * time.Second,
 		}),
 		grpc.MaxConcurrentStreams(setting.RpcSetting.MaxOpenConns),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+	}
+	secOpts, err := securityOptions()
+	if err != nil {
+		return nil, err
 	}
+	opts = append(opts, secOpts...)
 
 	// 创建 gRPC 服务器
 	server := grpc.NewServer(opts...)
 
 	return &RPCServer{
 		server: server,
-	}
+	}, nil
 }
 
 func (s *RPCServer) Start() error {
@@ -61,6 +71,16 @@ func (s *RPCServer) Start() error {
 func (s *RPCServer) Stop() {
 	if s.server != nil {
 		logger.Info().Msg("stopping gRPC server")
-		s.server.GracefulStop()
+		// SubscribeEvents 等流式调用不会自行结束，超时后强制关闭
+		stopped := make(chan struct{})
+		go func() {
+			s.server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(stopTimeout):
+			s.server.Stop()
+		}
 	}
 }
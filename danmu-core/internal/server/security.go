@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"danmu-core/logger"
+	"danmu-core/setting"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// securityOptions 根据配置返回 TLS 和鉴权相关的 ServerOption
+func securityOptions() ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+	if setting.RpcSetting.TLSEnable {
+		creds, err := loadServerCredentials()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	} else {
+		logger.Warn().Msg("gRPC server is running without TLS")
+	}
+
+	if setting.RpcSetting.AuthToken != "" {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(tokenAuthInterceptor(setting.RpcSetting.AuthToken)),
+			grpc.ChainStreamInterceptor(tokenStreamAuthInterceptor(setting.RpcSetting.AuthToken)),
+		)
+	} else {
+		logger.Warn().Msg("gRPC server is running without token authentication")
+	}
+	return opts, nil
+}
+
+func loadServerCredentials() (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(setting.RpcSetting.ServerCert, setting.RpcSetting.ServerKey)
+	if err != nil {
+		return nil, fmt.Errorf("load server cert error: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	// 配置了 CA 时开启双向认证，只接受由该 CA 签发的客户端证书
+	if setting.RpcSetting.CACert != "" {
+		pem, err := os.ReadFile(setting.RpcSetting.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("read ca cert error: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("parse ca cert error: %s", setting.RpcSetting.CACert)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// tokenAuthInterceptor 校验 metadata 中的 authorization: Bearer <token>
+func tokenAuthInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkToken(ctx, token, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// tokenStreamAuthInterceptor 流式调用 (SubscribeEvents) 的 token 校验
+func tokenStreamAuthInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkToken(ss.Context(), token, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkToken(ctx context.Context, token, method string) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing metadata")
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "missing authorization token")
+	}
+	got := strings.TrimPrefix(values[0], "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		logger.Warn().Str("method", method).Msg("invalid rpc token")
+		return status.Error(codes.Unauthenticated, "invalid authorization token")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"danmu-core/core"
+	"danmu-core/internal/proxy"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain ErrorInfo.domain，danmu-http 根据 reason 映射为 app code
+const ErrorDomain = "danmu-core"
+
+const (
+	ReasonTaskNotFound        = "TASK_NOT_FOUND"
+	ReasonTaskExists          = "TASK_EXISTS"
+	ReasonTaskDisabled        = "TASK_DISABLED"
+	ReasonUnsupportedPlatform = "UNSUPPORTED_PLATFORM"
+	ReasonInvalidCron         = "INVALID_CRON"
+	ReasonUnknownHandler      = "UNKNOWN_HANDLER"
+	ReasonPlatformInit        = "PLATFORM_INIT_FAILED"
+	ReasonInvalidArgument     = "INVALID_ARGUMENT"
+	ReasonProxyDisabled       = "PROXY_DISABLED"
+	ReasonProxyInvalid        = "PROXY_INVALID"
+	ReasonProxyExists         = "PROXY_EXISTS"
+	ReasonProxyNotFound       = "PROXY_NOT_FOUND"
+	ReasonProxyUnavailable    = "PROXY_UNAVAILABLE"
+	ReasonInternal            = "INTERNAL"
+)
+
+var errorCodes = []struct {
+	err    error
+	code   codes.Code
+	reason string
+}{
+	{core.ErrTaskNotFound, codes.NotFound, ReasonTaskNotFound},
+	{core.ErrTaskExists, codes.AlreadyExists, ReasonTaskExists},
+	{core.ErrTaskDisabled, codes.FailedPrecondition, ReasonTaskDisabled},
+	{core.ErrUnsupportedPlatform, codes.InvalidArgument, ReasonUnsupportedPlatform},
+	{core.ErrInvalidCron, codes.InvalidArgument, ReasonInvalidCron},
+	{core.ErrUnknownHandler, codes.InvalidArgument, ReasonUnknownHandler},
+	{core.ErrPlatformInit, codes.Unavailable, ReasonPlatformInit},
+	{proxy.ErrDisabled, codes.FailedPrecondition, ReasonProxyDisabled},
+	{proxy.ErrInvalid, codes.InvalidArgument, ReasonProxyInvalid},
+	{proxy.ErrExists, codes.AlreadyExists, ReasonProxyExists},
+	{proxy.ErrNotFound, codes.NotFound, ReasonProxyNotFound},
+	{proxy.ErrUnavailable, codes.Unavailable, ReasonProxyUnavailable},
+}
+
+// toStatus 将 core 返回的错误转换为带 ErrorInfo 的 gRPC status
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return newStatus(e.code, e.reason, err.Error())
+		}
+	}
+	return newStatus(codes.Internal, ReasonInternal, err.Error())
+}
+
+func invalidArgument(msg string) error {
+	return newStatus(codes.InvalidArgument, ReasonInvalidArgument, msg)
+}
+
+func newStatus(code codes.Code, reason, msg string) error {
+	st := status.New(code, msg)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: ErrorDomain,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const idempotencyTTL = 10 * time.Minute
+
+type idempotencyEntry struct {
+	resp    proto.Message
+	err     error
+	expires time.Time
+}
+
+// keyLock 同一个 key 的请求串行执行，refs 为持有或等待的请求数，为 0 且没有缓存结果时删除
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// idempotencyCache 缓存带 idempotency_key 的调用结果，相同 key 在有效期内重复调用直接返回首次结果
+// 只缓存成功和不可重试的错误，暂时性的错误 (如 Unavailable、PLATFORM_INIT_FAILED) 不缓存，重试时重新执行
+// 仅保存在内存中，core 重启后失效
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	keyMu   map[string]*keyLock
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{
+		entries: make(map[string]*idempotencyEntry),
+		keyMu:   make(map[string]*keyLock),
+	}
+}
+
+// do 执行 fn，key 为空时不做缓存。method 用于区分不同接口使用相同 key 的情况
+func (c *idempotencyCache) do(method, key string, fn func() (proto.Message, error)) (proto.Message, error) {
+	if key == "" {
+		return fn()
+	}
+	key = method + "/" + key
+
+	c.mu.Lock()
+	c.evictLocked(time.Now())
+	lock, ok := c.keyMu[key]
+	if !ok {
+		lock = &keyLock{}
+		c.keyMu[key] = lock
+	}
+	lock.refs++
+	c.mu.Unlock()
+	defer c.release(key, lock)
+
+	// 同一个 key 的并发请求串行执行，后到的请求直接读取缓存
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return entry.resp, entry.err
+	}
+
+	resp, err := fn()
+	if retryable(err) {
+		return resp, err
+	}
+	c.mu.Lock()
+	c.entries[key] = &idempotencyEntry{
+		resp:    resp,
+		err:     err,
+		expires: time.Now().Add(idempotencyTTL),
+	}
+	c.mu.Unlock()
+	return resp, err
+}
+
+func (c *idempotencyCache) release(key string, lock *keyLock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lock.refs--
+	if _, cached := c.entries[key]; lock.refs == 0 && !cached {
+		delete(c.keyMu, key)
+	}
+}
+
+func (c *idempotencyCache) evictLocked(now time.Time) {
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+			if c.keyMu[key] != nil && c.keyMu[key].refs == 0 {
+				delete(c.keyMu, key)
+			}
+		}
+	}
+}
+
+// retryable 暂时性的错误，使用相同 key 重试时应重新执行
+func retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled, codes.Aborted,
+		codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		err    error
+		cached bool
+	}{
+		{"success", nil, true},
+		{"not found", newStatus(codes.NotFound, ReasonTaskNotFound, "task not found"), true},
+		{"invalid argument", invalidArgument("conf.id is required"), true},
+		{"platform init", newStatus(codes.Unavailable, ReasonPlatformInit, "platform init failed"), false},
+		{"internal", newStatus(codes.Internal, ReasonInternal, "db error"), false},
+	} {
+		c := newIdempotencyCache()
+		calls := 0
+		fn := func() (proto.Message, error) {
+			calls++
+			if tc.err != nil {
+				return nil, tc.err
+			}
+			return &emptypb.Empty{}, nil
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := c.do("AddTask", "k1", fn); err != tc.err {
+				t.Errorf("%s: err = %v, want %v", tc.name, err, tc.err)
+			}
+		}
+		want := 2
+		if tc.cached {
+			want = 1
+		}
+		if calls != want {
+			t.Errorf("%s: fn called %d times, want %d", tc.name, calls, want)
+		}
+		if _, ok := c.keyMu["AddTask/k1"]; ok != tc.cached {
+			t.Errorf("%s: key lock kept = %v, want %v", tc.name, ok, tc.cached)
+		}
+	}
+}
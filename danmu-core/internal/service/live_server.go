@@ -4,71 +4,293 @@ import (
 	"context"
 	"danmu-core/core"
 	"danmu-core/generated/api"
+	"danmu-core/internal/eventhub"
 	"danmu-core/internal/model"
+	"danmu-core/internal/proxy"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 type LiveServer struct {
 	api.UnimplementedLiveServiceServer
+	idempotency *idempotencyCache
 }
 
 func NewLiveServer() *LiveServer {
-	return &LiveServer{}
+	return &LiveServer{
+		idempotency: newIdempotencyCache(),
+	}
 }
 
-func (s *LiveServer) AddTask(ctx context.Context, req *api.LiveConf) (*api.Response, error) {
-	conf := &model.LiveConf{
-		ID:            req.Id,
-		URL:           req.Url,
-		RoomDisplayID: req.RoomDisplayId,
-		Name:          req.Name,
-		Enable:        req.Enable,
+func (s *LiveServer) AddTask(ctx context.Context, req *api.AddTaskRequest) (*api.Task, error) {
+	if req.GetConf().GetId() <= 0 {
+		return nil, invalidArgument("conf.id is required")
+	}
+	conf, err := toModel(req.GetConf())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.idempotency.do("AddTask", req.GetIdempotencyKey(), func() (proto.Message, error) {
+		if err := core.Add(ctx, conf); err != nil {
+			return nil, toStatus(err)
+		}
+		return getTask(conf.ID)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return resp.(*api.Task), nil
+}
 
-	if err := core.Add(conf); err != nil {
-		return &api.Response{
-			Code:    400,
-			Message: err.Error(),
-		}, nil
+func (s *LiveServer) DeleteTask(ctx context.Context, req *api.DeleteTaskRequest) (*emptypb.Empty, error) {
+	_, err := s.idempotency.do("DeleteTask", req.GetIdempotencyKey(), func() (proto.Message, error) {
+		if err := core.Delete(ctx, req.GetId()); err != nil {
+			return nil, toStatus(err)
+		}
+		return &emptypb.Empty{}, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &emptypb.Empty{}, nil
+}
 
-	return &api.Response{
-		Code:    200,
-		Message: "success",
-	}, nil
+func (s *LiveServer) UpdateTask(ctx context.Context, req *api.UpdateTaskRequest) (*api.Task, error) {
+	if req.GetConf().GetId() <= 0 {
+		return nil, invalidArgument("conf.id is required")
+	}
+	resp, err := s.idempotency.do("UpdateTask", req.GetIdempotencyKey(), func() (proto.Message, error) {
+		current, err := core.Get(req.GetConf().GetId())
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		conf, err := applyMask(&current.Conf, req.GetConf(), req.GetUpdateMask())
+		if err != nil {
+			return nil, err
+		}
+		if err := core.Update(ctx, conf); err != nil {
+			return nil, toStatus(err)
+		}
+		return getTask(conf.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*api.Task), nil
+}
+
+func (s *LiveServer) GetTask(ctx context.Context, req *api.TaskID) (*api.Task, error) {
+	return getTask(req.GetId())
 }
 
-func (s *LiveServer) DeleteTask(ctx context.Context, req *api.TaskID) (*api.Response, error) {
-	if err := core.Delete(req.Id); err != nil {
-		return &api.Response{
-			Code:    400,
-			Message: err.Error(),
-		}, nil
+func (s *LiveServer) ListTasks(ctx context.Context, req *api.ListTasksRequest) (*api.ListTasksResponse, error) {
+	states := core.List()
+	tasks := make([]*api.Task, 0, len(states))
+	for _, state := range states {
+		tasks = append(tasks, toTask(state))
 	}
+	return &api.ListTasksResponse{Tasks: tasks}, nil
+}
 
-	return &api.Response{
-		Code:    200,
-		Message: "success",
-	}, nil
+func (s *LiveServer) StartTask(ctx context.Context, req *api.TaskID) (*api.Task, error) {
+	state, err := core.SetEnable(ctx, req.GetId(), true)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toTask(state), nil
+}
+
+func (s *LiveServer) StopTask(ctx context.Context, req *api.TaskID) (*api.Task, error) {
+	state, err := core.SetEnable(ctx, req.GetId(), false)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toTask(state), nil
+}
+
+func (s *LiveServer) RestartTask(ctx context.Context, req *api.TaskID) (*api.Task, error) {
+	state, err := core.Restart(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toTask(state), nil
+}
+
+// SubscribeEvents 推送直播间的实时事件，直到调用方取消或服务关闭
+func (s *LiveServer) SubscribeEvents(req *api.SubscribeEventsRequest, stream api.LiveService_SubscribeEventsServer) error {
+	if req.GetRoomDisplayId() == "" {
+		return invalidArgument("room_display_id is required")
+	}
+	sub := eventhub.Subscribe(req.GetRoomDisplayId())
+	defer sub.Close()
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-sub.C():
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// maxReplayDeadLetters 每次重放的死信数量上限，重放在 handler 队列中同步执行
+const maxReplayDeadLetters = 100
+
+func (s *LiveServer) ReplayDeadLetters(ctx context.Context, req *api.ReplayDeadLettersRequest) (*api.ReplayDeadLettersResponse, error) {
+	if len(req.GetIds()) == 0 || len(req.GetIds()) > maxReplayDeadLetters {
+		return nil, invalidArgument(fmt.Sprintf("ids must contain 1 to %d items", maxReplayDeadLetters))
+	}
+	results := core.ReplayDeadLetters(ctx, req.GetIds())
+	resp := &api.ReplayDeadLettersResponse{Results: make([]*api.ReplayDeadLetterResult, 0, len(results))}
+	for _, r := range results {
+		result := &api.ReplayDeadLetterResult{Id: r.ID, Success: r.Err == nil}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
 }
 
-func (s *LiveServer) UpdateTask(ctx context.Context, req *api.LiveConf) (*api.Response, error) {
-	conf := &model.LiveConf{
-		ID:            req.Id,
-		URL:           req.Url,
-		RoomDisplayID: req.RoomDisplayId,
-		Name:          req.Name,
-		Enable:        req.Enable,
+func (s *LiveServer) ListProxies(ctx context.Context, req *emptypb.Empty) (*api.ListProxiesResponse, error) {
+	list, err := proxy.List()
+	if err != nil {
+		return nil, toStatus(err)
 	}
+	resp := &api.ListProxiesResponse{Proxies: make([]*api.ProxyStatus, 0, len(list))}
+	for _, st := range list {
+		resp.Proxies = append(resp.Proxies, toProxyStatus(st))
+	}
+	return resp, nil
+}
+
+func (s *LiveServer) AddProxy(ctx context.Context, req *api.ProxyRequest) (*api.ProxyStatus, error) {
+	if req.GetUrl() == "" {
+		return nil, invalidArgument("url is required")
+	}
+	st, err := proxy.Add(req.GetUrl())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProxyStatus(st), nil
+}
 
-	if err := core.Update(conf); err != nil {
-		return &api.Response{
-			Code:    400,
-			Message: err.Error(),
-		}, nil
+func (s *LiveServer) RemoveProxy(ctx context.Context, req *api.ProxyRequest) (*emptypb.Empty, error) {
+	if req.GetUrl() == "" {
+		return nil, invalidArgument("url is required")
 	}
+	if err := proxy.Remove(req.GetUrl()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
 
-	return &api.Response{
-		Code:    200,
-		Message: "success",
+func (s *LiveServer) RotateProxy(ctx context.Context, req *api.TaskID) (*api.RotateProxyResponse, error) {
+	proxyURL, err := core.RotateProxy(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &api.RotateProxyResponse{Proxy: proxyURL}, nil
+}
+
+func getTask(id int64) (*api.Task, error) {
+	state, err := core.Get(id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toTask(state), nil
+}
+
+func toModel(c *api.LiveConf) (*model.LiveConf, error) {
+	if err := core.ValidateHandlers(c.GetHandlers()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &model.LiveConf{
+		ID:            c.GetId(),
+		URL:           c.GetUrl(),
+		RoomDisplayID: c.GetRoomDisplayId(),
+		Name:          c.GetName(),
+		Enable:        c.GetEnable(),
+		Cron:          c.GetCron(),
+		Handlers:      strings.Join(c.GetHandlers(), ","),
 	}, nil
 }
+
+func toTask(state *core.TaskState) *api.Task {
+	return &api.Task{
+		Conf: &api.LiveConf{
+			Id:            state.Conf.ID,
+			Url:           state.Conf.URL,
+			RoomDisplayId: state.Conf.RoomDisplayID,
+			Name:          state.Conf.Name,
+			Enable:        state.Conf.Enable,
+			Cron:          state.Conf.Cron,
+			Handlers:      state.Conf.HandlerNames(),
+		},
+		Running: state.Running,
+		Live:    state.Live,
+	}
+}
+
+// applyMask 将 mask 中的字段从 req 覆盖到 current 的副本上，mask 为空时整体替换
+func applyMask(current *model.LiveConf, req *api.LiveConf, mask *fieldmaskpb.FieldMask) (*model.LiveConf, error) {
+	if len(mask.GetPaths()) == 0 {
+		return toModel(req)
+	}
+	conf := *current
+	for _, path := range mask.GetPaths() {
+		switch path {
+		case "url":
+			conf.URL = req.GetUrl()
+		case "room_display_id":
+			conf.RoomDisplayID = req.GetRoomDisplayId()
+		case "name":
+			conf.Name = req.GetName()
+		case "enable":
+			conf.Enable = req.GetEnable()
+		case "cron":
+			conf.Cron = req.GetCron()
+		case "handlers":
+			if err := core.ValidateHandlers(req.GetHandlers()); err != nil {
+				return nil, toStatus(err)
+			}
+			conf.Handlers = strings.Join(req.GetHandlers(), ",")
+		default:
+			return nil, invalidArgument(fmt.Sprintf("unsupported update_mask path: %s", path))
+		}
+	}
+	return &conf, nil
+}
+
+func toProxyStatus(st *proxy.Status) *api.ProxyStatus {
+	var cooldown time.Time
+	if st.CooldownUntil.After(time.Now()) {
+		cooldown = st.CooldownUntil
+	}
+	return &api.ProxyStatus{
+		Url:           st.URL,
+		Healthy:       st.Healthy,
+		CooldownUntil: unixOrZero(cooldown),
+		Failures:      int32(st.Failures),
+		Rooms:         int32(st.Rooms),
+		LastCheck:     unixOrZero(st.LastCheck),
+		LastError:     st.LastError,
+		LatencyMs:     st.Latency.Milliseconds(),
+	}
+}
+
+// unixOrZero 未设置的时间返回 0
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,47 @@
+// Package testenv 为测试准备临时目录中的 sqlite 和日志配置，在测试包的 TestMain 中调用:
+//
+//	func TestMain(m *testing.M) {
+//		cleanup := testenv.Setup()
+//		model.Init()
+//		code := m.Run()
+//		model.Close()
+//		cleanup()
+//		os.Exit(code)
+//	}
+package testenv
+
+import (
+	"danmu-core/logger"
+	"danmu-core/setting"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const config = `[database]
+Type = sqlite
+Path = %s
+[log]
+LogSavePath = %s
+LogFileName = app.log
+LogLevel = error
+`
+
+// Setup 写入临时配置并初始化 setting、logger，返回删除临时目录的函数
+func Setup() (cleanup func()) {
+	dir, err := os.MkdirTemp("", "danmu-core-test")
+	if err != nil {
+		log.Fatalf("testenv create dir error: %v", err)
+	}
+	path := filepath.Join(dir, "app.ini")
+	data := fmt.Sprintf(config, filepath.Join(dir, "danmu.db"), filepath.Join(dir, "logs"))
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		log.Fatalf("testenv write config error: %v", err)
+	}
+	if err := setting.LoadFrom(path); err != nil {
+		log.Fatalf("testenv load config error: %v", err)
+	}
+	logger.Init()
+	return func() { os.RemoveAll(dir) }
+}
@@ -14,7 +14,8 @@ import (
 
 var Logger zerolog.Logger
 
-func init() {
+// Init 按 [log] 配置创建日志记录器，在 setting.Setup 之后调用；调用前的日志被丢弃
+func Init() {
 	// 创建日志目录
 	if err := os.MkdirAll(setting.LogSetting.LogSavePath, 0755); err != nil {
 		panic(fmt.Sprintf("create log directory failed: %v", err))
@@ -0,0 +1,453 @@
+package metrics
+
+import (
+	"context"
+	"danmu-core/logger"
+	"danmu-core/setting"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "danmu"
+	subsystem = "core"
+)
+
+var (
+	// FramesReceived websocket 收到的原始帧数量
+	FramesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "frames_received_total",
+		Help:      "Total number of websocket frames received per room.",
+	}, []string{"room"})
+
+	// MessagesReceived 按消息类型统计解码后的消息数量
+	MessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "messages_received_total",
+		Help:      "Total number of decoded messages per room and method.",
+	}, []string{"room", "method"})
+
+	// DecodeErrors 帧解码失败次数
+	DecodeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "decode_errors_total",
+		Help:      "Total number of frames that failed to decode per room.",
+	}, []string{"room"})
+
+	// UnknownMethods 未定义 proto 类型的消息数量
+	UnknownMethods = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "unknown_methods_total",
+		Help:      "Total number of messages with an unknown method per room.",
+	}, []string{"room", "method"})
+
+	// HandlerDuration 单个 handler 处理一条消息的耗时
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent by a message handler on a single message.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"room", "handler"})
+
+	// HandlerErrors handler 返回错误或 panic 的次数
+	HandlerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "handler_errors_total",
+		Help:      "Total number of errors returned by message handlers.",
+	}, []string{"room", "handler"})
+
+	// HandlerRetries handler 返回错误后重试的次数
+	HandlerRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "handler_retries_total",
+		Help:      "Total number of message handler retries.",
+	}, []string{"room", "handler"})
+
+	// DeadLetters 重试耗尽或无法解析写入死信的消息数量，result 为 db、file 或 lost
+	DeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "dead_letters_total",
+		Help:      "Total number of messages written to the dead-letter store, by result.",
+	}, []string{"room", "handler", "result"})
+
+	// TTWIDRefreshes 共用 ttwid 的获取次数，result 为 ok 或 error
+	TTWIDRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "ttwid_refreshes_total",
+		Help:      "Total number of shared ttwid cookie refreshes, by result.",
+	}, []string{"result"})
+
+	// SignerVMs goja 签名 VM 池中已创建的 VM 数量
+	SignerVMs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "signer_vms",
+		Help:      "Number of goja signer VMs created in the shared pool.",
+	})
+
+	// SignerFallbacks [douyin] Signer 为 auto 时签名连续被拒绝后切换到 goja 的次数
+	SignerFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "signer_fallbacks_total",
+		Help:      "Total number of rooms that switched from the native signer to goja after repeated rejections.",
+	}, []string{"room"})
+
+	// DouyinRequestWait 请求抖音接口前等待限流的时间
+	DouyinRequestWait = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "douyin_request_wait_seconds_total",
+		Help:      "Total time spent waiting for the shared Douyin request rate limiter.",
+	})
+
+	// WebsocketResumes 携带 cursor 重连的次数，服务端补发断开期间的消息
+	WebsocketResumes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "websocket_resumes_total",
+		Help:      "Total number of websocket reconnects resumed from the last cursor per room.",
+	}, []string{"room"})
+
+	// CoverageHoles 重连后未能补发的缺口数量
+	CoverageHoles = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "coverage_holes_total",
+		Help:      "Total number of message coverage holes after reconnects per room, by reason.",
+	}, []string{"room", "reason"})
+
+	// CoverageHoleSeconds 缺口的总时长
+	CoverageHoleSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "coverage_hole_seconds_total",
+		Help:      "Total duration of message coverage holes per room.",
+	}, []string{"room"})
+
+	// Transport 直播间当前使用的消息传输方式，websocket 或 polling 为 1
+	Transport = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "transport",
+		Help:      "Message transport currently used by a room.",
+	}, []string{"room", "transport"})
+
+	// TransportSwitches 切换传输方式的次数，reason 为 connect_failures、drops、poll_failures 或 retry_websocket
+	TransportSwitches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "transport_switches_total",
+		Help:      "Total number of message transport switches per room, by target transport and reason.",
+	}, []string{"room", "to", "reason"})
+
+	// PollRequests http 轮询的请求次数，result 为 ok 或 error
+	PollRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "poll_requests_total",
+		Help:      "Total number of im/fetch polling requests per room, by result.",
+	}, []string{"room", "result"})
+
+	// ProxyHealthy 代理是否可以分配，1 为可用，不健康或冷却中为 0
+	ProxyHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "proxy_healthy",
+		Help:      "Whether an outbound proxy can be assigned to rooms.",
+	}, []string{"proxy"})
+
+	// ProxyRooms 分配到每个代理的直播间数量
+	ProxyRooms = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "proxy_rooms",
+		Help:      "Number of rooms assigned to an outbound proxy.",
+	}, []string{"proxy"})
+
+	// ProxyChecks 代理健康检查次数，result 为 ok 或 error
+	ProxyChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "proxy_checks_total",
+		Help:      "Total number of outbound proxy health checks, by result.",
+	}, []string{"proxy", "result"})
+
+	// ProxyRotations 直播间切换代理的次数，reason 为 blocked、captcha、unhealthy 或 manual
+	ProxyRotations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "proxy_rotations_total",
+		Help:      "Total number of room proxy rotations, by reason.",
+	}, []string{"reason"})
+
+	// DBInsertDuration 数据库写入耗时
+	DBInsertDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "db_insert_duration_seconds",
+		Help:      "Time spent inserting a row into the database.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"table", "result"})
+
+	// Reconnects websocket 重连次数
+	Reconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "reconnects_total",
+		Help:      "Total number of websocket connection attempts per room.",
+	}, []string{"room", "result"})
+
+	// RecvQueueDepth RecvMsg 通道当前积压的消息数量
+	RecvQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "recv_queue_depth",
+		Help:      "Number of messages waiting in the RecvMsg channel.",
+	}, []string{"room"})
+
+	// HandlerQueueDepth 每个 handler 队列当前积压的消息数量，不包含溢出到磁盘的消息
+	HandlerQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "handler_queue_depth",
+		Help:      "Number of messages waiting in a handler queue.",
+	}, []string{"room", "handler"})
+
+	// HandlerQueueDropped handler 队列丢弃的消息数量，reason 为 overflow、priority、spill_full 或 spill_error
+	HandlerQueueDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "handler_queue_dropped_total",
+		Help:      "Total number of messages dropped by a handler queue, by reason.",
+	}, []string{"room", "handler", "reason"})
+
+	// HandlerQueueSpilled 写入溢出文件的消息数量
+	HandlerQueueSpilled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "handler_queue_spilled_total",
+		Help:      "Total number of messages spilled to disk by a handler queue.",
+	}, []string{"room", "handler"})
+
+	// HandlerQueueSpillBytes 溢出文件中尚未处理的字节数
+	HandlerQueueSpillBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "handler_queue_spill_bytes",
+		Help:      "Bytes of spilled messages not yet processed by a handler queue.",
+	}, []string{"room", "handler"})
+
+	// HandlerQueueBlocked block 策略下等待队列空位的时间
+	HandlerQueueBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "handler_queue_blocked_seconds_total",
+		Help:      "Total time spent waiting for a full handler queue with the block policy.",
+	}, []string{"room", "handler"})
+
+	// RoomLive 直播间是否正在直播, 1 为直播中
+	RoomLive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "room_live",
+		Help:      "Whether the room is currently live (1) or not (0).",
+	}, []string{"room"})
+
+	// ReconcileRuns live_confs 与任务列表对齐的执行次数
+	ReconcileRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "reconcile_runs_total",
+		Help:      "Total number of reconcile runs by result.",
+	}, []string{"result"})
+
+	// ReconcileDrift reconcile 发现并修正的偏差数量
+	ReconcileDrift = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "reconcile_drift_total",
+		Help:      "Total number of drifted tasks found by reconcile, by action and result.",
+	}, []string{"action", "result"})
+
+	// ReconcileLastSuccess 最近一次 reconcile 成功的时间戳
+	ReconcileLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "reconcile_last_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful reconcile run.",
+	})
+
+	// ArchivedRows 归档到 parquet 并从数据库删除的消息数量
+	ArchivedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "archived_rows_total",
+		Help:      "Total number of message rows archived to parquet and deleted, by table.",
+	}, []string{"table"})
+
+	// RetentionRuns 分区维护和归档的执行次数
+	RetentionRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "retention_runs_total",
+		Help:      "Total number of partition maintenance runs by result.",
+	}, []string{"result"})
+
+	// AnalyticsRows 写入分析库的事件行数，result 为 success 或 dropped (写入失败且超过 MaxPending)
+	AnalyticsRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "analytics_rows_total",
+		Help:      "Total number of event rows written to the analytics store by table and result.",
+	}, []string{"table", "result"})
+
+	// PublishEvents 发布到消息总线的事件数，result 为 success 或 dropped (消息总线不可用且超过 MaxPending)
+	PublishEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "publish_events_total",
+		Help:      "Total number of events published to the message bus by result.",
+	}, []string{"result"})
+
+	// PublishPending 本地缓存中等待发送的事件数
+	PublishPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "publish_pending_events",
+		Help:      "Number of events buffered locally waiting to be published.",
+	})
+
+	// RealtimeUpdates 写入 redis 的直播间更新数，result 为 success、error 或 skipped (redis 出错后暂停写入)
+	RealtimeUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "realtime_updates_total",
+		Help:      "Total number of realtime updates written to redis by result.",
+	}, []string{"result"})
+
+	// HubEvents 分发给实时订阅 (SubscribeEvents) 的事件数，result 为 delivered 或 dropped (订阅方消费过慢)
+	HubEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "hub_events_total",
+		Help:      "Total number of events dispatched to realtime subscribers by result.",
+	}, []string{"result"})
+
+	// HubSubscribers 当前的实时订阅数
+	HubSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "hub_subscribers",
+		Help:      "Number of active realtime event subscriptions.",
+	})
+
+	// DuplicateMessages 去重丢弃的消息数量，reason 为 duplicate (已处理过) 或 expired (早于去重时间范围的重放)
+	DuplicateMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "duplicate_messages_total",
+		Help:      "Total number of messages dropped by deduplication per room and reason.",
+	}, []string{"room", "reason"})
+
+	// ModerationFlags 被标记的弹幕数量，reason 为命中的信号，同一条弹幕可命中多个
+	ModerationFlags = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "moderation_flags_total",
+		Help:      "Total number of flagged chat messages per room and signal.",
+	}, []string{"room", "reason"})
+
+	// ModerationExcluded 未写入 common_messages 的弹幕数量
+	ModerationExcluded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "moderation_excluded_total",
+		Help:      "Total number of chat messages excluded from storage per room.",
+	}, []string{"room"})
+)
+
+// ObserveDBInsert 记录一次数据库写入的耗时和结果
+func ObserveDBInsert(table string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	DBInsertDuration.WithLabelValues(table, result).Observe(time.Since(start).Seconds())
+}
+
+// DeleteRoom 删除任务时清理该直播间的所有指标，避免残留过期的时间序列
+func DeleteRoom(room string) {
+	labels := prometheus.Labels{"room": room}
+	FramesReceived.DeletePartialMatch(labels)
+	MessagesReceived.DeletePartialMatch(labels)
+	DecodeErrors.DeletePartialMatch(labels)
+	UnknownMethods.DeletePartialMatch(labels)
+	HandlerDuration.DeletePartialMatch(labels)
+	HandlerErrors.DeletePartialMatch(labels)
+	HandlerRetries.DeletePartialMatch(labels)
+	DeadLetters.DeletePartialMatch(labels)
+	Reconnects.DeletePartialMatch(labels)
+	WebsocketResumes.DeletePartialMatch(labels)
+	CoverageHoles.DeletePartialMatch(labels)
+	CoverageHoleSeconds.DeletePartialMatch(labels)
+	Transport.DeletePartialMatch(labels)
+	TransportSwitches.DeletePartialMatch(labels)
+	PollRequests.DeletePartialMatch(labels)
+	RecvQueueDepth.DeletePartialMatch(labels)
+	HandlerQueueDepth.DeletePartialMatch(labels)
+	HandlerQueueDropped.DeletePartialMatch(labels)
+	HandlerQueueSpilled.DeletePartialMatch(labels)
+	HandlerQueueSpillBytes.DeletePartialMatch(labels)
+	HandlerQueueBlocked.DeletePartialMatch(labels)
+	RoomLive.DeletePartialMatch(labels)
+}
+
+type Server struct {
+	server *http.Server
+}
+
+func NewServer() *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &Server{
+		server: &http.Server{
+			Addr:    fmt.Sprintf("%s:%s", setting.MetricsSetting.Host, setting.MetricsSetting.Port),
+			Handler: mux,
+		},
+	}
+}
+
+// Start 启动 /metrics 端点，阻塞直到服务关闭
+func (s *Server) Start() error {
+	logger.Info().Str("addr", s.server.Addr).Msg("starting metrics server")
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve metrics: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	logger.Info().Msg("stopping metrics server")
+	if err := s.server.Shutdown(ctx); err != nil {
+		logger.Warn().Err(err).Msg("stop metrics server error")
+	}
+}
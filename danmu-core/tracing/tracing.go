@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"danmu-core/logger"
+	"danmu-core/setting"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "danmu-core"
+
+var provider *sdktrace.TracerProvider
+
+// Init 根据配置初始化全局 TracerProvider 和 propagator，未启用时使用 otel 默认的 noop 实现
+func Init() error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if !setting.TracingSetting.Enable {
+		return nil
+	}
+
+	exporter, err := newExporter(context.Background())
+	if err != nil {
+		return err
+	}
+
+	serviceName := setting.TracingSetting.ServiceName
+	if serviceName == "" {
+		serviceName = instrumentationName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return fmt.Errorf("create trace resource error: %w", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(setting.TracingSetting.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	logger.Info().
+		Str("exporter", setting.TracingSetting.Exporter).
+		Str("endpoint", setting.TracingSetting.Endpoint).
+		Float64("sample_ratio", setting.TracingSetting.SampleRatio).
+		Msg("tracing enabled")
+	return nil
+}
+
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(setting.TracingSetting.Exporter) {
+	case "", "otlp-grpc", "otlp":
+		opts := []otlptracegrpc.Option{}
+		if setting.TracingSetting.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(setting.TracingSetting.Endpoint))
+		}
+		if setting.TracingSetting.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case "otlp-http":
+		opts := []otlptracehttp.Option{}
+		if setting.TracingSetting.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(setting.TracingSetting.Endpoint))
+		}
+		if setting.TracingSetting.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter: %s", setting.TracingSetting.Exporter)
+	}
+}
+
+// Shutdown 刷新并关闭 exporter
+func Shutdown(ctx context.Context) {
+	if provider == nil {
+		return
+	}
+	if err := provider.Shutdown(ctx); err != nil {
+		logger.Warn().Err(err).Msg("shutdown tracer provider error")
+	}
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start 创建一个 span，调用方负责 End
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// End 结束 span，err 不为空时标记为错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SampleMessage 判断本条弹幕消息是否需要创建处理 span，避免高频消息产生大量 trace
+func SampleMessage() bool {
+	if !setting.TracingSetting.Enable {
+		return false
+	}
+	return rand.Float64() < setting.TracingSetting.MessageSampleRatio
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
+	"danmu-http/internal/analytics"
 	"danmu-http/internal/model"
 	"danmu-http/logger"
 	"danmu-http/router"
 	"danmu-http/setting"
+	"danmu-http/tracing"
 	"fmt"
 	"log"
 )
@@ -12,7 +15,12 @@ import (
 func main() {
 	setting.Init()
 	logger.Init()
+	if err := tracing.Init(); err != nil {
+		logger.Error().Err(err).Msg("tracing init failed")
+	}
+	defer tracing.Shutdown(context.Background())
 	model.Init()
+	analytics.Init()
 	router.Init()
 	defer model.Close()
 
@@ -0,0 +1,154 @@
+// Package analytics 通过 HTTP 接口查询 danmu-core 写入的 ClickHouse 兼容分析库，表结构需与 danmu-core 的 analytics 包保持一致
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"danmu-http/setting"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const requestTimeout = 30 * time.Second
+
+const (
+	TableChatEvents = "chat_events"
+	TableGiftEvents = "gift_events"
+)
+
+// Client 只执行查询语句，写入由 danmu-core 负责
+type Client struct {
+	endpoint string
+	database string
+	user     string
+	password string
+	http     *http.Client
+}
+
+var defaultClient *Client
+
+// Init 根据 [analytics] 配置创建默认客户端，未启用时 Default 返回 nil
+func Init() {
+	if !setting.AnalyticsSetting.Enable {
+		return
+	}
+	defaultClient = NewClient(
+		setting.AnalyticsSetting.Endpoint,
+		setting.AnalyticsSetting.Database,
+		setting.AnalyticsSetting.User,
+		setting.AnalyticsSetting.Password,
+	)
+}
+
+// Default 返回默认客户端，未启用分析库时为 nil
+func Default() *Client {
+	return defaultClient
+}
+
+func NewClient(endpoint, database, user, password string) *Client {
+	return &Client{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		database: database,
+		user:     user,
+		password: password,
+		http:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Select 执行查询并将 JSONEachRow 格式的结果逐行解码为 T
+func Select[T any](ctx context.Context, c *Client, query string, params *Params) ([]T, error) {
+	values := url.Values{}
+	values.Set("database", c.database)
+	values.Set("default_format", "JSONEachRow")
+	// 64 位整数按数字输出，与 postgres 查询结果的 json 保持一致
+	values.Set("output_format_json_quote_64bit_integers", "0")
+	if params != nil {
+		for name, value := range params.values {
+			values.Set("param_"+name, value)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/?"+values.Encode(), strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	if c.user != "" {
+		req.Header.Set("X-ClickHouse-User", c.user)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("clickhouse status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	rows := make([]T, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var row T
+		if err := json.Unmarshal(line, &row); err != nil {
+			return nil, fmt.Errorf("decode clickhouse row error: %w", err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Params 收集查询参数，语句中使用 {name:Type} 占位，值通过 param_name 传递，不拼接到语句中
+type Params struct {
+	values map[string]string
+}
+
+func NewParams() *Params {
+	return &Params{values: make(map[string]string)}
+}
+
+// Add 添加参数并返回占位符，typ 为 ClickHouse 类型，如 String、Int64、Array(UInt64)
+func (p *Params) Add(typ string, value any) string {
+	name := fmt.Sprintf("p%d", len(p.values))
+	p.values[name] = formatParam(value)
+	return "{" + name + ":" + typ + "}"
+}
+
+// paramEscaper 参数值按 TSV 格式解析，需转义反斜杠和控制字符
+var paramEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// quoteEscaper 数组中的字符串按 SQL 字面量解析
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func formatParam(value any) string {
+	switch v := value.(type) {
+	case string:
+		return paramEscaper.Replace(v)
+	case []string:
+		items := make([]string, len(v))
+		for i, s := range v {
+			items[i] = "'" + quoteEscaper.Replace(s) + "'"
+		}
+		return paramEscaper.Replace("[" + strings.Join(items, ",") + "]")
+	case []uint64:
+		items := make([]string, len(v))
+		for i, n := range v {
+			items[i] = fmt.Sprint(n)
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,218 @@
+package analytics
+
+import (
+	"context"
+	"danmu-http/internal/model"
+	"danmu-http/internal/validate"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// GiftRankingRow 连击合并后的一次送礼，combo_count 为连击的最终数量
+type GiftRankingRow struct {
+	UserID          uint64 `json:"user_id"`
+	UserName        string `json:"user_name"`
+	UserDisplayId   string `json:"user_display_id"`
+	RoomDisplayId   string `json:"room_display_id"`
+	RoomName        string `json:"room_name"`
+	ToUserID        uint64 `json:"to_user_id"`
+	ToUserName      string `json:"to_user_name"`
+	ToUserDisplayId string `json:"to_user_display_id"`
+	GiftID          int64  `json:"gift_id"`
+	GiftName        string `json:"gift_name"`
+	DiamondCount    int64  `json:"diamond_count"`
+	ComboCount      int64  `json:"combo_count"`
+	Image           string `json:"image_url"`
+	Message         string `json:"message"`
+	Timestamp       int64  `json:"timestamp"`
+}
+
+// ListGiftRanking 与 postgres 模式的连击规则一致：同一用户给同一对象送的同一礼物按时间排序，
+// 下一条的连击数不大于当前条时当前条为一次连击的结束，只保留每次连击的最后一条
+func ListGiftRanking(ctx context.Context, c *Client, req *validate.ListGiftRankingRequest) ([]*GiftRankingRow, error) {
+	params := NewParams()
+	conds := []string{
+		"room_display_id = " + params.Add("String", req.RoomDisplayId),
+		"timestamp BETWEEN " + params.Add("Int64", req.Begin) + " AND " + params.Add("Int64", req.End),
+	}
+	if len(req.ToUserIds) > 0 {
+		conds = append(conds, "to_user_id IN "+params.Add("Array(UInt64)", req.ToUserIds))
+	}
+	query := `
+SELECT user_id, user_name, user_display_id, room_display_id, room_name,
+       to_user_id, to_user_name, to_user_display_id,
+       gift_id, gift_name, diamond_count, combo_count, image_url, message, timestamp
+FROM (
+    SELECT *,
+           leadInFrame(toNullable(combo_count)) OVER (
+               PARTITION BY user_id, to_user_id, gift_id ORDER BY timestamp, msg_id
+               ROWS BETWEEN CURRENT ROW AND 1 FOLLOWING
+           ) AS next_combo
+    FROM ` + TableGiftEvents + ` FINAL
+    WHERE ` + strings.Join(conds, " AND ") + `
+)
+WHERE next_combo IS NULL OR next_combo <= combo_count
+ORDER BY timestamp`
+	return Select[*GiftRankingRow](ctx, c, query, params)
+}
+
+// 可排序的列，其他值使用默认的 timestamp desc
+var (
+	commonMessageOrderColumns = map[string]bool{
+		"id": true, "timestamp": true, "message_type": true, "user_id": true, "user_name": true,
+		"room_display_id": true, "streamer_id": true,
+	}
+	giftMessageOrderColumns = map[string]bool{
+		"id": true, "timestamp": true, "user_id": true, "user_name": true, "to_user_id": true,
+		"gift_id": true, "gift_name": true, "diamond_count": true, "room_display_id": true, "streamer_id": true,
+	}
+)
+
+// commonMessageBranches chat_events 和 gift_events 映射为 common_messages 的列，
+// 弹幕内容与 danmu-core 写入 postgres 时一样带昵称前缀
+var commonMessageBranches = []struct {
+	messageType string
+	query       string
+}{
+	{"WebcastChatMessage", `
+    SELECT msg_id AS id, 'WebcastChatMessage' AS message_type, room_id, room_display_id, room_name,
+           user_name, user_id, user_display_id, concat('[', user_name, ']: ', content) AS content,
+           timestamp, streamer_id
+    FROM ` + TableChatEvents + ` FINAL`},
+	{"WebcastGiftMessage", `
+    SELECT msg_id AS id, 'WebcastGiftMessage' AS message_type, room_id, room_display_id, room_name,
+           user_name, user_id, user_display_id, message AS content,
+           timestamp, streamer_id
+    FROM ` + TableGiftEvents + ` FINAL`},
+}
+
+// ListCommonMessages 对应 model.GetCommonMessageWithConditionPage
+func ListCommonMessages(ctx context.Context, c *Client, req *validate.CommonMessageQuery) ([]*model.CommonMessage, int64, error) {
+	params := NewParams()
+	var conds []string
+	if len(req.UserIDs) > 0 {
+		conds = append(conds, "user_id IN "+params.Add("Array(UInt64)", req.UserIDs))
+	}
+	if req.RoomDisplayId != "" {
+		conds = append(conds, "room_display_id = "+params.Add("String", req.RoomDisplayId))
+	}
+	if req.StreamerID != 0 {
+		conds = append(conds, "streamer_id = "+params.Add("Int64", req.StreamerID))
+	}
+	if req.Begin != 0 {
+		conds = append(conds, "timestamp >= "+params.Add("Int64", req.Begin))
+	}
+	if req.End != 0 {
+		conds = append(conds, "timestamp <= "+params.Add("Int64", req.End))
+	}
+	where := whereClause(conds)
+
+	var branches []string
+	for _, b := range commonMessageBranches {
+		if len(req.MessageType) > 0 && !slices.Contains(req.MessageType, b.messageType) {
+			continue
+		}
+		branches = append(branches, b.query+where)
+	}
+	// 分析库只有弹幕和礼物两种消息
+	if len(branches) == 0 {
+		return []*model.CommonMessage{}, 0, nil
+	}
+	from := "(" + strings.Join(branches, "\n    UNION ALL") + "\n)"
+	var outer string
+	if req.Search != "" {
+		outer = "\nWHERE positionUTF8(content, " + params.Add("String", req.Search) + ") > 0"
+	}
+
+	total, err := count(ctx, c, from+outer, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := "SELECT * FROM " + from + outer + orderClause(&req.PageRequest, commonMessageOrderColumns)
+	messages, err := Select[*model.CommonMessage](ctx, c, query, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+// ListGiftMessages 对应 model.GetGiftMessageWithConditionPage
+func ListGiftMessages(ctx context.Context, c *Client, req *validate.GiftMessageQuery) ([]*model.GiftMessage, int64, error) {
+	params := NewParams()
+	var conds []string
+	if len(req.UserIDs) > 0 {
+		conds = append(conds, "user_id IN "+params.Add("Array(UInt64)", req.UserIDs))
+	}
+	if len(req.ToUserIds) > 0 {
+		conds = append(conds, "to_user_id IN "+params.Add("Array(UInt64)", req.ToUserIds))
+	}
+	if req.Begin != 0 {
+		conds = append(conds, "timestamp >= "+params.Add("Int64", req.Begin))
+	}
+	if req.End != 0 {
+		conds = append(conds, "timestamp <= "+params.Add("Int64", req.End))
+	}
+	if req.DiamondCount != 0 {
+		conds = append(conds, "diamond_count >= "+params.Add("Int64", req.DiamondCount))
+	}
+	if req.RoomDisplayId != "" {
+		conds = append(conds, "room_display_id = "+params.Add("String", req.RoomDisplayId))
+	}
+	if req.StreamerID != 0 {
+		conds = append(conds, "streamer_id = "+params.Add("Int64", req.StreamerID))
+	}
+	if req.Search != "" {
+		conds = append(conds, "positionUTF8(message, "+params.Add("String", req.Search)+") > 0")
+	}
+	from := `(
+    SELECT toInt64(msg_id) AS id, user_id, user_name, user_display_id,
+           to_user_id, to_user_name, to_user_display_id, gift_name, gift_id,
+           room_id, room_display_id, room_name, message, timestamp,
+           diamond_count, image_url, toString(combo_count) AS combo_count, streamer_id
+    FROM ` + TableGiftEvents + ` FINAL` + whereClause(conds) + `
+)`
+
+	total, err := count(ctx, c, from, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := "SELECT * FROM " + from + orderClause(&req.PageRequest, giftMessageOrderColumns)
+	messages, err := Select[*model.GiftMessage](ctx, c, query, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+func count(ctx context.Context, c *Client, from string, params *Params) (int64, error) {
+	rows, err := Select[struct {
+		Total int64 `json:"total"`
+	}](ctx, c, "SELECT count() AS total FROM "+from, params)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].Total, nil
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return "\n    WHERE " + strings.Join(conds, " AND ")
+}
+
+// orderClause 排序列只能是 columns 中的列，未指定方向时与 postgres 一样升序，分页参数已由 validate 校验
+func orderClause(req *validate.PageRequest, columns map[string]bool) string {
+	orderBy, direction := "timestamp", "DESC"
+	if columns[req.OrderBy] {
+		orderBy, direction = req.OrderBy, "ASC"
+		if strings.EqualFold(req.OrderDirection, "desc") {
+			direction = "DESC"
+		}
+	}
+	return fmt.Sprintf("\nORDER BY %s %s\nLIMIT %d OFFSET %d", orderBy, direction, req.PageSize, (req.Page-1)*req.PageSize)
+}
@@ -0,0 +1,132 @@
+package analytics
+
+import (
+	"context"
+	"danmu-http/internal/validate"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeClickHouse 记录最后一次查询，返回固定的 JSONEachRow 结果
+type fakeClickHouse struct {
+	*httptest.Server
+	params url.Values
+	query  string
+	status int
+	body   string
+}
+
+func newFakeClickHouse(t *testing.T, status int, body string) *fakeClickHouse {
+	f := &fakeClickHouse{status: status, body: body}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-ClickHouse-User") != "reader" || r.Header.Get("X-ClickHouse-Key") != "secret" {
+			http.Error(w, "authentication failed", http.StatusUnauthorized)
+			return
+		}
+		q, _ := io.ReadAll(r.Body)
+		f.params, f.query = r.URL.Query(), string(q)
+		w.WriteHeader(f.status)
+		io.WriteString(w, f.body)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeClickHouse) client() *Client {
+	return NewClient(f.URL, "danmu", "reader", "secret")
+}
+
+func TestListGiftRanking(t *testing.T) {
+	f := newFakeClickHouse(t, http.StatusOK,
+		`{"user_id":18446744073709551615,"user_name":"a","room_display_id":"123","gift_id":1,"gift_name":"rose","diamond_count":1,"combo_count":5,"timestamp":1000}`+"\n"+
+			"\n"+
+			`{"user_id":2,"user_name":"b","room_display_id":"123","to_user_id":7,"gift_id":2,"gift_name":"car","diamond_count":100,"combo_count":1,"timestamp":2000}`+"\n")
+	rows, err := ListGiftRanking(context.Background(), f.client(), &validate.ListGiftRankingRequest{
+		RoomDisplayId: "123\tx",
+		Begin:         1,
+		End:           3000,
+		ToUserIds:     []uint64{7, 8},
+	})
+	if err != nil {
+		t.Fatalf("ListGiftRanking: %v", err)
+	}
+
+	wantParams := map[string]string{
+		"database":       "danmu",
+		"default_format": "JSONEachRow",
+		"output_format_json_quote_64bit_integers": "0",
+		"param_p0": `123\tx`,
+		"param_p1": "1",
+		"param_p2": "3000",
+		"param_p3": "[7,8]",
+	}
+	for name, want := range wantParams {
+		if got := f.params.Get(name); got != want {
+			t.Errorf("param %s = %q, want %q", name, got, want)
+		}
+	}
+	for _, want := range []string{
+		"FROM gift_events FINAL",
+		"room_display_id = {p0:String}",
+		"timestamp BETWEEN {p1:Int64} AND {p2:Int64}",
+		"to_user_id IN {p3:Array(UInt64)}",
+		"WHERE next_combo IS NULL OR next_combo <= combo_count",
+	} {
+		if !strings.Contains(f.query, want) {
+			t.Errorf("query missing %q:\n%s", want, f.query)
+		}
+	}
+	if strings.Contains(f.query, "123") {
+		t.Errorf("parameter value was inlined into query:\n%s", f.query)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	if rows[0].UserID != 18446744073709551615 || rows[0].GiftName != "rose" || rows[0].ComboCount != 5 {
+		t.Errorf("row 0 = %+v", rows[0])
+	}
+	if rows[1].ToUserID != 7 || rows[1].DiamondCount != 100 || rows[1].Timestamp != 2000 {
+		t.Errorf("row 1 = %+v", rows[1])
+	}
+}
+
+func TestListGiftRankingWithoutToUsers(t *testing.T) {
+	f := newFakeClickHouse(t, http.StatusOK, "")
+	rows, err := ListGiftRanking(context.Background(), f.client(), &validate.ListGiftRankingRequest{
+		RoomDisplayId: "123", Begin: 1, End: 2,
+	})
+	if err != nil {
+		t.Fatalf("ListGiftRanking: %v", err)
+	}
+	if rows == nil || len(rows) != 0 {
+		t.Errorf("rows = %#v, want empty non-nil slice", rows)
+	}
+	if strings.Contains(f.query, "to_user_id IN") || f.params.Has("param_p3") {
+		t.Errorf("unexpected to_user_id filter:\n%s", f.query)
+	}
+}
+
+func TestListGiftRankingError(t *testing.T) {
+	f := newFakeClickHouse(t, http.StatusBadRequest, "Code: 60. DB::Exception: Table danmu.gift_events does not exist.\n")
+	_, err := ListGiftRanking(context.Background(), f.client(), &validate.ListGiftRankingRequest{
+		RoomDisplayId: "123", Begin: 1, End: 2,
+	})
+	if err == nil || !strings.Contains(err.Error(), "status 400") || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("error = %v, want status 400 with server message", err)
+	}
+}
+
+func TestListGiftRankingBadRow(t *testing.T) {
+	f := newFakeClickHouse(t, http.StatusOK, `{"user_id":"not a number"}`+"\n")
+	_, err := ListGiftRanking(context.Background(), f.client(), &validate.ListGiftRankingRequest{
+		RoomDisplayId: "123", Begin: 1, End: 2,
+	})
+	if err == nil || !strings.Contains(err.Error(), "decode clickhouse row error") {
+		t.Fatalf("error = %v, want decode error", err)
+	}
+}
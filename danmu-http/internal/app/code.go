@@ -10,6 +10,19 @@ const (
 	// 业务错误码从1000开始
 	ErrInvalidRequest = 1000
 	ErrDatabase       = 1001
+
+	// danmu-core 任务相关错误码
+	ErrTaskNotFound    = 1100
+	ErrTaskExists      = 1101
+	ErrTaskDisabled    = 1102
+	ErrTaskInvalid     = 1103
+	ErrCoreUnavailable = 1104
+
+	// danmu-core 代理池相关错误码
+	ErrProxyDisabled = 1200
+	ErrProxyInvalid  = 1201
+	ErrProxyExists   = 1202
+	ErrProxyNotFound = 1203
 )
 
 var MsgFlags = map[int]string{
@@ -21,6 +34,17 @@ var MsgFlags = map[int]string{
 
 	ErrInvalidRequest: "invalid request",
 	ErrDatabase:       "database error",
+
+	ErrTaskNotFound:    "task not found",
+	ErrTaskExists:      "task already exists",
+	ErrTaskDisabled:    "task is disabled",
+	ErrTaskInvalid:     "invalid task config",
+	ErrCoreUnavailable: "danmu-core unavailable",
+
+	ErrProxyDisabled: "proxy is disabled",
+	ErrProxyInvalid:  "invalid proxy url",
+	ErrProxyExists:   "proxy already exists",
+	ErrProxyNotFound: "proxy not found",
 }
 
 func GetMsg(code int) string {
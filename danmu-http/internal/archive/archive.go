@@ -0,0 +1,91 @@
+// Package archive 读取 danmu-core 归档的 parquet 消息文件，行结构需与 danmu-core 的 archive 包保持一致
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+var ErrInvalidPath = errors.New("invalid archive path")
+
+// CommonMessageRow common_messages 的归档行
+type CommonMessageRow struct {
+	ID             uint64 `parquet:"id"`
+	MessageType    string `parquet:"message_type,dict"`
+	RoomID         uint64 `parquet:"room_id"`
+	RoomDisplayId  string `parquet:"room_display_id,dict"`
+	RoomName       string `parquet:"room_name,dict"`
+	UserName       string `parquet:"user_name"`
+	UserID         uint64 `parquet:"user_id"`
+	UserDisplayId  string `parquet:"user_display_id"`
+	Content        string `parquet:"content"`
+	Timestamp      uint64 `parquet:"timestamp"`
+	FavoriteUserId uint64 `parquet:"favorite_user_id"`
+	StreamerID     int64  `parquet:"streamer_id"`
+}
+
+// GiftMessageRow gift_messages 的归档行
+type GiftMessageRow struct {
+	ID              int64  `parquet:"id"`
+	UserID          uint64 `parquet:"user_id"`
+	UserName        string `parquet:"user_name"`
+	UserDisplayId   string `parquet:"user_display_id"`
+	ToUserID        uint64 `parquet:"to_user_id"`
+	ToUserName      string `parquet:"to_user_name,dict"`
+	ToUserDisplayId string `parquet:"to_user_display_id,dict"`
+	GiftName        string `parquet:"gift_name,dict"`
+	GiftID          int64  `parquet:"gift_id"`
+	RoomID          uint64 `parquet:"room_id"`
+	RoomDisplayId   string `parquet:"room_display_id,dict"`
+	RoomName        string `parquet:"room_name,dict"`
+	Message         string `parquet:"message"`
+	Timestamp       uint64 `parquet:"timestamp"`
+	DiamondCount    int32  `parquet:"diamond_count"`
+	Image           string `parquet:"image_url,dict"`
+	RepeatEnd       int32  `parquet:"repeat_end"`
+	ComboCount      string `parquet:"combo_count"`
+	StreamerID      int64  `parquet:"streamer_id"`
+}
+
+// Resolve 将 message_archives 中记录的相对路径转换为 dir 下的路径，拒绝指向 dir 之外的路径
+func Resolve(dir, rel string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	r, err := filepath.Rel(dir, path)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidPath, rel)
+	}
+	return path, nil
+}
+
+// Scan 依次读取文件中的行，keep 返回 false 时停止读取
+func Scan[T any](path string, keep func(row *T) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := parquet.NewGenericReader[T](f)
+	defer r.Close()
+	rows := make([]T, 1000)
+	for {
+		n, err := r.Read(rows)
+		for i := 0; i < n; i++ {
+			if !keep(&rows[i]) {
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read %s error: %w", path, err)
+		}
+	}
+}
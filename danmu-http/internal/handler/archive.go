@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"danmu-http/internal/app"
+	"danmu-http/internal/service"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ArchiveHandler struct {
+	service service.ArchiveService
+}
+
+func NewArchiveHandler(s service.ArchiveService) *ArchiveHandler {
+	return &ArchiveHandler{service: s}
+}
+
+func (h *ArchiveHandler) List(c *gin.Context) {
+	var req validate.ArchiveListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	archives, total, err := h.service.ListArchives(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Msg("list archives failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"total": total,
+		"list":  archives,
+	})
+}
+
+func (h *ArchiveHandler) ListCommonMessages(c *gin.Context) {
+	var req validate.ArchiveCommonMessageQuery
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	messages, total, truncated, err := h.service.QueryCommonMessages(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Interface("request", req).Msg("query archived common messages failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"total":     total,
+		"list":      messages,
+		"truncated": truncated,
+	})
+}
+
+func (h *ArchiveHandler) ListGiftMessages(c *gin.Context) {
+	var req validate.ArchiveGiftMessageQuery
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	messages, total, truncated, err := h.service.QueryGiftMessages(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Interface("request", req).Msg("query archived gift messages failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"total":     total,
+		"list":      messages,
+		"truncated": truncated,
+	})
+}
+
+func (h *ArchiveHandler) ListRetention(c *gin.Context) {
+	policies, err := h.service.ListRetentionPolicies(c.Request.Context())
+	if err != nil {
+		logger.Error().Err(err).Msg("list retention policies failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"list": policies,
+	})
+}
+
+func (h *ArchiveHandler) SetRetention(c *gin.Context) {
+	var req validate.RetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	if err := h.service.SetRetentionPolicy(c.Request.Context(), &req); err != nil {
+		logger.Error().Err(err).Interface("request", req).Msg("set retention policy failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, nil)
+}
+
+func (h *ArchiveHandler) DeleteRetention(c *gin.Context) {
+	room := c.Param("room_display_id")
+	if err := h.service.DeleteRetentionPolicy(c.Request.Context(), room); err != nil {
+		logger.Error().Err(err).Str("room_display_id", room).Msg("delete retention policy failed")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+			return
+		}
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, nil)
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"danmu-http/internal/app"
+	"danmu-http/internal/service"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CoverageHoleHandler struct {
+	service service.CoverageHoleService
+}
+
+func NewCoverageHoleHandler(s service.CoverageHoleService) *CoverageHoleHandler {
+	return &CoverageHoleHandler{service: s}
+}
+
+func (h *CoverageHoleHandler) List(c *gin.Context) {
+	var req validate.CoverageHoleListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	holes, total, duration, err := h.service.ListCoverageHoles(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Str("room_display_id", req.RoomDisplayId).Msg("list coverage holes failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"total":    total,
+		"duration": duration,
+		"list":     holes,
+	})
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"danmu-http/internal/app"
+	"danmu-http/internal/service"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"danmu-http/rpc"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DeadLetterHandler struct {
+	service service.DeadLetterService
+}
+
+func NewDeadLetterHandler(s service.DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{service: s}
+}
+
+func (h *DeadLetterHandler) List(c *gin.Context) {
+	var req validate.DeadLetterListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	letters, total, err := h.service.ListDeadLetters(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Str("room_display_id", req.RoomDisplayId).Msg("list dead letters failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"total": total,
+		"list":  letters,
+	})
+}
+
+func (h *DeadLetterHandler) Get(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	letter, err := h.service.GetDeadLetter(c.Request.Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("get dead letter failed")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+			return
+		}
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, letter)
+}
+
+func (h *DeadLetterHandler) Replay(c *gin.Context) {
+	var req validate.DeadLetterReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	results, err := h.service.ReplayDeadLetters(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Ints64("ids", req.IDs).Msg("replay dead letters failed")
+		httpCode, code := rpc.ErrorCode(err)
+		app.NewGin(c).Response(httpCode, code, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, results)
+}
+
+func (h *DeadLetterHandler) Delete(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteDeadLetter(c.Request.Context(), id); err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("delete dead letter failed")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+			return
+		}
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, nil)
+}
+
+func (h *DeadLetterHandler) Purge(c *gin.Context) {
+	var req validate.DeadLetterPurgeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	rows, err := h.service.PurgeDeadLetters(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Msg("purge dead letters failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{"deleted": rows})
+}
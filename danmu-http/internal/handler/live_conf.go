@@ -5,12 +5,22 @@ import (
 	"danmu-http/internal/service"
 	"danmu-http/internal/validate"
 	"danmu-http/logger"
+	"danmu-http/rpc"
+	"errors"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// idempotencyKeyHeader 客户端重试时携带相同的值，danmu-core 返回首次调用的结果
+const idempotencyKeyHeader = "Idempotency-Key"
+
+const maxImportBodySize = 2 << 20
+
 type LiveConfHandler struct {
 	service service.LiveConfService
 }
@@ -33,9 +43,10 @@ func (h *LiveConfHandler) Create(c *gin.Context) {
 		return
 	}
 
+	req.IdempotencyKey = c.GetHeader(idempotencyKeyHeader)
 	if err := h.service.AddLiveConf(c.Request.Context(), &req); err != nil {
 		logger.Error().Err(err).Interface("request", req).Msg("create live conf failed")
-		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		taskErrorResponse(c, err)
 		return
 	}
 
@@ -56,9 +67,10 @@ func (h *LiveConfHandler) Update(c *gin.Context) {
 		return
 	}
 
+	req.IdempotencyKey = c.GetHeader(idempotencyKeyHeader)
 	if err := h.service.UpdateLiveConf(c.Request.Context(), &req); err != nil {
 		logger.Error().Err(err).Interface("request", req).Msg("update live conf failed")
-		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		taskErrorResponse(c, err)
 		return
 	}
 
@@ -76,7 +88,7 @@ func (h *LiveConfHandler) Delete(c *gin.Context) {
 
 	if err := h.service.DeleteLiveConf(c.Request.Context(), id); err != nil {
 		logger.Error().Err(err).Int64("id", id).Msg("delete live conf failed")
-		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		taskErrorResponse(c, err)
 		return
 	}
 
@@ -115,3 +127,151 @@ func (h *LiveConfHandler) List(c *gin.Context) {
 		"list": confs,
 	})
 }
+
+func (h *LiveConfHandler) GetTask(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.service.GetTask(c.Request.Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("get task failed")
+		taskErrorResponse(c, err)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, task)
+}
+
+func (h *LiveConfHandler) ListTasks(c *gin.Context) {
+	tasks, err := h.service.ListTasks(c.Request.Context())
+	if err != nil {
+		logger.Error().Err(err).Msg("list tasks failed")
+		taskErrorResponse(c, err)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"list": tasks,
+	})
+}
+
+func (h *LiveConfHandler) Start(c *gin.Context) {
+	h.setEnable(c, true)
+}
+
+func (h *LiveConfHandler) Stop(c *gin.Context) {
+	h.setEnable(c, false)
+}
+
+func (h *LiveConfHandler) setEnable(c *gin.Context, enable bool) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.service.SetEnable(c.Request.Context(), id, enable)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Bool("enable", enable).Msg("switch task failed")
+		taskErrorResponse(c, err)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, task)
+}
+
+func (h *LiveConfHandler) Restart(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.service.RestartTask(c.Request.Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("restart task failed")
+		taskErrorResponse(c, err)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, task)
+}
+
+// Import 批量导入直播间，根据 Content-Type 解析请求体:
+//
+//	application/json     [{"url": ...}] 或 {"rows": [...]}
+//	text/csv             带表头的 csv
+//	text/plain           每行一个链接
+//	multipart/form-data  file 字段上传以上任一格式，按扩展名区分
+func (h *LiveConfHandler) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBodySize)
+	rows, err := parseImportRows(c)
+	if err != nil {
+		logger.Error().Err(err).Msg("parse import rows failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, err.Error())
+		return
+	}
+
+	results, err := h.service.ImportLiveConf(c.Request.Context(), rows)
+	if err != nil {
+		logger.Error().Err(err).Int("rows", len(rows)).Msg("import live conf failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"list": results,
+	})
+}
+
+func parseImportRows(c *gin.Context) ([]validate.LiveConfImportRow, error) {
+	if c.ContentType() == "multipart/form-data" {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		switch strings.ToLower(filepath.Ext(fh.Filename)) {
+		case ".json":
+			return validate.ParseImportJSON(f)
+		case ".csv":
+			return validate.ParseImportCSV(f)
+		default:
+			return validate.ParseImportText(f)
+		}
+	}
+
+	switch c.ContentType() {
+	case "application/json":
+		return validate.ParseImportJSON(c.Request.Body)
+	case "text/csv":
+		return validate.ParseImportCSV(c.Request.Body)
+	default:
+		return validate.ParseImportText(c.Request.Body)
+	}
+}
+
+func parseID(c *gin.Context) (int64, bool) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		logger.Error().Err(err).Str("id", idStr).Msg("invalid id")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return 0, false
+	}
+	return id, true
+}
+
+// taskErrorResponse 根据 danmu-core 返回的错误选择 http 状态码和 app code
+func taskErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+		return
+	}
+	httpCode, code := rpc.ErrorCode(err)
+	app.NewGin(c).Response(httpCode, code, nil)
+}
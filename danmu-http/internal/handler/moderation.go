@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"danmu-http/internal/app"
+	"danmu-http/internal/service"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ModerationHandler struct {
+	service service.ModerationService
+}
+
+func NewModerationHandler(s service.ModerationService) *ModerationHandler {
+	return &ModerationHandler{service: s}
+}
+
+func (h *ModerationHandler) ListFlags(c *gin.Context) {
+	var req validate.ChatFlagListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	flags, total, err := h.service.ListChatFlags(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Str("room_display_id", req.RoomDisplayId).Msg("list chat flags failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"total": total,
+		"list":  flags,
+	})
+}
+
+func (h *ModerationHandler) GetFlag(c *gin.Context) {
+	room := c.Param("room_display_id")
+	userID, ok := flagUserID(c)
+	if !ok {
+		return
+	}
+	flag, err := h.service.GetChatFlag(c.Request.Context(), room, userID)
+	if err != nil {
+		logger.Error().Err(err).Str("room_display_id", room).Uint64("user_id", userID).Msg("get chat flag failed")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+			return
+		}
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, flag)
+}
+
+func (h *ModerationHandler) DeleteFlag(c *gin.Context) {
+	room := c.Param("room_display_id")
+	userID, ok := flagUserID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteChatFlag(c.Request.Context(), room, userID); err != nil {
+		logger.Error().Err(err).Str("room_display_id", room).Uint64("user_id", userID).Msg("delete chat flag failed")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+			return
+		}
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, nil)
+}
+
+func flagUserID(c *gin.Context) (uint64, bool) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"bytes"
+	"danmu-http/internal/app"
+	"danmu-http/internal/model"
+	"danmu-http/internal/overlay"
+	"danmu-http/internal/service"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// overlaySocketKind 页面连接 WebSocket 使用的 kind，与页面类型在同一路径参数中
+const overlaySocketKind = "ws"
+
+// overlayUpgrader 使用默认的同源检查，页面和 WebSocket 由同一地址提供
+var overlayUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+type OverlayHandler struct {
+	service service.OverlayService
+}
+
+func NewOverlayHandler(s service.OverlayService) *OverlayHandler {
+	return &OverlayHandler{service: s}
+}
+
+func (h *OverlayHandler) List(c *gin.Context) {
+	room := c.Query("room_display_id")
+	overlays, err := h.service.ListOverlays(c.Request.Context(), room)
+	if err != nil {
+		logger.Error().Err(err).Str("room_display_id", room).Msg("list overlays failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"list": overlays,
+	})
+}
+
+func (h *OverlayHandler) Get(c *gin.Context) {
+	id, ok := overlayID(c)
+	if !ok {
+		return
+	}
+
+	o, err := h.service.GetOverlay(c.Request.Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("get overlay failed")
+		overlayErrorResponse(c, err)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, o)
+}
+
+func (h *OverlayHandler) Create(c *gin.Context) {
+	var req validate.OverlayAddRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	o, err := h.service.AddOverlay(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Interface("request", req).Msg("create overlay failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, o)
+}
+
+func (h *OverlayHandler) Update(c *gin.Context) {
+	var req validate.OverlayUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	o, err := h.service.UpdateOverlay(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Interface("request", req).Msg("update overlay failed")
+		overlayErrorResponse(c, err)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, o)
+}
+
+func (h *OverlayHandler) Delete(c *gin.Context) {
+	id, ok := overlayID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteOverlay(c.Request.Context(), id); err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("delete overlay failed")
+		overlayErrorResponse(c, err)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, nil)
+}
+
+func (h *OverlayHandler) RotateToken(c *gin.Context) {
+	id, ok := overlayID(c)
+	if !ok {
+		return
+	}
+
+	o, err := h.service.RotateToken(c.Request.Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("rotate overlay token failed")
+		overlayErrorResponse(c, err)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, o)
+}
+
+func (h *OverlayHandler) Reset(c *gin.Context) {
+	id, ok := overlayID(c)
+	if !ok {
+		return
+	}
+
+	o, err := h.service.ResetOverlay(c.Request.Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("reset overlay failed")
+		overlayErrorResponse(c, err)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, o)
+}
+
+// Page OBS 浏览器源访问的页面，不需要登录，kind 为 ws 时升级为 WebSocket 推送实时消息
+func (h *OverlayHandler) Page(c *gin.Context) {
+	o, err := h.service.GetOverlayByToken(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		logger.Error().Err(err).Msg("get overlay by token failed")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	kind := c.Param("kind")
+	if kind == overlaySocketKind {
+		h.socket(c, o)
+		return
+	}
+	if !overlay.ValidKind(kind) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	var buf bytes.Buffer
+	if err := overlay.Render(&buf, o, kind); err != nil {
+		logger.Error().Err(err).Int64("overlay", o.ID).Str("kind", kind).Msg("render overlay failed")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+func (h *OverlayHandler) socket(c *gin.Context, o *model.Overlay) {
+	kind := c.Query("kind")
+	if kind != "" && !overlay.ValidKind(kind) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	conn, err := overlayUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 失败时已经写入了错误响应
+		logger.Warn().Err(err).Int64("overlay", o.ID).Msg("upgrade overlay websocket failed")
+		return
+	}
+	h.service.Serve(o, kind, conn)
+}
+
+func overlayID(c *gin.Context) (int64, bool) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		logger.Error().Err(err).Str("id", idStr).Msg("invalid id")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return 0, false
+	}
+	return id, true
+}
+
+func overlayErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+		return
+	}
+	app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+}
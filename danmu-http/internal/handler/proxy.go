@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"danmu-http/internal/app"
+	"danmu-http/internal/service"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"danmu-http/rpc"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProxyHandler struct {
+	service service.ProxyService
+}
+
+func NewProxyHandler(s service.ProxyService) *ProxyHandler {
+	return &ProxyHandler{service: s}
+}
+
+func (h *ProxyHandler) List(c *gin.Context) {
+	proxies, err := h.service.ListProxies(c.Request.Context())
+	if err != nil {
+		logger.Error().Err(err).Msg("list proxies failed")
+		httpCode, code := rpc.ErrorCode(err)
+		app.NewGin(c).Response(httpCode, code, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, proxies)
+}
+
+func (h *ProxyHandler) Add(c *gin.Context) {
+	var req validate.ProxyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	status, err := h.service.AddProxy(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Msg("add proxy failed")
+		httpCode, code := rpc.ErrorCode(err)
+		app.NewGin(c).Response(httpCode, code, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, status)
+}
+
+func (h *ProxyHandler) Remove(c *gin.Context) {
+	var req validate.ProxyRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	if err := h.service.RemoveProxy(c.Request.Context(), &req); err != nil {
+		logger.Error().Err(err).Msg("remove proxy failed")
+		httpCode, code := rpc.ErrorCode(err)
+		app.NewGin(c).Response(httpCode, code, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, nil)
+}
+
+func (h *ProxyHandler) Rotate(c *gin.Context) {
+	var req validate.ProxyRotateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	proxy, err := h.service.RotateProxy(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", req.ID).Msg("rotate proxy failed")
+		httpCode, code := rpc.ErrorCode(err)
+		app.NewGin(c).Response(httpCode, code, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{"proxy": proxy})
+}
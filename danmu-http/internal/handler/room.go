@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"danmu-http/internal/app"
+	"danmu-http/internal/service"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RoomHandler struct {
+	service service.RoomService
+}
+
+func NewRoomHandler(s service.RoomService) *RoomHandler {
+	return &RoomHandler{service: s}
+}
+
+func (h *RoomHandler) Latest(c *gin.Context) {
+	roomDisplayId := c.Param("room_display_id")
+	snapshot, err := h.service.GetLatestSnapshot(c.Request.Context(), roomDisplayId)
+	if err != nil {
+		logger.Error().Err(err).Str("room_display_id", roomDisplayId).Msg("get latest room snapshot failed")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+			return
+		}
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, snapshot)
+}
+
+func (h *RoomHandler) ListSnapshots(c *gin.Context) {
+	roomDisplayId := c.Param("room_display_id")
+	var req validate.RoomSnapshotQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	snapshots, total, err := h.service.ListSnapshots(c.Request.Context(), roomDisplayId, &req)
+	if err != nil {
+		logger.Error().Err(err).Str("room_display_id", roomDisplayId).Msg("list room snapshots failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"total": total,
+		"list":  snapshots,
+	})
+}
+
+func (h *RoomHandler) ListTitles(c *gin.Context) {
+	roomDisplayId := c.Param("room_display_id")
+	titles, err := h.service.ListTitles(c.Request.Context(), roomDisplayId)
+	if err != nil {
+		logger.Error().Err(err).Str("room_display_id", roomDisplayId).Msg("list room titles failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"list": titles,
+	})
+}
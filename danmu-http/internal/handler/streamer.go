@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"danmu-http/internal/app"
+	"danmu-http/internal/service"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StreamerHandler struct {
+	service service.StreamerService
+}
+
+func NewStreamerHandler(s service.StreamerService) *StreamerHandler {
+	return &StreamerHandler{service: s}
+}
+
+func (h *StreamerHandler) List(c *gin.Context) {
+	var req validate.StreamerSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	streamers, total, err := h.service.SearchStreamer(c.Request.Context(), &req)
+	if err != nil {
+		logger.Error().Err(err).Str("keyword", req.Keyword).Msg("search streamer failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"total": total,
+		"list":  streamers,
+	})
+}
+
+func (h *StreamerHandler) Get(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	streamer, err := h.service.GetStreamer(c.Request.Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("get streamer failed")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+			return
+		}
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, streamer)
+}
+
+func (h *StreamerHandler) ListSessions(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	var req validate.StreamerSessionRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	sessions, total, err := h.service.ListSessions(c.Request.Context(), id, &req)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("list streamer sessions failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"total": total,
+		"list":  sessions,
+	})
+}
+
+func (h *StreamerHandler) ListTitles(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	titles, err := h.service.ListTitles(c.Request.Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Int64("id", id).Msg("list streamer titles failed")
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, gin.H{
+		"list": titles,
+	})
+}
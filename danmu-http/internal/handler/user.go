@@ -5,9 +5,12 @@ import (
 	"danmu-http/internal/service"
 	"danmu-http/internal/validate"
 	"danmu-http/logger"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type UserHandler struct {
@@ -58,3 +61,60 @@ func (h *UserHandler) SearchUser(c *gin.Context) {
 		"list":  users,
 	})
 }
+
+func (h *UserHandler) GetUser(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Uint64("user_id", userID).Msg("get user failed")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+			return
+		}
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, user)
+}
+
+func (h *UserHandler) GetProfile(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var req validate.UserProfileRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error().Err(err).Msg("bind request failed")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return
+	}
+
+	profile, err := h.service.GetProfile(c.Request.Context(), userID, &req)
+	if err != nil {
+		logger.Error().Err(err).Uint64("user_id", userID).Msg("get user profile failed")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			app.NewGin(c).Response(http.StatusNotFound, app.NotFound, nil)
+			return
+		}
+		app.NewGin(c).Response(http.StatusInternalServerError, app.ERROR, nil)
+		return
+	}
+
+	app.NewGin(c).Response(http.StatusOK, app.SUCCESS, profile)
+}
+
+func parseUserID(c *gin.Context) (uint64, bool) {
+	idStr := c.Param("user_id")
+	userID, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", idStr).Msg("invalid user_id")
+		app.NewGin(c).Response(http.StatusBadRequest, app.InvalidParams, nil)
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"danmu-http/internal/validate"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TableNameMessageArchive  = "message_archives"
+	TableNameRetentionPolicy = "retention_policies"
+)
+
+// MessageArchive mapped from table <message_archives>
+// danmu-core 归档并从数据库删除的消息文件
+type MessageArchive struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	MessageTable  string `gorm:"column:message_table;not null" json:"message_table"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	Month         int64  `gorm:"column:month;not null" json:"month"`
+	Path          string `gorm:"column:path;not null" json:"path"` // 相对归档目录的路径
+	Rows          int64  `gorm:"column:rows;not null" json:"rows"`
+	Size          int64  `gorm:"column:size;not null" json:"size"`
+	Begin         int64  `gorm:"column:begin;not null" json:"begin"`
+	End           int64  `gorm:"column:end;not null" json:"end"`
+	CreatedOn     int64  `gorm:"column:created_on" json:"created_on"`
+}
+
+// TableName MessageArchive's table name
+func (*MessageArchive) TableName() string {
+	return TableNameMessageArchive
+}
+
+// RetentionPolicy mapped from table <retention_policies>
+type RetentionPolicy struct {
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	RetainDays    int    `gorm:"column:retain_days;not null" json:"retain_days"` // 0 表示永久保留
+	ModifiedOn    int64  `gorm:"column:modified_on" json:"modified_on"`
+	ModifiedBy    string `gorm:"column:modified_by" json:"modified_by"`
+}
+
+// TableName RetentionPolicy's table name
+func (*RetentionPolicy) TableName() string {
+	return TableNameRetentionPolicy
+}
+
+func GetMessageArchivesPage(req *validate.ArchiveListRequest) ([]*MessageArchive, int64, error) {
+	var archives []*MessageArchive
+	var total int64
+	db := DB.Model(&MessageArchive{})
+	if req.MessageTable != "" {
+		db = db.Where("message_table = ?", req.MessageTable)
+	}
+	if req.RoomDisplayId != "" {
+		db = db.Where("room_display_id = ?", req.RoomDisplayId)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Order("month desc, id desc").
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&archives).Error
+	return archives, total, err
+}
+
+// FindMessageArchives 返回直播间与 [begin, end] 有交集的归档文件，按时间顺序，begin、end 为 0 时不限制
+func FindMessageArchives(table, roomDisplayId string, begin, end int64) ([]*MessageArchive, error) {
+	var archives []*MessageArchive
+	db := DB.Where("message_table = ? AND room_display_id = ?", table, roomDisplayId)
+	if begin != 0 {
+		db = db.Where(`"end" >= ?`, begin)
+	}
+	if end != 0 {
+		db = db.Where("begin <= ?", end)
+	}
+	err := db.Order("begin, id").Find(&archives).Error
+	return archives, err
+}
+
+func GetRetentionPolicies() ([]*RetentionPolicy, error) {
+	var policies []*RetentionPolicy
+	err := DB.Order("room_display_id").Find(&policies).Error
+	return policies, err
+}
+
+func (model *RetentionPolicy) Upsert() error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_display_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"retain_days", "modified_on", "modified_by"}),
+	}).Create(model).Error
+}
+
+// DeleteRetentionPolicy 删除后该直播间使用 danmu-core 配置中的默认保留天数
+func DeleteRetentionPolicy(roomDisplayId string) (int64, error) {
+	result := DB.Where("room_display_id = ?", roomDisplayId).Delete(&RetentionPolicy{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"danmu-http/internal/validate"
+	"encoding/json"
+)
+
+const TableNameChatFlag = "chat_flags"
+
+// ChatFlag mapped from table <chat_flags>
+// danmu-core 检测到的刷屏或疑似机器人用户，每个直播间每个用户一条
+type ChatFlag struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	UserName      string `gorm:"column:user_name" json:"user_name"`
+	UserDisplayId string `gorm:"column:user_display_id" json:"user_display_id"`
+	StreamerID    int64  `gorm:"column:streamer_id;default:0" json:"streamer_id"`
+	MaxScore      int    `gorm:"column:max_score;not null" json:"max_score"`
+	LastScore     int    `gorm:"column:last_score;not null" json:"last_score"`
+	Reasons       string `gorm:"column:reasons" json:"reasons"` // 最近一次标记命中的信号，逗号分隔
+	FlaggedCount  int64  `gorm:"column:flagged_count;not null" json:"flagged_count"`
+	ExcludedCount int64  `gorm:"column:excluded_count;not null" json:"excluded_count"`
+	Evidence      string `gorm:"column:evidence" json:"-"`
+	FirstFlagged  int64  `gorm:"column:first_flagged;not null" json:"first_flagged"`
+	LastFlagged   int64  `gorm:"column:last_flagged;not null" json:"last_flagged"`
+
+	EvidenceJSON json.RawMessage `gorm:"-" json:"evidence,omitempty"` // 最近一次标记的依据
+}
+
+// TableName ChatFlag's table name
+func (*ChatFlag) TableName() string {
+	return TableNameChatFlag
+}
+
+// FillEvidence 将 evidence 列作为 json 输出
+func (f *ChatFlag) FillEvidence() {
+	if f.Evidence != "" && json.Valid([]byte(f.Evidence)) {
+		f.EvidenceJSON = json.RawMessage(f.Evidence)
+	}
+}
+
+// GetChatFlagsPage 按最近一次标记时间倒序
+func GetChatFlagsPage(req *validate.ChatFlagListRequest) ([]*ChatFlag, int64, error) {
+	var flags []*ChatFlag
+	var total int64
+	db := DB.Model(&ChatFlag{})
+	if req.RoomDisplayId != "" {
+		db = db.Where("room_display_id = ?", req.RoomDisplayId)
+	}
+	if req.Reason != "" {
+		db = db.Where("reasons LIKE ?", "%"+req.Reason+"%")
+	}
+	if req.MinScore > 0 {
+		db = db.Where("max_score >= ?", req.MinScore)
+	}
+	if req.Begin != 0 {
+		db = db.Where("last_flagged >= ?", req.Begin)
+	}
+	if req.End != 0 {
+		db = db.Where("last_flagged <= ?", req.End)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Order("last_flagged desc").
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&flags).Error
+	return flags, total, err
+}
+
+func GetChatFlag(roomDisplayId string, userID uint64) (*ChatFlag, error) {
+	var flag ChatFlag
+	return &flag, DB.Where("room_display_id = ? AND user_id = ?", roomDisplayId, userID).First(&flag).Error
+}
+
+// DeleteChatFlag 忽略标记，用户再次被检测到时重新记录
+func DeleteChatFlag(roomDisplayId string, userID uint64) (int64, error) {
+	result := DB.Where("room_display_id = ? AND user_id = ?", roomDisplayId, userID).Delete(&ChatFlag{})
+	return result.RowsAffected, result.Error
+}
@@ -16,6 +16,7 @@ type CommonMessage struct {
 	UserDisplayId string `gorm:"column:user_display_id;not null" json:"user_display_id"`
 	Content       string `gorm:"column:content;not null" json:"content"`
 	Timestamp     int64  `gorm:"column:timestamp;not null" json:"timestamp"`
+	StreamerID    int64  `gorm:"column:streamer_id" json:"streamer_id"`
 }
 
 // TableName CommonMessage's table name
@@ -41,6 +42,10 @@ func GetCommonMessageWithConditionPage(req *validate.CommonMessageQuery) ([]*Com
 		db = db.Where("room_display_id = ?", req.RoomDisplayId)
 	}
 
+	if req.StreamerID != 0 {
+		db = db.Where("streamer_id = ?", req.StreamerID)
+	}
+
 	if req.Begin != 0 {
 		db = db.Where("timestamp >= ?", req.Begin)
 	}
@@ -50,7 +55,7 @@ func GetCommonMessageWithConditionPage(req *validate.CommonMessageQuery) ([]*Com
 	}
 
 	if req.Search != "" {
-		db = db.Where("content LIKE ?", "%"+req.Search+"%")
+		db = db.Where(`content LIKE ? ESCAPE '\'`, likeContains(req.Search))
 	}
 
 	err := db.Count(&total).Error
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"danmu-http/internal/validate"
+
+	"gorm.io/gorm"
+)
+
+const TableNameCoverageHole = "coverage_holes"
+
+// CoverageHole mapped from table <coverage_holes>
+// danmu-core 重连后未能通过 cursor 补发的时间范围，范围内的消息可能缺失
+type CoverageHole struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	ConfID        int64  `gorm:"column:conf_id;not null" json:"conf_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	RoomID        string `gorm:"column:room_id;not null" json:"room_id"`
+	StartTime     int64  `gorm:"column:start_time;not null" json:"start_time"`
+	EndTime       int64  `gorm:"column:end_time;not null" json:"end_time"`
+	Reason        string `gorm:"column:reason;not null" json:"reason"`
+	CreatedAt     int64  `gorm:"column:created_at;not null" json:"created_at"`
+}
+
+// TableName CoverageHole's table name
+func (*CoverageHole) TableName() string {
+	return TableNameCoverageHole
+}
+
+// GetCoverageHolesPage 返回与 [begin, end] 重叠的缺口，按开始时间倒序，duration 为所有匹配缺口的总时长 (毫秒)
+func GetCoverageHolesPage(req *validate.CoverageHoleListRequest) (holes []*CoverageHole, total int64, duration int64, err error) {
+	db := DB.Model(&CoverageHole{})
+	if req.RoomDisplayId != "" {
+		db = db.Where("room_display_id = ?", req.RoomDisplayId)
+	}
+	if req.RoomID != "" {
+		db = db.Where("room_id = ?", req.RoomID)
+	}
+	if req.Begin != 0 {
+		db = db.Where("end_time >= ?", req.Begin)
+	}
+	if req.End != 0 {
+		db = db.Where("start_time <= ?", req.End)
+	}
+	var sum struct {
+		Total    int64
+		Duration int64
+	}
+	if err = db.Session(&gorm.Session{}).
+		Select("COUNT(*) AS total, COALESCE(SUM(end_time - start_time), 0) AS duration").
+		Scan(&sum).Error; err != nil {
+		return nil, 0, 0, err
+	}
+	err = db.Order("start_time desc").
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&holes).Error
+	return holes, sum.Total, sum.Duration, err
+}
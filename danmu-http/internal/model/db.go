@@ -6,28 +6,43 @@ import (
 	"danmu-http/utils"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// [database] Type 支持的数据库
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+const defaultSQLitePath = "../data/danmu.db"
+
 var DB *gorm.DB
 
 func Init() {
-	var dsn = fmt.Sprintf("user=%s password=%s host=%s dbname=%s port=%s sslmode=disable search_path=%s",
-		setting.DatabaseSetting.User,
-		setting.DatabaseSetting.Password,
-		setting.DatabaseSetting.Host,
-		setting.DatabaseSetting.DBName,
-		setting.DatabaseSetting.Port,
-		setting.DatabaseSetting.SearchPath)
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	dialector, err := openDialector()
+	if err != nil {
+		log.Fatalf("db.Setup failure: %v", err)
+		return
+	}
+	DB, err = gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		log.Fatalf("db.Setup failure: %v", err)
 		return
 	}
+	// 消息等表由 danmu-core 创建，sqlite 下这里只创建 danmu-http 自己使用的表
+	if IsSQLite() {
+		if err := DB.AutoMigrate(&Auth{}, &Overlay{}); err != nil {
+			log.Fatalf("db.Migrate failure: %v", err)
+		}
+	}
 
 	sqlDB, err := DB.DB()
 	if err != nil {
@@ -46,6 +61,44 @@ func Init() {
 	}
 }
 
+func openDialector() (gorm.Dialector, error) {
+	switch setting.DatabaseSetting.Type {
+	case "", DialectPostgres:
+		var dsn = fmt.Sprintf("user=%s password=%s host=%s dbname=%s port=%s sslmode=disable search_path=%s",
+			setting.DatabaseSetting.User,
+			setting.DatabaseSetting.Password,
+			setting.DatabaseSetting.Host,
+			setting.DatabaseSetting.DBName,
+			setting.DatabaseSetting.Port,
+			setting.DatabaseSetting.SearchPath)
+		return postgres.Open(dsn), nil
+	case DialectSQLite:
+		path := setting.DatabaseSetting.Path
+		if path == "" {
+			path = defaultSQLitePath
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("create sqlite dir error: %w", err)
+		}
+		// 与 danmu-core 同时读写同一个文件，使用 WAL 并在锁冲突时等待
+		return sqlite.Open(path + "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate"), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type %q", setting.DatabaseSetting.Type)
+	}
+}
+
+// IsSQLite 当前是否使用 sqlite
+func IsSQLite() bool {
+	return DB.Dialector.Name() == DialectSQLite
+}
+
+// likeContains 返回包含 keyword 的 LIKE 模式，转义其中的通配符，配合 ESCAPE '\' 使用
+func likeContains(keyword string) string {
+	return "%" + likeEscaper.Replace(keyword) + "%"
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 func initDefaultAdmin() error {
 
 	// 创建默认管理员账户
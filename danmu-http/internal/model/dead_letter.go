@@ -0,0 +1,84 @@
+package model
+
+import "danmu-http/internal/validate"
+
+const TableNameDeadLetter = "dead_letters"
+
+// DeadLetter mapped from table <dead_letters>
+// danmu-core 中 handler 重试耗尽或无法解析的原始消息
+type DeadLetter struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	ConfID        int64  `gorm:"column:conf_id;not null" json:"conf_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	Handler       string `gorm:"column:handler;not null" json:"handler"`
+	Method        string `gorm:"column:method" json:"method"`
+	MsgID         uint64 `gorm:"column:msg_id" json:"msg_id"`
+	Payload       []byte `gorm:"column:payload;not null" json:"payload,omitempty"` // protobuf 编码的 Webcast_Im_Message，列表中不返回
+	Error         string `gorm:"column:error" json:"error"`
+	Attempts      int    `gorm:"column:attempts;not null" json:"attempts"`
+	Replays       int    `gorm:"column:replays;not null" json:"replays"`
+	FirstFailed   int64  `gorm:"column:first_failed;not null" json:"first_failed"`
+	LastFailed    int64  `gorm:"column:last_failed;not null" json:"last_failed"`
+}
+
+// TableName DeadLetter's table name
+func (*DeadLetter) TableName() string {
+	return TableNameDeadLetter
+}
+
+// GetDeadLettersPage 按最近一次失败时间倒序，不查询 payload
+func GetDeadLettersPage(req *validate.DeadLetterListRequest) ([]*DeadLetter, int64, error) {
+	var letters []*DeadLetter
+	var total int64
+	db := DB.Model(&DeadLetter{})
+	if req.RoomDisplayId != "" {
+		db = db.Where("room_display_id = ?", req.RoomDisplayId)
+	}
+	if req.Handler != "" {
+		db = db.Where("handler = ?", req.Handler)
+	}
+	if req.Method != "" {
+		db = db.Where("method = ?", req.Method)
+	}
+	if req.Begin != 0 {
+		db = db.Where("last_failed >= ?", req.Begin)
+	}
+	if req.End != 0 {
+		db = db.Where("last_failed <= ?", req.End)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Omit("payload").
+		Order("last_failed desc").
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&letters).Error
+	return letters, total, err
+}
+
+func GetDeadLetter(id int64) (*DeadLetter, error) {
+	var letter DeadLetter
+	return &letter, DB.Where("id = ?", id).First(&letter).Error
+}
+
+func DeleteDeadLetter(id int64) (int64, error) {
+	result := DB.Where("id = ?", id).Delete(&DeadLetter{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeDeadLetters 删除 before 之前最后一次失败的死信，可按直播间、handler 和消息类型过滤
+func PurgeDeadLetters(req *validate.DeadLetterPurgeRequest) (int64, error) {
+	db := DB.Where("last_failed < ?", req.Before)
+	if req.RoomDisplayId != "" {
+		db = db.Where("room_display_id = ?", req.RoomDisplayId)
+	}
+	if req.Handler != "" {
+		db = db.Where("handler = ?", req.Handler)
+	}
+	if req.Method != "" {
+		db = db.Where("method = ?", req.Method)
+	}
+	result := db.Delete(&DeadLetter{})
+	return result.RowsAffected, result.Error
+}
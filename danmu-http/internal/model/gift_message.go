@@ -23,6 +23,7 @@ type GiftMessage struct {
 	DiamondCount    uint32 `gorm:"column:diamond_count;not null" json:"diamond_count"`
 	Image           string `gorm:"column:image_url" json:"image_url"`
 	ComboCount      string `gorm:"column:combo_count" json:"combo_count"`
+	StreamerID      int64  `gorm:"column:streamer_id" json:"streamer_id"`
 }
 
 type ToUser struct {
@@ -39,7 +40,8 @@ func GetToUsersByRoomDisplayId(roomDisplayId string) ([]*ToUser, error) {
 	var toUsers []*ToUser
 	err := DB.Model(&GiftMessage{}).
 		Where("room_display_id = ? AND to_user_id != ?", roomDisplayId, 0).
-		Select("DISTINCT ON (to_user_id) to_user_id, to_user_name, to_user_display_id").
+		Select("to_user_id, MAX(to_user_name) AS to_user_name, MAX(to_user_display_id) AS to_user_display_id").
+		Group("to_user_id").
 		Find(&toUsers).Error
 	if err != nil {
 		return nil, err
@@ -72,8 +74,11 @@ func GetGiftMessageWithConditionPage(req *validate.GiftMessageQuery) ([]*GiftMes
 	if req.RoomDisplayId != "" {
 		db = db.Where("room_display_id = ?", req.RoomDisplayId)
 	}
+	if req.StreamerID != 0 {
+		db = db.Where("streamer_id = ?", req.StreamerID)
+	}
 	if req.Search != "" {
-		db = db.Where("message LIKE ?", "%"+req.Search+"%")
+		db = db.Where(`message LIKE ? ESCAPE '\'`, likeContains(req.Search))
 	}
 
 	if req.OrderBy == "" {
@@ -1,6 +1,10 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
 
 const TableNameLiveConf = "live_confs"
 
@@ -15,6 +19,9 @@ type LiveConf struct {
 	ModifiedBy    string `gorm:"column:modified_by" json:"modified_by"`
 	CratedBy      string `gorm:"column:crated_by" json:"crated_by"`
 	Enable        bool   `gorm:"column:enable;not null;" json:"enable"`
+	Cron          string `gorm:"column:cron" json:"cron"`
+	Handlers      string `gorm:"column:handlers" json:"handlers"` // 逗号分隔的 handler 名称
+	StreamerID    int64  `gorm:"column:streamer_id" json:"streamer_id"`
 }
 
 // TableName LiveConf's table name
@@ -22,6 +29,17 @@ func (*LiveConf) TableName() string {
 	return TableNameLiveConf
 }
 
+// HandlerNames 解析 Handlers 字段
+func (conf *LiveConf) HandlerNames() []string {
+	var names []string
+	for _, name := range strings.Split(conf.Handlers, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func (conf *LiveConf) Insert(db *gorm.DB) error {
 	return db.Create(conf).Error
 }
@@ -39,6 +57,11 @@ func GetLiveConfById(id int64) (*LiveConf, error) {
 	return &conf, DB.Where("id = ?", id).First(&conf).Error
 }
 
+func GetLiveConfsByStreamerID(streamerID int64) ([]*LiveConf, error) {
+	var confs []*LiveConf
+	return confs, DB.Where("streamer_id = ?", streamerID).Find(&confs).Error
+}
+
 func GetAllLiveConf() ([]*LiveConf, error) {
 	var confs []*LiveConf
 	return confs, DB.Find(&confs).Error
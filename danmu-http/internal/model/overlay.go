@@ -0,0 +1,68 @@
+package model
+
+const TableNameOverlay = "overlays"
+
+// Overlay mapped from table <overlays>
+// OBS 浏览器源使用的直播间叠加层，通过 token 访问，不需要登录
+type Overlay struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	Name          string `gorm:"column:name" json:"name"`
+	Token         string `gorm:"column:token;not null;uniqueIndex" json:"token"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null;index" json:"room_display_id"`
+	Theme         string `gorm:"column:theme" json:"theme"`           // 预设主题，见 overlay.Themes
+	CustomCSS     string `gorm:"column:custom_css" json:"custom_css"` // 追加在主题之后的样式
+	MinDiamond    int64  `gorm:"column:min_diamond" json:"min_diamond"`
+	AlertDuration int    `gorm:"column:alert_duration" json:"alert_duration"` // 秒
+	AlertQueue    int    `gorm:"column:alert_queue" json:"alert_queue"`
+	ChatLimit     int    `gorm:"column:chat_limit" json:"chat_limit"`
+	ShowMember    bool   `gorm:"column:show_member" json:"show_member"`
+	TopCount      int    `gorm:"column:top_count" json:"top_count"`
+	GoalTitle     string `gorm:"column:goal_title" json:"goal_title"`
+	GoalType      string `gorm:"column:goal_type" json:"goal_type"` // diamond 或 gift
+	GoalGiftID    int64  `gorm:"column:goal_gift_id" json:"goal_gift_id"`
+	GoalTarget    int64  `gorm:"column:goal_target" json:"goal_target"`
+	ResetAt       int64  `gorm:"column:reset_at" json:"reset_at"` // 毫秒，礼物榜和目标从该时间开始统计
+	CreatedOn     int64  `gorm:"column:created_on" json:"created_on"`
+	ModifiedOn    int64  `gorm:"column:modified_on" json:"modified_on"`
+	ModifiedBy    string `gorm:"column:modified_by" json:"modified_by"`
+
+	URLs map[string]string `gorm:"-" json:"urls"` // 各页面的地址
+}
+
+// TableName Overlay's table name
+func (*Overlay) TableName() string {
+	return TableNameOverlay
+}
+
+func (o *Overlay) Insert() error {
+	return DB.Create(o).Error
+}
+
+func (o *Overlay) Update() error {
+	return DB.Save(o).Error
+}
+
+func DeleteOverlayById(id int64) (int64, error) {
+	result := DB.Delete(&Overlay{ID: id})
+	return result.RowsAffected, result.Error
+}
+
+func GetOverlayById(id int64) (*Overlay, error) {
+	var overlay Overlay
+	return &overlay, DB.Where("id = ?", id).First(&overlay).Error
+}
+
+func GetOverlayByToken(token string) (*Overlay, error) {
+	var overlay Overlay
+	return &overlay, DB.Where("token = ?", token).First(&overlay).Error
+}
+
+// GetOverlays roomDisplayId 为空时返回所有叠加层
+func GetOverlays(roomDisplayId string) ([]*Overlay, error) {
+	var overlays []*Overlay
+	db := DB.Order("id")
+	if roomDisplayId != "" {
+		db = db.Where("room_display_id = ?", roomDisplayId)
+	}
+	return overlays, db.Find(&overlays).Error
+}
@@ -0,0 +1,94 @@
+package model
+
+const (
+	TableNameRoomSnapshot = "room_snapshots"
+	TableNameRoomTitle    = "room_titles"
+)
+
+// RoomSnapshot mapped from table <room_snapshots>
+type RoomSnapshot struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	RoomID        int64  `gorm:"column:room_id;not null" json:"room_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	StreamerID    int64  `gorm:"column:streamer_id" json:"streamer_id"`
+	Status        int64  `gorm:"column:status" json:"status"`
+	Title         string `gorm:"column:title" json:"title"`
+	Cover         string `gorm:"column:cover" json:"cover"`
+	OwnerNickname string `gorm:"column:owner_nickname" json:"owner_nickname"`
+	OwnerAvatar   string `gorm:"column:owner_avatar" json:"owner_avatar"`
+	Category      string `gorm:"column:category" json:"category"`
+	UserCount     int64  `gorm:"column:user_count" json:"user_count"`
+	TotalUser     int64  `gorm:"column:total_user" json:"total_user"`
+	LikeCount     int64  `gorm:"column:like_count" json:"like_count"`
+	Source        string `gorm:"column:source" json:"source"`
+	Timestamp     int64  `gorm:"column:timestamp;not null" json:"timestamp"`
+}
+
+// TableName RoomSnapshot's table name
+func (*RoomSnapshot) TableName() string {
+	return TableNameRoomSnapshot
+}
+
+// RoomTitle mapped from table <room_titles>
+type RoomTitle struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	RoomID        int64  `gorm:"column:room_id;not null" json:"room_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;not null" json:"room_display_id"`
+	StreamerID    int64  `gorm:"column:streamer_id" json:"streamer_id"`
+	Title         string `gorm:"column:title;not null" json:"title"`
+	Timestamp     int64  `gorm:"column:timestamp;not null" json:"timestamp"`
+}
+
+// TableName RoomTitle's table name
+func (*RoomTitle) TableName() string {
+	return TableNameRoomTitle
+}
+
+func GetRoomSnapshotsPage(roomDisplayId string, begin, end int64, page, pageSize int) ([]*RoomSnapshot, int64, error) {
+	var snapshots []*RoomSnapshot
+	var total int64
+	db := DB.Model(&RoomSnapshot{}).Where("room_display_id = ?", roomDisplayId)
+	if begin != 0 {
+		db = db.Where("timestamp >= ?", begin)
+	}
+	if end != 0 {
+		db = db.Where("timestamp <= ?", end)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Order("timestamp desc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&snapshots).Error
+	return snapshots, total, err
+}
+
+func GetLatestRoomSnapshot(roomDisplayId string) (*RoomSnapshot, error) {
+	var snapshot RoomSnapshot
+	return &snapshot, DB.Where("room_display_id = ?", roomDisplayId).Order("timestamp desc").First(&snapshot).Error
+}
+
+// GetLatestRoomSnapshotsByRoomIds 每个 room_id 最近一次的快照
+func GetLatestRoomSnapshotsByRoomIds(roomIds []int64) ([]*RoomSnapshot, error) {
+	var snapshots []*RoomSnapshot
+	if len(roomIds) == 0 {
+		return snapshots, nil
+	}
+	err := DB.Raw("SELECT * FROM (SELECT *, ROW_NUMBER() OVER (PARTITION BY room_id ORDER BY timestamp DESC, id DESC) AS rn FROM "+
+		TableNameRoomSnapshot+" WHERE room_id IN (?)) s WHERE rn = 1", roomIds).
+		Scan(&snapshots).Error
+	return snapshots, err
+}
+
+// GetRoomTitles 标题变更记录，roomDisplayId 和 streamerID 只使用非空的一个
+func GetRoomTitles(roomDisplayId string, streamerID int64) ([]*RoomTitle, error) {
+	var titles []*RoomTitle
+	db := DB.Model(&RoomTitle{})
+	if streamerID != 0 {
+		db = db.Where("streamer_id = ?", streamerID)
+	} else {
+		db = db.Where("room_display_id = ?", roomDisplayId)
+	}
+	return titles, db.Order("timestamp desc").Find(&titles).Error
+}
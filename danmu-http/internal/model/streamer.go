@@ -0,0 +1,77 @@
+package model
+
+const TableNameStreamer = "streamers"
+
+// Streamer mapped from table <streamers>
+type Streamer struct {
+	ID         int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	SecUid     string `gorm:"column:sec_uid;not null" json:"sec_uid"`
+	Uid        string `gorm:"column:uid" json:"uid"`
+	Nickname   string `gorm:"column:nickname" json:"nickname"`
+	Avatar     string `gorm:"column:avatar" json:"avatar"`
+	DisplayID  string `gorm:"column:display_id" json:"display_id"`
+	WebRid     string `gorm:"column:web_rid" json:"web_rid"`
+	CreatedOn  int64  `gorm:"column:created_on" json:"created_on"`
+	ModifiedOn int64  `gorm:"column:modified_on" json:"modified_on"`
+}
+
+// TableName Streamer's table name
+func (*Streamer) TableName() string {
+	return TableNameStreamer
+}
+
+// StreamerSession 主播的一场直播，room_id 每次开播都会变化
+type StreamerSession struct {
+	RoomID        int64  `gorm:"column:room_id" json:"room_id"`
+	RoomDisplayId string `gorm:"column:room_display_id" json:"room_display_id"`
+	RoomName      string `gorm:"column:room_name" json:"room_name"`
+	Begin         int64  `gorm:"column:begin" json:"begin"`
+	End           int64  `gorm:"column:end" json:"end"`
+	MessageCount  int64  `gorm:"column:message_count" json:"message_count"`
+	Title         string `gorm:"-" json:"title"` // 最近一次快照中的标题
+	Cover         string `gorm:"-" json:"cover"`
+	Category      string `gorm:"-" json:"category"`
+}
+
+func GetStreamerById(id int64) (*Streamer, error) {
+	var streamer Streamer
+	return &streamer, DB.Where("id = ?", id).First(&streamer).Error
+}
+
+func SearchStreamer(page int, pageSize int, keyword string) ([]*Streamer, int64, error) {
+	var streamers []*Streamer
+	var total int64
+	db := DB.Model(&Streamer{})
+	if keyword != "" {
+		like := likeContains(keyword)
+		db = db.Where(`nickname LIKE ? ESCAPE '\' OR display_id LIKE ? ESCAPE '\' OR web_rid = ?`, like, like, keyword)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Order("id desc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&streamers).Error
+	return streamers, total, err
+}
+
+// GetStreamerSessionsPage 按 room_id 聚合主播在所有直播间的消息
+func GetStreamerSessionsPage(streamerID int64, page int, pageSize int) ([]*StreamerSession, int64, error) {
+	var sessions []*StreamerSession
+	var total int64
+	db := DB.Model(&CommonMessage{}).Where("streamer_id = ?", streamerID)
+	if err := db.Distinct("room_id").Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := DB.Model(&CommonMessage{}).
+		Select("room_id, MAX(room_display_id) AS room_display_id, MAX(room_name) AS room_name, "+
+			"MIN(timestamp) AS begin, MAX(timestamp) AS \"end\", COUNT(*) AS message_count").
+		Where("streamer_id = ?", streamerID).
+		Group("room_id").
+		Order("begin desc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Scan(&sessions).Error
+	return sessions, total, err
+}
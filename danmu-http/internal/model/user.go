@@ -1,13 +1,24 @@
 package model
 
-const TableNameUser = "users"
+const (
+	TableNameUser            = "users"
+	TableNameUserNameHistory = "user_name_history"
+)
 
 // User mapped from table <users>
 type User struct {
-	ID        uint64 `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
-	UserID    uint64 `gorm:"column:user_id;not null;index" json:"user_id"`
-	DisplayID string `gorm:"column:display_id;not null" json:"display_id"`
-	UserName  string `gorm:"column:user_name;not null" json:"user_name"`
+	ID             uint64 `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	UserID         uint64 `gorm:"column:user_id;not null;uniqueIndex" json:"user_id"`
+	DisplayID      string `gorm:"column:display_id;not null" json:"display_id"`
+	UserName       string `gorm:"column:user_name;not null" json:"user_name"`
+	SecUid         string `gorm:"column:sec_uid" json:"sec_uid"`
+	Avatar         string `gorm:"column:avatar" json:"avatar"`
+	Gender         int32  `gorm:"column:gender" json:"gender"`
+	PayGrade       uint64 `gorm:"column:pay_grade" json:"pay_grade"`
+	FollowerCount  uint64 `gorm:"column:follower_count" json:"follower_count"`
+	FollowingCount uint64 `gorm:"column:following_count" json:"following_count"`
+	FirstSeen      int64  `gorm:"column:first_seen" json:"first_seen"`
+	LastSeen       int64  `gorm:"column:last_seen" json:"last_seen"`
 }
 
 // TableName User's table name
@@ -15,6 +26,20 @@ func (*User) TableName() string {
 	return TableNameUser
 }
 
+// UserNameHistory mapped from table <user_name_history>
+type UserNameHistory struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	UserID    uint64 `gorm:"column:user_id;not null;index" json:"user_id"`
+	UserName  string `gorm:"column:user_name;not null" json:"user_name"`
+	DisplayID string `gorm:"column:display_id;not null" json:"display_id"`
+	Timestamp int64  `gorm:"column:timestamp;not null" json:"timestamp"`
+}
+
+// TableName UserNameHistory's table name
+func (*UserNameHistory) TableName() string {
+	return TableNameUserNameHistory
+}
+
 func GetAllUsersPage(page int, pageSize int) ([]*User, int64, error) {
 	var users []*User
 	var total int64
@@ -25,25 +50,28 @@ func GetAllUsersPage(page int, pageSize int) ([]*User, int64, error) {
 	}
 
 	// Apply ordering and pagination
-	err = db.Order("user_id desc").
+	err = db.Order("last_seen desc nulls last, user_id desc").
 		Offset((page - 1) * pageSize).
 		Limit(pageSize).
 		Find(&users).Error
 	return users, total, err
 }
 
+// SearchUser 按昵称或抖音号搜索，曾用名同样可以匹配
 func SearchUser(page int, pageSize int, keyword string) ([]*User, int64, error) {
 	var users []*User
 	var total int64
 	db := DB.Model(&User{})
+	if keyword != "" {
+		like := likeContains(keyword)
+		db = db.Where(`user_name LIKE ? ESCAPE '\' or display_id LIKE ? ESCAPE '\' or user_id IN (?)`, like, like,
+			DB.Model(&UserNameHistory{}).Select("user_id").Where(`user_name LIKE ? ESCAPE '\' or display_id LIKE ? ESCAPE '\'`, like, like))
+	}
 	err := db.Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
-	if keyword != "" {
-		db.Where("user_name LIKE ? or display_id LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
-	}
-	err = db.Order("user_id desc").
+	err = db.Order("last_seen desc nulls last, user_id desc").
 		Offset((page - 1) * pageSize).
 		Limit(pageSize).
 		Find(&users).Error
@@ -52,3 +80,19 @@ func SearchUser(page int, pageSize int, keyword string) ([]*User, int64, error)
 	}
 	return users, total, nil
 }
+
+func GetUserByUserID(userID uint64) (*User, error) {
+	var user User
+	err := DB.Where("user_id = ?", userID).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserNameHistory 按时间倒序返回用户的昵称和抖音号变更记录
+func GetUserNameHistory(userID uint64) ([]*UserNameHistory, error) {
+	var history []*UserNameHistory
+	err := DB.Where("user_id = ?", userID).Order("timestamp desc").Find(&history).Error
+	return history, err
+}
@@ -0,0 +1,196 @@
+package model
+
+import "sort"
+
+const (
+	TableNameUserRoomStat      = "user_room_stats"
+	TableNameUserGiftStat      = "user_gift_stats"
+	TableNameUserDailyActivity = "user_daily_activity"
+	TableNameUserFansClub      = "user_fans_clubs"
+)
+
+// UserRoomStat mapped from table <user_room_stats>
+type UserRoomStat struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	RoomName      string `gorm:"column:room_name" json:"room_name"`
+	StreamerID    int64  `gorm:"column:streamer_id" json:"streamer_id"`
+	ChatCount     int64  `gorm:"column:chat_count" json:"chat_count"`
+	GiftCount     int64  `gorm:"column:gift_count" json:"gift_count"`
+	DiamondTotal  int64  `gorm:"column:diamond_total" json:"diamond_total"`
+	FirstSeen     int64  `gorm:"column:first_seen" json:"first_seen"`
+	LastSeen      int64  `gorm:"column:last_seen" json:"last_seen"`
+}
+
+// TableName UserRoomStat's table name
+func (*UserRoomStat) TableName() string {
+	return TableNameUserRoomStat
+}
+
+// UserGiftStat mapped from table <user_gift_stats>
+type UserGiftStat struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	ToUserID      uint64 `gorm:"column:to_user_id;primaryKey" json:"to_user_id"`
+	GiftID        int64  `gorm:"column:gift_id;primaryKey" json:"gift_id"`
+	ToUserName    string `gorm:"column:to_user_name" json:"to_user_name"`
+	GiftName      string `gorm:"column:gift_name" json:"gift_name"`
+	Image         string `gorm:"column:image_url" json:"image_url"`
+	GiftCount     int64  `gorm:"column:gift_count" json:"gift_count"`
+	DiamondTotal  int64  `gorm:"column:diamond_total" json:"diamond_total"`
+	LastSent      int64  `gorm:"column:last_sent" json:"last_sent"`
+}
+
+// TableName UserGiftStat's table name
+func (*UserGiftStat) TableName() string {
+	return TableNameUserGiftStat
+}
+
+// UserDailyActivity mapped from table <user_daily_activity>
+type UserDailyActivity struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	RoomDisplayId string `gorm:"column:room_display_id;primaryKey" json:"room_display_id"`
+	Day           int64  `gorm:"column:day;primaryKey" json:"day"`
+	ChatCount     int64  `gorm:"column:chat_count" json:"chat_count"`
+	GiftCount     int64  `gorm:"column:gift_count" json:"gift_count"`
+	DiamondTotal  int64  `gorm:"column:diamond_total" json:"diamond_total"`
+	FirstSeen     int64  `gorm:"column:first_seen" json:"first_seen"`
+	LastSeen      int64  `gorm:"column:last_seen" json:"last_seen"`
+}
+
+// TableName UserDailyActivity's table name
+func (*UserDailyActivity) TableName() string {
+	return TableNameUserDailyActivity
+}
+
+// UserFansClub mapped from table <user_fans_clubs>
+type UserFansClub struct {
+	UserID        uint64 `gorm:"column:user_id;primaryKey" json:"user_id"`
+	AnchorID      uint64 `gorm:"column:anchor_id;primaryKey" json:"anchor_id"`
+	ClubName      string `gorm:"column:club_name" json:"club_name"`
+	Level         int32  `gorm:"column:level" json:"level"`
+	RoomDisplayId string `gorm:"column:room_display_id" json:"room_display_id"`
+	StreamerID    int64  `gorm:"column:streamer_id" json:"streamer_id"`
+	FirstSeen     int64  `gorm:"column:first_seen" json:"first_seen"`
+	LastSeen      int64  `gorm:"column:last_seen" json:"last_seen"`
+}
+
+// TableName UserFansClub's table name
+func (*UserFansClub) TableName() string {
+	return TableNameUserFansClub
+}
+
+// UserRecipientStat 用户在直播间送给某个接收者的礼物合计
+type UserRecipientStat struct {
+	RoomDisplayId string `json:"room_display_id"`
+	ToUserID      uint64 `json:"to_user_id"`
+	ToUserName    string `json:"to_user_name"`
+	GiftCount     int64  `json:"gift_count"`
+	DiamondTotal  int64  `json:"diamond_total"`
+	LastSent      int64  `json:"last_sent"`
+}
+
+// UserTopGift 用户在所有直播间送出的某种礼物合计
+type UserTopGift struct {
+	GiftID       int64  `json:"gift_id"`
+	GiftName     string `json:"gift_name"`
+	Image        string `json:"image_url"`
+	GiftCount    int64  `json:"gift_count"`
+	DiamondTotal int64  `json:"diamond_total"`
+}
+
+func GetUserRoomStats(userID uint64) ([]*UserRoomStat, error) {
+	var stats []*UserRoomStat
+	err := DB.Where("user_id = ?", userID).Order("last_seen desc").Find(&stats).Error
+	return stats, err
+}
+
+// getUserGiftStats 按 last_sent 倒序，合计时第一条记录的名称即为最近使用的名称
+func getUserGiftStats(userID uint64) ([]*UserGiftStat, error) {
+	var gifts []*UserGiftStat
+	err := DB.Where("user_id = ?", userID).Order("last_sent desc").Find(&gifts).Error
+	return gifts, err
+}
+
+// GetUserRecipientStats 按直播间和接收者合计，按钻石数倒序
+func GetUserRecipientStats(userID uint64) ([]*UserRecipientStat, error) {
+	gifts, err := getUserGiftStats(userID)
+	if err != nil {
+		return nil, err
+	}
+	type recipientKey struct {
+		roomDisplayId string
+		toUserID      uint64
+	}
+	index := make(map[recipientKey]*UserRecipientStat)
+	stats := make([]*UserRecipientStat, 0)
+	for _, g := range gifts {
+		key := recipientKey{roomDisplayId: g.RoomDisplayId, toUserID: g.ToUserID}
+		stat, ok := index[key]
+		if !ok {
+			stat = &UserRecipientStat{
+				RoomDisplayId: g.RoomDisplayId,
+				ToUserID:      g.ToUserID,
+				ToUserName:    g.ToUserName,
+				LastSent:      g.LastSent,
+			}
+			index[key] = stat
+			stats = append(stats, stat)
+		}
+		stat.GiftCount += g.GiftCount
+		stat.DiamondTotal += g.DiamondTotal
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].DiamondTotal > stats[j].DiamondTotal
+	})
+	return stats, nil
+}
+
+// GetUserTopGifts 按礼物合计所有直播间，按钻石数、数量倒序取前 limit 个
+func GetUserTopGifts(userID uint64, limit int) ([]*UserTopGift, error) {
+	gifts, err := getUserGiftStats(userID)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[int64]*UserTopGift)
+	top := make([]*UserTopGift, 0)
+	for _, g := range gifts {
+		gift, ok := index[g.GiftID]
+		if !ok {
+			gift = &UserTopGift{
+				GiftID:   g.GiftID,
+				GiftName: g.GiftName,
+				Image:    g.Image,
+			}
+			index[g.GiftID] = gift
+			top = append(top, gift)
+		}
+		gift.GiftCount += g.GiftCount
+		gift.DiamondTotal += g.DiamondTotal
+	}
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].DiamondTotal != top[j].DiamondTotal {
+			return top[i].DiamondTotal > top[j].DiamondTotal
+		}
+		return top[i].GiftCount > top[j].GiftCount
+	})
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	return top, nil
+}
+
+func GetUserFansClubs(userID uint64) ([]*UserFansClub, error) {
+	var clubs []*UserFansClub
+	err := DB.Where("user_id = ?", userID).Order("level desc, last_seen desc").Find(&clubs).Error
+	return clubs, err
+}
+
+// GetUserDailyActivity 返回 since 之后每天每个直播间的活动，按日期倒序
+func GetUserDailyActivity(userID uint64, since int64) ([]*UserDailyActivity, error) {
+	var activity []*UserDailyActivity
+	err := DB.Where("user_id = ? AND day >= ?", userID, since).
+		Order("day desc, last_seen desc").
+		Find(&activity).Error
+	return activity, err
+}
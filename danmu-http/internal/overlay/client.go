@@ -0,0 +1,105 @@
+package overlay
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait    = 10 * time.Second
+	pongWait     = 60 * time.Second
+	pingInterval = 30 * time.Second
+	// sendBuffer 页面消费过慢时断开连接，页面会自动重连并重新获取当前状态
+	sendBuffer     = 256
+	maxMessageSize = 512
+)
+
+// client 一个页面的 WebSocket 连接，页面只接收消息，不发送数据
+type client struct {
+	conn *websocket.Conn
+	kind string // 为空时接收所有消息
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+}
+
+func newClient(conn *websocket.Conn, kind string) *client {
+	return &client{
+		conn: conn,
+		kind: kind,
+		send: make(chan []byte, sendBuffer),
+		done: make(chan struct{}),
+	}
+}
+
+// push 不会阻塞，缓冲已满时断开连接
+func (c *client) push(data []byte) {
+	select {
+	case <-c.done:
+	case c.send <- data:
+	default:
+		c.close()
+	}
+}
+
+// close 发送完缓冲中的消息后关闭连接，可重复调用
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *client) writeLoop() {
+	ping := time.NewTicker(pingInterval)
+	defer func() {
+		ping.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case data := <-c.send:
+			if err := c.write(websocket.TextMessage, data); err != nil {
+				c.close()
+				return
+			}
+		case <-ping.C:
+			if err := c.write(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			for {
+				select {
+				case data := <-c.send:
+					if err := c.write(websocket.TextMessage, data); err != nil {
+						return
+					}
+				default:
+					_ = c.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *client) write(messageType int, data []byte) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// readLoop 处理 pong 和关闭，连接断开后返回
+func (c *client) readLoop() {
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
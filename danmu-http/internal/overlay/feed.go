@@ -0,0 +1,161 @@
+package overlay
+
+import (
+	"context"
+	"danmu-http/logger"
+	"danmu-http/rpc"
+	api "danmu-http/rpc/proto"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	minRetryInterval = time.Second
+	maxRetryInterval = 30 * time.Second
+	// comboTTL 超过该时间没有新的连击消息时丢弃连击记录
+	comboTTL = 5 * time.Minute
+)
+
+// comboKey 同一用户送给同一接收者的同一种礼物，连击的 combo_count 为累计值
+type comboKey struct {
+	userID   uint64
+	toUserID uint64
+	giftID   int64
+}
+
+type comboState struct {
+	count    uint64
+	lastSeen time.Time
+}
+
+// feed 一个直播间的 SubscribeEvents 订阅，由该直播间的所有叠加层会话共享
+type feed struct {
+	room   string
+	cancel context.CancelFunc
+
+	mu       sync.RWMutex
+	sessions map[*session]struct{}
+
+	combos map[comboKey]*comboState // 只在 run 的 goroutine 中访问
+}
+
+func newFeed(room string) *feed {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &feed{
+		room:     room,
+		cancel:   cancel,
+		sessions: make(map[*session]struct{}),
+		combos:   make(map[comboKey]*comboState),
+	}
+	go f.run(ctx)
+	return f
+}
+
+func (f *feed) add(s *session) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions[s] = struct{}{}
+}
+
+// remove 返回剩余的会话数
+func (f *feed) remove(s *session) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.sessions, s)
+	return len(f.sessions)
+}
+
+func (f *feed) close() {
+	f.cancel()
+}
+
+// run 订阅 danmu-core 的事件，断开后按指数退避重连，直到 close
+func (f *feed) run(ctx context.Context) {
+	retry := minRetryInterval
+	for {
+		start := time.Now()
+		err := f.subscribe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// 连接保持了一段时间后断开，视为正常断开，从最小间隔开始重试
+		if time.Since(start) > maxRetryInterval {
+			retry = minRetryInterval
+		}
+		logger.Warn().Err(err).Str("room_display_id", f.room).Dur("retry", retry).Msg("overlay event stream disconnected")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, maxRetryInterval)
+	}
+}
+
+func (f *feed) subscribe(ctx context.Context) error {
+	client, err := rpc.GetClient()
+	if err != nil {
+		return fmt.Errorf("get rpc client error: %w", err)
+	}
+	stream, err := client.SubscribeEvents(ctx, &api.SubscribeEventsRequest{RoomDisplayId: f.room})
+	if err != nil {
+		return fmt.Errorf("subscribe events error: %w", err)
+	}
+	prune := time.NewTicker(time.Minute)
+	defer prune.Stop()
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		select {
+		case <-prune.C:
+			f.pruneCombos()
+		default:
+		}
+		f.dispatch(ev)
+	}
+}
+
+// dispatch 计算礼物相对上一条连击消息新增的数量，然后分发给所有会话
+func (f *feed) dispatch(ev *api.Event) {
+	var delta uint64
+	if gift := ev.GetGift(); gift != nil {
+		delta = f.comboDelta(comboKey{ev.GetUser().GetId(), gift.GetToUser().GetId(), gift.GetGiftId()}, gift.GetComboCount())
+		if delta == 0 {
+			return
+		}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for s := range f.sessions {
+		s.handle(ev, delta)
+	}
+}
+
+// comboDelta 连击数小于等于上一次时视为新的一轮连击
+func (f *feed) comboDelta(key comboKey, combo uint64) uint64 {
+	now := time.Now()
+	state, ok := f.combos[key]
+	if !ok {
+		f.combos[key] = &comboState{count: combo, lastSeen: now}
+		return combo
+	}
+	delta := combo
+	if combo > state.count {
+		delta = combo - state.count
+	}
+	state.count = combo
+	state.lastSeen = now
+	return delta
+}
+
+func (f *feed) pruneCombos() {
+	deadline := time.Now().Add(-comboTTL)
+	for key, state := range f.combos {
+		if state.lastSeen.Before(deadline) {
+			delete(f.combos, key)
+		}
+	}
+}
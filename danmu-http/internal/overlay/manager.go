@@ -0,0 +1,101 @@
+// Package overlay 为 OBS 浏览器源提供直播间叠加层页面 (弹幕、礼物提醒、礼物榜、目标进度)
+//
+// 每个直播间使用一个 SubscribeEvents 订阅，由该直播间的所有叠加层共享；
+// 每个叠加层维护一个会话，提醒队列、连击合并和礼物榜在服务端计算，同一 token 的多个页面看到相同的状态
+package overlay
+
+import (
+	"danmu-http/internal/model"
+	"danmu-http/metrics"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+type Manager struct {
+	loader Loader
+
+	mu       sync.Mutex
+	feeds    map[string]*feed
+	sessions map[int64]*session
+}
+
+func NewManager(loader Loader) *Manager {
+	return &Manager{
+		loader:   loader,
+		feeds:    make(map[string]*feed),
+		sessions: make(map[int64]*session),
+	}
+}
+
+// Serve 向页面推送叠加层的实时消息，阻塞直到连接断开，kind 为空时推送所有类型的消息
+func (m *Manager) Serve(o *model.Overlay, kind string, conn *websocket.Conn) {
+	c := newClient(conn, kind)
+	s := m.join(o, c)
+	metrics.OverlayConnections.WithLabelValues(kind).Inc()
+	defer metrics.OverlayConnections.WithLabelValues(kind).Dec()
+
+	go c.writeLoop()
+	c.readLoop()
+	c.close()
+	m.leave(s, c)
+}
+
+func (m *Manager) join(o *model.Overlay, c *client) *session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[o.ID]
+	if !ok {
+		s = newSession(o, m.loader)
+		m.sessions[o.ID] = s
+		f, ok := m.feeds[o.RoomDisplayId]
+		if !ok {
+			f = newFeed(o.RoomDisplayId)
+			m.feeds[o.RoomDisplayId] = f
+		}
+		f.add(s)
+	}
+	s.add(c)
+	return s
+}
+
+// leave 最后一个页面断开时销毁会话，直播间没有会话时取消订阅
+func (m *Manager) leave(s *session, c *client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s.remove(c) > 0 || m.sessions[s.id] != s {
+		return
+	}
+	m.release(s)
+	s.close(false)
+}
+
+func (m *Manager) release(s *session) {
+	delete(m.sessions, s.id)
+	if f, ok := m.feeds[s.room]; ok && f.remove(s) == 0 {
+		f.close()
+		delete(m.feeds, s.room)
+	}
+}
+
+// Reload 叠加层配置修改后通知页面刷新
+func (m *Manager) Reload(id int64) {
+	m.stop(id, true)
+}
+
+// Close 叠加层删除或更换 token 后断开所有页面
+func (m *Manager) Close(id int64) {
+	m.stop(id, false)
+}
+
+func (m *Manager) stop(id int64, reload bool) {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	if ok {
+		m.release(s)
+	}
+	m.mu.Unlock()
+	if ok {
+		s.close(reload)
+	}
+}
@@ -0,0 +1,73 @@
+package overlay
+
+import (
+	"danmu-http/internal/model"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+)
+
+// 页面类型
+const (
+	KindChat   = "chat"
+	KindAlerts = "alerts"
+	KindTop    = "top"
+	KindGoal   = "goal"
+)
+
+// Kinds 可以作为浏览器源的页面
+var Kinds = []string{KindChat, KindAlerts, KindTop, KindGoal}
+
+// Themes 预设主题，DefaultTheme 为半透明深色卡片
+var Themes = []string{DefaultTheme, "light", "transparent"}
+
+const DefaultTheme = "default"
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var pages = func() map[string]*template.Template {
+	pages := make(map[string]*template.Template, len(Kinds))
+	for _, kind := range Kinds {
+		pages[kind] = template.Must(template.ParseFS(templateFS, "templates/base.html", "templates/"+kind+".html"))
+	}
+	return pages
+}()
+
+// styleEscaper 防止自定义样式提前结束 <style> 标签
+var styleEscaper = strings.NewReplacer("</", `<\/`)
+
+type pageData struct {
+	Kind      string
+	Title     string
+	Theme     string
+	CustomCSS template.CSS
+	ChatLimit int
+}
+
+// ValidKind kind 是否为可以渲染的页面
+func ValidKind(kind string) bool {
+	return slices.Contains(Kinds, kind)
+}
+
+// Render 渲染叠加层页面，页面通过同一 token 下的 ws 地址接收实时消息
+func Render(w io.Writer, o *model.Overlay, kind string) error {
+	page, ok := pages[kind]
+	if !ok {
+		return fmt.Errorf("unknown overlay kind %q", kind)
+	}
+	theme := o.Theme
+	if !slices.Contains(Themes, theme) {
+		theme = DefaultTheme
+	}
+	return page.ExecuteTemplate(w, "base", &pageData{
+		Kind:      kind,
+		Title:     o.Name,
+		Theme:     theme,
+		CustomCSS: template.CSS(styleEscaper.Replace(o.CustomCSS)),
+		ChatLimit: newConfig(o).chatLimit,
+	})
+}
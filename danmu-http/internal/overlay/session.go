@@ -0,0 +1,552 @@
+package overlay
+
+import (
+	"context"
+	"danmu-http/internal/model"
+	"danmu-http/logger"
+	api "danmu-http/rpc/proto"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 叠加层配置的默认值
+const (
+	defaultAlertDuration = 5 * time.Second
+	defaultAlertQueue    = 20
+	defaultChatLimit     = 20
+	defaultTopCount      = 5
+)
+
+const (
+	// alertGap 两个提醒之间的间隔
+	alertGap = 500 * time.Millisecond
+	// comboExtend 正在显示的提醒收到连击时至少再显示的时间，最长显示 maxAlertFactor 倍的 AlertDuration
+	comboExtend    = 3 * time.Second
+	maxAlertFactor = 3
+	// holdTTL 未达到 MinDiamond 的连击超过该时间没有新的消息时丢弃
+	holdTTL = 30 * time.Second
+	// flushInterval 礼物榜和目标进度的推送间隔
+	flushInterval = time.Second
+	loadTimeout   = time.Minute
+)
+
+// 目标类型
+const (
+	GoalDiamond = "diamond" // 钻石总数
+	GoalGift    = "gift"    // 指定礼物的数量
+)
+
+// 推送给页面的消息类型
+const (
+	msgInit        = "init"
+	msgChat        = "chat"
+	msgAlert       = "alert"
+	msgAlertUpdate = "alert_update"
+	msgAlertEnd    = "alert_end"
+	msgTop         = "top"
+	msgGoal        = "goal"
+	msgReload      = "reload"
+)
+
+// message 推送给页面的 json 消息
+type message struct {
+	Type        string      `json:"type"`
+	Chat        *chatItem   `json:"chat,omitempty"`
+	ChatHistory []*chatItem `json:"chat_history,omitempty"`
+	Alert       *alertItem  `json:"alert,omitempty"`
+	AlertID     int64       `json:"alert_id,omitempty"` // alert_end 结束显示的提醒
+	Top         []*topEntry `json:"top,omitempty"`
+	Goal        *goalState  `json:"goal,omitempty"`
+}
+
+// kind 接收该消息的页面，为空时发送给所有页面
+func (m *message) kind() string {
+	switch m.Type {
+	case msgChat:
+		return KindChat
+	case msgAlert, msgAlertUpdate, msgAlertEnd:
+		return KindAlerts
+	case msgTop:
+		return KindTop
+	case msgGoal:
+		return KindGoal
+	}
+	return ""
+}
+
+type chatItem struct {
+	ID        uint64 `json:"id,string"`
+	Type      string `json:"type"` // chat 或 member
+	UserName  string `json:"user_name"`
+	Content   string `json:"content"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type alertItem struct {
+	ID           int64  `json:"id"`
+	UserName     string `json:"user_name"`
+	ToUserName   string `json:"to_user_name"`
+	GiftName     string `json:"gift_name"`
+	Image        string `json:"image_url"`
+	Message      string `json:"message"`
+	DiamondCount int64  `json:"diamond_count"` // 单个礼物的钻石数
+	Count        int64  `json:"count"`         // 合并连击后的数量
+	Diamonds     int64  `json:"diamonds"`
+}
+
+type topEntry struct {
+	UserID   uint64 `json:"user_id,string"`
+	UserName string `json:"user_name"`
+	Diamonds int64  `json:"diamonds"`
+}
+
+type goalState struct {
+	Title   string `json:"title"`
+	Type    string `json:"type"`
+	Current int64  `json:"current"`
+	Target  int64  `json:"target"`
+}
+
+// alert 等待或正在显示的礼物提醒，同一用户送给同一接收者的同一种礼物合并为一个提醒
+type alert struct {
+	item     alertItem
+	key      comboKey
+	ends     time.Time
+	deadline time.Time // 连击延长显示的上限
+	lastSeen time.Time
+}
+
+func (a *alert) add(count uint64) {
+	a.item.Count += int64(count)
+	a.item.Diamonds = a.item.DiamondCount * a.item.Count
+}
+
+// GiftTotal 用户在 ResetAt 之后的送礼合计，用于初始化礼物榜和目标
+type GiftTotal struct {
+	UserID   uint64
+	UserName string
+	Diamonds int64
+	Gifts    map[int64]int64 // gift_id -> 数量
+}
+
+// Loader 从数据库加载 [begin, end] 的送礼合计
+type Loader func(ctx context.Context, roomDisplayId string, begin, end int64) ([]*GiftTotal, error)
+
+type config struct {
+	minDiamond    int64
+	alertDuration time.Duration
+	alertQueue    int
+	chatLimit     int
+	showMember    bool
+	topCount      int
+	goalTitle     string
+	goalType      string
+	goalGiftID    int64
+	goalTarget    int64
+	resetAt       int64
+}
+
+func newConfig(o *model.Overlay) config {
+	c := config{
+		minDiamond:    o.MinDiamond,
+		alertDuration: time.Duration(o.AlertDuration) * time.Second,
+		alertQueue:    o.AlertQueue,
+		chatLimit:     o.ChatLimit,
+		showMember:    o.ShowMember,
+		topCount:      o.TopCount,
+		goalTitle:     o.GoalTitle,
+		goalType:      o.GoalType,
+		goalGiftID:    o.GoalGiftID,
+		goalTarget:    o.GoalTarget,
+		resetAt:       o.ResetAt,
+	}
+	if c.alertDuration <= 0 {
+		c.alertDuration = defaultAlertDuration
+	}
+	if c.alertQueue <= 0 {
+		c.alertQueue = defaultAlertQueue
+	}
+	if c.chatLimit <= 0 {
+		c.chatLimit = defaultChatLimit
+	}
+	if c.topCount <= 0 {
+		c.topCount = defaultTopCount
+	}
+	return c
+}
+
+// session 一个叠加层的状态，由使用同一 token 的所有页面共享，最后一个页面断开时销毁
+type session struct {
+	id   int64
+	room string
+	conf config
+	// since 之前的礼物从数据库加载，实时事件只统计之后的部分
+	since int64
+
+	mu      sync.Mutex
+	closed  bool
+	done    chan struct{}
+	clients map[*client]struct{}
+	chats   []*chatItem
+	pending []*alert
+	held    map[comboKey]*alert // 未达到 MinDiamond 的连击
+	showing *alert
+	waiting bool // 两个提醒之间的间隔中
+	timer   *time.Timer
+	seq     int64
+	totals  map[uint64]*topEntry
+	goal    int64
+	dirty   bool
+}
+
+func newSession(o *model.Overlay, loader Loader) *session {
+	s := &session{
+		id:      o.ID,
+		room:    o.RoomDisplayId,
+		conf:    newConfig(o),
+		since:   time.Now().UnixMilli(),
+		done:    make(chan struct{}),
+		clients: make(map[*client]struct{}),
+		held:    make(map[comboKey]*alert),
+		totals:  make(map[uint64]*topEntry),
+	}
+	if loader != nil && s.conf.resetAt > 0 && s.conf.resetAt < s.since {
+		go s.load(loader)
+	}
+	go s.run()
+	return s
+}
+
+func (s *session) load(loader Loader) {
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	totals, err := loader(ctx, s.room, s.conf.resetAt, s.since)
+	if err != nil {
+		logger.Warn().Err(err).Int64("overlay", s.id).Msg("load overlay gift totals failed")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range totals {
+		s.count(t.UserID, t.UserName, t.Diamonds)
+		switch s.conf.goalType {
+		case GoalDiamond:
+			s.goal += t.Diamonds
+		case GoalGift:
+			s.goal += t.Gifts[s.conf.goalGiftID]
+		}
+	}
+	s.dirty = true
+}
+
+// run 定期推送礼物榜和目标进度，清理过期的连击
+func (s *session) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+func (s *session) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deadline := time.Now().Add(-holdTTL)
+	for key, a := range s.held {
+		if a.lastSeen.Before(deadline) {
+			delete(s.held, key)
+		}
+	}
+	if !s.dirty {
+		return
+	}
+	s.dirty = false
+	s.broadcast(&message{Type: msgTop, Top: s.topList()})
+	if goal := s.goalState(); goal != nil {
+		s.broadcast(&message{Type: msgGoal, Goal: goal})
+	}
+}
+
+// handle 处理 feed 分发的事件，delta 为礼物相对上一条连击消息新增的数量
+func (s *session) handle(ev *api.Event, delta uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	switch p := ev.Payload.(type) {
+	case *api.Event_Chat:
+		s.addChat(&chatItem{
+			ID:        ev.MsgId,
+			Type:      msgChat,
+			UserName:  ev.GetUser().GetName(),
+			Content:   p.Chat.GetContent(),
+			Timestamp: ev.Timestamp,
+		})
+	case *api.Event_Member:
+		if s.conf.showMember {
+			s.addChat(&chatItem{
+				ID:        ev.MsgId,
+				Type:      "member",
+				UserName:  ev.GetUser().GetName(),
+				Timestamp: ev.Timestamp,
+			})
+		}
+	case *api.Event_Gift:
+		s.addGift(ev, p.Gift, delta)
+		if ev.Timestamp > s.since {
+			diamonds := int64(p.Gift.GetDiamondCount()) * int64(delta)
+			s.count(ev.GetUser().GetId(), ev.GetUser().GetName(), diamonds)
+			switch s.conf.goalType {
+			case GoalDiamond:
+				s.goal += diamonds
+			case GoalGift:
+				if p.Gift.GetGiftId() == s.conf.goalGiftID {
+					s.goal += int64(delta)
+				}
+			}
+			s.dirty = true
+		}
+	}
+}
+
+func (s *session) addChat(item *chatItem) {
+	s.chats = append(s.chats, item)
+	if len(s.chats) > s.conf.chatLimit {
+		s.chats = s.chats[len(s.chats)-s.conf.chatLimit:]
+	}
+	s.broadcast(&message{Type: msgChat, Chat: item})
+}
+
+func (s *session) count(userID uint64, name string, diamonds int64) {
+	if diamonds == 0 {
+		return
+	}
+	entry, ok := s.totals[userID]
+	if !ok {
+		entry = &topEntry{UserID: userID}
+		s.totals[userID] = entry
+	}
+	if name != "" {
+		entry.UserName = name
+	}
+	entry.Diamonds += diamonds
+}
+
+func (s *session) topList() []*topEntry {
+	top := make([]*topEntry, 0, len(s.totals))
+	for _, entry := range s.totals {
+		top = append(top, entry)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Diamonds != top[j].Diamonds {
+			return top[i].Diamonds > top[j].Diamonds
+		}
+		return top[i].UserID < top[j].UserID
+	})
+	if len(top) > s.conf.topCount {
+		top = top[:s.conf.topCount]
+	}
+	return top
+}
+
+func (s *session) goalState() *goalState {
+	if s.conf.goalType == "" {
+		return nil
+	}
+	return &goalState{
+		Title:   s.conf.goalTitle,
+		Type:    s.conf.goalType,
+		Current: s.goal,
+		Target:  s.conf.goalTarget,
+	}
+}
+
+// addGift 连击合并到正在显示或等待中的同一提醒，未达到 MinDiamond 的连击累计到达到后再加入队列
+func (s *session) addGift(ev *api.Event, gift *api.Gift, delta uint64) {
+	key := comboKey{ev.GetUser().GetId(), gift.GetToUser().GetId(), gift.GetGiftId()}
+	now := time.Now()
+	if a := s.showing; a != nil && a.key == key {
+		a.add(delta)
+		if ends := now.Add(comboExtend); ends.After(a.ends) {
+			a.ends = ends
+		}
+		if a.ends.After(a.deadline) {
+			a.ends = a.deadline
+		}
+		s.broadcast(&message{Type: msgAlertUpdate, Alert: &a.item})
+		return
+	}
+	for _, a := range s.pending {
+		if a.key == key {
+			a.add(delta)
+			return
+		}
+	}
+	a, ok := s.held[key]
+	if !ok {
+		a = &alert{
+			key: key,
+			item: alertItem{
+				UserName:     ev.GetUser().GetName(),
+				ToUserName:   gift.GetToUser().GetName(),
+				GiftName:     gift.GetGiftName(),
+				Image:        gift.GetImageUrl(),
+				Message:      gift.GetMessage(),
+				DiamondCount: int64(gift.GetDiamondCount()),
+			},
+		}
+	}
+	a.add(delta)
+	a.lastSeen = now
+	if a.item.Diamonds < s.conf.minDiamond {
+		s.held[key] = a
+		return
+	}
+	delete(s.held, key)
+	s.enqueue(a)
+}
+
+// enqueue 队列已满时丢弃价值最低的提醒
+func (s *session) enqueue(a *alert) {
+	if len(s.pending) >= s.conf.alertQueue {
+		lowest := 0
+		for i, p := range s.pending {
+			if p.item.Diamonds < s.pending[lowest].item.Diamonds {
+				lowest = i
+			}
+		}
+		if s.pending[lowest].item.Diamonds >= a.item.Diamonds {
+			return
+		}
+		s.pending = append(s.pending[:lowest], s.pending[lowest+1:]...)
+	}
+	s.seq++
+	a.item.ID = s.seq
+	s.pending = append(s.pending, a)
+	if s.showing == nil && !s.waiting {
+		s.showNext()
+	}
+}
+
+func (s *session) showNext() {
+	if len(s.pending) == 0 {
+		return
+	}
+	a := s.pending[0]
+	s.pending = s.pending[1:]
+	now := time.Now()
+	a.ends = now.Add(s.conf.alertDuration)
+	a.deadline = now.Add(maxAlertFactor * s.conf.alertDuration)
+	s.showing = a
+	s.broadcast(&message{Type: msgAlert, Alert: &a.item})
+	s.timer = time.AfterFunc(s.conf.alertDuration, s.expire)
+}
+
+// expire 提醒显示结束，连击延长了显示时间时重新计时
+func (s *session) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.showing
+	if s.closed || a == nil {
+		return
+	}
+	if remaining := time.Until(a.ends); remaining > 0 {
+		s.timer = time.AfterFunc(remaining, s.expire)
+		return
+	}
+	s.showing = nil
+	s.waiting = true
+	s.broadcast(&message{Type: msgAlertEnd, AlertID: a.item.ID})
+	s.timer = time.AfterFunc(alertGap, s.next)
+}
+
+func (s *session) next() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.waiting = false
+	if s.showing == nil {
+		s.showNext()
+	}
+}
+
+// add 页面连接后发送当前状态
+func (s *session) add(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[c] = struct{}{}
+	init := &message{Type: msgInit}
+	switch c.kind {
+	case KindChat:
+		init.ChatHistory = s.chats
+	case KindAlerts:
+		if s.showing != nil {
+			init.Alert = &s.showing.item
+		}
+	case KindTop:
+		init.Top = s.topList()
+	case KindGoal:
+		init.Goal = s.goalState()
+	}
+	data, err := json.Marshal(init)
+	if err != nil {
+		logger.Error().Err(err).Msg("marshal overlay message failed")
+		return
+	}
+	c.push(data)
+}
+
+// remove 返回剩余的页面数
+func (s *session) remove(c *client) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, c)
+	return len(s.clients)
+}
+
+func (s *session) broadcast(m *message) {
+	if len(s.clients) == 0 {
+		return
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		logger.Error().Err(err).Msg("marshal overlay message failed")
+		return
+	}
+	kind := m.kind()
+	for c := range s.clients {
+		if kind == "" || c.kind == "" || c.kind == kind {
+			c.push(data)
+		}
+	}
+}
+
+// close 停止会话并断开所有页面，reload 为 true 时通知页面刷新以使用新的配置
+func (s *session) close(reload bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if reload {
+		s.broadcast(&message{Type: msgReload})
+	}
+	for c := range s.clients {
+		c.close()
+	}
+}
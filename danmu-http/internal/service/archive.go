@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"danmu-http/internal/archive"
+	"danmu-http/internal/model"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"danmu-http/middleware"
+	"danmu-http/setting"
+	"danmu-http/tracing"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+const defaultArchiveMaxScanRows = 50000
+
+type ArchiveService interface {
+	ListArchives(ctx context.Context, req *validate.ArchiveListRequest) ([]*model.MessageArchive, int64, error)
+	// 返回的 bool 表示匹配的消息超过 MaxScanRows，结果不完整
+	QueryCommonMessages(ctx context.Context, req *validate.ArchiveCommonMessageQuery) ([]*model.CommonMessage, int64, bool, error)
+	QueryGiftMessages(ctx context.Context, req *validate.ArchiveGiftMessageQuery) ([]*model.GiftMessage, int64, bool, error)
+	ListRetentionPolicies(ctx context.Context) ([]*model.RetentionPolicy, error)
+	SetRetentionPolicy(ctx context.Context, req *validate.RetentionPolicyRequest) error
+	DeleteRetentionPolicy(ctx context.Context, roomDisplayId string) error
+}
+
+type archiveService struct {
+}
+
+func NewArchiveService() ArchiveService {
+	return &archiveService{}
+}
+
+func (s *archiveService) ListArchives(ctx context.Context, req *validate.ArchiveListRequest) ([]*model.MessageArchive, int64, error) {
+	return model.GetMessageArchivesPage(req)
+}
+
+func (s *archiveService) QueryCommonMessages(ctx context.Context, req *validate.ArchiveCommonMessageQuery) (list []*model.CommonMessage, total int64, truncated bool, err error) {
+	ctx, span := tracing.Start(ctx, "archiveService.QueryCommonMessages")
+	span.SetAttributes(attribute.String("room_display_id", req.RoomDisplayId))
+	defer func() { tracing.End(span, err) }()
+
+	match := func(r *archive.CommonMessageRow) bool {
+		ts := int64(r.Timestamp)
+		return (req.Begin == 0 || ts >= req.Begin) &&
+			(req.End == 0 || ts <= req.End) &&
+			(len(req.MessageType) == 0 || slices.Contains(req.MessageType, r.MessageType)) &&
+			(len(req.UserIDs) == 0 || slices.Contains(req.UserIDs, r.UserID)) &&
+			(req.Search == "" || strings.Contains(r.Content, req.Search))
+	}
+	convert := func(r *archive.CommonMessageRow) *model.CommonMessage {
+		return &model.CommonMessage{
+			ID:            r.ID,
+			MessageType:   r.MessageType,
+			RoomID:        int64(r.RoomID),
+			RoomDisplayId: r.RoomDisplayId,
+			RoomName:      r.RoomName,
+			UserName:      r.UserName,
+			UserID:        r.UserID,
+			UserDisplayId: r.UserDisplayId,
+			Content:       r.Content,
+			Timestamp:     int64(r.Timestamp),
+			StreamerID:    r.StreamerID,
+		}
+	}
+	messages, truncated, err := scanArchives(model.TableNameCommonMessage, req.RoomDisplayId, req.Begin, req.End, match, convert)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	list, total = sortAndPage(messages, func(m *model.CommonMessage) int64 { return m.Timestamp }, &req.PageRequest)
+	return list, total, truncated, nil
+}
+
+func (s *archiveService) QueryGiftMessages(ctx context.Context, req *validate.ArchiveGiftMessageQuery) (list []*model.GiftMessage, total int64, truncated bool, err error) {
+	ctx, span := tracing.Start(ctx, "archiveService.QueryGiftMessages")
+	span.SetAttributes(attribute.String("room_display_id", req.RoomDisplayId))
+	defer func() { tracing.End(span, err) }()
+
+	match := func(r *archive.GiftMessageRow) bool {
+		ts := int64(r.Timestamp)
+		return (req.Begin == 0 || ts >= req.Begin) &&
+			(req.End == 0 || ts <= req.End) &&
+			(len(req.UserIDs) == 0 || slices.Contains(req.UserIDs, r.UserID)) &&
+			(len(req.ToUserIds) == 0 || slices.Contains(req.ToUserIds, r.ToUserID)) &&
+			int64(r.DiamondCount) >= req.DiamondCount &&
+			(req.Search == "" || strings.Contains(r.Message, req.Search))
+	}
+	convert := func(r *archive.GiftMessageRow) *model.GiftMessage {
+		return &model.GiftMessage{
+			ID:              r.ID,
+			UserID:          r.UserID,
+			UserName:        r.UserName,
+			UserDisplayId:   r.UserDisplayId,
+			ToUserID:        r.ToUserID,
+			ToUserName:      r.ToUserName,
+			ToUserDisplayId: r.ToUserDisplayId,
+			GiftName:        r.GiftName,
+			GiftID:          r.GiftID,
+			RoomID:          int64(r.RoomID),
+			RoomDisplayId:   r.RoomDisplayId,
+			RoomName:        r.RoomName,
+			Message:         r.Message,
+			Timestamp:       int64(r.Timestamp),
+			DiamondCount:    uint32(r.DiamondCount),
+			Image:           r.Image,
+			ComboCount:      r.ComboCount,
+			StreamerID:      r.StreamerID,
+		}
+	}
+	messages, truncated, err := scanArchives(model.TableNameGiftMessage, req.RoomDisplayId, req.Begin, req.End, match, convert)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	list, total = sortAndPage(messages, func(m *model.GiftMessage) int64 { return m.Timestamp }, &req.PageRequest)
+	return list, total, truncated, nil
+}
+
+// scanArchives 读取直播间在时间范围内的归档文件，返回匹配的消息，超过 MaxScanRows 时停止读取
+func scanArchives[T any, M any](table, room string, begin, end int64, match func(*T) bool, convert func(*T) *M) ([]*M, bool, error) {
+	archives, err := model.FindMessageArchives(table, room, begin, end)
+	if err != nil {
+		return nil, false, err
+	}
+	limit := setting.ArchiveSetting.MaxScanRows
+	if limit <= 0 {
+		limit = defaultArchiveMaxScanRows
+	}
+
+	var (
+		result    []*M
+		truncated bool
+	)
+	for _, a := range archives {
+		path, err := archive.Resolve(setting.ArchiveSetting.Dir, a.Path)
+		if err != nil {
+			return nil, false, err
+		}
+		err = archive.Scan(path, func(row *T) bool {
+			if !match(row) {
+				return true
+			}
+			if len(result) >= limit {
+				truncated = true
+				return false
+			}
+			result = append(result, convert(row))
+			return true
+		})
+		if err != nil {
+			logger.Error().Err(err).Str("path", path).Msg("read archive failed")
+			return nil, false, err
+		}
+		if truncated {
+			break
+		}
+	}
+	return result, truncated, nil
+}
+
+// sortAndPage 归档只支持按时间排序，order_direction 为 asc 时正序，默认倒序
+func sortAndPage[M any](messages []*M, ts func(*M) int64, req *validate.PageRequest) ([]*M, int64) {
+	asc := strings.EqualFold(req.OrderDirection, "asc")
+	sort.SliceStable(messages, func(i, j int) bool {
+		if asc {
+			return ts(messages[i]) < ts(messages[j])
+		}
+		return ts(messages[i]) > ts(messages[j])
+	})
+	total := int64(len(messages))
+	from := min((req.Page-1)*req.PageSize, len(messages))
+	to := min(from+req.PageSize, len(messages))
+	return messages[from:to], total
+}
+
+func (s *archiveService) ListRetentionPolicies(ctx context.Context) ([]*model.RetentionPolicy, error) {
+	return model.GetRetentionPolicies()
+}
+
+func (s *archiveService) SetRetentionPolicy(ctx context.Context, req *validate.RetentionPolicyRequest) error {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	policy := &model.RetentionPolicy{
+		RoomDisplayId: req.RoomDisplayId,
+		RetainDays:    *req.RetainDays,
+		ModifiedOn:    time.Now().Unix(),
+		ModifiedBy:    auth.Email,
+	}
+	logger.Info().
+		Str("operator", auth.Email).
+		Str("room_id", req.RoomDisplayId).
+		Int("retain_days", policy.RetainDays).
+		Msg("set retention policy")
+	return policy.Upsert()
+}
+
+func (s *archiveService) DeleteRetentionPolicy(ctx context.Context, roomDisplayId string) error {
+	rows, err := model.DeleteRetentionPolicy(roomDisplayId)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
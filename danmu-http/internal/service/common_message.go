@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"danmu-http/internal/analytics"
 	"danmu-http/internal/model"
 	"danmu-http/internal/validate"
 	"danmu-http/logger"
@@ -13,10 +14,11 @@ type CommonMessageService interface {
 }
 
 type commonMessageService struct {
+	analyticsClient *analytics.Client // 启用 [analytics] 时从分析库查询
 }
 
 func NewCommonMessageService() CommonMessageService {
-	return &commonMessageService{}
+	return &commonMessageService{analyticsClient: analytics.Default()}
 }
 
 func (s *commonMessageService) GetCommonMessageWithConditionPage(ctx context.Context, req *validate.CommonMessageQuery) ([]*model.CommonMessage, int64, error) {
@@ -25,7 +27,15 @@ func (s *commonMessageService) GetCommonMessageWithConditionPage(ctx context.Con
 		return nil, 0, err
 	}
 
-	messages, count, err := model.GetCommonMessageWithConditionPage(req)
+	var (
+		messages []*model.CommonMessage
+		count    int64
+	)
+	if s.analyticsClient != nil {
+		messages, count, err = analytics.ListCommonMessages(ctx, s.analyticsClient, req)
+	} else {
+		messages, count, err = model.GetCommonMessageWithConditionPage(req)
+	}
 	if err != nil {
 		logger.Error().
 			Err(err).
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"context"
+	"danmu-http/internal/model"
+	"danmu-http/internal/validate"
+)
+
+type CoverageHoleService interface {
+	// ListCoverageHoles duration 为所有匹配缺口的总时长 (毫秒)
+	ListCoverageHoles(ctx context.Context, req *validate.CoverageHoleListRequest) (holes []*model.CoverageHole, total int64, duration int64, err error)
+}
+
+type coverageHoleService struct {
+}
+
+func NewCoverageHoleService() CoverageHoleService {
+	return &coverageHoleService{}
+}
+
+func (s *coverageHoleService) ListCoverageHoles(ctx context.Context, req *validate.CoverageHoleListRequest) ([]*model.CoverageHole, int64, int64, error) {
+	return model.GetCoverageHolesPage(req)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"danmu-http/internal/model"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"danmu-http/middleware"
+	"danmu-http/rpc"
+	api "danmu-http/rpc/proto"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DeadLetterService interface {
+	ListDeadLetters(ctx context.Context, req *validate.DeadLetterListRequest) ([]*model.DeadLetter, int64, error)
+	GetDeadLetter(ctx context.Context, id int64) (*model.DeadLetter, error)
+	// ReplayDeadLetters 由 danmu-core 交给原 handler 重新处理，成功的死信被删除
+	ReplayDeadLetters(ctx context.Context, req *validate.DeadLetterReplayRequest) ([]*api.ReplayDeadLetterResult, error)
+	DeleteDeadLetter(ctx context.Context, id int64) error
+	PurgeDeadLetters(ctx context.Context, req *validate.DeadLetterPurgeRequest) (int64, error)
+}
+
+type deadLetterService struct {
+}
+
+func NewDeadLetterService() DeadLetterService {
+	return &deadLetterService{}
+}
+
+func (s *deadLetterService) ListDeadLetters(ctx context.Context, req *validate.DeadLetterListRequest) ([]*model.DeadLetter, int64, error) {
+	return model.GetDeadLettersPage(req)
+}
+
+func (s *deadLetterService) GetDeadLetter(ctx context.Context, id int64) (*model.DeadLetter, error) {
+	return model.GetDeadLetter(id)
+}
+
+func (s *deadLetterService) ReplayDeadLetters(ctx context.Context, req *validate.DeadLetterReplayRequest) ([]*api.ReplayDeadLetterResult, error) {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rpcClient, err := rpc.GetClient()
+	if err != nil {
+		logger.Error().Err(err).Msg("get rpc client failed")
+		return nil, err
+	}
+
+	// 重放在 handler 队列中依次执行，比其他调用需要更长的超时
+	ctx, cancel := rpc.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	res, err := rpcClient.ReplayDeadLetters(ctx, &api.ReplayDeadLettersRequest{Ids: req.IDs})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info().
+		Str("operator", auth.Email).
+		Ints64("ids", req.IDs).
+		Msg("replay dead letters")
+	return res.Results, nil
+}
+
+func (s *deadLetterService) DeleteDeadLetter(ctx context.Context, id int64) error {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := model.DeleteDeadLetter(id)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	logger.Info().
+		Str("operator", auth.Email).
+		Int64("id", id).
+		Msg("delete dead letter")
+	return nil
+}
+
+func (s *deadLetterService) PurgeDeadLetters(ctx context.Context, req *validate.DeadLetterPurgeRequest) (int64, error) {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := model.PurgeDeadLetters(req)
+	if err != nil {
+		return 0, err
+	}
+	logger.Info().
+		Str("operator", auth.Email).
+		Str("room_display_id", req.RoomDisplayId).
+		Str("handler", req.Handler).
+		Str("method", req.Method).
+		Int64("before", req.Before).
+		Int64("rows", rows).
+		Msg("purge dead letters")
+	return rows, nil
+}
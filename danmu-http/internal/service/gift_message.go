@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"danmu-http/internal/analytics"
 	"danmu-http/internal/model"
 	"danmu-http/internal/validate"
 	"danmu-http/logger"
@@ -20,10 +21,11 @@ type GiftMessageService interface {
 }
 
 type giftMessageService struct {
+	analyticsClient *analytics.Client // 启用 [analytics] 时排行和礼物消息从分析库查询
 }
 
 func NewGiftMessageService() GiftMessageService {
-	return &giftMessageService{}
+	return &giftMessageService{analyticsClient: analytics.Default()}
 }
 
 type UserGift struct {
@@ -54,6 +56,10 @@ func (s *giftMessageService) ListGiftRanking(ctx context.Context, req *validate.
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
+	if s.analyticsClient != nil {
+		return s.listGiftRankingFromAnalytics(ctx, req)
+	}
+
 	// 获取总记录数
 	total, err := model.GetGiftMessagesCount(req.ToUserIds, req.RoomDisplayId, req.Begin, req.End)
 	if err != nil {
@@ -292,12 +298,66 @@ func (s *giftMessageService) mergeAndSortResults(ctx context.Context, results ch
 	return result, nil
 }
 
+// listGiftRankingFromAnalytics 连击已在分析库中合并，这里只按用户和送礼对象汇总
+func (s *giftMessageService) listGiftRankingFromAnalytics(ctx context.Context, req *validate.ListGiftRankingRequest) ([]*UserGift, error) {
+	rows, err := analytics.ListGiftRanking(ctx, s.analyticsClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gift ranking from analytics: %w", err)
+	}
+
+	userGiftMap := make(map[string]*UserGift)
+	result := make([]*UserGift, 0)
+	for _, row := range rows {
+		userKey := fmt.Sprintf("%d_%d", row.UserID, row.ToUserID)
+		userGift, exists := userGiftMap[userKey]
+		if !exists {
+			userGift = &UserGift{
+				UserID:          row.UserID,
+				UserName:        row.UserName,
+				UserDisplayId:   row.UserDisplayId,
+				RoomDisplayId:   row.RoomDisplayId,
+				RoomName:        row.RoomName,
+				ToUserID:        row.ToUserID,
+				ToUserName:      row.ToUserName,
+				ToUserDisplayId: row.ToUserDisplayId,
+				GiftList:        make([]*Gift, 0, 20),
+			}
+			userGiftMap[userKey] = userGift
+			result = append(result, userGift)
+		}
+		userGift.GiftList = append(userGift.GiftList, &Gift{
+			GiftID:       row.GiftID,
+			GiftName:     row.GiftName,
+			DiamondCount: row.DiamondCount,
+			ComboCount:   row.ComboCount,
+			Image:        row.Image,
+			Message:      row.Message,
+			Timestamp:    row.Timestamp,
+		})
+		userGift.Total += row.DiamondCount * row.ComboCount
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Total > result[j].Total
+	})
+	return result, nil
+}
+
 func (s *giftMessageService) ListToUser(ctx context.Context, roomDisplayId string) ([]*model.ToUser, error) {
 	return model.GetToUsersByRoomDisplayId(roomDisplayId)
 }
 
 func (s *giftMessageService) ListGiftMessagePageWithCondition(ctx context.Context, req *validate.GiftMessageQuery) ([]*model.GiftMessage, int64, error) {
-	giftMessages, total, err := model.GetGiftMessageWithConditionPage(req)
+	var (
+		giftMessages []*model.GiftMessage
+		total        int64
+		err          error
+	)
+	if s.analyticsClient != nil {
+		giftMessages, total, err = analytics.ListGiftMessages(ctx, s.analyticsClient, req)
+	} else {
+		giftMessages, total, err = model.GetGiftMessageWithConditionPage(req)
+	}
 	if err != nil {
 		logger.Error().
 			Err(err).
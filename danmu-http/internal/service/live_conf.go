@@ -8,9 +8,11 @@ import (
 	"danmu-http/middleware"
 	"danmu-http/rpc"
 	api "danmu-http/rpc/proto"
-	"errors"
+	"danmu-http/tracing"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
 )
 
@@ -20,6 +22,11 @@ type LiveConfService interface {
 	UpdateLiveConf(ctx context.Context, req *validate.LiveConfUpdateRequest) error
 	DeleteLiveConf(ctx context.Context, id int64) error
 	GetLiveConfById(ctx context.Context, id int64) (*model.LiveConf, error)
+	GetTask(ctx context.Context, id int64) (*api.Task, error)
+	ListTasks(ctx context.Context) ([]*api.Task, error)
+	SetEnable(ctx context.Context, id int64, enable bool) (*api.Task, error)
+	RestartTask(ctx context.Context, id int64) (*api.Task, error)
+	ImportLiveConf(ctx context.Context, rows []validate.LiveConfImportRow) ([]*ImportResult, error)
 }
 
 type liveConfService struct {
@@ -33,12 +40,21 @@ func (s *liveConfService) ListLiveConf(ctx context.Context) ([]*model.LiveConf,
 	return model.GetAllLiveConf()
 }
 
-func (s *liveConfService) AddLiveConf(ctx context.Context, req *validate.LiveConfAddRequest) error {
+func (s *liveConfService) AddLiveConf(ctx context.Context, req *validate.LiveConfAddRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "liveConfService.AddLiveConf")
+	span.SetAttributes(attribute.String("room_display_id", req.RoomDisplayID))
+	defer func() { tracing.End(span, err) }()
+
 	auth, err := middleware.GetAuthFromContext(ctx)
 	if err != nil {
 		return err
 	}
+	_, err = s.addLiveConf(ctx, auth, req)
+	return err
+}
 
+// addLiveConf 写入数据库并通知 danmu-core 创建任务，rpc 失败时回滚
+func (s *liveConfService) addLiveConf(ctx context.Context, auth *model.Auth, req *validate.LiveConfAddRequest) (int64, error) {
 	if auth.Name == "" {
 		auth.Name = "system"
 	}
@@ -56,13 +72,15 @@ func (s *liveConfService) AddLiveConf(ctx context.Context, req *validate.LiveCon
 		URL:           req.URL,
 		Name:          req.Name,
 		Enable:        req.Enable,
+		Cron:          req.Cron,
+		Handlers:      strings.Join(req.Handlers, ","),
 		ModifiedBy:    auth.Email,
 		CratedBy:      auth.Email,
 		ModifiedOn:    now,
 		CreatedOn:     now,
 	}
 
-	err = model.DB.Transaction(func(tx *gorm.DB) error {
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
 		if err := liveConf.Insert(tx); err != nil {
 			logger.Error().
 				Err(err).
@@ -78,31 +96,27 @@ func (s *liveConfService) AddLiveConf(ctx context.Context, req *validate.LiveCon
 			return err
 		}
 
-		ctx, cancel := rpc.WithTimeout(5 * time.Second)
+		ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
 		defer cancel()
 
-		rpcReq := &api.LiveConf{
-			Id:            liveConf.ID,
-			RoomDisplayId: liveConf.RoomDisplayID,
-			Url:           liveConf.URL,
-			Name:          liveConf.Name,
-			Enable:        liveConf.Enable,
+		rpcReq := &api.AddTaskRequest{
+			Conf:           toRPCConf(liveConf),
+			IdempotencyKey: req.IdempotencyKey,
 		}
-		res, err := rpcClient.AddTask(ctx, rpcReq)
-		if err != nil {
+		if _, err := rpcClient.AddTask(ctx, rpcReq); err != nil {
 			logger.Error().Err(err).Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("add live conf to rpc failed")
 			return err
 		}
-		if res.Code != 200 {
-			logger.Error().Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("add live conf to rpc failed")
-			return errors.New(res.Message)
-		}
 		return nil
 	})
-	return err
+	return liveConf.ID, err
 }
 
-func (s *liveConfService) UpdateLiveConf(ctx context.Context, req *validate.LiveConfUpdateRequest) error {
+func (s *liveConfService) UpdateLiveConf(ctx context.Context, req *validate.LiveConfUpdateRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "liveConfService.UpdateLiveConf")
+	span.SetAttributes(attribute.Int64("conf_id", req.ID), attribute.String("room_display_id", req.RoomDisplayID))
+	defer func() { tracing.End(span, err) }()
+
 	auth, err := middleware.GetAuthFromContext(ctx)
 	if err != nil {
 		return err
@@ -125,6 +139,8 @@ func (s *liveConfService) UpdateLiveConf(ctx context.Context, req *validate.Live
 	conf.URL = req.URL
 	conf.Name = req.Name
 	conf.Enable = req.Enable
+	conf.Cron = req.Cron
+	conf.Handlers = strings.Join(req.Handlers, ",")
 	conf.ModifiedBy = auth.Email
 	conf.ModifiedOn = time.Now().Unix()
 
@@ -140,31 +156,36 @@ func (s *liveConfService) UpdateLiveConf(ctx context.Context, req *validate.Live
 			return err
 		}
 
-		ctx, cancel := rpc.WithTimeout(5 * time.Second)
+		ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
 		defer cancel()
 
-		rpcReq := &api.LiveConf{
-			Id:            conf.ID,
-			RoomDisplayId: conf.RoomDisplayID,
-			Url:           conf.URL,
-			Name:          conf.Name,
-			Enable:        conf.Enable,
+		rpcReq := &api.UpdateTaskRequest{
+			Conf:           toRPCConf(conf),
+			IdempotencyKey: req.IdempotencyKey,
+		}
+		_, err = rpcClient.UpdateTask(ctx, rpcReq)
+		if rpc.IsTaskNotFound(err) {
+			// core 中没有该任务时按新配置创建，使数据库和 core 重新一致
+			logger.Warn().Int64("conf_id", conf.ID).Msg("task not found in core, adding it")
+			_, err = rpcClient.AddTask(ctx, &api.AddTaskRequest{
+				Conf:           toRPCConf(conf),
+				IdempotencyKey: req.IdempotencyKey,
+			})
 		}
-		res, err := rpcClient.UpdateTask(ctx, rpcReq)
 		if err != nil {
 			logger.Error().Err(err).Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("update live conf to rpc failed")
 			return err
 		}
-		if res.Code != 200 {
-			logger.Error().Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("update live conf to rpc failed")
-			return errors.New(res.Message)
-		}
 		return nil
 	})
 	return err
 }
 
-func (s *liveConfService) DeleteLiveConf(ctx context.Context, id int64) error {
+func (s *liveConfService) DeleteLiveConf(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "liveConfService.DeleteLiveConf")
+	span.SetAttributes(attribute.Int64("conf_id", id))
+	defer func() { tracing.End(span, err) }()
+
 	auth, err := middleware.GetAuthFromContext(ctx)
 	if err != nil {
 		return err
@@ -181,10 +202,10 @@ func (s *liveConfService) DeleteLiveConf(ctx context.Context, id int64) error {
 		return err
 	}
 
-	ctx, cancel := rpc.WithTimeout(5 * time.Second)
+	ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	if _, err := rpcClient.DeleteTask(ctx, &api.TaskID{Id: id}); err != nil {
+	if _, err := rpcClient.DeleteTask(ctx, &api.DeleteTaskRequest{Id: id}); err != nil {
 		logger.Error().Err(err).Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("delete live conf to rpc failed")
 		return err
 	}
@@ -199,3 +220,114 @@ func (s *liveConfService) DeleteLiveConf(ctx context.Context, id int64) error {
 func (s *liveConfService) GetLiveConfById(ctx context.Context, id int64) (*model.LiveConf, error) {
 	return model.GetLiveConfById(id)
 }
+
+func (s *liveConfService) GetTask(ctx context.Context, id int64) (*api.Task, error) {
+	rpcClient, err := rpc.GetClient()
+	if err != nil {
+		logger.Error().Err(err).Msg("get rpc client failed")
+		return nil, err
+	}
+
+	ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return rpcClient.GetTask(ctx, &api.TaskID{Id: id})
+}
+
+func (s *liveConfService) ListTasks(ctx context.Context) ([]*api.Task, error) {
+	rpcClient, err := rpc.GetClient()
+	if err != nil {
+		logger.Error().Err(err).Msg("get rpc client failed")
+		return nil, err
+	}
+
+	ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res, err := rpcClient.ListTasks(ctx, &api.ListTasksRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Tasks, nil
+}
+
+// SetEnable 启动或停止任务，并将 enable 持久化到 live_confs
+func (s *liveConfService) SetEnable(ctx context.Context, id int64, enable bool) (task *api.Task, err error) {
+	ctx, span := tracing.Start(ctx, "liveConfService.SetEnable")
+	span.SetAttributes(attribute.Int64("conf_id", id), attribute.Bool("enable", enable))
+	defer func() { tracing.End(span, err) }()
+
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info().
+		Str("operator", auth.Email).
+		Int64("conf_id", id).
+		Bool("enable", enable).
+		Msg("switching live task")
+
+	conf, err := model.GetLiveConfById(id)
+	if err != nil {
+		logger.Error().Err(err).Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("get live conf by id failed")
+		return nil, err
+	}
+	conf.Enable = enable
+	conf.ModifiedBy = auth.Email
+	conf.ModifiedOn = time.Now().Unix()
+
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := conf.Update(tx); err != nil {
+			logger.Error().Err(err).Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("update live conf failed")
+			return err
+		}
+
+		rpcClient, err := rpc.GetClient()
+		if err != nil {
+			logger.Error().Err(err).Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("get rpc client failed")
+			return err
+		}
+
+		ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		if enable {
+			task, err = rpcClient.StartTask(ctx, &api.TaskID{Id: id})
+		} else {
+			task, err = rpcClient.StopTask(ctx, &api.TaskID{Id: id})
+		}
+		if err != nil {
+			logger.Error().Err(err).Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("switch live task to rpc failed")
+			return err
+		}
+		return nil
+	})
+	return task, err
+}
+
+func (s *liveConfService) RestartTask(ctx context.Context, id int64) (task *api.Task, err error) {
+	ctx, span := tracing.Start(ctx, "liveConfService.RestartTask")
+	span.SetAttributes(attribute.Int64("conf_id", id))
+	defer func() { tracing.End(span, err) }()
+
+	rpcClient, err := rpc.GetClient()
+	if err != nil {
+		logger.Error().Err(err).Msg("get rpc client failed")
+		return nil, err
+	}
+
+	ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return rpcClient.RestartTask(ctx, &api.TaskID{Id: id})
+}
+
+func toRPCConf(conf *model.LiveConf) *api.LiveConf {
+	return &api.LiveConf{
+		Id:            conf.ID,
+		RoomDisplayId: conf.RoomDisplayID,
+		Url:           conf.URL,
+		Name:          conf.Name,
+		Enable:        conf.Enable,
+		Cron:          conf.Cron,
+		Handlers:      conf.HandlerNames(),
+	}
+}
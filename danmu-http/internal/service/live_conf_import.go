@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"danmu-http/internal/model"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"danmu-http/middleware"
+	"danmu-http/tracing"
+	"danmu-http/utils"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	ImportCreated   = "created"
+	ImportDuplicate = "duplicate"
+	ImportInvalid   = "invalid"
+	ImportFailed    = "failed"
+)
+
+// importWorkers 同时解析链接的数量，短链接和主页链接需要请求抖音
+const importWorkers = 5
+
+// ImportResult 批量导入中每一行的处理结果
+type ImportResult struct {
+	Row           int    `json:"row"` // 从1开始
+	Input         string `json:"input"`
+	URL           string `json:"url,omitempty"`
+	RoomDisplayID string `json:"room_display_id,omitempty"`
+	Name          string `json:"name,omitempty"`
+	ID            int64  `json:"id,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ImportLiveConf 并发解析链接后按行顺序创建配置，单行失败不影响其他行
+func (s *liveConfService) ImportLiveConf(ctx context.Context, rows []validate.LiveConfImportRow) (results []*ImportResult, err error) {
+	ctx, span := tracing.Start(ctx, "liveConfService.ImportLiveConf")
+	span.SetAttributes(attribute.Int("rows", len(rows)))
+	defer func() { tracing.End(span, err) }()
+
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := model.GetAllLiveConf()
+	if err != nil {
+		logger.Error().Err(err).Str("auth_id", auth.ID).Str("auth_name", auth.Name).Msg("get all live conf failed")
+		return nil, err
+	}
+	seen := make(map[string]bool, len(existing)*2)
+	for _, conf := range existing {
+		seen[conf.RoomDisplayID] = true
+		seen[conf.URL] = true
+	}
+
+	results = resolveImportRows(ctx, rows)
+	for i, row := range rows {
+		result := results[i]
+		if result.Status == ImportInvalid {
+			continue
+		}
+		canonical, webRid := result.URL, result.RoomDisplayID
+		if seen[webRid] || seen[canonical] {
+			result.Status = ImportDuplicate
+			continue
+		}
+
+		enable := true
+		if row.Enable != nil {
+			enable = *row.Enable
+		}
+		req := &validate.LiveConfAddRequest{
+			RoomDisplayID: webRid,
+			URL:           canonical,
+			Name:          result.Name,
+			Enable:        enable,
+			Cron:          row.Cron,
+			Handlers:      row.Handlers,
+		}
+		id, err := s.addLiveConf(ctx, auth, req)
+		if err != nil {
+			result.Status = ImportFailed
+			result.Error = err.Error()
+			continue
+		}
+		// 只记录创建成功的直播间，失败的行在后面重复出现时仍会尝试创建
+		seen[webRid] = true
+		seen[canonical] = true
+		result.ID = id
+		result.Status = ImportCreated
+	}
+
+	logger.Info().
+		Str("operator", auth.Email).
+		Int("rows", len(rows)).
+		Msg("imported live configurations")
+	return results, nil
+}
+
+// resolveImportRows 用 importWorkers 个 goroutine 解析每一行的链接，解析失败的行状态为 ImportInvalid
+func resolveImportRows(ctx context.Context, rows []validate.LiveConfImportRow) []*ImportResult {
+	results := make([]*ImportResult, len(rows))
+	for i, row := range rows {
+		results[i] = &ImportResult{Row: i + 1, Input: row.URL}
+	}
+
+	tasks := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < min(importWorkers, len(rows)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				result := results[i]
+				canonical, webRid, err := utils.NormalizeDouyinURL(ctx, rows[i].URL)
+				if err != nil {
+					result.Status = ImportInvalid
+					result.Error = err.Error()
+					continue
+				}
+				result.URL = canonical
+				result.RoomDisplayID = webRid
+				result.Name = rows[i].Name
+				if result.Name == "" {
+					result.Name = webRid
+				}
+			}
+		}()
+	}
+	for i := range rows {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"danmu-http/internal/validate"
+	"fmt"
+	"testing"
+)
+
+func TestResolveImportRows(t *testing.T) {
+	rows := make([]validate.LiveConfImportRow, 0, 3*importWorkers)
+	for i := 0; i < cap(rows); i++ {
+		url := fmt.Sprintf("live.douyin.com/%d?enter_from=share", 1000+i)
+		if i%4 == 3 {
+			url = "https://example.com/" + fmt.Sprint(i)
+		}
+		rows = append(rows, validate.LiveConfImportRow{URL: url})
+	}
+	rows[0].Name = "first"
+
+	results := resolveImportRows(context.Background(), rows)
+	if len(results) != len(rows) {
+		t.Fatalf("got %d results, want %d", len(results), len(rows))
+	}
+	for i, r := range results {
+		if r.Row != i+1 || r.Input != rows[i].URL {
+			t.Fatalf("result %d = row %d input %q, want rows in order", i, r.Row, r.Input)
+		}
+		if i%4 == 3 {
+			if r.Status != ImportInvalid || r.Error == "" {
+				t.Errorf("row %d: status %q error %q, want invalid", r.Row, r.Status, r.Error)
+			}
+			continue
+		}
+		webRid := fmt.Sprint(1000 + i)
+		if r.Status != "" || r.RoomDisplayID != webRid || r.URL != "https://live.douyin.com/"+webRid {
+			t.Errorf("row %d: got %+v, want web_rid %s", r.Row, r, webRid)
+		}
+	}
+	if results[0].Name != "first" || results[1].Name != "1001" {
+		t.Errorf("names = %q, %q, want first, 1001", results[0].Name, results[1].Name)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"danmu-http/internal/model"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"danmu-http/middleware"
+
+	"gorm.io/gorm"
+)
+
+type ModerationService interface {
+	ListChatFlags(ctx context.Context, req *validate.ChatFlagListRequest) ([]*model.ChatFlag, int64, error)
+	GetChatFlag(ctx context.Context, roomDisplayId string, userID uint64) (*model.ChatFlag, error)
+	// DeleteChatFlag 忽略误判的标记
+	DeleteChatFlag(ctx context.Context, roomDisplayId string, userID uint64) error
+}
+
+type moderationService struct {
+}
+
+func NewModerationService() ModerationService {
+	return &moderationService{}
+}
+
+func (s *moderationService) ListChatFlags(ctx context.Context, req *validate.ChatFlagListRequest) ([]*model.ChatFlag, int64, error) {
+	flags, total, err := model.GetChatFlagsPage(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, flag := range flags {
+		flag.FillEvidence()
+	}
+	return flags, total, nil
+}
+
+func (s *moderationService) GetChatFlag(ctx context.Context, roomDisplayId string, userID uint64) (*model.ChatFlag, error) {
+	flag, err := model.GetChatFlag(roomDisplayId, userID)
+	if err != nil {
+		return nil, err
+	}
+	flag.FillEvidence()
+	return flag, nil
+}
+
+func (s *moderationService) DeleteChatFlag(ctx context.Context, roomDisplayId string, userID uint64) error {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := model.DeleteChatFlag(roomDisplayId, userID)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	logger.Info().
+		Str("operator", auth.Email).
+		Str("room_id", roomDisplayId).
+		Uint64("user_id", userID).
+		Msg("delete chat flag")
+	return nil
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"danmu-http/internal/model"
+	"danmu-http/internal/overlay"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"danmu-http/middleware"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// overlayTokenBytes token 的随机字节数，base64 编码后为 32 个字符
+const overlayTokenBytes = 24
+
+type OverlayService interface {
+	ListOverlays(ctx context.Context, roomDisplayId string) ([]*model.Overlay, error)
+	GetOverlay(ctx context.Context, id int64) (*model.Overlay, error)
+	AddOverlay(ctx context.Context, req *validate.OverlayAddRequest) (*model.Overlay, error)
+	UpdateOverlay(ctx context.Context, req *validate.OverlayUpdateRequest) (*model.Overlay, error)
+	DeleteOverlay(ctx context.Context, id int64) error
+	// RotateToken 更换 token，旧地址立即失效
+	RotateToken(ctx context.Context, id int64) (*model.Overlay, error)
+	// ResetOverlay 礼物榜和目标从当前时间重新开始统计
+	ResetOverlay(ctx context.Context, id int64) (*model.Overlay, error)
+	GetOverlayByToken(ctx context.Context, token string) (*model.Overlay, error)
+	// Serve 向页面推送实时消息，阻塞直到连接断开
+	Serve(o *model.Overlay, kind string, conn *websocket.Conn)
+}
+
+type overlayService struct {
+	manager     *overlay.Manager
+	giftService GiftMessageService
+}
+
+func NewOverlayService() OverlayService {
+	s := &overlayService{giftService: NewGiftMessageService()}
+	s.manager = overlay.NewManager(s.loadGiftTotals)
+	return s
+}
+
+func (s *overlayService) ListOverlays(ctx context.Context, roomDisplayId string) ([]*model.Overlay, error) {
+	overlays, err := model.GetOverlays(roomDisplayId)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overlays {
+		fillOverlayURLs(o)
+	}
+	return overlays, nil
+}
+
+func (s *overlayService) GetOverlay(ctx context.Context, id int64) (*model.Overlay, error) {
+	o, err := model.GetOverlayById(id)
+	if err != nil {
+		return nil, err
+	}
+	fillOverlayURLs(o)
+	return o, nil
+}
+
+func (s *overlayService) AddOverlay(ctx context.Context, req *validate.OverlayAddRequest) (*model.Overlay, error) {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := newOverlayToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	o := &model.Overlay{
+		Name:          req.Name,
+		Token:         token,
+		RoomDisplayId: req.RoomDisplayId,
+		Theme:         req.Theme,
+		CustomCSS:     req.CustomCSS,
+		MinDiamond:    req.MinDiamond,
+		AlertDuration: req.AlertDuration,
+		AlertQueue:    req.AlertQueue,
+		ChatLimit:     req.ChatLimit,
+		ShowMember:    req.ShowMember,
+		TopCount:      req.TopCount,
+		GoalTitle:     req.GoalTitle,
+		GoalType:      req.GoalType,
+		GoalGiftID:    req.GoalGiftID,
+		GoalTarget:    req.GoalTarget,
+		ResetAt:       now.UnixMilli(),
+		CreatedOn:     now.Unix(),
+		ModifiedOn:    now.Unix(),
+		ModifiedBy:    auth.Email,
+	}
+	if o.Theme == "" {
+		o.Theme = overlay.DefaultTheme
+	}
+	if err := o.Insert(); err != nil {
+		return nil, err
+	}
+	logger.Info().
+		Str("operator", auth.Email).
+		Int64("overlay", o.ID).
+		Str("room_id", o.RoomDisplayId).
+		Msg("add overlay")
+	fillOverlayURLs(o)
+	return o, nil
+}
+
+// UpdateOverlay 保存后通知已打开的页面刷新
+func (s *overlayService) UpdateOverlay(ctx context.Context, req *validate.OverlayUpdateRequest) (*model.Overlay, error) {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o, err := model.GetOverlayById(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	o.Name = req.Name
+	o.RoomDisplayId = req.RoomDisplayId
+	o.Theme = req.Theme
+	o.CustomCSS = req.CustomCSS
+	o.MinDiamond = req.MinDiamond
+	o.AlertDuration = req.AlertDuration
+	o.AlertQueue = req.AlertQueue
+	o.ChatLimit = req.ChatLimit
+	o.ShowMember = req.ShowMember
+	o.TopCount = req.TopCount
+	o.GoalTitle = req.GoalTitle
+	o.GoalType = req.GoalType
+	o.GoalGiftID = req.GoalGiftID
+	o.GoalTarget = req.GoalTarget
+	o.ModifiedOn = time.Now().Unix()
+	o.ModifiedBy = auth.Email
+	if o.Theme == "" {
+		o.Theme = overlay.DefaultTheme
+	}
+	if err := o.Update(); err != nil {
+		return nil, err
+	}
+	s.manager.Reload(o.ID)
+	fillOverlayURLs(o)
+	return o, nil
+}
+
+func (s *overlayService) DeleteOverlay(ctx context.Context, id int64) error {
+	rows, err := model.DeleteOverlayById(id)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	s.manager.Close(id)
+	return nil
+}
+
+func (s *overlayService) RotateToken(ctx context.Context, id int64) (*model.Overlay, error) {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o, err := model.GetOverlayById(id)
+	if err != nil {
+		return nil, err
+	}
+	if o.Token, err = newOverlayToken(); err != nil {
+		return nil, err
+	}
+	o.ModifiedOn = time.Now().Unix()
+	o.ModifiedBy = auth.Email
+	if err := o.Update(); err != nil {
+		return nil, err
+	}
+	logger.Info().Str("operator", auth.Email).Int64("overlay", o.ID).Msg("rotate overlay token")
+	s.manager.Close(o.ID)
+	fillOverlayURLs(o)
+	return o, nil
+}
+
+func (s *overlayService) ResetOverlay(ctx context.Context, id int64) (*model.Overlay, error) {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o, err := model.GetOverlayById(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	o.ResetAt = now.UnixMilli()
+	o.ModifiedOn = now.Unix()
+	o.ModifiedBy = auth.Email
+	if err := o.Update(); err != nil {
+		return nil, err
+	}
+	s.manager.Reload(o.ID)
+	fillOverlayURLs(o)
+	return o, nil
+}
+
+func (s *overlayService) GetOverlayByToken(ctx context.Context, token string) (*model.Overlay, error) {
+	return model.GetOverlayByToken(token)
+}
+
+func (s *overlayService) Serve(o *model.Overlay, kind string, conn *websocket.Conn) {
+	s.manager.Serve(o, kind, conn)
+}
+
+// loadGiftTotals 复用礼物排行的连击处理，按用户合计所有接收者
+func (s *overlayService) loadGiftTotals(ctx context.Context, roomDisplayId string, begin, end int64) ([]*overlay.GiftTotal, error) {
+	ranking, err := s.giftService.ListGiftRanking(ctx, &validate.ListGiftRankingRequest{
+		RoomDisplayId: roomDisplayId,
+		Begin:         begin,
+		End:           end,
+	})
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[uint64]*overlay.GiftTotal)
+	totals := make([]*overlay.GiftTotal, 0, len(ranking))
+	for _, r := range ranking {
+		total, ok := index[r.UserID]
+		if !ok {
+			total = &overlay.GiftTotal{
+				UserID:   r.UserID,
+				UserName: r.UserName,
+				Gifts:    make(map[int64]int64),
+			}
+			index[r.UserID] = total
+			totals = append(totals, total)
+		}
+		total.Diamonds += r.Total
+		for _, g := range r.GiftList {
+			total.Gifts[g.GiftID] += g.ComboCount
+		}
+	}
+	return totals, nil
+}
+
+func newOverlayToken() (string, error) {
+	b := make([]byte, overlayTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate overlay token error: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// fillOverlayURLs 页面地址，添加到 OBS 浏览器源时需要加上 danmu-http 的地址
+func fillOverlayURLs(o *model.Overlay) {
+	o.URLs = make(map[string]string, len(overlay.Kinds))
+	for _, kind := range overlay.Kinds {
+		o.URLs[kind] = "/overlay/" + o.Token + "/" + kind
+	}
+}
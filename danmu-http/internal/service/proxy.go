@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"danmu-http/internal/validate"
+	"danmu-http/logger"
+	"danmu-http/middleware"
+	"danmu-http/rpc"
+	api "danmu-http/rpc/proto"
+	"net/url"
+	"time"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ProxyService 管理 danmu-core 的出站代理池，修改只保存在 danmu-core 内存中，重启后以配置为准
+type ProxyService interface {
+	ListProxies(ctx context.Context) ([]*api.ProxyStatus, error)
+	// AddProxy 添加后 danmu-core 立即检查一次，返回检查结果
+	AddProxy(ctx context.Context, req *validate.ProxyRequest) (*api.ProxyStatus, error)
+	RemoveProxy(ctx context.Context, req *validate.ProxyRequest) error
+	// RotateProxy 切换任务使用的代理，返回新代理，为空表示直接连接
+	RotateProxy(ctx context.Context, req *validate.ProxyRotateRequest) (string, error)
+}
+
+type proxyService struct {
+}
+
+func NewProxyService() ProxyService {
+	return &proxyService{}
+}
+
+func (s *proxyService) ListProxies(ctx context.Context) ([]*api.ProxyStatus, error) {
+	rpcClient, err := rpc.GetClient()
+	if err != nil {
+		logger.Error().Err(err).Msg("get rpc client failed")
+		return nil, err
+	}
+	ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res, err := rpcClient.ListProxies(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Proxies, nil
+}
+
+func (s *proxyService) AddProxy(ctx context.Context, req *validate.ProxyRequest) (*api.ProxyStatus, error) {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rpcClient, err := rpc.GetClient()
+	if err != nil {
+		logger.Error().Err(err).Msg("get rpc client failed")
+		return nil, err
+	}
+	// 添加时同步执行一次健康检查，超时时间需大于 danmu-core 的 CheckTimeout
+	ctx, cancel := rpc.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	status, err := rpcClient.AddProxy(ctx, &api.ProxyRequest{Url: req.URL})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info().
+		Str("operator", auth.Email).
+		Str("proxy", status.Url).
+		Bool("healthy", status.Healthy).
+		Msg("add proxy")
+	return status, nil
+}
+
+func (s *proxyService) RemoveProxy(ctx context.Context, req *validate.ProxyRequest) error {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	rpcClient, err := rpc.GetClient()
+	if err != nil {
+		logger.Error().Err(err).Msg("get rpc client failed")
+		return err
+	}
+	ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := rpcClient.RemoveProxy(ctx, &api.ProxyRequest{Url: req.URL}); err != nil {
+		return err
+	}
+	logger.Info().
+		Str("operator", auth.Email).
+		Str("proxy", redactProxy(req.URL)).
+		Msg("remove proxy")
+	return nil
+}
+
+func (s *proxyService) RotateProxy(ctx context.Context, req *validate.ProxyRotateRequest) (string, error) {
+	auth, err := middleware.GetAuthFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	rpcClient, err := rpc.GetClient()
+	if err != nil {
+		logger.Error().Err(err).Msg("get rpc client failed")
+		return "", err
+	}
+	ctx, cancel := rpc.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res, err := rpcClient.RotateProxy(ctx, &api.TaskID{Id: req.ID})
+	if err != nil {
+		return "", err
+	}
+	logger.Info().
+		Str("operator", auth.Email).
+		Int64("id", req.ID).
+		Str("proxy", res.Proxy).
+		Msg("rotate proxy")
+	return res.Proxy, nil
+}
+
+// redactProxy 日志中不记录代理的用户名密码
+func redactProxy(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"danmu-http/internal/model"
+	"danmu-http/internal/validate"
+)
+
+type RoomService interface {
+	GetLatestSnapshot(ctx context.Context, roomDisplayId string) (*model.RoomSnapshot, error)
+	ListSnapshots(ctx context.Context, roomDisplayId string, req *validate.RoomSnapshotQuery) ([]*model.RoomSnapshot, int64, error)
+	ListTitles(ctx context.Context, roomDisplayId string) ([]*model.RoomTitle, error)
+}
+
+type roomService struct {
+}
+
+func NewRoomService() RoomService {
+	return &roomService{}
+}
+
+func (s *roomService) GetLatestSnapshot(ctx context.Context, roomDisplayId string) (*model.RoomSnapshot, error) {
+	return model.GetLatestRoomSnapshot(roomDisplayId)
+}
+
+func (s *roomService) ListSnapshots(ctx context.Context, roomDisplayId string, req *validate.RoomSnapshotQuery) ([]*model.RoomSnapshot, int64, error) {
+	return model.GetRoomSnapshotsPage(roomDisplayId, req.Begin, req.End, req.Page, req.PageSize)
+}
+
+func (s *roomService) ListTitles(ctx context.Context, roomDisplayId string) ([]*model.RoomTitle, error) {
+	return model.GetRoomTitles(roomDisplayId, 0)
+}
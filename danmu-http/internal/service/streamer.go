@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"danmu-http/internal/model"
+	"danmu-http/internal/validate"
+)
+
+// StreamerDetail 主播信息及其关联的直播配置
+type StreamerDetail struct {
+	*model.Streamer
+	LiveConfs []*model.LiveConf `json:"live_confs"`
+}
+
+type StreamerService interface {
+	SearchStreamer(ctx context.Context, req *validate.StreamerSearchRequest) ([]*model.Streamer, int64, error)
+	GetStreamer(ctx context.Context, id int64) (*StreamerDetail, error)
+	ListSessions(ctx context.Context, id int64, req *validate.StreamerSessionRequest) ([]*model.StreamerSession, int64, error)
+	ListTitles(ctx context.Context, id int64) ([]*model.RoomTitle, error)
+}
+
+type streamerService struct {
+}
+
+func NewStreamerService() StreamerService {
+	return &streamerService{}
+}
+
+func (s *streamerService) SearchStreamer(ctx context.Context, req *validate.StreamerSearchRequest) ([]*model.Streamer, int64, error) {
+	return model.SearchStreamer(req.Page, req.PageSize, req.Keyword)
+}
+
+func (s *streamerService) GetStreamer(ctx context.Context, id int64) (*StreamerDetail, error) {
+	streamer, err := model.GetStreamerById(id)
+	if err != nil {
+		return nil, err
+	}
+	confs, err := model.GetLiveConfsByStreamerID(id)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamerDetail{Streamer: streamer, LiveConfs: confs}, nil
+}
+
+// ListSessions 直播场次，附带该场次最近一次快照中的标题、封面和分类
+func (s *streamerService) ListSessions(ctx context.Context, id int64, req *validate.StreamerSessionRequest) ([]*model.StreamerSession, int64, error) {
+	sessions, total, err := model.GetStreamerSessionsPage(id, req.Page, req.PageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	roomIds := make([]int64, 0, len(sessions))
+	for _, session := range sessions {
+		roomIds = append(roomIds, session.RoomID)
+	}
+	snapshots, err := model.GetLatestRoomSnapshotsByRoomIds(roomIds)
+	if err != nil {
+		return nil, 0, err
+	}
+	byRoom := make(map[int64]*model.RoomSnapshot, len(snapshots))
+	for _, snapshot := range snapshots {
+		byRoom[snapshot.RoomID] = snapshot
+	}
+	for _, session := range sessions {
+		if snapshot, ok := byRoom[session.RoomID]; ok {
+			session.Title = snapshot.Title
+			session.Cover = snapshot.Cover
+			session.Category = snapshot.Category
+		}
+	}
+	return sessions, total, nil
+}
+
+func (s *streamerService) ListTitles(ctx context.Context, id int64) ([]*model.RoomTitle, error) {
+	return model.GetRoomTitles("", id)
+}
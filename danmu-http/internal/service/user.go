@@ -4,11 +4,38 @@ import (
 	"context"
 	"danmu-http/internal/model"
 	"danmu-http/internal/validate"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 type UserService interface {
 	ListAllUsers(ctx context.Context, req *validate.UserPageRequest) ([]*model.User, int64, error)
 	SearchUser(ctx context.Context, req *validate.UserSearchRequest) ([]*model.User, int64, error)
+	GetUser(ctx context.Context, userID uint64) (*UserDetail, error)
+	GetProfile(ctx context.Context, userID uint64, req *validate.UserProfileRequest) (*UserProfile, error)
+}
+
+// UserDetail 用户资料及昵称变更记录
+type UserDetail struct {
+	*model.User
+	NameHistory []*model.UserNameHistory `json:"name_history"`
+}
+
+// UserProfile 用户在所有直播间的活动汇总
+type UserProfile struct {
+	User         *model.User                `json:"user"` // 没有用户资料时为 null
+	FirstSeen    int64                      `json:"first_seen"`
+	LastSeen     int64                      `json:"last_seen"`
+	ChatCount    int64                      `json:"chat_count"`
+	GiftCount    int64                      `json:"gift_count"`
+	DiamondTotal int64                      `json:"diamond_total"`
+	Rooms        []*model.UserRoomStat      `json:"rooms"`
+	Recipients   []*model.UserRecipientStat `json:"recipients"`
+	TopGifts     []*model.UserTopGift       `json:"top_gifts"`
+	FansClubs    []*model.UserFansClub      `json:"fans_clubs"`
+	Timeline     []*model.UserDailyActivity `json:"timeline"`
 }
 
 type userService struct {
@@ -25,3 +52,71 @@ func (s *userService) ListAllUsers(ctx context.Context, req *validate.UserPageRe
 func (s *userService) SearchUser(ctx context.Context, req *validate.UserSearchRequest) ([]*model.User, int64, error) {
 	return model.SearchUser(req.Page, req.PageSize, req.Keyword)
 }
+
+func (s *userService) GetUser(ctx context.Context, userID uint64) (*UserDetail, error) {
+	user, err := model.GetUserByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := model.GetUserNameHistory(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &UserDetail{User: user, NameHistory: history}, nil
+}
+
+// GetProfile 汇总 danmu-core 增量维护的用户统计表，用户资料和统计都不存在时返回 gorm.ErrRecordNotFound
+func (s *userService) GetProfile(ctx context.Context, userID uint64, req *validate.UserProfileRequest) (*UserProfile, error) {
+	days, top := req.Days, req.Top
+	if days == 0 {
+		days = 30
+	}
+	if top == 0 {
+		top = 10
+	}
+
+	user, err := model.GetUserByUserID(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	rooms, err := model.GetUserRoomStats(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil && len(rooms) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	profile := &UserProfile{User: user, Rooms: rooms}
+	for _, room := range rooms {
+		profile.ChatCount += room.ChatCount
+		profile.GiftCount += room.GiftCount
+		profile.DiamondTotal += room.DiamondTotal
+		if profile.FirstSeen == 0 || room.FirstSeen < profile.FirstSeen {
+			profile.FirstSeen = room.FirstSeen
+		}
+		profile.LastSeen = max(profile.LastSeen, room.LastSeen)
+	}
+	if user != nil {
+		if user.FirstSeen != 0 && (profile.FirstSeen == 0 || user.FirstSeen < profile.FirstSeen) {
+			profile.FirstSeen = user.FirstSeen
+		}
+		profile.LastSeen = max(profile.LastSeen, user.LastSeen)
+	}
+
+	if profile.Recipients, err = model.GetUserRecipientStats(userID); err != nil {
+		return nil, err
+	}
+	if profile.TopGifts, err = model.GetUserTopGifts(userID, top); err != nil {
+		return nil, err
+	}
+	if profile.FansClubs, err = model.GetUserFansClubs(userID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+	if profile.Timeline, err = model.GetUserDailyActivity(userID, since.UnixMilli()); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
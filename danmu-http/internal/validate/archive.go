@@ -0,0 +1,35 @@
+package validate
+
+type ArchiveListRequest struct {
+	MessageTable  string `form:"message_table" binding:"omitempty,oneof=common_messages gift_messages"`
+	RoomDisplayId string `form:"room_display_id" binding:"omitempty"`
+	Page          int    `form:"page" binding:"required,min=1"`
+	PageSize      int    `form:"page_size" binding:"required,min=1,max=500"`
+}
+
+// ArchiveCommonMessageQuery 查询已归档的消息，条件同 CommonMessageQuery，归档按直播间存储所以 room_display_id 必填
+type ArchiveCommonMessageQuery struct {
+	Search        string   `json:"search" binding:"omitempty"`
+	MessageType   []string `json:"message_type" binding:"omitempty"`
+	UserIDs       []uint64 `json:"user_ids" binding:"omitempty"`
+	RoomDisplayId string   `json:"room_display_id" binding:"required"`
+	Begin         int64    `json:"begin" binding:"omitempty,min=1"`
+	End           int64    `json:"end" binding:"omitempty,min=1"`
+	PageRequest
+}
+
+type ArchiveGiftMessageQuery struct {
+	Search        string   `json:"search" binding:"omitempty"`
+	UserIDs       []uint64 `json:"user_ids" binding:"omitempty"`
+	ToUserIds     []uint64 `json:"to_user_ids" binding:"omitempty"`
+	RoomDisplayId string   `json:"room_display_id" binding:"required"`
+	Begin         int64    `json:"begin" binding:"omitempty,min=1"`
+	End           int64    `json:"end" binding:"omitempty,min=1"`
+	DiamondCount  int64    `json:"diamond_count" binding:"omitempty,min=0"`
+	PageRequest
+}
+
+type RetentionPolicyRequest struct {
+	RoomDisplayId string `json:"room_display_id" binding:"required"`
+	RetainDays    *int   `json:"retain_days" binding:"required,min=0,max=36500"` // 0 表示永久保留
+}
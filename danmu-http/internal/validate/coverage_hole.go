@@ -0,0 +1,10 @@
+package validate
+
+type CoverageHoleListRequest struct {
+	RoomDisplayId string `form:"room_display_id" binding:"omitempty"`
+	RoomID        string `form:"room_id" binding:"omitempty"`     // 抖音 room_id，查询某一场直播
+	Begin         int64  `form:"begin" binding:"omitempty,min=1"` // 毫秒，返回与 [begin, end] 重叠的缺口
+	End           int64  `form:"end" binding:"omitempty,min=1"`
+	Page          int    `form:"page" binding:"required,min=1"`
+	PageSize      int    `form:"page_size" binding:"required,min=1,max=500"`
+}
@@ -0,0 +1,23 @@
+package validate
+
+type DeadLetterListRequest struct {
+	RoomDisplayId string `form:"room_display_id" binding:"omitempty"`
+	Handler       string `form:"handler" binding:"omitempty"`
+	Method        string `form:"method" binding:"omitempty"`
+	Begin         int64  `form:"begin" binding:"omitempty,min=1"` // 最近一次失败时间
+	End           int64  `form:"end" binding:"omitempty,min=1"`
+	Page          int    `form:"page" binding:"required,min=1"`
+	PageSize      int    `form:"page_size" binding:"required,min=1,max=500"`
+}
+
+type DeadLetterReplayRequest struct {
+	IDs []int64 `json:"ids" binding:"required,min=1,max=100,dive,min=1"`
+}
+
+// DeadLetterPurgeRequest before 必填，清除全部时传当前时间
+type DeadLetterPurgeRequest struct {
+	RoomDisplayId string `form:"room_display_id" binding:"omitempty"`
+	Handler       string `form:"handler" binding:"omitempty"`
+	Method        string `form:"method" binding:"omitempty"`
+	Before        int64  `form:"before" binding:"required,min=1"`
+}
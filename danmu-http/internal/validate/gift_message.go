@@ -11,7 +11,8 @@ type GiftMessageQuery struct {
 	Search        string   `json:"search" binding:"omitempty"`
 	UserIDs       []uint64 `json:"user_ids" binding:"omitempty"`
 	ToUserIds     []uint64 `json:"to_user_ids" binding:"omitempty"`
-	RoomDisplayId string   `json:"room_display_id" binding:"required"`
+	RoomDisplayId string   `json:"room_display_id" binding:"required_without=StreamerID"`
+	StreamerID    int64    `json:"streamer_id" binding:"omitempty,min=1"` // 查询主播在所有直播间的消息
 	Begin         int64    `json:"begin" binding:"omitempty,min=1"`
 	End           int64    `json:"end" binding:"omitempty,min=1"`
 	DiamondCount  int64    `json:"diamond_count" binding:"omitempty,min=0"`
@@ -1,16 +1,34 @@
 package validate
 
 type LiveConfAddRequest struct {
-	RoomDisplayID string `json:"room_display_id" binding:"required"`
-	URL           string `json:"url" binding:"required,url"`
-	Name          string `json:"name" binding:"required"`
-	Enable        bool   `json:"enable" binding:"required"`
+	RoomDisplayID  string   `json:"room_display_id" binding:"required"`
+	URL            string   `json:"url" binding:"required,url"`
+	Name           string   `json:"name" binding:"required"`
+	Enable         bool     `json:"enable" binding:"required"`
+	Cron           string   `json:"cron" binding:"omitempty"`
+	Handlers       []string `json:"handlers" binding:"omitempty,dive,required"`
+	IdempotencyKey string   `json:"-"` // 取自 Idempotency-Key 请求头
 }
 
 type LiveConfUpdateRequest struct {
-	ID            int64  `json:"id" binding:"required"`
-	RoomDisplayID string `json:"room_display_id" binding:"required"`
-	URL           string `json:"url" binding:"required,url"`
-	Name          string `json:"name" binding:"required"`
-	Enable        bool   `json:"enable" binding:"omitempty"`
+	ID             int64    `json:"id" binding:"required"`
+	RoomDisplayID  string   `json:"room_display_id" binding:"required"`
+	URL            string   `json:"url" binding:"required,url"`
+	Name           string   `json:"name" binding:"required"`
+	Enable         bool     `json:"enable" binding:"omitempty"`
+	Cron           string   `json:"cron" binding:"omitempty"`
+	Handlers       []string `json:"handlers" binding:"omitempty,dive,required"`
+	IdempotencyKey string   `json:"-"`
 }
+
+// LiveConfImportRow 批量导入中的一行，只有 url 必填
+type LiveConfImportRow struct {
+	URL      string   `json:"url"`
+	Name     string   `json:"name"`
+	Enable   *bool    `json:"enable"` // 为空时默认启用
+	Cron     string   `json:"cron"`
+	Handlers []string `json:"handlers"`
+}
+
+// MaxImportRows 单次导入的最大行数
+const MaxImportRows = 500
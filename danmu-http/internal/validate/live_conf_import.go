@@ -0,0 +1,113 @@
+package validate
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrTooManyRows = fmt.Errorf("too many rows, max %d", MaxImportRows)
+
+// ParseImportJSON 支持 [{"url": ...}] 或 {"rows": [{"url": ...}]}
+func ParseImportJSON(r io.Reader) ([]LiveConfImportRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var rows []LiveConfImportRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		var wrapped struct {
+			Rows []LiveConfImportRow `json:"rows"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, fmt.Errorf("parse json error: %w", err)
+		}
+		rows = wrapped.Rows
+	}
+	return checkRows(rows)
+}
+
+// ParseImportCSV 第一行为表头，支持 url,name,enable,cron,handlers 列，handlers 使用 | 分隔
+func ParseImportCSV(r io.Reader) ([]LiveConfImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header error: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, errors.New("csv header must contain url column")
+	}
+	get := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []LiveConfImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv error: %w", err)
+		}
+		row := LiveConfImportRow{
+			URL:  get(record, "url"),
+			Name: get(record, "name"),
+			Cron: get(record, "cron"),
+		}
+		if v := get(record, "enable"); v != "" {
+			enable, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid enable %q", len(rows)+2, v)
+			}
+			row.Enable = &enable
+		}
+		if v := get(record, "handlers"); v != "" {
+			row.Handlers = strings.Split(v, "|")
+		}
+		rows = append(rows, row)
+	}
+	return checkRows(rows)
+}
+
+// ParseImportText 每行一个链接，忽略空行和 # 开头的注释
+func ParseImportText(r io.Reader) ([]LiveConfImportRow, error) {
+	var rows []LiveConfImportRow
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rows = append(rows, LiveConfImportRow{URL: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return checkRows(rows)
+}
+
+func checkRows(rows []LiveConfImportRow) ([]LiveConfImportRow, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("no rows to import")
+	}
+	if len(rows) > MaxImportRows {
+		return nil, ErrTooManyRows
+	}
+	return rows, nil
+}
@@ -0,0 +1,11 @@
+package validate
+
+type ChatFlagListRequest struct {
+	RoomDisplayId string `form:"room_display_id" binding:"omitempty"`
+	Reason        string `form:"reason" binding:"omitempty,oneof=rate duplicate flood join_chat account"`
+	MinScore      int    `form:"min_score" binding:"omitempty,min=0,max=100"`
+	Begin         int64  `form:"begin" binding:"omitempty,min=1"` // 最近一次标记时间
+	End           int64  `form:"end" binding:"omitempty,min=1"`
+	Page          int    `form:"page" binding:"required,min=1"`
+	PageSize      int    `form:"page_size" binding:"required,min=1,max=500"`
+}
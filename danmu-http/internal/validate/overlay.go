@@ -0,0 +1,36 @@
+package validate
+
+type OverlayAddRequest struct {
+	Name          string `json:"name" binding:"omitempty,max=100"`
+	RoomDisplayId string `json:"room_display_id" binding:"required"`
+	Theme         string `json:"theme" binding:"omitempty,oneof=default light transparent"`
+	CustomCSS     string `json:"custom_css" binding:"omitempty,max=20000"`
+	MinDiamond    int64  `json:"min_diamond" binding:"omitempty,min=0"`           // 连击合计低于该钻石数的礼物不提醒
+	AlertDuration int    `json:"alert_duration" binding:"omitempty,min=1,max=60"` // 秒，为 0 时使用默认值
+	AlertQueue    int    `json:"alert_queue" binding:"omitempty,min=1,max=200"`
+	ChatLimit     int    `json:"chat_limit" binding:"omitempty,min=1,max=100"`
+	ShowMember    bool   `json:"show_member" binding:"omitempty"`
+	TopCount      int    `json:"top_count" binding:"omitempty,min=1,max=50"`
+	GoalTitle     string `json:"goal_title" binding:"omitempty,max=100"`
+	GoalType      string `json:"goal_type" binding:"omitempty,oneof=diamond gift"`
+	GoalGiftID    int64  `json:"goal_gift_id" binding:"required_if=GoalType gift"`
+	GoalTarget    int64  `json:"goal_target" binding:"required_with=GoalType,min=0"`
+}
+
+type OverlayUpdateRequest struct {
+	ID            int64  `json:"id" binding:"required"`
+	Name          string `json:"name" binding:"omitempty,max=100"`
+	RoomDisplayId string `json:"room_display_id" binding:"required"`
+	Theme         string `json:"theme" binding:"omitempty,oneof=default light transparent"`
+	CustomCSS     string `json:"custom_css" binding:"omitempty,max=20000"`
+	MinDiamond    int64  `json:"min_diamond" binding:"omitempty,min=0"`
+	AlertDuration int    `json:"alert_duration" binding:"omitempty,min=1,max=60"`
+	AlertQueue    int    `json:"alert_queue" binding:"omitempty,min=1,max=200"`
+	ChatLimit     int    `json:"chat_limit" binding:"omitempty,min=1,max=100"`
+	ShowMember    bool   `json:"show_member" binding:"omitempty"`
+	TopCount      int    `json:"top_count" binding:"omitempty,min=1,max=50"`
+	GoalTitle     string `json:"goal_title" binding:"omitempty,max=100"`
+	GoalType      string `json:"goal_type" binding:"omitempty,oneof=diamond gift"`
+	GoalGiftID    int64  `json:"goal_gift_id" binding:"required_if=GoalType gift"`
+	GoalTarget    int64  `json:"goal_target" binding:"required_with=GoalType,min=0"`
+}
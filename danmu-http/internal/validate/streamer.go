@@ -0,0 +1,19 @@
+package validate
+
+type StreamerSearchRequest struct {
+	Page     int    `form:"page" binding:"required,min=1"`
+	PageSize int    `form:"page_size" binding:"required,min=1,max=500"`
+	Keyword  string `form:"keyword" binding:"omitempty"`
+}
+
+type StreamerSessionRequest struct {
+	Page     int `form:"page" binding:"required,min=1"`
+	PageSize int `form:"page_size" binding:"required,min=1,max=500"`
+}
+
+type RoomSnapshotQuery struct {
+	Begin    int64 `form:"begin" binding:"omitempty,min=1"`
+	End      int64 `form:"end" binding:"omitempty,min=1"`
+	Page     int   `form:"page" binding:"required,min=1"`
+	PageSize int   `form:"page_size" binding:"required,min=1,max=500"`
+}
@@ -10,3 +10,8 @@ type UserSearchRequest struct {
 	PageSize int    `form:"page_size" binding:"required,min=1,max=500"`
 	Keyword  string `form:"keyword" binding:"omitempty"`
 }
+
+type UserProfileRequest struct {
+	Days int `form:"days" binding:"omitempty,min=1,max=365"` // 活动时间线的天数，默认 30
+	Top  int `form:"top" binding:"omitempty,min=1,max=100"`  // 礼物排行数量，默认 10
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	namespace = "danmu"
+	subsystem = "http"
+)
+
+var (
+	// RequestsTotal HTTP 请求数，route 使用 gin 注册的路由模板，避免路径参数导致 label 爆炸
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	// RequestDuration HTTP 请求耗时
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// GRPCClientHandled 调用 danmu-core 的 gRPC 请求数
+	GRPCClientHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "grpc_client_handled_total",
+		Help:      "Total number of gRPC calls to danmu-core by method and status code.",
+	}, []string{"grpc_method", "grpc_code"})
+
+	// GRPCClientDuration 调用 danmu-core 的 gRPC 耗时
+	GRPCClientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "grpc_client_handling_seconds",
+		Help:      "Latency of gRPC calls to danmu-core by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"grpc_method"})
+
+	// OverlayConnections 当前连接的叠加层页面数
+	OverlayConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "overlay_connections",
+		Help:      "Number of connected overlay pages by kind.",
+	}, []string{"kind"})
+)
+
+// UnaryClientInterceptor 统计 gRPC 客户端调用次数、状态码和耗时
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		GRPCClientDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		GRPCClientHandled.WithLabelValues(method, status.Code(err).String()).Inc()
+		return err
+	}
+}
+
+// StreamClientInterceptor 统计 gRPC 流式调用的建立次数和状态码，耗时为建立流的时间
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		GRPCClientDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		GRPCClientHandled.WithLabelValues(method, status.Code(err).String()).Inc()
+		return stream, err
+	}
+}
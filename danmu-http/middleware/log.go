@@ -3,6 +3,7 @@ package middleware
 import (
 	"danmu-http/internal/model"
 	"danmu-http/logger"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +22,12 @@ func Logger() gin.HandlerFunc {
 		// 执行时间
 		latency := end.Sub(start)
 
+		// 叠加层的 token 可以直接访问页面，不写入日志
+		path := c.Request.URL.Path
+		if token := c.Param("token"); token != "" {
+			path = strings.Replace(path, token, "***", 1)
+		}
+
 		// 获取认证信息（如果存在）
 		var email string
 		if auth, exists := c.Get("auth"); exists && auth != nil {
@@ -32,7 +39,7 @@ func Logger() gin.HandlerFunc {
 			Int("status", c.Writer.Status()).
 			Str("client_ip", c.ClientIP()).
 			Str("method", c.Request.Method).
-			Str("path", c.Request.URL.Path).
+			Str("path", path).
 			Dur("latency", latency).
 			Str("user_agent", c.Request.UserAgent()).
 			Str("auth_email", email).
@@ -45,7 +52,7 @@ func Logger() gin.HandlerFunc {
 				Int("status", c.Writer.Status()).
 				Str("client_ip", c.ClientIP()).
 				Str("method", c.Request.Method).
-				Str("path", c.Request.URL.Path).
+				Str("path", path).
 				Dur("latency", latency).
 				Str("user_agent", c.Request.UserAgent()).
 				Str("user_email", email).
@@ -4,8 +4,13 @@ import (
 	"danmu-http/internal/handler"
 	"danmu-http/internal/service"
 	"danmu-http/middleware"
+	"danmu-http/setting"
+	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 var (
@@ -14,6 +19,14 @@ var (
 	giftMessageHandler   *handler.GiftMessageHandler
 	commonMessageHandler *handler.CommonMessageHandler
 	userHandler          *handler.UserHandler
+	streamerHandler      *handler.StreamerHandler
+	roomHandler          *handler.RoomHandler
+	archiveHandler       *handler.ArchiveHandler
+	overlayHandler       *handler.OverlayHandler
+	moderationHandler    *handler.ModerationHandler
+	deadLetterHandler    *handler.DeadLetterHandler
+	proxyHandler         *handler.ProxyHandler
+	coverageHoleHandler  *handler.CoverageHoleHandler
 )
 
 func Init() {
@@ -22,15 +35,33 @@ func Init() {
 	giftMessageHandler = handler.NewGiftMessageHandler(service.NewGiftMessageService())
 	commonMessageHandler = handler.NewCommonMessageHandler(service.NewCommonMessageService())
 	userHandler = handler.NewUserHandler(service.NewUserService())
+	streamerHandler = handler.NewStreamerHandler(service.NewStreamerService())
+	roomHandler = handler.NewRoomHandler(service.NewRoomService())
+	archiveHandler = handler.NewArchiveHandler(service.NewArchiveService())
+	overlayHandler = handler.NewOverlayHandler(service.NewOverlayService())
+	moderationHandler = handler.NewModerationHandler(service.NewModerationService())
+	deadLetterHandler = handler.NewDeadLetterHandler(service.NewDeadLetterService())
+	proxyHandler = handler.NewProxyHandler(service.NewProxyService())
+	coverageHoleHandler = handler.NewCoverageHoleHandler(service.NewCoverageHoleService())
 
 }
 
 func SetupRouter() *gin.Engine {
 	r := gin.New()
+	// 叠加层的地址中包含 token，且 WebSocket 连接时间很长，不记录 trace
+	r.Use(otelgin.Middleware(setting.TracingSetting.ServiceName, otelgin.WithFilter(func(req *http.Request) bool {
+		return !strings.HasPrefix(req.URL.Path, "/overlay/")
+	})))
 	r.Use(middleware.Logger())
+	r.Use(middleware.Metrics())
 	r.Use(gin.Recovery())
 	r.Use(middleware.Cors())
 
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// OBS 浏览器源使用的叠加层页面，通过 token 访问，不需要登录
+	r.GET("/overlay/:token/:kind", overlayHandler.Page)
+
 	api := r.Group("/api")
 	{
 		// 不需要认证的路由
@@ -69,12 +100,18 @@ func SetupRouter() *gin.Engine {
 				adminLiveConf.Use(middleware.AdminRequired())
 				{
 					adminLiveConf.POST("", liveConfHandler.Create)
+					adminLiveConf.POST("/import", liveConfHandler.Import)
 					adminLiveConf.PUT("", liveConfHandler.Update)
 					adminLiveConf.DELETE("/:id", liveConfHandler.Delete)
+					adminLiveConf.POST("/:id/start", liveConfHandler.Start)
+					adminLiveConf.POST("/:id/stop", liveConfHandler.Stop)
+					adminLiveConf.POST("/:id/restart", liveConfHandler.Restart)
 				}
 
 				// 所有认证用户
+				liveConf.GET("/tasks", liveConfHandler.ListTasks)
 				liveConf.GET("/:id", liveConfHandler.Get)
+				liveConf.GET("/:id/task", liveConfHandler.GetTask)
 				liveConf.GET("", liveConfHandler.List)
 			}
 
@@ -86,17 +123,114 @@ func SetupRouter() *gin.Engine {
 				giftMessage.POST("", giftMessageHandler.ListGiftMessagePageWithCondition)
 			}
 
+			// Streamer 相关路由
+			streamer := authenticated.Group("/streamer")
+			{
+				streamer.GET("", streamerHandler.List)
+				streamer.GET("/:id", streamerHandler.Get)
+				streamer.GET("/:id/sessions", streamerHandler.ListSessions)
+				streamer.GET("/:id/titles", streamerHandler.ListTitles)
+			}
+
+			// Room 相关路由
+			room := authenticated.Group("/room")
+			{
+				room.GET("/:room_display_id", roomHandler.Latest)
+				room.GET("/:room_display_id/snapshots", roomHandler.ListSnapshots)
+				room.GET("/:room_display_id/titles", roomHandler.ListTitles)
+			}
+
 			// CommonMessage 相关路由
 			commonMessage := authenticated.Group("/common-message")
 			{
 				commonMessage.POST("", commonMessageHandler.ListPageableWithCondition)
 			}
 
+			// Archive 相关路由，查询已从数据库删除的过期消息
+			archive := authenticated.Group("/archive")
+			{
+				archive.GET("", archiveHandler.List)
+				archive.POST("/common-message", archiveHandler.ListCommonMessages)
+				archive.POST("/gift-message", archiveHandler.ListGiftMessages)
+			}
+
+			// Retention 相关路由
+			retention := authenticated.Group("/retention")
+			{
+				// 管理员权限
+				adminRetention := retention.Group("")
+				adminRetention.Use(middleware.AdminRequired())
+				{
+					adminRetention.PUT("", archiveHandler.SetRetention)
+					adminRetention.DELETE("/:room_display_id", archiveHandler.DeleteRetention)
+				}
+
+				// 所有认证用户
+				retention.GET("", archiveHandler.ListRetention)
+			}
+
+			// Overlay 相关路由，返回的 token 可以直接访问叠加层，只有管理员可以查看
+			overlay := authenticated.Group("/overlay")
+			overlay.Use(middleware.AdminRequired())
+			{
+				overlay.GET("", overlayHandler.List)
+				overlay.GET("/:id", overlayHandler.Get)
+				overlay.POST("", overlayHandler.Create)
+				overlay.PUT("", overlayHandler.Update)
+				overlay.DELETE("/:id", overlayHandler.Delete)
+				overlay.POST("/:id/rotate-token", overlayHandler.RotateToken)
+				overlay.POST("/:id/reset", overlayHandler.Reset)
+			}
+
+			// Moderation 相关路由，danmu-core 检测到的刷屏和疑似机器人用户
+			moderation := authenticated.Group("/moderation")
+			{
+				// 管理员权限
+				adminModeration := moderation.Group("")
+				adminModeration.Use(middleware.AdminRequired())
+				{
+					adminModeration.DELETE("/flags/:room_display_id/:user_id", moderationHandler.DeleteFlag)
+				}
+
+				// 所有认证用户
+				moderation.GET("/flags", moderationHandler.ListFlags)
+				moderation.GET("/flags/:room_display_id/:user_id", moderationHandler.GetFlag)
+			}
+
+			// DeadLetter 相关路由，danmu-core 中 handler 重试耗尽的原始消息，只有管理员可以查看和处理
+			deadLetter := authenticated.Group("/dead-letter")
+			deadLetter.Use(middleware.AdminRequired())
+			{
+				deadLetter.GET("", deadLetterHandler.List)
+				deadLetter.GET("/:id", deadLetterHandler.Get)
+				deadLetter.POST("/replay", deadLetterHandler.Replay)
+				deadLetter.DELETE("/:id", deadLetterHandler.Delete)
+				deadLetter.DELETE("", deadLetterHandler.Purge)
+			}
+
+			// CoverageHole 相关路由，danmu-core 重连后未能补发消息的时间范围
+			coverageHole := authenticated.Group("/coverage-hole")
+			{
+				coverageHole.GET("", coverageHoleHandler.List)
+			}
+
+			// Proxy 相关路由，danmu-core 访问抖音使用的代理池，只有管理员可以查看和修改
+			proxy := authenticated.Group("/proxy")
+			proxy.Use(middleware.AdminRequired())
+			{
+				proxy.GET("", proxyHandler.List)
+				proxy.POST("", proxyHandler.Add)
+				proxy.DELETE("", proxyHandler.Remove)
+				proxy.POST("/rotate", proxyHandler.Rotate)
+			}
+
 			// User 相关路由
 			user := authenticated.Group("/user")
 			{
 				user.GET("", userHandler.ListAllUsers)
 				user.GET("/search", userHandler.SearchUser)
+				user.GET("/:user_id", userHandler.GetUser)
+				user.GET("/:user_id/profile", userHandler.GetProfile)
 			}
 		}
 	}
@@ -2,14 +2,15 @@ package rpc
 
 import (
 	"danmu-http/logger"
+	"danmu-http/metrics"
 	api "danmu-http/rpc/proto"
 	"danmu-http/setting"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 )
 
@@ -40,15 +41,22 @@ func connect() error {
 	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
 	defer cancel()
 
-	var err error
-	conn, err = grpc.DialContext(ctx, setting.RPCSetting.LiveServiceAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	opts, err := securityOptions()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to load rpc credentials")
+		return err
+	}
+	opts = append(opts,
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
 			Time:                keepaliveTime,
 			Timeout:             keepaliveTimeout,
 			PermitWithoutStream: true,
 		}),
+		grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(metrics.StreamClientInterceptor()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	)
+	conn, err = grpc.DialContext(ctx, setting.RPCSetting.LiveServiceAddr, opts...)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to connect to gRPC server")
 		return err
@@ -99,7 +107,7 @@ func Close() error {
 	return nil
 }
 
-// WithTimeout 使用超时上下文包装 RPC 调用
-func WithTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), timeout)
+// WithTimeout 使用超时上下文包装 RPC 调用，保留 parent 中的 trace 信息
+func WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, timeout)
 }
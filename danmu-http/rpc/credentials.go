@@ -0,0 +1,84 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"danmu-http/logger"
+	"danmu-http/setting"
+	"fmt"
+	"os"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// tokenCredentials 每次调用时在 metadata 中附带 authorization token
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{
+		"authorization": "Bearer " + t.token,
+	}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// securityOptions 根据配置返回 TLS 和 token 相关的 DialOption
+func securityOptions() ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+	if setting.RPCSetting.TLSEnable {
+		creds, err := loadClientCredentials()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	if setting.RPCSetting.AuthToken != "" {
+		if !setting.RPCSetting.TLSEnable {
+			logger.Warn().Msg("rpc auth token is sent without TLS")
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{
+			token:  setting.RPCSetting.AuthToken,
+			secure: setting.RPCSetting.TLSEnable,
+		}))
+	}
+	return opts, nil
+}
+
+func loadClientCredentials() (credentials.TransportCredentials, error) {
+	tlsConfig := &tls.Config{
+		ServerName: setting.RPCSetting.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if setting.RPCSetting.CACert != "" {
+		pem, err := os.ReadFile(setting.RPCSetting.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("read ca cert error: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("parse ca cert error: %s", setting.RPCSetting.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if setting.RPCSetting.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(setting.RPCSetting.ClientCert, setting.RPCSetting.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client cert error: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
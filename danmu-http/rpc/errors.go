@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"danmu-http/internal/app"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// danmu-core ErrorInfo.reason 对应的 app code
+var reasonCodes = map[string]int{
+	"TASK_NOT_FOUND":       app.ErrTaskNotFound,
+	"TASK_EXISTS":          app.ErrTaskExists,
+	"TASK_DISABLED":        app.ErrTaskDisabled,
+	"UNSUPPORTED_PLATFORM": app.ErrTaskInvalid,
+	"INVALID_CRON":         app.ErrTaskInvalid,
+	"UNKNOWN_HANDLER":      app.ErrTaskInvalid,
+	"INVALID_ARGUMENT":     app.ErrTaskInvalid,
+	"PLATFORM_INIT_FAILED": app.ErrCoreUnavailable,
+	"PROXY_DISABLED":       app.ErrProxyDisabled,
+	"PROXY_INVALID":        app.ErrProxyInvalid,
+	"PROXY_EXISTS":         app.ErrProxyExists,
+	"PROXY_NOT_FOUND":      app.ErrProxyNotFound,
+}
+
+// Reason 返回 danmu-core 错误 ErrorInfo 中的 reason，没有时返回空字符串
+func Reason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+// IsTaskNotFound danmu-core 中没有该配置的任务，例如 core 重启后还未从数据库加载或创建时 rpc 失败
+func IsTaskNotFound(err error) bool {
+	return Reason(err) == "TASK_NOT_FOUND"
+}
+
+// ErrorCode 将 danmu-core 返回的 gRPC 错误转换为 http 状态码和 app code
+// 非 gRPC 错误返回 500 / app.ERROR
+func ErrorCode(err error) (int, int) {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.OK {
+		return http.StatusInternalServerError, app.ERROR
+	}
+
+	appCode := app.ERROR
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			if code, ok := reasonCodes[info.GetReason()]; ok {
+				appCode = code
+			}
+			break
+		}
+	}
+
+	switch st.Code() {
+	case codes.NotFound:
+		if appCode == app.ERROR {
+			appCode = app.ErrTaskNotFound
+		}
+		return http.StatusNotFound, appCode
+	case codes.AlreadyExists:
+		if appCode == app.ERROR {
+			appCode = app.ErrTaskExists
+		}
+		return http.StatusConflict, appCode
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		if appCode == app.ERROR {
+			appCode = app.ErrTaskInvalid
+		}
+		return http.StatusBadRequest, appCode
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return http.StatusServiceUnavailable, app.ErrCoreUnavailable
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return http.StatusBadGateway, app.ErrCoreUnavailable
+	default:
+		return http.StatusInternalServerError, appCode
+	}
+}
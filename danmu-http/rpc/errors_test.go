@@ -0,0 +1,36 @@
+package rpc
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func coreError(t *testing.T, code codes.Code, reason string) error {
+	t.Helper()
+	st, err := status.New(code, reason).WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: "danmu-core"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.Err()
+}
+
+func TestIsTaskNotFound(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{coreError(t, codes.NotFound, "TASK_NOT_FOUND"), true},
+		{coreError(t, codes.NotFound, "PROXY_NOT_FOUND"), false},
+		{status.Error(codes.NotFound, "no details"), false},
+		{errors.New("plain error"), false},
+		{nil, false},
+	} {
+		if got := IsTaskNotFound(tc.err); got != tc.want {
+			t.Errorf("IsTaskNotFound(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -19,23 +20,68 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	LiveService_AddTask_FullMethodName    = "/live.LiveService/AddTask"
-	LiveService_DeleteTask_FullMethodName = "/live.LiveService/DeleteTask"
-	LiveService_UpdateTask_FullMethodName = "/live.LiveService/UpdateTask"
+	LiveService_AddTask_FullMethodName           = "/live.LiveService/AddTask"
+	LiveService_DeleteTask_FullMethodName        = "/live.LiveService/DeleteTask"
+	LiveService_UpdateTask_FullMethodName        = "/live.LiveService/UpdateTask"
+	LiveService_GetTask_FullMethodName           = "/live.LiveService/GetTask"
+	LiveService_ListTasks_FullMethodName         = "/live.LiveService/ListTasks"
+	LiveService_StartTask_FullMethodName         = "/live.LiveService/StartTask"
+	LiveService_StopTask_FullMethodName          = "/live.LiveService/StopTask"
+	LiveService_RestartTask_FullMethodName       = "/live.LiveService/RestartTask"
+	LiveService_SubscribeEvents_FullMethodName   = "/live.LiveService/SubscribeEvents"
+	LiveService_ReplayDeadLetters_FullMethodName = "/live.LiveService/ReplayDeadLetters"
+	LiveService_ListProxies_FullMethodName       = "/live.LiveService/ListProxies"
+	LiveService_AddProxy_FullMethodName          = "/live.LiveService/AddProxy"
+	LiveService_RemoveProxy_FullMethodName       = "/live.LiveService/RemoveProxy"
+	LiveService_RotateProxy_FullMethodName       = "/live.LiveService/RotateProxy"
 )
 
 // LiveServiceClient is the client API for LiveService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 //
-// LiveService 定义抖音直播管理服务
+// # LiveService 定义抖音直播管理服务
+//
+// 错误通过 gRPC status 返回:
+//
+//	NOT_FOUND        任务不存在
+//	ALREADY_EXISTS   任务已存在
+//	INVALID_ARGUMENT 配置不合法 (不支持的平台、cron 表达式、handler 名称、代理地址等)
+//	FAILED_PRECONDITION 任务未启用，或未启用 [proxy] 时调用代理相关接口
+//	UNAVAILABLE      平台初始化失败，可稍后重试
+//
+// status details 中携带 google.rpc.ErrorInfo，reason 为 TASK_NOT_FOUND 等枚举值
 type LiveServiceClient interface {
 	// AddTask 添加直播任务
-	AddTask(ctx context.Context, in *LiveConf, opts ...grpc.CallOption) (*Response, error)
+	AddTask(ctx context.Context, in *AddTaskRequest, opts ...grpc.CallOption) (*Task, error)
 	// DeleteTask 删除直播任务
-	DeleteTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Response, error)
-	// UpdateTask 更新直播任务
-	UpdateTask(ctx context.Context, in *LiveConf, opts ...grpc.CallOption) (*Response, error)
+	DeleteTask(ctx context.Context, in *DeleteTaskRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// UpdateTask 更新直播任务，update_mask 为空时整体替换
+	UpdateTask(ctx context.Context, in *UpdateTaskRequest, opts ...grpc.CallOption) (*Task, error)
+	// GetTask 查询单个任务及其运行状态
+	GetTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Task, error)
+	// ListTasks 查询所有任务及其运行状态
+	ListTasks(ctx context.Context, in *ListTasksRequest, opts ...grpc.CallOption) (*ListTasksResponse, error)
+	// StartTask 启动任务，不会修改 live_confs，由调用方负责持久化 enable
+	StartTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Task, error)
+	// StopTask 停止任务，不会修改 live_confs，由调用方负责持久化 enable
+	StopTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Task, error)
+	// RestartTask 重启任务，重新建立连接
+	RestartTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Task, error)
+	// SubscribeEvents 订阅直播间的实时事件 (弹幕、礼物、进场、点赞)，直到调用方取消
+	// 只推送订阅之后收到的事件，礼物的 combo_count 为连击累计值，订阅方消费过慢时丢弃事件
+	SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	// ReplayDeadLetters 将死信交给原任务中同名的 handler 重新处理，不重试，成功的死信被删除
+	// 每条死信单独返回结果，任务或 handler 已不存在时该条失败
+	ReplayDeadLetters(ctx context.Context, in *ReplayDeadLettersRequest, opts ...grpc.CallOption) (*ReplayDeadLettersResponse, error)
+	// ListProxies 查询代理池中所有代理的状态
+	ListProxies(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListProxiesResponse, error)
+	// AddProxy 添加代理并立即检查一次，只保存在内存中，重启后以 [proxy] Proxies 为准
+	AddProxy(ctx context.Context, in *ProxyRequest, opts ...grpc.CallOption) (*ProxyStatus, error)
+	// RemoveProxy 删除代理，使用它的直播间在下次请求时切换到其他代理
+	RemoveProxy(ctx context.Context, in *ProxyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// RotateProxy 将任务当前的代理放入冷却并切换到其他代理，运行中的任务立即重新连接
+	RotateProxy(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*RotateProxyResponse, error)
 }
 
 type liveServiceClient struct {
@@ -46,9 +92,9 @@ func NewLiveServiceClient(cc grpc.ClientConnInterface) LiveServiceClient {
 	return &liveServiceClient{cc}
 }
 
-func (c *liveServiceClient) AddTask(ctx context.Context, in *LiveConf, opts ...grpc.CallOption) (*Response, error) {
+func (c *liveServiceClient) AddTask(ctx context.Context, in *AddTaskRequest, opts ...grpc.CallOption) (*Task, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Response)
+	out := new(Task)
 	err := c.cc.Invoke(ctx, LiveService_AddTask_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
@@ -56,9 +102,9 @@ func (c *liveServiceClient) AddTask(ctx context.Context, in *LiveConf, opts ...g
 	return out, nil
 }
 
-func (c *liveServiceClient) DeleteTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Response, error) {
+func (c *liveServiceClient) DeleteTask(ctx context.Context, in *DeleteTaskRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Response)
+	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, LiveService_DeleteTask_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
@@ -66,9 +112,9 @@ func (c *liveServiceClient) DeleteTask(ctx context.Context, in *TaskID, opts ...
 	return out, nil
 }
 
-func (c *liveServiceClient) UpdateTask(ctx context.Context, in *LiveConf, opts ...grpc.CallOption) (*Response, error) {
+func (c *liveServiceClient) UpdateTask(ctx context.Context, in *UpdateTaskRequest, opts ...grpc.CallOption) (*Task, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Response)
+	out := new(Task)
 	err := c.cc.Invoke(ctx, LiveService_UpdateTask_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
@@ -76,18 +122,171 @@ func (c *liveServiceClient) UpdateTask(ctx context.Context, in *LiveConf, opts .
 	return out, nil
 }
 
+func (c *liveServiceClient) GetTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Task, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Task)
+	err := c.cc.Invoke(ctx, LiveService_GetTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *liveServiceClient) ListTasks(ctx context.Context, in *ListTasksRequest, opts ...grpc.CallOption) (*ListTasksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTasksResponse)
+	err := c.cc.Invoke(ctx, LiveService_ListTasks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *liveServiceClient) StartTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Task, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Task)
+	err := c.cc.Invoke(ctx, LiveService_StartTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *liveServiceClient) StopTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Task, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Task)
+	err := c.cc.Invoke(ctx, LiveService_StopTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *liveServiceClient) RestartTask(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*Task, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Task)
+	err := c.cc.Invoke(ctx, LiveService_RestartTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *liveServiceClient) SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LiveService_ServiceDesc.Streams[0], LiveService_SubscribeEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LiveService_SubscribeEventsClient = grpc.ServerStreamingClient[Event]
+
+func (c *liveServiceClient) ReplayDeadLetters(ctx context.Context, in *ReplayDeadLettersRequest, opts ...grpc.CallOption) (*ReplayDeadLettersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReplayDeadLettersResponse)
+	err := c.cc.Invoke(ctx, LiveService_ReplayDeadLetters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *liveServiceClient) ListProxies(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListProxiesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListProxiesResponse)
+	err := c.cc.Invoke(ctx, LiveService_ListProxies_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *liveServiceClient) AddProxy(ctx context.Context, in *ProxyRequest, opts ...grpc.CallOption) (*ProxyStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProxyStatus)
+	err := c.cc.Invoke(ctx, LiveService_AddProxy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *liveServiceClient) RemoveProxy(ctx context.Context, in *ProxyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, LiveService_RemoveProxy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *liveServiceClient) RotateProxy(ctx context.Context, in *TaskID, opts ...grpc.CallOption) (*RotateProxyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RotateProxyResponse)
+	err := c.cc.Invoke(ctx, LiveService_RotateProxy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LiveServiceServer is the server API for LiveService service.
 // All implementations must embed UnimplementedLiveServiceServer
 // for forward compatibility.
 //
-// LiveService 定义抖音直播管理服务
+// # LiveService 定义抖音直播管理服务
+//
+// 错误通过 gRPC status 返回:
+//
+//	NOT_FOUND        任务不存在
+//	ALREADY_EXISTS   任务已存在
+//	INVALID_ARGUMENT 配置不合法 (不支持的平台、cron 表达式、handler 名称、代理地址等)
+//	FAILED_PRECONDITION 任务未启用，或未启用 [proxy] 时调用代理相关接口
+//	UNAVAILABLE      平台初始化失败，可稍后重试
+//
+// status details 中携带 google.rpc.ErrorInfo，reason 为 TASK_NOT_FOUND 等枚举值
 type LiveServiceServer interface {
 	// AddTask 添加直播任务
-	AddTask(context.Context, *LiveConf) (*Response, error)
+	AddTask(context.Context, *AddTaskRequest) (*Task, error)
 	// DeleteTask 删除直播任务
-	DeleteTask(context.Context, *TaskID) (*Response, error)
-	// UpdateTask 更新直播任务
-	UpdateTask(context.Context, *LiveConf) (*Response, error)
+	DeleteTask(context.Context, *DeleteTaskRequest) (*emptypb.Empty, error)
+	// UpdateTask 更新直播任务，update_mask 为空时整体替换
+	UpdateTask(context.Context, *UpdateTaskRequest) (*Task, error)
+	// GetTask 查询单个任务及其运行状态
+	GetTask(context.Context, *TaskID) (*Task, error)
+	// ListTasks 查询所有任务及其运行状态
+	ListTasks(context.Context, *ListTasksRequest) (*ListTasksResponse, error)
+	// StartTask 启动任务，不会修改 live_confs，由调用方负责持久化 enable
+	StartTask(context.Context, *TaskID) (*Task, error)
+	// StopTask 停止任务，不会修改 live_confs，由调用方负责持久化 enable
+	StopTask(context.Context, *TaskID) (*Task, error)
+	// RestartTask 重启任务，重新建立连接
+	RestartTask(context.Context, *TaskID) (*Task, error)
+	// SubscribeEvents 订阅直播间的实时事件 (弹幕、礼物、进场、点赞)，直到调用方取消
+	// 只推送订阅之后收到的事件，礼物的 combo_count 为连击累计值，订阅方消费过慢时丢弃事件
+	SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error
+	// ReplayDeadLetters 将死信交给原任务中同名的 handler 重新处理，不重试，成功的死信被删除
+	// 每条死信单独返回结果，任务或 handler 已不存在时该条失败
+	ReplayDeadLetters(context.Context, *ReplayDeadLettersRequest) (*ReplayDeadLettersResponse, error)
+	// ListProxies 查询代理池中所有代理的状态
+	ListProxies(context.Context, *emptypb.Empty) (*ListProxiesResponse, error)
+	// AddProxy 添加代理并立即检查一次，只保存在内存中，重启后以 [proxy] Proxies 为准
+	AddProxy(context.Context, *ProxyRequest) (*ProxyStatus, error)
+	// RemoveProxy 删除代理，使用它的直播间在下次请求时切换到其他代理
+	RemoveProxy(context.Context, *ProxyRequest) (*emptypb.Empty, error)
+	// RotateProxy 将任务当前的代理放入冷却并切换到其他代理，运行中的任务立即重新连接
+	RotateProxy(context.Context, *TaskID) (*RotateProxyResponse, error)
 	mustEmbedUnimplementedLiveServiceServer()
 }
 
@@ -98,15 +297,48 @@ type LiveServiceServer interface {
 // pointer dereference when methods are called.
 type UnimplementedLiveServiceServer struct{}
 
-func (UnimplementedLiveServiceServer) AddTask(context.Context, *LiveConf) (*Response, error) {
+func (UnimplementedLiveServiceServer) AddTask(context.Context, *AddTaskRequest) (*Task, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AddTask not implemented")
 }
-func (UnimplementedLiveServiceServer) DeleteTask(context.Context, *TaskID) (*Response, error) {
+func (UnimplementedLiveServiceServer) DeleteTask(context.Context, *DeleteTaskRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteTask not implemented")
 }
-func (UnimplementedLiveServiceServer) UpdateTask(context.Context, *LiveConf) (*Response, error) {
+func (UnimplementedLiveServiceServer) UpdateTask(context.Context, *UpdateTaskRequest) (*Task, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateTask not implemented")
 }
+func (UnimplementedLiveServiceServer) GetTask(context.Context, *TaskID) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTask not implemented")
+}
+func (UnimplementedLiveServiceServer) ListTasks(context.Context, *ListTasksRequest) (*ListTasksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTasks not implemented")
+}
+func (UnimplementedLiveServiceServer) StartTask(context.Context, *TaskID) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartTask not implemented")
+}
+func (UnimplementedLiveServiceServer) StopTask(context.Context, *TaskID) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopTask not implemented")
+}
+func (UnimplementedLiveServiceServer) RestartTask(context.Context, *TaskID) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestartTask not implemented")
+}
+func (UnimplementedLiveServiceServer) SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeEvents not implemented")
+}
+func (UnimplementedLiveServiceServer) ReplayDeadLetters(context.Context, *ReplayDeadLettersRequest) (*ReplayDeadLettersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplayDeadLetters not implemented")
+}
+func (UnimplementedLiveServiceServer) ListProxies(context.Context, *emptypb.Empty) (*ListProxiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProxies not implemented")
+}
+func (UnimplementedLiveServiceServer) AddProxy(context.Context, *ProxyRequest) (*ProxyStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddProxy not implemented")
+}
+func (UnimplementedLiveServiceServer) RemoveProxy(context.Context, *ProxyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveProxy not implemented")
+}
+func (UnimplementedLiveServiceServer) RotateProxy(context.Context, *TaskID) (*RotateProxyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateProxy not implemented")
+}
 func (UnimplementedLiveServiceServer) mustEmbedUnimplementedLiveServiceServer() {}
 func (UnimplementedLiveServiceServer) testEmbeddedByValue()                     {}
 
@@ -129,7 +361,7 @@ func RegisterLiveServiceServer(s grpc.ServiceRegistrar, srv LiveServiceServer) {
 }
 
 func _LiveService_AddTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(LiveConf)
+	in := new(AddTaskRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -141,13 +373,13 @@ func _LiveService_AddTask_Handler(srv interface{}, ctx context.Context, dec func
 		FullMethod: LiveService_AddTask_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(LiveServiceServer).AddTask(ctx, req.(*LiveConf))
+		return srv.(LiveServiceServer).AddTask(ctx, req.(*AddTaskRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
 func _LiveService_DeleteTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(TaskID)
+	in := new(DeleteTaskRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -159,13 +391,13 @@ func _LiveService_DeleteTask_Handler(srv interface{}, ctx context.Context, dec f
 		FullMethod: LiveService_DeleteTask_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(LiveServiceServer).DeleteTask(ctx, req.(*TaskID))
+		return srv.(LiveServiceServer).DeleteTask(ctx, req.(*DeleteTaskRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
 func _LiveService_UpdateTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(LiveConf)
+	in := new(UpdateTaskRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -177,7 +409,198 @@ func _LiveService_UpdateTask_Handler(srv interface{}, ctx context.Context, dec f
 		FullMethod: LiveService_UpdateTask_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(LiveServiceServer).UpdateTask(ctx, req.(*LiveConf))
+		return srv.(LiveServiceServer).UpdateTask(ctx, req.(*UpdateTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_GetTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).GetTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_GetTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).GetTask(ctx, req.(*TaskID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_ListTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTasksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).ListTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_ListTasks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).ListTasks(ctx, req.(*ListTasksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_StartTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).StartTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_StartTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).StartTask(ctx, req.(*TaskID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_StopTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).StopTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_StopTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).StopTask(ctx, req.(*TaskID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_RestartTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).RestartTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_RestartTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).RestartTask(ctx, req.(*TaskID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_SubscribeEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LiveServiceServer).SubscribeEvents(m, &grpc.GenericServerStream[SubscribeEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LiveService_SubscribeEventsServer = grpc.ServerStreamingServer[Event]
+
+func _LiveService_ReplayDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplayDeadLettersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).ReplayDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_ReplayDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).ReplayDeadLetters(ctx, req.(*ReplayDeadLettersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_ListProxies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).ListProxies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_ListProxies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).ListProxies(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_AddProxy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProxyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).AddProxy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_AddProxy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).AddProxy(ctx, req.(*ProxyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_RemoveProxy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProxyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).RemoveProxy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_RemoveProxy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).RemoveProxy(ctx, req.(*ProxyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LiveService_RotateProxy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LiveServiceServer).RotateProxy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LiveService_RotateProxy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LiveServiceServer).RotateProxy(ctx, req.(*TaskID))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -201,7 +624,53 @@ var LiveService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateTask",
 			Handler:    _LiveService_UpdateTask_Handler,
 		},
+		{
+			MethodName: "GetTask",
+			Handler:    _LiveService_GetTask_Handler,
+		},
+		{
+			MethodName: "ListTasks",
+			Handler:    _LiveService_ListTasks_Handler,
+		},
+		{
+			MethodName: "StartTask",
+			Handler:    _LiveService_StartTask_Handler,
+		},
+		{
+			MethodName: "StopTask",
+			Handler:    _LiveService_StopTask_Handler,
+		},
+		{
+			MethodName: "RestartTask",
+			Handler:    _LiveService_RestartTask_Handler,
+		},
+		{
+			MethodName: "ReplayDeadLetters",
+			Handler:    _LiveService_ReplayDeadLetters_Handler,
+		},
+		{
+			MethodName: "ListProxies",
+			Handler:    _LiveService_ListProxies_Handler,
+		},
+		{
+			MethodName: "AddProxy",
+			Handler:    _LiveService_AddProxy_Handler,
+		},
+		{
+			MethodName: "RemoveProxy",
+			Handler:    _LiveService_RemoveProxy_Handler,
+		},
+		{
+			MethodName: "RotateProxy",
+			Handler:    _LiveService_RotateProxy_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeEvents",
+			Handler:       _LiveService_SubscribeEvents_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/live_rpc.proto",
 }
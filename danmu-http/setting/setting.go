@@ -18,7 +18,8 @@ type App struct {
 var AppSetting = &App{}
 
 type Database struct {
-	Type         string
+	Type         string // postgres 或 sqlite
+	Path         string // sqlite 数据库文件，danmu-core 和 danmu-http 需指向同一个文件
 	User         string
 	Password     string
 	Host         string
@@ -61,10 +62,44 @@ var JWTSetting = &JWT{}
 
 type RPC struct {
 	LiveServiceAddr string `ini:"live_service_addr"`
+	TLSEnable       bool   `ini:"tls_enable"`
+	CACert          string `ini:"ca_cert"`     // 校验 danmu-core 服务端证书的 CA
+	ClientCert      string `ini:"client_cert"` // 配置后向 danmu-core 提供客户端证书 (mTLS)
+	ClientKey       string `ini:"client_key"`
+	ServerName      string `ini:"server_name"` // 覆盖证书校验时使用的主机名
+	AuthToken       string `ini:"auth_token"`  // 与 danmu-core 的 AuthToken 一致
 }
 
 var RPCSetting = &RPC{}
 
+type Tracing struct {
+	Enable      bool
+	ServiceName string
+	Exporter    string // otlp-grpc, otlp-http, stdout
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+var TracingSetting = &Tracing{}
+
+type Archive struct {
+	Dir         string // danmu-core 的 retention.ArchiveDir，需能在本机访问
+	MaxScanRows int    // 单次查询归档最多返回的匹配消息数量
+}
+
+var ArchiveSetting = &Archive{}
+
+type Analytics struct {
+	Enable   bool   // 礼物排行和消息查询改为读取 danmu-core 写入的 ClickHouse 分析库
+	Endpoint string // HTTP 接口地址，如 http://localhost:8123
+	Database string
+	User     string
+	Password string
+}
+
+var AnalyticsSetting = &Analytics{}
+
 var (
 	cfg        *ini.File
 	configPath string
@@ -92,6 +127,9 @@ func Init() {
 	mapTo("rpc", RpcSetting)
 	mapTo("jwt", JWTSetting)
 	mapTo("rpc", RPCSetting)
+	mapTo("tracing", TracingSetting)
+	mapTo("archive", ArchiveSetting)
+	mapTo("analytics", AnalyticsSetting)
 }
 
 func mapTo(section string, v interface{}) {
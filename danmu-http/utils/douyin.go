@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const douyinLiveHost = "live.douyin.com"
+
+var (
+	ErrUnsupportedURL = errors.New("unsupported douyin url")
+	ErrWebRidNotFound = errors.New("web_rid not found")
+
+	webRidPathRg = regexp.MustCompile(`^/(\d+)/?$`)
+	// 主页、分享页 html 中用户自己直播间的 "web_rid":"<id>" 字段，兼容转义 (\"web_rid\") 和 url 编码 (%22web_rid%22) 的写法。
+	// 页面中还有推荐的其他直播间链接，不匹配 live.douyin.com/<id>
+	webRidPageRg = regexp.MustCompile(`(?:"|\\"|%22)web_rid(?:"|\\"|%22)\s*(?::|%3A)\s*(?:"|\\"|%22)(\d+)(?:"|\\"|%22)`)
+
+	douyinHTTPClient = &http.Client{Timeout: 5 * time.Second}
+)
+
+const douyinUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+
+// NormalizeDouyinURL 将直播间链接、v.douyin.com 短链接和用户主页链接解析为 https://live.douyin.com/<web_rid>
+func NormalizeDouyinURL(ctx context.Context, raw string) (canonical string, webRid string, err error) {
+	u, err := parseLooseURL(raw)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch host := strings.ToLower(u.Hostname()); {
+	case host == douyinLiveHost:
+		webRid, err = webRidFromPath(u.Path)
+	case host == "v.douyin.com":
+		// 短链接只跟随一次重定向，避免解析结果又是短链接时无限循环
+		var final *url.URL
+		final, err = resolveRedirect(ctx, u.String())
+		if err == nil {
+			webRid, err = webRidFromResolved(ctx, final)
+		}
+	case host == "www.douyin.com" || host == "douyin.com" || strings.HasSuffix(host, ".iesdouyin.com") || host == "iesdouyin.com":
+		webRid, err = webRidFromResolved(ctx, u)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedURL, raw)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return "https://" + douyinLiveHost + "/" + webRid, webRid, nil
+}
+
+// parseLooseURL 兼容省略 scheme 以及粘贴时带上的分享文案
+func parseLooseURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if i := strings.Index(raw, "http"); i > 0 {
+		raw = raw[i:]
+	}
+	if fields := strings.Fields(raw); len(fields) > 0 {
+		raw = fields[0]
+	}
+	if raw == "" {
+		return nil, fmt.Errorf("%w: empty url", ErrUnsupportedURL)
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedURL, raw)
+	}
+	return u, nil
+}
+
+func webRidFromPath(path string) (string, error) {
+	m := webRidPathRg.FindStringSubmatch(path)
+	if len(m) <= 1 {
+		return "", fmt.Errorf("%w: %s", ErrWebRidNotFound, path)
+	}
+	return m[1], nil
+}
+
+// webRidFromResolved 处理 douyin.com 下的各类页面
+//
+//	www.douyin.com/follow/live/<web_rid>、www.douyin.com/root/live/<web_rid>  直接取路径
+//	www.douyin.com/user/<sec_uid>、iesdouyin.com/share/user/...                 请求页面查找直播间地址
+func webRidFromResolved(ctx context.Context, u *url.URL) (string, error) {
+	host := strings.ToLower(u.Hostname())
+	if host == douyinLiveHost {
+		return webRidFromPath(u.Path)
+	}
+	for _, prefix := range []string{"/follow/live", "/root/live", "/live"} {
+		if strings.HasPrefix(u.Path, prefix+"/") {
+			return webRidFromPath(strings.TrimPrefix(u.Path, prefix))
+		}
+	}
+	if strings.Contains(u.Path, "/user/") {
+		return webRidFromPage(ctx, u.String())
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedURL, u.String())
+}
+
+func resolveRedirect(ctx context.Context, rawURL string) (*url.URL, error) {
+	client := *douyinHTTPClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := doDouyinRequest(ctx, &client, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return nil, fmt.Errorf("%w: %s does not redirect", ErrUnsupportedURL, rawURL)
+	}
+	return resp.Request.URL.Parse(location)
+}
+
+func webRidFromPage(ctx context.Context, rawURL string) (string, error) {
+	resp, err := doDouyinRequest(ctx, douyinHTTPClient, rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 2<<20))
+	if err != nil {
+		return "", fmt.Errorf("read %s error: %w", rawURL, err)
+	}
+	m := webRidPageRg.FindSubmatch(body)
+	if len(m) <= 1 {
+		return "", fmt.Errorf("%w: %s (用户可能未开通直播)", ErrWebRidNotFound, rawURL)
+	}
+	return string(m[1]), nil
+}
+
+func doDouyinRequest(ctx context.Context, client *http.Client, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", douyinUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request %s error: %w", rawURL, err)
+	}
+	return resp, nil
+}
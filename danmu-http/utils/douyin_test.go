@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebRidFromPage(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{"json", `{"user":{"nickname":"a","web_rid":"123456"}}`, "123456"},
+		{"escaped", `self.__pace_f.push([1,"{\"roomInfo\":{\"web_rid\":\"223344\"}}"])`, "223344"},
+		{"url encoded", `<script id="RENDER_DATA">%7B%22web_rid%22%3A%22334455%22%7D</script>`, "334455"},
+		{"recommended room first", `<a href="https://live.douyin.com/999999">推荐</a>{"web_rid":"445566"}`, "445566"},
+		{"recommended room only", `<a href="https://live.douyin.com/999999">推荐</a>`, ""},
+		{"empty web_rid", `{"web_rid":""}`, ""},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(tc.body))
+		}))
+		got, err := webRidFromPage(context.Background(), srv.URL)
+		srv.Close()
+		if tc.want == "" {
+			if !errors.Is(err, ErrWebRidNotFound) {
+				t.Errorf("%s: got %q, %v, want ErrWebRidNotFound", tc.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+}